@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+
+	"goback/config"
+	"goback/history"
+	"goback/utils"
+)
+
+// checkSizeAnomaly сравнивает размер нового архива с медианой последних успешных
+// архивов бэкапа из истории запусков. Отклонение за порог size_anomaly выводится
+// предупреждением, а при action: fail возвращается как ErrVerificationFailed
+func (e *Executor) checkSizeAnomaly(backupConfig *config.BackupConfig, size int64) error {
+	anomaly := backupConfig.SizeAnomaly
+	if anomaly == nil {
+		return nil
+	}
+
+	records, err := history.Load(e.globalConfig.HistoryPath())
+	if err != nil {
+		fmt.Printf("Warning: size anomaly check skipped: %v\n", err)
+		return nil
+	}
+	sizes := history.RecentSizes(records, backupConfig.Name, anomaly.HistoryWindow())
+	if len(sizes) == 0 {
+		return nil
+	}
+
+	baseline := medianSize(sizes)
+	change := float64(size-baseline) / float64(baseline) * 100
+	var message string
+	switch {
+	case anomaly.MaxDecreasePercent > 0 && -change >= anomaly.MaxDecreasePercent:
+		message = fmt.Sprintf("archive size %s is %.0f%% smaller than the median %s of the last %d archive(s) (max_decrease_percent: %g)",
+			utils.FormatSize(size), -change, utils.FormatSize(baseline), len(sizes), anomaly.MaxDecreasePercent)
+	case anomaly.MaxIncreasePercent > 0 && change >= anomaly.MaxIncreasePercent:
+		message = fmt.Sprintf("archive size %s is %.0f%% larger than the median %s of the last %d archive(s) (max_increase_percent: %g)",
+			utils.FormatSize(size), change, utils.FormatSize(baseline), len(sizes), anomaly.MaxIncreasePercent)
+	default:
+		return nil
+	}
+
+	if anomaly.Action == "fail" {
+		return fmt.Errorf("%w: %s", ErrVerificationFailed, message)
+	}
+	fmt.Printf("Warning: %s\n", message)
+	return nil
+}
+
+// medianSize возвращает медиану размеров: один неудачный или нетипичный бэкап
+// не сдвигает базу сравнения
+func medianSize(sizes []int64) int64 {
+	sorted := append([]int64(nil), sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,164 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/catalog"
+	"goback/checksum"
+	"goback/config"
+	"goback/storage"
+	"goback/utils"
+)
+
+// catalogExportDir - директория в корне destination, куда выгружается копия каталога
+const catalogExportDir = ".goback"
+
+// recordArchive добавляет архив в каталог; ошибки каталога не должны ронять бэкап
+func (e *Executor) recordArchive(backupName, destination, key string, size int64, sum string, t time.Time) {
+	// Время берем из имени файла, как и при сканировании в rebuild-index,
+	// чтобы записи из обоих источников совпадали
+	if parsed, err := e.globalConfig.FileNaming().ParseDate(filepath.Base(key), backupName); err == nil {
+		t = parsed
+	}
+
+	err := catalog.Update(e.globalConfig.CatalogPath(), func(c *catalog.Catalog) {
+		c.Add(catalog.Entry{
+			Backup:      backupName,
+			Destination: destination,
+			Key:         key,
+			Size:        size,
+			Time:        t,
+			Checksum:    sum,
+		})
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to update catalog: %v\n", err)
+	}
+}
+
+// fileChecksum считает контрольную сумму архива настроенным алгоритмом;
+// при ошибке возвращает пустую строку, чтобы не ронять бэкап
+func (e *Executor) fileChecksum(path string) string {
+	sum, err := checksum.File(path, e.globalConfig.ChecksumAlgorithm)
+	if err != nil {
+		fmt.Printf("Warning: failed to compute checksum of %s: %v\n", filepath.Base(path), err)
+		return ""
+	}
+	return checksum.Format(e.globalConfig.ChecksumAlgorithm, sum)
+}
+
+// writeChecksumManifest сохраняет SHA-256 архива рядом с ним (sidecar или MANIFEST),
+// чтобы goback verify мог обнаружить порчу архивов на диске бэкапов
+func (e *Executor) writeChecksumManifest(archivePath, archiveChecksum string) {
+	mode := e.globalConfig.ChecksumManifest
+	if mode == "none" {
+		return
+	}
+
+	// Сумму, уже посчитанную для каталога, используем повторно
+	algorithm, sum := checksum.Parse(archiveChecksum)
+	if archiveChecksum == "" || algorithm != "sha256" {
+		var err error
+		if sum, err = checksum.File(archivePath, "sha256"); err != nil {
+			fmt.Printf("Warning: failed to compute SHA-256 of %s: %v\n", filepath.Base(archivePath), err)
+			return
+		}
+	}
+
+	var err error
+	if mode == "manifest" {
+		err = checksum.AddToManifest(archivePath, sum)
+	} else {
+		err = checksum.WriteSidecar(archivePath, sum)
+	}
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// forgetArchives удаляет из каталога архивы, удаленные retention
+func (e *Executor) forgetArchives(destination string, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	err := catalog.Update(e.globalConfig.CatalogPath(), func(c *catalog.Catalog) {
+		for _, key := range keys {
+			c.Remove(destination, key)
+		}
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to update catalog: %v\n", err)
+	}
+}
+
+// ExportCatalog выгружает текущий каталог в корень каждого destination,
+// чтобы знание о существующих архивах пережило потерю хоста. offline пропускает
+// сетевые destinations
+func ExportCatalog(cfg *config.Config, offline bool) error {
+	catalogPath := cfg.Global.CatalogPath()
+	if _, err := os.Stat(catalogPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	key := filepath.Join(catalogExportDir, filepath.Base(catalogPath))
+	var failed []string
+
+	for _, dest := range allDestinations(cfg) {
+		if offline && isNetworkDestination(&dest) {
+			continue
+		}
+
+		target, err := newStorage(&dest, cfg.Global.ThrottleSchedule())
+		if err == nil {
+			err = target.Upload(catalogPath, key, nil)
+		}
+		if err != nil {
+			utils.PrintError("Failed to export catalog to %s: %v", dest.Name, err)
+			failed = append(failed, dest.Name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to export catalog to: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// RebuildCatalog сканирует backup_dir и все destinations и строит каталог заново
+func RebuildCatalog(cfg *config.Config) (*catalog.Catalog, error) {
+	result := &catalog.Catalog{}
+
+	for _, backupCfg := range cfg.Backups {
+		entries, err := catalog.Scan(storage.NewLocalStorage(cfg.Global.BackupDir), catalog.LocalDestination, backupCfg.Subdirectory, backupCfg.Name, cfg.Global.FileNaming())
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			result.Add(entry)
+		}
+
+		for i := range backupCfg.Destinations {
+			dest := &backupCfg.Destinations[i]
+			target, err := newStorage(dest, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			entries, err := catalog.Scan(target, dest.Name, backupCfg.Subdirectory, backupCfg.Name, cfg.Global.FileNaming())
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				result.Add(entry)
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -1,33 +1,112 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+
+	"goback/compression"
+	"goback/config"
 )
 
-// ExecuteCommand выполняет команду и проверяет наличие output_file
-func ExecuteCommand(command string, outputFile string) error {
-	command = strings.TrimSpace(command)
-	if command == "" {
-		return fmt.Errorf("empty command")
+// ExecuteCommand выполняет команду бэкапа и проверяет наличие output_file
+func ExecuteCommand(backupConfig *config.BackupConfig) error {
+	cmd, err := commandFor(backupConfig)
+	if err != nil {
+		return err
 	}
-
-	// Выполняем команду через shell для поддержки многострочных команд и пайпов
-	cmd := exec.Command("sh", "-c", command)
 	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("command failed: %w", err)
+	if err := runCommand(backupConfig, cmd); err != nil {
+		return err
 	}
 
 	// Проверяем, что output_file существует
-	if _, err := os.Stat(outputFile); os.IsNotExist(err) {
-		return fmt.Errorf("output file does not exist after command execution: %s", outputFile)
+	if _, err := os.Stat(backupConfig.OutputFile); os.IsNotExist(err) {
+		return fmt.Errorf("output file does not exist after command execution: %s", backupConfig.OutputFile)
 	}
 
 	return nil
 }
 
+// StreamCommand выполняет команду бэкапа и пишет ее stdout в w; stderr
+// команды выводится как обычно
+func StreamCommand(backupConfig *config.BackupConfig, w io.Writer) error {
+	cmd, err := commandFor(backupConfig)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = w
+
+	return runCommand(backupConfig, cmd)
+}
+
+// commandFor строит команду бэкапа: строка выполняется через shell (по умолчанию
+// sh) для поддержки многострочных команд и пайпов, список аргументов - напрямую
+func commandFor(backupConfig *config.BackupConfig) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if command := backupConfig.Command; command.IsArgv() {
+		if len(command.Args) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		cmd = exec.Command(command.Args[0], command.Args[1:]...)
+	} else {
+		line := strings.TrimSpace(command.Line)
+		if line == "" {
+			return nil, fmt.Errorf("empty command")
+		}
+		shell := backupConfig.Shell
+		if shell == "" {
+			shell = "sh"
+		}
+		cmd = exec.Command(shell, "-c", line)
+	}
+
+	cmd.Dir = backupConfig.WorkingDir
+	cmd.Stderr = os.Stderr
+	if len(backupConfig.Env) > 0 {
+		names := make([]string, 0, len(backupConfig.Env))
+		for name := range backupConfig.Env {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		cmd.Env = os.Environ()
+		for _, name := range names {
+			cmd.Env = append(cmd.Env, name+"="+backupConfig.Env[name])
+		}
+	}
+	return cmd, nil
+}
+
+// runCommand выполняет команду; с fail_on_nonzero_exit: false ненулевой код
+// выхода выводится предупреждением
+func runCommand(backupConfig *config.BackupConfig, cmd *exec.Cmd) error {
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && backupConfig.FailOnNonzeroExit != nil && !*backupConfig.FailOnNonzeroExit {
+		fmt.Printf("Warning: command exited with status %d (fail_on_nonzero_exit: false)\n", exitErr.ExitCode())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
+
+// compressCommand пишет stdout команды бэкапа с stream: true прямо в архив
+// destination, без output_file на диске
+func (e *Executor) compressCommand(compressor compression.Compressor, compressionType string, backupConfig *config.BackupConfig, destination string, stats *archiveStats) error {
+	streamer, ok := compressor.(compression.StreamCompressor)
+	if !ok {
+		return fmt.Errorf("compression %s cannot stream command output, use gzip, zstd, xz or none", compressionType)
+	}
+
+	fmt.Printf("Streaming command output: %s\n", backupConfig.Command)
+	return compressProduced(streamer, destination, stats, func(w io.Writer) error {
+		return StreamCommand(backupConfig, w)
+	})
+}
@@ -0,0 +1,449 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/checksum"
+	"goback/config"
+	"goback/encryption"
+	"goback/notify"
+	"goback/spool"
+	"goback/storage"
+	"goback/utils"
+)
+
+// Stage - шаг конвейера, который преобразует архив перед отправкой в конкретный destination
+type Stage interface {
+	Name() string
+	// OutputName возвращает имя результата шага для файла name
+	OutputName(name string) string
+	// Process обрабатывает файл source и возвращает путь к результату внутри workDir
+	Process(source, workDir string) (string, error)
+}
+
+type encryptStage struct {
+	encryptor encryption.Encryptor
+}
+
+func (s *encryptStage) Name() string {
+	return "encrypt"
+}
+
+func (s *encryptStage) OutputName(name string) string {
+	return name + s.encryptor.Extension()
+}
+
+func (s *encryptStage) Process(source, workDir string) (string, error) {
+	destination := filepath.Join(workDir, s.OutputName(filepath.Base(source)))
+	if err := s.encryptor.Encrypt(source, destination); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// buildStages собирает конвейер для destination в зависимости от его настроек
+func buildStages(dest *config.DestinationConfig) ([]Stage, error) {
+	var stages []Stage
+
+	if dest.Encryption != nil {
+		encryptor, err := encryption.NewEncryptor(dest.Encryption.Type, dest.Encryption.Options())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create encryptor: %w", err)
+		}
+		stages = append(stages, &encryptStage{encryptor: encryptor})
+	}
+
+	return stages, nil
+}
+
+// destinationKey возвращает ключ объекта архива filename в destination: имя после
+// всех шагов конвейера (например, с расширением шифрования destination). По нему
+// загружается архив и его же показывает dry run
+func destinationKey(backupConfig *config.BackupConfig, stages []Stage, filename string) string {
+	for _, stage := range stages {
+		filename = stage.OutputName(filename)
+	}
+	return filepath.Join(backupConfig.Subdirectory, filename)
+}
+
+// newStorage создает хранилище destination; throttle - расписание лимитов скорости
+// загрузки (global.throttle), которое действует вместе с rate_limit
+func newStorage(dest *config.DestinationConfig, throttle *utils.Throttle) (storage.Storage, error) {
+	var rateLimit int64
+	if dest.RateLimit != "" {
+		limit, err := utils.ParseSize(dest.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate_limit: %w", err)
+		}
+		rateLimit = limit
+	}
+
+	switch dest.Type {
+	case "local", "":
+		local := storage.NewLocalStorage(dest.Path)
+		local.SetRateLimit(rateLimit)
+		local.SetThrottle(throttle)
+		return local, nil
+	case "s3":
+		// part_size уже проверен при загрузке конфигурации
+		var partSize int64
+		if dest.PartSize != "" {
+			partSize, _ = utils.ParseSize(dest.PartSize)
+		}
+		s3, err := storage.NewS3Storage(storage.S3Options{
+			Endpoint:    dest.Endpoint,
+			Region:      dest.Region,
+			Bucket:      dest.Bucket,
+			Prefix:      dest.Prefix,
+			AccessKey:   dest.AccessKey,
+			SecretKey:   dest.SecretKey,
+			PathStyle:   dest.PathStyle,
+			PartSize:    partSize,
+			Concurrency: dest.UploadConcurrency,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s3.SetRateLimit(rateLimit)
+		s3.SetThrottle(throttle)
+		s3.SetAttemptTimeout(dest.AttemptTimeout)
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unsupported destination type: %s", dest.Type)
+	}
+}
+
+// isNetworkDestination сообщает, что доставка в destination идет по сети
+func isNetworkDestination(dest *config.DestinationConfig) bool {
+	return dest.Type == "s3"
+}
+
+// uploadAllowed проверяет, открыто ли сейчас окно загрузки destination
+func uploadAllowed(dest *config.DestinationConfig, now time.Time) bool {
+	if dest.UploadWindow == "" {
+		return true
+	}
+
+	window, err := utils.ParseTimeWindow(dest.UploadWindow)
+	if err != nil {
+		// Конфигурация уже проверена при загрузке
+		return true
+	}
+
+	return window.Contains(now)
+}
+
+// deliverToDestinations прогоняет архив через конвейер каждого destination и загружает
+// результат; итог доставки в каждый destination добавляется в result
+func (e *Executor) deliverToDestinations(backupConfig *config.BackupConfig, archivePath string, createdAt time.Time, result *notify.Result) error {
+	failed := &deliveryError{}
+
+	for i := range backupConfig.Destinations {
+		dest := &backupConfig.Destinations[i]
+		uploaded, err := e.deliverToDestination(backupConfig, dest, archivePath, createdAt)
+		if err = classifyError(err); err != nil {
+			utils.PrintError("Destination %s failed: %v", dest.Name, err)
+			failed.names = append(failed.names, dest.Name)
+			failed.errs = append(failed.errs, err)
+			result.Deliveries = append(result.Deliveries, notify.Delivery{Destination: dest.Name, Status: "failed", Error: err.Error()})
+			continue
+		}
+		status := "uploaded"
+		if !uploaded {
+			status = "deferred"
+		}
+		result.Deliveries = append(result.Deliveries, notify.Delivery{Destination: dest.Name, Status: status})
+	}
+
+	if len(failed.errs) > 0 {
+		return failed
+	}
+
+	return nil
+}
+
+// deliveryError объединяет ошибки нескольких destinations и сохраняет их
+// для errors.Is (например, ErrDestinationFull)
+type deliveryError struct {
+	names []string
+	errs  []error
+}
+
+func (e *deliveryError) Error() string {
+	parts := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		parts[i] = fmt.Sprintf("%s (%v)", e.names[i], err)
+	}
+	return "failed to deliver to destination(s): " + strings.Join(parts, "; ")
+}
+
+func (e *deliveryError) Unwrap() []error {
+	return e.errs
+}
+
+// deliverToDestination прогоняет архив через шаги конвейера и загружает результат,
+// либо откладывает загрузку в spool, если окно загрузки закрыто (uploaded = false)
+func (e *Executor) deliverToDestination(backupConfig *config.BackupConfig, dest *config.DestinationConfig, archivePath string, createdAt time.Time) (uploaded bool, err error) {
+	stages, err := buildStages(dest)
+	if err != nil {
+		return false, err
+	}
+
+	target, err := newStorage(dest, e.globalConfig.ThrottleSchedule())
+	if err != nil {
+		return false, err
+	}
+
+	// Промежуточные результаты шагов складываем во временную директорию,
+	// чтобы локальный архив оставался нетронутым для остальных destination
+	workDir, err := os.MkdirTemp("", "backup-stage-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create stage directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	current := archivePath
+	// Шаги обрабатывают архив целиком: тома собираются перед ними и
+	// снова разбиваются после
+	splitSize := backupConfig.VolumeSize(e.globalConfig)
+	if len(stages) > 0 && len(utils.VolumeParts(archivePath)) > 0 {
+		current = filepath.Join(workDir, filepath.Base(archivePath))
+		if err := utils.JoinArchive(archivePath, current); err != nil {
+			return false, err
+		}
+	}
+	for _, stage := range stages {
+		current, err = stage.Process(current, workDir)
+		if err != nil {
+			return false, fmt.Errorf("stage %s failed: %w", stage.Name(), err)
+		}
+	}
+	if current != archivePath && splitSize > 0 {
+		if _, err := utils.SplitArchive(current, splitSize); err != nil {
+			return false, err
+		}
+	}
+
+	info, err := utils.StatArchive(current)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	key := destinationKey(backupConfig, stages, filepath.Base(archivePath))
+	// Сумма считается по файлу после всех шагов (например, по зашифрованному архиву)
+	sum := e.fileChecksum(current)
+
+	keys := archiveKeys(backupConfig, dest)
+
+	offline := e.offline && isNetworkDestination(dest)
+	if offline || !uploadAllowed(dest, time.Now()) {
+		job, err := spool.Open(e.globalConfig.SpoolDir()).Enqueue(current, spool.Job{
+			Backup:      backupConfig.Name,
+			Destination: dest.Name,
+			Key:         key,
+			Size:        info.Size(),
+			Checksum:    sum,
+			RunID:       e.runID,
+			JobID:       e.jobID(backupConfig.Name),
+			Keys:        keys,
+		}, current != archivePath)
+		if err != nil {
+			return false, err
+		}
+		if offline {
+			e.mu.Lock()
+			e.deferred = append(e.deferred, backupConfig.Name+" -> "+dest.Name)
+			e.mu.Unlock()
+			fmt.Printf("Upload to %s deferred: offline mode (spool job %s)\n", dest.Name, job.ID)
+			return false, nil
+		}
+		fmt.Printf("Upload to %s deferred until window %s (spool job %s)\n", dest.Name, dest.UploadWindow, job.ID)
+		return false, nil
+	}
+
+	fmt.Printf("Uploading to destination %s: %s\n", dest.Name, key)
+	if err := e.upload(target, dest, current, key, objectMetadata(e.runID, e.jobID(backupConfig.Name), keys)); err != nil {
+		// Неудачную загрузку сохраняем в spool, чтобы повторить ее при следующих запусках
+		job, spoolErr := spool.Open(e.globalConfig.SpoolDir()).Enqueue(current, spool.Job{
+			Backup:      backupConfig.Name,
+			Destination: dest.Name,
+			Key:         key,
+			Size:        info.Size(),
+			Checksum:    sum,
+			Attempts:    1,
+			LastAttempt: time.Now(),
+			LastError:   err.Error(),
+			RunID:       e.runID,
+			JobID:       e.jobID(backupConfig.Name),
+			Keys:        keys,
+		}, current != archivePath)
+		if spoolErr != nil {
+			return false, fmt.Errorf("upload failed: %w (and could not be spooled: %v)", err, spoolErr)
+		}
+		return false, fmt.Errorf("upload failed, queued for retry as spool job %s: %w", job.ID, err)
+	}
+
+	e.recordArchive(backupConfig.Name, dest.Name, key, info.Size(), sum, createdAt)
+	return true, nil
+}
+
+// archiveKeys возвращает идентификаторы ключей, которыми зашифрован архив для
+// destination: ключи шага шифрования destination и ключи самого бэкапа
+func archiveKeys(backupConfig *config.BackupConfig, dest *config.DestinationConfig) []string {
+	var keys []string
+	if dest.Encryption != nil {
+		keys = append(keys, encryption.KeyIDs(dest.Encryption.Type, dest.Encryption.Options())...)
+	}
+	if backupConfig.Encryption != nil {
+		keys = append(keys, encryption.KeyIDs(backupConfig.Encryption.Type, backupConfig.Encryption.Options())...)
+	}
+	return keys
+}
+
+// upload загружает архив в destination; тома разбитого архива загружаются
+// отдельными объектами key.partNNN
+func (e *Executor) upload(target storage.Storage, dest *config.DestinationConfig, path, key string, metadata map[string]string) error {
+	parts := utils.VolumeParts(path)
+	if len(parts) == 0 {
+		return e.uploadFile(target, dest, path, key, metadata)
+	}
+
+	for i, part := range parts {
+		if err := e.uploadFile(target, dest, part, utils.VolumeName(key, i+1), metadata); err != nil {
+			return fmt.Errorf("volume %d of %d: %w", i+1, len(parts), err)
+		}
+	}
+	return nil
+}
+
+// uploadFile загружает файл в destination, повторяя попытку после временных ошибок
+// с экспоненциально растущей паузой. Итоговая ошибка оборачивает
+// ErrUploadRetryable или ErrUploadPermanent
+func (e *Executor) uploadFile(target storage.Storage, dest *config.DestinationConfig, path, key string, metadata map[string]string) error {
+	retries := dest.UploadRetries()
+	backoff := dest.UploadBackoff()
+
+	for attempt := 1; ; attempt++ {
+		err := target.Upload(path, key, metadata)
+		if err == nil {
+			return nil
+		}
+		if !storage.Retryable(err) {
+			return fmt.Errorf("%w: %w", ErrUploadPermanent, err)
+		}
+		// Повтор не начинаем, если он выйдет за окно бэкапа
+		if attempt > retries || (!e.deadline.IsZero() && time.Now().Add(backoff).After(e.deadline)) {
+			return fmt.Errorf("%w (gave up after %d attempt(s)): %w", ErrUploadRetryable, attempt, err)
+		}
+
+		fmt.Printf("Upload to %s failed (attempt %d of %d), retrying in %s: %v\n", dest.Name, attempt, retries+1, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// ProcessSpool загружает отложенные архивы, для которых открылось окно загрузки.
+// force игнорирует окна. Возвращает число загруженных и оставшихся в очереди задач
+func (e *Executor) ProcessSpool(backups []config.BackupConfig, force bool) (int, int, error) {
+	queue := spool.Open(e.globalConfig.SpoolDir())
+
+	jobs, err := queue.Jobs()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	uploaded, pending := 0, 0
+	for _, job := range jobs {
+		if job.Expired(e.globalConfig.SpoolMaxAge, time.Now()) {
+			utils.PrintError("Spool job %s expired after %d failed attempt(s), dropping %s (last error: %s)", job.ID, job.Attempts, job.Key, job.LastError)
+			if err := queue.Remove(job); err != nil {
+				fmt.Printf("Warning: failed to remove spool job %s: %v\n", job.ID, err)
+			}
+			continue
+		}
+
+		dest := findDestination(backups, job.Backup, job.Destination)
+		if dest == nil {
+			fmt.Printf("Warning: spool job %s refers to unknown destination %s of %s, keeping it\n", job.ID, job.Destination, job.Backup)
+			pending++
+			continue
+		}
+
+		// В режиме offline сетевые destinations недоступны даже с force
+		if e.offline && isNetworkDestination(dest) {
+			pending++
+			continue
+		}
+
+		// Загрузки, упавшие в этом же запуске, повторяем только в следующих
+		if !force && (!uploadAllowed(dest, time.Now()) || job.LastAttempt.After(e.startedAt)) {
+			pending++
+			continue
+		}
+
+		// Архив мог пролежать в очереди долго - проверяем, что он не поврежден
+		if job.Checksum != "" {
+			if err := checksum.Verify(queue.DataPath(job), job.Checksum); err != nil {
+				utils.PrintError("Spool job %s is corrupted, dropping it: %v", job.ID, err)
+				if err := queue.Remove(job); err != nil {
+					fmt.Printf("Warning: failed to remove spool job %s: %v\n", job.ID, err)
+				}
+				continue
+			}
+		}
+
+		target, err := newStorage(dest, e.globalConfig.ThrottleSchedule())
+		if err != nil {
+			return uploaded, pending, err
+		}
+
+		fmt.Printf("Uploading spooled archive to %s: %s\n", dest.Name, job.Key)
+		if err := e.upload(target, dest, queue.DataPath(job), job.Key, objectMetadata(job.RunID, job.JobID, job.Keys)); err != nil {
+			utils.PrintError("Spooled upload to %s failed: %v", dest.Name, err)
+			job.Attempts++
+			job.LastAttempt = time.Now()
+			job.LastError = err.Error()
+			if err := queue.Save(job); err != nil {
+				fmt.Printf("Warning: failed to update spool job %s: %v\n", job.ID, err)
+			}
+			pending++
+			continue
+		}
+
+		e.recordArchive(job.Backup, dest.Name, job.Key, job.Size, job.Checksum, job.CreatedAt)
+
+		if err := queue.Remove(job); err != nil {
+			fmt.Printf("Warning: failed to remove spool job %s: %v\n", job.ID, err)
+		}
+		uploaded++
+	}
+
+	return uploaded, pending, nil
+}
+
+// findDestination ищет destination бэкапа; для неявных бэкапов (self backup)
+// подходит destination с тем же именем из любого бэкапа
+func findDestination(backups []config.BackupConfig, backupName, destName string) *config.DestinationConfig {
+	var fallback *config.DestinationConfig
+
+	for i := range backups {
+		for j := range backups[i].Destinations {
+			dest := &backups[i].Destinations[j]
+			if dest.Name != destName {
+				continue
+			}
+			if backups[i].Name == backupName {
+				return dest
+			}
+			if fallback == nil {
+				fallback = dest
+			}
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"goback/config"
+	"goback/history"
+	"goback/hold"
+	"goback/notify"
+	"goback/retention"
+
+	"github.com/robfig/cron/v3"
+)
+
+// BuildDigest собирает сводку за period до now по истории запусков: успешность,
+// объем и рост архивов каждого бэкапа, а также архивы, которые retention удалит
+// за следующий такой же период при запусках по schedule (без него - ежедневно)
+func BuildDigest(cfg *config.Config, now time.Time, period time.Duration) (notify.Digest, error) {
+	digest := notify.Digest{Since: now.Add(-period), Until: now}
+
+	records, err := history.Load(cfg.Global.HistoryPath())
+	if err != nil {
+		return digest, err
+	}
+
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		entry := notify.DigestEntry{Backup: backupCfg.Name}
+		if paused, reason := backupCfg.Paused(now); paused {
+			entry.Paused = reason
+		}
+
+		var firstSize int64
+		for _, record := range records {
+			if record.Kind != history.KindBackup || record.Backup != backupCfg.Name || record.Time.Before(digest.Since) || record.Time.After(now) {
+				continue
+			}
+			entry.Runs++
+			if !record.Success {
+				entry.LastError = record.Error
+				continue
+			}
+			entry.Succeeded++
+			entry.Bytes += record.Size
+			if firstSize == 0 {
+				firstSize = record.Size
+			}
+			entry.LastSize = record.Size
+		}
+		if entry.Runs > 0 {
+			entry.SuccessRate = float64(entry.Succeeded) * 100 / float64(entry.Runs)
+		}
+		if firstSize > 0 {
+			entry.Growth = float64(entry.LastSize-firstSize) * 100 / float64(firstSize)
+		}
+
+		upcoming, err := upcomingDeletions(cfg, backupCfg, now, period)
+		if err != nil {
+			return digest, fmt.Errorf("failed to forecast retention of %s: %w", backupCfg.Name, err)
+		}
+		entry.Upcoming = upcoming
+
+		digest.Backups = append(digest.Backups, entry)
+	}
+
+	return digest, nil
+}
+
+// upcomingDeletions прогоняет retention на запусках за следующий period и
+// возвращает имена существующих архивов, которые будут удалены (кроме удержанных
+// до конца периода)
+func upcomingDeletions(cfg *config.Config, backupCfg *config.BackupConfig, now time.Time, period time.Duration) ([]string, error) {
+	files, err := retention.FindBackupFiles(cfg.Global.BackupDir, backupCfg.Subdirectory, backupCfg.Name, cfg.Global.FileNaming())
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+
+	var existing []time.Time
+	for _, file := range files {
+		existing = append(existing, file.Time)
+	}
+
+	sim := retention.Simulate(EffectiveRetention(&cfg.Global, backupCfg), existing, RunTimes(backupCfg.Schedule, 24*time.Hour, now, now.Add(period)))
+	kept := make(map[time.Time]bool, len(sim.Kept))
+	for _, file := range sim.Kept {
+		kept[file.Time] = true
+	}
+
+	holds, err := hold.Load(cfg.Global.HoldsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var upcoming []string
+	for _, file := range files {
+		if !kept[file.Time] && hold.Find(holds, backupCfg.Name, filepath.Base(file.Path), now.Add(period)) == nil {
+			upcoming = append(upcoming, filepath.Base(file.Path))
+		}
+	}
+	sort.Strings(upcoming)
+	return upcoming, nil
+}
+
+// RunTimes возвращает моменты запусков между from и until по cron-расписанию
+// или через каждые every, если расписания нет
+func RunTimes(schedule string, every time.Duration, from, until time.Time) []time.Time {
+	var times []time.Time
+	if parsed, err := cron.ParseStandard(schedule); err == nil && schedule != "" {
+		for t := parsed.Next(from); !t.IsZero() && !t.After(until); t = parsed.Next(t) {
+			times = append(times, t)
+		}
+		return times
+	}
+
+	for t := from.Add(every); !t.After(until); t = t.Add(every) {
+		times = append(times, t)
+	}
+	return times
+}
+
+// SendDigest отправляет сводку в глобальные notifications с events: [digest]
+func (e *Executor) SendDigest(digest notify.Digest) {
+	var notifications []config.NotificationConfig
+	for _, notification := range e.globalConfig.Notifications {
+		if notification.On("digest") {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	if len(notifications) == 0 {
+		fmt.Printf("No notifications with events: [digest]\n")
+		return
+	}
+
+	e.send(notifications, digest.Success(), func(notifier notify.Notifier) error {
+		return notifier.NotifyDigest(digest)
+	})
+}
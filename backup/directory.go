@@ -1,21 +1,42 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 )
 
+// CopyOptions управляет обходом исходной директории
+type CopyOptions struct {
+	ExcludePatterns []string
+	// Parallelism - сколько директорий читается одновременно (stat/readdir)
+	Parallelism int
+	// Gentle - щадящий режим для сетевых ФС: последовательный обход с паузами
+	Gentle bool
+	// GentleDelay - пауза после чтения каждой директории в щадящем режиме
+	GentleDelay time.Duration
+	// FollowSymlinks передает вместо симлинков их цели, обходя директории, на
+	// которые они указывают; битые симлинки передаются как есть
+	FollowSymlinks bool
+	// Deadline - момент, после которого копирование прерывается (окно бэкапа)
+	Deadline time.Time
+	// Skipped вызывается для каждого пропущенного пути с причиной (совпавший
+	// exclude-паттерн, тип файла или ошибка чтения)
+	Skipped func(relPath, reason string)
+}
+
 // CopyDirectory копирует директорию с поддержкой exclude_patterns
-func CopyDirectory(source, destination string, excludePatterns []string) error {
+func CopyDirectory(source, destination string, opts CopyOptions) error {
 	// Создаем целевую директорию
 	if err := os.MkdirAll(destination, 0755); err != nil {
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Нормализуем пути для корректной работы
 	absSource, err := filepath.Abs(source)
 	if err != nil {
 		return fmt.Errorf("failed to get absolute path for source: %w", err)
@@ -26,74 +47,260 @@ func CopyDirectory(source, destination string, excludePatterns []string) error {
 		return fmt.Errorf("failed to get absolute path for destination: %w", err)
 	}
 
-	return filepath.Walk(absSource, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			// Пропускаем файлы/директории, к которым нет доступа
-			return nil
+	// Права директорий выставляются после копирования содержимого:
+	// в директорию без права записи иначе нельзя было бы копировать
+	var dirs []string
+	var modes []os.FileMode
+
+	err = WalkDirectory(absSource, opts, func(relPath string, info os.FileInfo) error {
+		if info.IsDir() {
+			dirs = append(dirs, filepath.Join(absDestination, relPath))
+			modes = append(modes, info.Mode()&modeMask)
+		}
+		return copyEntry(absSource, absDestination, relPath, info)
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i]); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", dirs[i], err)
 		}
+	}
+
+	return nil
+}
+
+// WalkDirectory обходит source с учетом exclude_patterns, параллелизма, щадящего
+// режима и окна бэкапа и вызывает visit для каждой директории, файла и симлинка.
+// Вызовы visit сериализованы, поэтому он может писать в общий архив; директория
+// всегда передается раньше своего содержимого
+func WalkDirectory(source string, opts CopyOptions, visit func(relPath string, info os.FileInfo) error) error {
+	// Нормализуем пути для корректной работы
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for source: %w", err)
+	}
+
+	parallelism := opts.Parallelism
+	if parallelism < 1 || opts.Gentle {
+		parallelism = 1
+	}
+
+	w := &walker{
+		source: absSource,
+		opts:   opts,
+		visit:  visit,
+		slots:  make(chan struct{}, parallelism),
+	}
 
-		relPath, err := filepath.Rel(absSource, path)
+	w.wg.Add(1)
+	go w.walkDir(".")
+	w.wg.Wait()
+
+	return w.err
+}
+
+// walker обходит дерево, ограничивая число одновременных операций с ФС
+// количеством слотов, чтобы не перегружать сетевые файловые системы
+type walker struct {
+	source string
+	opts   CopyOptions
+	visit  func(relPath string, info os.FileInfo) error
+	slots  chan struct{}
+	wg     sync.WaitGroup
+
+	// visitMu сериализует вызовы visit
+	visitMu sync.Mutex
+
+	mu  sync.Mutex
+	err error
+}
+
+func (w *walker) fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *walker) failed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err != nil
+}
+
+func (w *walker) walkDir(relDir string) {
+	defer w.wg.Done()
+
+	w.slots <- struct{}{}
+	defer func() { <-w.slots }()
+
+	if w.failed() {
+		return
+	}
+
+	if !w.opts.Deadline.IsZero() && time.Now().After(w.opts.Deadline) {
+		w.fail(ErrWindowExceeded)
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Join(w.source, relDir))
+	if w.opts.Gentle && w.opts.GentleDelay > 0 {
+		time.Sleep(w.opts.GentleDelay)
+	}
+	if err != nil {
+		// Пропускаем директории, к которым нет доступа
+		w.skip(relDir, fmt.Sprintf("unreadable directory: %v", unwrapPathError(err)))
+		return
+	}
+
+	for _, entry := range entries {
+		relPath := filepath.Join(relDir, entry.Name())
+
+		info, err := entry.Info()
 		if err != nil {
-			return err
+			// Файл мог быть удален между ReadDir и Lstat
+			w.skip(relPath, "vanished during walk")
+			continue
 		}
 
-		// Пропускаем корневую директорию (relPath == ".")
-		if relPath == "." {
-			return nil
+		if w.opts.FollowSymlinks && info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(filepath.Join(w.source, relPath))
+			switch {
+			case err != nil:
+				// Битый симлинк сохраняется как симлинк
+			case target.IsDir() && w.symlinkLoop(relPath):
+				w.skip(relPath, "symlink loop")
+				continue
+			default:
+				info = target
+			}
 		}
 
 		// Пропускаем специальные файлы (socket, named pipe, device files)
-		mode := info.Mode()
-		if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
-			return nil
+		if kind := specialFileKind(info.Mode()); kind != "" {
+			w.skip(relPath, "special file ("+kind+")")
+			continue
 		}
 
 		// Проверяем exclude patterns
-		if shouldExclude(relPath, excludePatterns) {
-			if info.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
+		if pattern, excluded := matchExclude(relPath, w.opts.ExcludePatterns); excluded {
+			w.skip(relPath, fmt.Sprintf("excluded by pattern %q", pattern))
+			continue
 		}
 
-		destPath := filepath.Join(absDestination, relPath)
+		w.visitMu.Lock()
+		err = w.visit(relPath, info)
+		w.visitMu.Unlock()
+		if err != nil {
+			w.fail(err)
+			return
+		}
 
 		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
+			// Поддиректория обрабатывается, когда освободится слот
+			w.wg.Add(1)
+			go w.walkDir(relPath)
 		}
+	}
+}
 
-		// Проверяем, является ли это симлинком
-		// filepath.Walk использует os.Lstat, поэтому info содержит информацию о симлинке, а не о цели
-		if info.Mode()&os.ModeSymlink != 0 {
-			// Копируем симлинк как симлинк
-			target, err := os.Readlink(path)
-			if err != nil {
-				// Не удалось прочитать симлинк, пропускаем
-				return nil
-			}
-			// Проверяем, существует ли уже файл/симлинк по целевому пути
-			if _, err := os.Lstat(destPath); err == nil {
-				// Файл уже существует, удаляем его
-				if err := os.Remove(destPath); err != nil {
-					return fmt.Errorf("failed to remove existing file for symlink: %w", err)
-				}
-			}
-			// Создаем новый симлинк
-			return os.Symlink(target, destPath)
+// symlinkLoop сообщает, что симлинк relPath указывает на директорию, внутри
+// которой он находится: обход такой директории не закончился бы
+func (w *walker) symlinkLoop(relPath string) bool {
+	target, err := filepath.EvalSymlinks(filepath.Join(w.source, relPath))
+	if err != nil {
+		return true
+	}
+	for dir := filepath.Dir(relPath); ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(filepath.Join(w.source, dir)); err == nil && resolved == target {
+			return true
+		}
+		if dir == "." {
+			return false
 		}
+	}
+}
+
+// skip сообщает о пропущенном пути через CopyOptions.Skipped
+func (w *walker) skip(relPath, reason string) {
+	if w.opts.Skipped == nil {
+		return
+	}
+
+	w.visitMu.Lock()
+	defer w.visitMu.Unlock()
+	w.opts.Skipped(relPath, reason)
+}
+
+// specialFileKind возвращает тип специального файла, который не архивируется,
+// или пустую строку для обычных файлов, директорий и симлинков
+func specialFileKind(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	default:
+		return ""
+	}
+}
 
-		// Обычный файл - проверяем, что он все еще существует перед копированием
-		// (может быть удален между моментом обнаружения и копированием)
-		if _, err := os.Lstat(path); os.IsNotExist(err) {
-			// Файл не существует, пропускаем
+// unwrapPathError убирает из ошибки путь, который и так выводится рядом
+func unwrapPathError(err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// copyEntry копирует один элемент дерева из source в destination
+func copyEntry(source, destination, relPath string, info os.FileInfo) error {
+	path := filepath.Join(source, relPath)
+	destPath := filepath.Join(destination, relPath)
+
+	if info.IsDir() {
+		return os.MkdirAll(destPath, 0700)
+	}
+
+	// Проверяем, является ли это симлинком
+	// ReadDir использует lstat, поэтому info содержит информацию о симлинке, а не о цели
+	if info.Mode()&os.ModeSymlink != 0 {
+		// Копируем симлинк как симлинк
+		target, err := os.Readlink(path)
+		if err != nil {
+			// Не удалось прочитать симлинк, пропускаем
 			return nil
 		}
+		// Проверяем, существует ли уже файл/симлинк по целевому пути
+		if _, err := os.Lstat(destPath); err == nil {
+			// Файл уже существует, удаляем его
+			if err := os.Remove(destPath); err != nil {
+				return fmt.Errorf("failed to remove existing file for symlink: %w", err)
+			}
+		}
+		// Создаем новый симлинк
+		return os.Symlink(target, destPath)
+	}
 
-		return copyFile(path, destPath, info.Mode())
-	})
+	// Обычный файл - проверяем, что он все еще существует перед копированием
+	// (может быть удален между моментом обнаружения и копированием)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		// Файл не существует, пропускаем
+		return nil
+	}
+
+	return copyFile(path, destPath, info.Mode())
 }
 
-func shouldExclude(path string, patterns []string) bool {
+// matchExclude возвращает первый паттерн, под который попадает path
+func matchExclude(path string, patterns []string) (string, bool) {
 	fileName := filepath.Base(path)
 	
 	for _, pattern := range patterns {
@@ -109,7 +316,7 @@ func shouldExclude(path string, patterns []string) bool {
 		}
 
 		if matched {
-			return true
+			return pattern, true
 		}
 
 		// Проверяем совпадение только с именем файла (для паттернов типа *.sock)
@@ -119,18 +326,22 @@ func shouldExclude(path string, patterns []string) bool {
 		}
 
 		if matched {
-			return true
+			return pattern, true
 		}
 
 		// Также проверяем, начинается ли путь с паттерна (для директорий)
 		if strings.HasPrefix(path, pattern) {
-			return true
+			return pattern, true
 		}
 	}
 
-	return false
+	return "", false
 }
 
+// modeMask - биты прав, которые копируются точно (включая setuid/setgid/sticky)
+const modeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// copyFile копирует файл и выставляет права явно, а не через umask
 func copyFile(src, dst string, mode os.FileMode) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -138,13 +349,20 @@ func copyFile(src, dst string, mode os.FileMode) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
 	if err != nil {
 		return err
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Chmod(dst, mode&modeMask)
 }
 
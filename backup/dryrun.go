@@ -0,0 +1,226 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/compression"
+	"goback/config"
+	"goback/encryption"
+	"goback/hooks"
+	"goback/retention"
+	"goback/utils"
+)
+
+// SetDryRun включает режим, в котором ExecuteBackup только показывает, что
+// было бы сделано: источник обходится, но ничего не записывается и не удаляется
+func (e *Executor) SetDryRun(dryRun bool) {
+	e.dryRun = dryRun
+}
+
+// dryRunBackup обходит источник с учетом exclude_patterns, вычисляет имя архива
+// и показывает, какие архивы удалит retention
+func (e *Executor) dryRunBackup(backupConfig *config.BackupConfig) error {
+	if err := CheckReadOnly(e.globalConfig, backupConfig, backupConfig.HasHooks()); err != nil {
+		return err
+	}
+	if backupConfig.IsReadOnly(e.globalConfig) {
+		fmt.Printf("Source is read-only: files are opened without updating access times\n")
+	}
+
+	compressionType := e.compressionType(backupConfig)
+
+	compressor, err := compression.NewCompressorWithOptions(compressionType, e.compressionOptions(backupConfig, compressionType))
+	if err != nil {
+		return fmt.Errorf("failed to create compressor: %w", err)
+	}
+
+	if len(backupConfig.PreHooks) > 0 {
+		fmt.Printf("Backup pre-hooks:\n")
+		hooks.DryRunHooks(backupConfig.PreHooks, backupConfig.HookFailure(e.globalConfig), e.runEnv(backupConfig.Name)...)
+	}
+
+	for _, service := range backupConfig.Services {
+		fmt.Printf("Would stop service %s:%s during the copy\n", service.Type, service.Name)
+	}
+	if volume := backupConfig.DockerVolume; backupConfig.Type == config.TypeDockerVolume && volume.Containers != "" {
+		fmt.Printf("Would %s containers using volume %s during the copy\n", volume.Containers, volume.Volume)
+	}
+	if backupConfig.Barrier != nil {
+		fmt.Printf("Would apply %s barrier during the copy\n", backupConfig.Barrier.Mode)
+	}
+
+	if backupConfig.SourceDir != "" {
+		if _, err := os.Stat(backupConfig.SourceDir); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: source_dir %s does not exist", ErrSourceMissing, backupConfig.SourceDir)
+		}
+		if _, ok := compressor.(compression.TreeCompressor); !ok {
+			return fmt.Errorf("compression %s cannot archive a directory, use tar, tar.gz, tar.zst, tar.xz or zip", compressionType)
+		}
+		if err := e.dryRunWalk(backupConfig); err != nil {
+			return err
+		}
+	} else if backupConfig.IsDump() {
+		if _, ok := compressor.(compression.StreamCompressor); !ok {
+			return fmt.Errorf("compression %s cannot stream a database dump, use gzip, zstd, xz or none", compressionType)
+		}
+		dumper, err := newDumper(backupConfig)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Would run: %s\n", dumper)
+		fmt.Printf("Would stream the dump into the archive\n")
+	} else {
+		if backupConfig.Command.IsZero() {
+			return fmt.Errorf("invalid backup configuration: no source_dir or command")
+		}
+		fmt.Printf("Would run command: %s\n", backupConfig.Command)
+		if backupConfig.Command.IsArgv() {
+			fmt.Printf("  without shell, args: %q\n", backupConfig.Command.Args)
+		} else if backupConfig.Shell != "" {
+			fmt.Printf("  shell: %s\n", backupConfig.Shell)
+		}
+		if backupConfig.WorkingDir != "" {
+			fmt.Printf("  working directory: %s\n", backupConfig.WorkingDir)
+		}
+		if len(backupConfig.Env) > 0 {
+			// Значения не выводятся: в env обычно передаются пароли
+			names := make([]string, 0, len(backupConfig.Env))
+			for name := range backupConfig.Env {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			fmt.Printf("  environment: %s\n", strings.Join(names, ", "))
+		}
+		if backupConfig.Stream {
+			if _, ok := compressor.(compression.StreamCompressor); !ok {
+				return fmt.Errorf("compression %s cannot stream command output, use gzip, zstd, xz or none", compressionType)
+			}
+			fmt.Printf("Would stream command output into the archive\n")
+		} else {
+			fmt.Printf("Would archive command output: %s\n", backupConfig.OutputFile)
+		}
+	}
+
+	now := time.Now()
+	filename := e.globalConfig.FileNaming().Generate(backupConfig.Name, now)
+	filename += utils.GetExtension(compressionType)
+	if backupConfig.Encryption != nil {
+		encryptor, err := encryption.NewEncryptor(backupConfig.Encryption.Type, backupConfig.Encryption.Options())
+		if err != nil {
+			return fmt.Errorf("failed to create encryptor: %w", err)
+		}
+		filename += encryptor.Extension()
+	}
+	destinationPath := filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory, filename)
+	if backupConfig.IsDirectory() {
+		fmt.Printf("Would create directory: %s\n", destinationPath)
+		if backupConfig.Incremental == config.IncrementalHardlink {
+			if e.invalidated(backupConfig) {
+				fmt.Printf("Would copy a full snapshot: state was invalidated\n")
+			} else if previous := e.previousSnapshot(backupConfig); previous != "" {
+				fmt.Printf("Would hardlink unchanged files from %s\n", filepath.Base(previous))
+			}
+		}
+	} else {
+		fmt.Printf("Would create archive: %s (%s)\n", destinationPath, compressionType)
+	}
+
+	for i := range backupConfig.Destinations {
+		dest := &backupConfig.Destinations[i]
+		stages, err := buildStages(dest)
+		if err != nil {
+			return err
+		}
+		key := destinationKey(backupConfig, stages, filename)
+		if e.offline && isNetworkDestination(dest) {
+			fmt.Printf("Would defer upload to %s: offline mode\n", dest.Name)
+		} else if uploadAllowed(dest, now) {
+			fmt.Printf("Would upload to destination %s: %s\n", dest.Name, key)
+		} else {
+			fmt.Printf("Would defer upload to %s until window %s: %s\n", dest.Name, dest.UploadWindow, key)
+		}
+	}
+
+	// Новый архив участвует в расчете retention так же, как при реальном запуске
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		fmt.Printf("Warning: failed to list existing backups: %v\n", err)
+	}
+	createdAt, ok := retention.MatchBackupFile(filename, backupConfig.Name, e.globalConfig.FileNaming())
+	if !ok {
+		createdAt = now
+	}
+	files = append(files, retention.BackupFile{Path: destinationPath, Time: createdAt})
+
+	toRemove := retention.Plan(files, EffectiveRetention(e.globalConfig, backupConfig), heldArchives(e.globalConfig, backupConfig, "would"))
+	if len(toRemove) == 0 {
+		fmt.Printf("Retention would not remove any backups\n")
+	}
+	for _, file := range toRemove {
+		if file.Path == destinationPath {
+			fmt.Printf("Retention would remove the new archive itself: %s\n", filename)
+			continue
+		}
+		fmt.Printf("Retention would remove: %s\n", filepath.Base(file.Path))
+	}
+
+	if backupConfig.KeepLocal != nil && !*backupConfig.KeepLocal {
+		fmt.Printf("Would remove local archive after delivery (keep_local: false)\n")
+	}
+
+	if len(backupConfig.PostHooks) > 0 {
+		fmt.Printf("Backup post-hooks:\n")
+		// Размер и длительность станут известны только после копирования
+		env := append(e.runEnv(backupConfig.Name), "GOBACK_ARCHIVE_PATH="+destinationPath, "GOBACK_ARCHIVE="+filename, "GOBACK_STATUS=success")
+		hooks.DryRunHooks(backupConfig.PostHooks, postHookFailure(backupConfig.HookFailure(e.globalConfig)), env...)
+	}
+	if len(backupConfig.OnSuccess) > 0 {
+		fmt.Printf("Backup on_success hooks:\n")
+		hooks.DryRunHooks(backupConfig.OnSuccess, hooks.FailureWarn, e.runEnv(backupConfig.Name)...)
+	}
+	if len(backupConfig.OnFailure) > 0 {
+		fmt.Printf("Backup on_failure hooks:\n")
+		hooks.DryRunHooks(backupConfig.OnFailure, hooks.FailureWarn, e.runEnv(backupConfig.Name)...)
+	}
+
+	utils.PrintSuccess("Dry run completed: %s", backupConfig.Name)
+	return nil
+}
+
+// dryRunWalk обходит source_dir так же, как при упаковке, и выводит пропущенные
+// пути с причинами и итоговый объем
+func (e *Executor) dryRunWalk(backupConfig *config.BackupConfig) error {
+	var files, dirs, skipped int
+	var size int64
+
+	opts := e.copyOptions(backupConfig)
+	opts.Skipped = func(relPath, reason string) {
+		skipped++
+		fmt.Printf("  skip %s: %s\n", relPath, reason)
+	}
+
+	err := WalkDirectory(backupConfig.SourceDir, opts, func(relPath string, info os.FileInfo) error {
+		if info.IsDir() {
+			dirs++
+			return nil
+		}
+		files++
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk source: %w", err)
+	}
+
+	fmt.Printf("Source %s: %d file(s), %d director(ies), %s uncompressed, %d path(s) skipped\n", backupConfig.SourceDir, files, dirs, utils.FormatSize(size), skipped)
+	return nil
+}
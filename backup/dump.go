@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+
+	"goback/compression"
+	"goback/config"
+	"goback/dump"
+)
+
+// newDumper создает dumper для бэкапа с type базы данных или тома Docker
+func newDumper(backupConfig *config.BackupConfig) (dump.Dumper, error) {
+	switch backupConfig.Type {
+	case config.TypePostgres:
+		pg := backupConfig.Postgres
+		if pg == nil {
+			pg = &config.PostgresConfig{}
+		}
+		return dump.NewPostgresDumper(pg.Options())
+	case config.TypeMySQL:
+		my := backupConfig.MySQL
+		if my == nil {
+			my = &config.MySQLConfig{}
+		}
+		return dump.NewMySQLDumper(my.Options())
+	case config.TypeMongoDB:
+		mongo := backupConfig.MongoDB
+		if mongo == nil {
+			mongo = &config.MongoDBConfig{}
+		}
+		return dump.NewMongoDBDumper(mongo.Options())
+	case config.TypeDockerVolume:
+		return dump.NewDockerVolumeDumper(backupConfig.DockerVolume.Options())
+	default:
+		return nil, fmt.Errorf("unsupported backup type: %s", backupConfig.Type)
+	}
+}
+
+// compressDump запускает дамп и пишет его вывод прямо в архив destination.
+// Ошибка дампа доходит до компрессора через pipe, поэтому возвращается она сама,
+// а при ошибке записи архива - ошибка записи, а не оборванного дампа
+func (e *Executor) compressDump(compressor compression.Compressor, compressionType string, backupConfig *config.BackupConfig, destination string, stats *archiveStats) error {
+	streamer, ok := compressor.(compression.StreamCompressor)
+	if !ok {
+		return fmt.Errorf("compression %s cannot stream a database dump, use gzip, zstd, xz or none", compressionType)
+	}
+
+	dumper, err := newDumper(backupConfig)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Running %s\n", dumper)
+
+	return compressProduced(streamer, destination, stats, dumper.Dump)
+}
+
+// compressProduced пишет в архив destination то, что produce записывает в pipe.
+// Если архив не записался, produce получает ошибку записи в pipe и завершается
+func compressProduced(streamer compression.StreamCompressor, destination string, stats *archiveStats, produce func(io.Writer) error) error {
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := produce(writer)
+		writer.CloseWithError(err)
+		done <- err
+	}()
+
+	counter := &countingReader{reader: reader}
+	err := streamer.CompressStream(counter, destination)
+	*stats = archiveStats{files: 1, bytes: counter.n}
+	reader.CloseWithError(fmt.Errorf("archive write failed"))
+	produceErr := <-done
+	if err != nil {
+		return err
+	}
+	return produceErr
+}
@@ -0,0 +1,49 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+
+	"goback/hooks"
+	"goback/lock"
+)
+
+// Ошибки, по которым программы, встраивающие goback, могут различать причины
+// сбоя через errors.Is. Ошибки конвейера оборачивают одну из них вместе с
+// исходной причиной
+var (
+	// ErrWindowExceeded - бэкап прерван или пропущен, потому что закончилось окно max_window
+	ErrWindowExceeded = errors.New("backup window exceeded")
+	// ErrSourceMissing - нет source_dir или команда не создала output_file
+	ErrSourceMissing = errors.New("backup source is missing")
+	// ErrDestinationFull - в backup_dir или destination закончилось место
+	ErrDestinationFull = errors.New("destination is full")
+	// ErrHookFailed - хук завершился с ошибкой
+	ErrHookFailed = hooks.ErrHookFailed
+	// ErrSkippedByHook - бэкап пропущен: pre-хук с on_hook_failure: skip-backup завершился ошибкой
+	ErrSkippedByHook = errors.New("skipped because a pre-hook failed")
+	// ErrLocked - бэкап или запуск уже выполняет другой процесс goback
+	ErrLocked = lock.ErrLocked
+	// ErrVerificationFailed - архив не прошел проверку (размер, контрольная сумма)
+	ErrVerificationFailed = errors.New("archive verification failed")
+	// ErrUploadRetryable - загрузка не удалась из-за временной (сетевой) ошибки
+	// и после всех повторов
+	ErrUploadRetryable = errors.New("retryable upload error")
+	// ErrUploadPermanent - загрузка не удалась из-за ошибки, которую повтор не
+	// исправит (авторизация, нет бакета)
+	ErrUploadPermanent = errors.New("permanent upload error")
+)
+
+// classifyError добавляет к ошибке ErrDestinationFull, если ее причина - нехватка места
+func classifyError(err error) error {
+	if err == nil || errors.Is(err, ErrDestinationFull) {
+		return err
+	}
+
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return fmt.Errorf("%w: %w", ErrDestinationFull, err)
+	}
+
+	return err
+}
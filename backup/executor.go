@@ -1,80 +1,290 @@
 package backup
 
 import (
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"goback/catalog"
+	"goback/checksum"
 	"goback/compression"
 	"goback/config"
+	"goback/encryption"
 	"goback/hooks"
+	"goback/lock"
+	"goback/metadata"
+	"goback/notify"
+	"goback/progress"
 	"goback/retention"
+	"goback/services"
 	"goback/utils"
 )
 
+// defaultGentleDelay - пауза между чтениями директорий в щадящем режиме
+const defaultGentleDelay = 20 * time.Millisecond
+
 type Executor struct {
 	globalConfig *config.GlobalConfig
+	runID        string
+	deadline     time.Time
+	startedAt    time.Time
+	dryRun       bool
+	offline      bool
+	verbose      bool
+	// lockWait - сколько ждать блокировку бэкапа, занятую другим запуском
+	lockWait time.Duration
+
+	// mu защищает deferred и results при параллельных бэкапах
+	mu       sync.Mutex
+	deferred []string
+	results  []notify.Result
 }
 
 func NewExecutor(globalConfig *config.GlobalConfig) *Executor {
+	startedAt := time.Now()
 	return &Executor{
 		globalConfig: globalConfig,
+		runID:        NewRunID(startedAt),
+		startedAt:    startedAt,
+		lockWait:     globalConfig.LockWait,
 	}
 }
 
+// SetLockWait задает, сколько ждать освобождения блокировки бэкапа другим
+// запуском (0 - сразу вернуть ошибку lock.ErrLocked)
+func (e *Executor) SetLockWait(wait time.Duration) {
+	e.lockWait = wait
+}
+
+// SetDeadline задает момент окончания окна бэкапа: после него новые бэкапы
+// не запускаются, а копирование текущего прерывается
+func (e *Executor) SetDeadline(deadline time.Time) {
+	e.deadline = deadline
+}
+
+// SetVerbose включает вывод каждого пропущенного при обходе пути с причиной
+func (e *Executor) SetVerbose(verbose bool) {
+	e.verbose = verbose
+}
+
+// SetOffline запрещает сетевые операции: загрузки в сетевые destinations
+// откладываются в spool до запуска без --offline
+func (e *Executor) SetOffline(offline bool) {
+	e.offline = offline
+}
+
+// Deferred возвращает доставки, отложенные в режиме offline ("backup -> destination")
+func (e *Executor) Deferred() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.deferred...)
+}
+
+// Results возвращает итоги выполненных бэкапов в порядке их завершения
+func (e *Executor) Results() []notify.Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]notify.Result(nil), e.results...)
+}
+
+// Ran сообщает, что executor выполнил хотя бы один бэкап
+func (e *Executor) Ran() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.results) > 0
+}
+
+// WindowExceeded сообщает, что окно бэкапа уже закончилось
+func (e *Executor) WindowExceeded() bool {
+	return !e.deadline.IsZero() && time.Now().After(e.deadline)
+}
+
+// ExecuteBackup выполняет бэкап и отправляет его итог в notifications
 func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
-	utils.PrintHeader("Starting backup: %s", backupConfig.Name)
+	// Бэкап, который еще выполняет другой запуск (cron поверх долгого копирования,
+	// daemon и ручной запуск), пропускается без уведомления - итог сообщит тот запуск
+	if !e.dryRun {
+		backupLock, err := lock.Acquire(e.globalConfig.BackupLockPath(backupConfig.Name), e.runID, e.lockWait)
+		if err != nil {
+			return err
+		}
+		defer backupLock.Release()
+	}
+
+	// healthcheck_url получает /start один раз, итог - после всех повторов
+	e.pingHealthcheck(backupConfig, notify.PingStart, fmt.Sprintf("backup %s started (job %s)", backupConfig.Name, e.jobID(backupConfig.Name)))
+
+	startedAt := time.Now()
+	var result notify.Result
+	var err error
+	for attempt := 1; ; attempt++ {
+		result = notify.Result{Backup: backupConfig.Name, RunID: e.runID, JobID: e.jobID(backupConfig.Name)}
+		err = e.executeBackup(backupConfig, &result)
+		if !e.shouldRetry(backupConfig, err, result, attempt) {
+			if err == nil && attempt > 1 {
+				fmt.Printf("Backup %s succeeded on attempt %d of %d\n", backupConfig.Name, attempt, backupConfig.Retries+1)
+			}
+			break
+		}
+		delay := backupConfig.BackupRetryDelay()
+		utils.PrintError("Backup %s failed (attempt %d of %d), retrying in %s: %v", backupConfig.Name, attempt, backupConfig.Retries+1, delay, err)
+		time.Sleep(delay)
+	}
+	// Пропуск по on_hook_failure: skip-backup - не сбой, уведомление не отправляется,
+	// а healthcheck получает только запись в журнал: без ping об успехе сервис
+	// сообщит о пропущенном бэкапе по своему таймауту
+	if errors.Is(err, ErrSkippedByHook) {
+		e.pingHealthcheck(backupConfig, notify.PingLog, err.Error())
+	} else if !e.dryRun {
+		result.Duration = time.Since(startedAt)
+		e.notify(backupConfig, result, err)
+		e.runOutcomeHooks(backupConfig, result, err)
+	}
+	return err
+}
 
-	// Выполняем локальные pre-hooks
+// runOutcomeHooks выполняет on_success или on_failure бэкапа по его итогу
+func (e *Executor) runOutcomeHooks(backupConfig *config.BackupConfig, result notify.Result, err error) {
+	outcomeHooks, name := backupConfig.OnSuccess, "on_success"
+	if err != nil {
+		outcomeHooks, name = backupConfig.OnFailure, "on_failure"
+	}
+	if len(outcomeHooks) == 0 {
+		return
+	}
+
+	env := append(e.runEnv(backupConfig.Name), ResultEnv(err, result.Duration)...)
+	if result.Archive != "" {
+		env = append(env, archiveEnv(filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory, result.Archive), result.Size)...)
+	}
+
+	fmt.Printf("Running backup %s hooks...\n", name)
+	if err := hooks.RunHooks(outcomeHooks, hooks.FailureWarn, env...); err != nil {
+		fmt.Printf("Warning: backup %s hooks completed with errors\n", name)
+	}
+}
+
+// shouldRetry решает, повторять ли бэкап после неудачной попытки attempt. Повторяется
+// только бэкап, архив которого не был создан: после создания архива ошибки доставки
+// повторяет сама загрузка, а новый архив дублировал бы уже сохраненный
+func (e *Executor) shouldRetry(backupConfig *config.BackupConfig, err error, result notify.Result, attempt int) bool {
+	if err == nil || e.dryRun || attempt > backupConfig.Retries || result.Archive != "" {
+		return false
+	}
+	// Закончившееся окно и заполненный диск повтором не исправляются, а
+	// пропущенный по on_hook_failure бэкап не считается неудачным
+	if errors.Is(err, ErrWindowExceeded) || errors.Is(err, ErrDestinationFull) || errors.Is(err, ErrSkippedByHook) {
+		return false
+	}
+	// Повтор не начинаем, если он выйдет за окно бэкапа
+	return e.deadline.IsZero() || time.Now().Add(backupConfig.BackupRetryDelay()).Before(e.deadline)
+}
+
+// postHookFailure - on_hook_failure для post-хуков: после копирования пропустить
+// бэкап уже нельзя, поэтому skip-backup действует как warn
+func postHookFailure(action string) string {
+	if action == hooks.FailureSkipBackup {
+		return hooks.FailureWarn
+	}
+	return action
+}
+
+// executeBackup выполняет бэкап, заполняя в result сведения о созданном архиве
+func (e *Executor) executeBackup(backupConfig *config.BackupConfig, result *notify.Result) error {
+	if e.WindowExceeded() {
+		return ErrWindowExceeded
+	}
+
+	utils.PrintHeader("Starting backup: %s (job %s)", backupConfig.Name, e.jobID(backupConfig.Name))
+
+	if e.dryRun {
+		return e.dryRunBackup(backupConfig)
+	}
+	startedAt := time.Now()
+
+	if backupConfig.SourceDir != "" {
+		if _, err := os.Stat(backupConfig.SourceDir); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: source_dir %s does not exist", ErrSourceMissing, backupConfig.SourceDir)
+		}
+	}
+
+	if err := CheckReadOnly(e.globalConfig, backupConfig, backupConfig.HasHooks()); err != nil {
+		return err
+	}
+
+	// Выполняем локальные pre-hooks; при on_hook_failure abort и skip-backup
+	// бэкап без их результата (например, согласованного дампа) не создается
 	if len(backupConfig.PreHooks) > 0 {
 		fmt.Printf("Running backup pre-hooks...\n")
-		if err := hooks.RunHooks(backupConfig.PreHooks); err != nil {
-			fmt.Printf("Warning: backup pre-hooks completed with errors\n")
+		if err := hooks.RunHooks(backupConfig.PreHooks, backupConfig.HookFailure(e.globalConfig), e.runEnv(backupConfig.Name)...); err != nil {
+			switch hooks.FailureAction(err) {
+			case hooks.FailureAbort:
+				return fmt.Errorf("pre-hook failed, backup aborted: %w", err)
+			case hooks.FailureSkipBackup:
+				return fmt.Errorf("%w: %w", ErrSkippedByHook, err)
+			default:
+				fmt.Printf("Warning: backup pre-hooks completed with errors\n")
+			}
 		}
 	}
 
 	// Определяем тип сжатия
-	compressionType := backupConfig.Compression
-	if compressionType == "" {
-		compressionType = e.globalConfig.DefaultCompression
-	}
+	compressionType := e.compressionType(backupConfig)
 
-	// Создаем временную директорию для бэкапа
-	tmpDir, err := os.MkdirTemp("", "backup-*")
+	// Сервисы останавливаются только на время чтения данных и запускаются
+	// обратно при любом исходе, в том числе при ошибке в середине бэкапа
+	resume, err := e.quiesceServices(backupConfig)
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return err
 	}
-	defer os.RemoveAll(tmpDir)
+	var resumeErr error
+	resumeServices := func() {
+		if resume != nil {
+			if resumeErr = resume(); resumeErr != nil {
+				utils.PrintError("%v", resumeErr)
+			}
+			resume = nil
+		}
+	}
+	defer resumeServices()
 
-	var sourcePath string
+	// Барьер ставится после остановки сервисов и снимается сразу после копирования
+	release, err := e.engageBarrier(backupConfig)
+	if err != nil {
+		return err
+	}
+	var releaseErr error
+	releaseBarrier := func() {
+		if release != nil {
+			if releaseErr = release(); releaseErr != nil {
+				utils.PrintError("%v", releaseErr)
+			}
+			release = nil
+		}
+	}
+	defer releaseBarrier()
 
-	// Выполняем бэкап
-	if backupConfig.SourceDir != "" {
-		// Бэкап директории
-		sourcePath = tmpDir
-		if err := CopyDirectory(backupConfig.SourceDir, sourcePath, backupConfig.ExcludePatterns); err != nil {
-			return fmt.Errorf("failed to copy directory: %w", err)
-		}
-	} else if backupConfig.Command != "" {
-		// Бэкап через команду
-		if err := ExecuteCommand(backupConfig.Command, backupConfig.OutputFile); err != nil {
+	// Для бэкапа через команду сначала получаем output_file (с stream: true вывод
+	// команды пишется прямо в архив при сжатии)
+	if backupConfig.SourceDir == "" && !backupConfig.IsDump() && !backupConfig.Stream {
+		if backupConfig.Command.IsZero() {
+			return fmt.Errorf("invalid backup configuration: no source_dir or command")
+		}
+		if err := ExecuteCommand(backupConfig); err != nil {
 			return fmt.Errorf("failed to execute command: %w", err)
 		}
-
-		// Копируем output_file во временную директорию
-		sourcePath = filepath.Join(tmpDir, filepath.Base(backupConfig.OutputFile))
-		if err := copyFileToTemp(backupConfig.OutputFile, sourcePath); err != nil {
-			return fmt.Errorf("failed to copy output file: %w", err)
+		if _, err := os.Stat(backupConfig.OutputFile); errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: command did not create output_file %s", ErrSourceMissing, backupConfig.OutputFile)
 		}
-	} else {
-		return fmt.Errorf("invalid backup configuration: no source_dir or command")
 	}
 
 	// Создаем имя файла
 	now := time.Now()
-	filename := utils.GenerateFilename(e.globalConfig.FilenameMask, backupConfig.Name, now)
+	filename := e.globalConfig.FileNaming().Generate(backupConfig.Name, now)
 	ext := utils.GetExtension(compressionType)
 	if ext != "" {
 		filename += ext
@@ -83,66 +293,548 @@ func (e *Executor) ExecuteBackup(backupConfig *config.BackupConfig) error {
 	// Создаем целевую директорию
 	backupSubDir := filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory)
 	if err := os.MkdirAll(backupSubDir, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+		return classifyError(fmt.Errorf("failed to create backup directory: %w", err))
 	}
 
+	// Архив пишется под временным именем, которое игнорируется retention и
+	// сканированием, и получает настоящее имя только целиком записанным: после сбоя
+	// посреди сжатия не остается обрезанного архива, похожего на валидную копию.
+	// Зашифрованный бэкап сначала сжимается в отдельный временный файл, который
+	// удаляется после шифрования
+	compressedPath := filepath.Join(backupSubDir, filename) + ".tmp"
+	var encryptor encryption.Encryptor
+	if backupConfig.Encryption != nil {
+		var err error
+		encryptor, err = encryption.NewEncryptor(backupConfig.Encryption.Type, backupConfig.Encryption.Options())
+		if err != nil {
+			return fmt.Errorf("failed to create encryptor: %w", err)
+		}
+		filename += encryptor.Extension()
+	}
 	destinationPath := filepath.Join(backupSubDir, filename)
+	writePath := destinationPath + ".tmp"
 
 	// Применяем сжатие
-	compressor, err := compression.NewCompressor(compressionType)
+	opts := e.compressionOptions(backupConfig, compressionType)
+	invalidated := e.invalidated(backupConfig)
+	if backupConfig.Incremental == config.IncrementalHardlink {
+		if invalidated {
+			fmt.Printf("State was invalidated: copying a full snapshot\n")
+		} else {
+			opts.LinkDest = e.previousSnapshot(backupConfig)
+		}
+	}
+	// Без шифрования тома пишет сам компрессор; зашифрованный архив разбивается
+	// на тома после шифрования
+	splitSize := backupConfig.VolumeSize(e.globalConfig)
+	if encryptor == nil {
+		opts.SplitSize = splitSize
+	}
+	reporter := e.startProgress(backupConfig)
+	defer reporter.Stop()
+	opts.Progress = reporter
+	compressor, err := compression.NewCompressorWithOptions(compressionType, opts)
 	if err != nil {
 		return fmt.Errorf("failed to create compressor: %w", err)
 	}
 
-	fmt.Printf("Compressing to %s...\n", destinationPath)
-	if err := compressor.Compress(sourcePath, destinationPath); err != nil {
-		return fmt.Errorf("failed to compress: %w", err)
+	if backupConfig.IsDirectory() {
+		fmt.Printf("Copying to %s...\n", destinationPath)
+	} else {
+		fmt.Printf("Compressing to %s...\n", destinationPath)
+	}
+	var snapshot *metadata.Snapshot
+	var stats archiveStats
+	if backupConfig.SourceDir != "" {
+		// Файлы читаются прямо из source_dir и сразу пишутся в архив
+		snapshot, result.Changes, err = e.compressDirectory(compressor, compressionType, backupConfig, compressedPath, &stats, reporter)
+	} else if backupConfig.IsDump() {
+		// Дамп базы пишется в архив потоком, без output_file на диске
+		err = e.compressDump(compressor, compressionType, backupConfig, compressedPath, &stats)
+	} else if backupConfig.Stream {
+		err = e.compressCommand(compressor, compressionType, backupConfig, compressedPath, &stats)
+	} else {
+		err = compressor.Compress(backupConfig.OutputFile, compressedPath)
+		if info, statErr := os.Stat(backupConfig.OutputFile); statErr == nil {
+			stats = archiveStats{files: 1, bytes: info.Size()}
+		}
+	}
+	reporter.Stop()
+	releaseBarrier()
+	if err != nil {
+		// Недописанный архив не должен попасть под retention как валидная копия
+		utils.RemoveArchive(compressedPath)
+		return classifyError(fmt.Errorf("failed to compress: %w", err))
 	}
 
-	utils.PrintSuccess("Backup created: %s", filename)
+	if encryptor != nil {
+		err := encryptor.Encrypt(compressedPath, writePath)
+		os.Remove(compressedPath)
+		if err != nil {
+			os.Remove(writePath)
+			return classifyError(fmt.Errorf("failed to encrypt archive: %w", err))
+		}
+		if splitSize > 0 {
+			if _, err := utils.SplitArchive(writePath, splitSize); err != nil {
+				utils.RemoveArchive(writePath)
+				return classifyError(err)
+			}
+		}
+	}
 
-	// Применяем retention policy
-	retentionPolicy := e.globalConfig.Retention
-	if backupConfig.Retention != nil {
-		retentionPolicy = *backupConfig.Retention
+	if err := utils.CommitArchive(writePath, destinationPath); err != nil {
+		utils.RemoveArchive(writePath)
+		return classifyError(fmt.Errorf("failed to finalize archive: %w", err))
 	}
 
+	resumeServices()
+
+	info, err := utils.StatArchive(destinationPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+	size := info.Size()
+	if info.IsDir() {
+		if size, err = utils.DirSize(destinationPath); err != nil {
+			return fmt.Errorf("failed to measure backup directory: %w", err)
+		}
+	}
+
+	// Слишком маленький архив обычно означает пустой дамп - считаем бэкап неудачным
+	// и удаляем архив, чтобы retention не принял его за валидную копию
+	if limit := backupConfig.MinArchiveSize; limit != "" {
+		minSize, _ := utils.ParseSize(limit)
+		if size < minSize {
+			utils.RemoveArchive(destinationPath)
+			return fmt.Errorf("%w: archive size %s is below min_archive_size %s", ErrVerificationFailed, utils.FormatSize(size), limit)
+		}
+	}
+	if err := e.checkSizeAnomaly(backupConfig, size); err != nil {
+		utils.RemoveArchive(destinationPath)
+		return err
+	}
+
+	// Запись о ключах нужна, чтобы после ротации найти ключ для восстановления. Пишем ее
+	// только после проверок размера: отклоненный архив удален и не оставляет .keys.json
+	if encryptor != nil {
+		record := encryption.NewKeyRecord(backupConfig.Encryption.Type, backupConfig.Encryption.Options())
+		if err := encryption.WriteKeyRecord(destinationPath, record); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	if volumes := len(utils.VolumeParts(destinationPath)); volumes > 0 {
+		utils.PrintSuccess("Backup created: %s (%s in %d volumes of %s)", filename, utils.FormatSize(size), volumes, utils.FormatSize(splitSize))
+	} else {
+		utils.PrintSuccess("Backup created: %s (%s)", filename, utils.FormatSize(size))
+	}
+	if snapshotDir, ok := compressor.(*compression.DirectoryCompressor); ok && opts.LinkDest != "" {
+		fmt.Printf("Hardlinked %d unchanged file(s) (%s) from %s, %s copied\n", snapshotDir.Linked, utils.FormatSize(snapshotDir.LinkedBytes), filepath.Base(opts.LinkDest), utils.FormatSize(size-snapshotDir.LinkedBytes))
+	}
+	result.Archive = filename
+	result.Size = size
+
+	if snapshot != nil {
+		if err := snapshot.Save(e.globalConfig.MetadataPath(backupConfig.Name)); err != nil {
+			fmt.Printf("Warning: failed to save metadata snapshot: %v\n", err)
+		}
+	}
+
+	// У директории нет единой контрольной суммы: в каталог она попадает без нее
+	var archiveChecksum string
+	if !info.IsDir() {
+		archiveChecksum = e.fileChecksum(destinationPath)
+		e.writeChecksumManifest(destinationPath, archiveChecksum)
+	}
+	e.writeArchiveMeta(backupConfig, destinationPath, compressionType, archiveChecksum, size, stats, startedAt)
+	e.recordArchive(backupConfig.Name, catalog.LocalDestination, filepath.Join(backupConfig.Subdirectory, filename), size, archiveChecksum, now)
+
+	// Доставляем архив в дополнительные destinations
+	var deliveryErr error
+	if len(backupConfig.Destinations) > 0 {
+		deliveryErr = e.deliverToDestinations(backupConfig, destinationPath, now, result)
+	}
+
+	// Применяем retention policy
 	fmt.Printf("Applying retention policy...\n")
-	if err := retention.ApplyRetention(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, retention.RetentionPolicy{
-		Daily:   retentionPolicy.Daily,
-		Weekly:  retentionPolicy.Weekly,
-		Monthly: retentionPolicy.Monthly,
-		Yearly:  retentionPolicy.Yearly,
-	}); err != nil {
+	removed, err := retention.ApplyRetention(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming(), EffectiveRetention(e.globalConfig, backupConfig), heldArchives(e.globalConfig, backupConfig, "will"))
+	if err != nil {
 		fmt.Printf("Warning: retention policy failed: %v\n", err)
 	}
 
-	// Выполняем локальные post-hooks
+	removedKeys := make([]string, 0, len(removed))
+	for _, path := range removed {
+		removedKeys = append(removedKeys, filepath.Join(backupConfig.Subdirectory, filepath.Base(path)))
+		result.Removed = append(result.Removed, filepath.Base(path))
+	}
+	e.forgetArchives(catalog.LocalDestination, removedKeys)
+
+	// В destinations, куда архив не доставлен, старые копии остаются нетронутыми
+	if len(backupConfig.Destinations) > 0 {
+		skip := make(map[string]bool)
+		var failed *deliveryError
+		if errors.As(deliveryErr, &failed) {
+			for _, name := range failed.names {
+				skip[name] = true
+			}
+		}
+		e.applyRemoteRetention(backupConfig, skip)
+	}
+
+	// Выполняем локальные post-hooks с путем к архиву и итогом бэкапа: ошибка
+	// доставки или запуска сервисов после копирования - тоже failure
+	var postHookErr error
 	if len(backupConfig.PostHooks) > 0 {
 		fmt.Printf("Running backup post-hooks...\n")
-		if err := hooks.RunHooks(backupConfig.PostHooks); err != nil {
-			fmt.Printf("Warning: backup post-hooks completed with errors\n")
+		outcome := deliveryErr
+		if outcome == nil {
+			outcome = releaseErr
+		}
+		if outcome == nil {
+			outcome = resumeErr
+		}
+		env := append(e.runEnv(backupConfig.Name), archiveEnv(destinationPath, size)...)
+		env = append(env, ResultEnv(outcome, time.Since(startedAt))...)
+		if err := hooks.RunHooks(backupConfig.PostHooks, postHookFailure(backupConfig.HookFailure(e.globalConfig)), env...); err != nil {
+			if hooks.FailureAction(err) == hooks.FailureAbort {
+				postHookErr = fmt.Errorf("post-hook failed: %w", err)
+			} else {
+				fmt.Printf("Warning: backup post-hooks completed with errors\n")
+			}
+		}
+	}
+
+	if deliveryErr != nil {
+		return deliveryErr
+	}
+
+	// Архив создан, но сервис остался остановленным - это тоже сбой бэкапа
+	if releaseErr != nil {
+		return releaseErr
+	}
+
+	if resumeErr != nil {
+		return resumeErr
+	}
+
+	// Локальная копия больше не нужна, если архив доставлен во все destinations
+	if backupConfig.KeepLocal != nil && !*backupConfig.KeepLocal {
+		if err := utils.RemoveArchive(destinationPath); err != nil {
+			fmt.Printf("Warning: failed to remove local archive: %v\n", err)
+		} else {
+			fmt.Printf("Removed local archive (keep_local: false): %s\n", filename)
+			if err := checksum.Forget(destinationPath); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			e.forgetArchives(catalog.LocalDestination, []string{filepath.Join(backupConfig.Subdirectory, filename)})
 		}
 	}
 
+	if invalidated {
+		e.clearInvalidation(backupConfig)
+	}
+
+	// Архив сохранен, но post-хук с on_hook_failure: abort не выполнился
+	if postHookErr != nil {
+		return postHookErr
+	}
+
 	utils.PrintSuccess("Backup completed: %s", backupConfig.Name)
 	return nil
 }
 
-func copyFileToTemp(src, dst string) error {
-	srcFile, err := os.Open(src)
+// EffectiveRetention возвращает политику хранения бэкапа с учетом глобальной
+func EffectiveRetention(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig) retention.RetentionPolicy {
+	policy := globalConfig.Retention
+	if backupConfig.Retention != nil {
+		policy = *backupConfig.Retention
+	}
+	return retentionPolicy(policy)
+}
+
+// DestinationRetention возвращает политику хранения архивов бэкапа в destination:
+// retention destination или ту же, что и в backup_dir
+func DestinationRetention(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, dest *config.DestinationConfig) retention.RetentionPolicy {
+	if dest.Retention != nil {
+		return retentionPolicy(*dest.Retention)
+	}
+	return EffectiveRetention(globalConfig, backupConfig)
+}
+
+// retentionPolicy переводит политику из конфигурации в параметры retention
+func retentionPolicy(policy config.RetentionPolicy) retention.RetentionPolicy {
+	// max_total_size уже проверен при загрузке конфигурации
+	maxTotalSize, _ := utils.ParseSize(policy.MaxTotalSize)
+
+	return retention.RetentionPolicy{
+		KeepLast:     policy.KeepLast,
+		Hourly:       policy.Hourly,
+		Daily:        policy.Daily,
+		Weekly:       policy.Weekly,
+		Monthly:      policy.Monthly,
+		Yearly:       policy.Yearly,
+		MaxTotalSize: maxTotalSize,
+	}
+}
+
+// quiesceServices останавливает services бэкапа (и контейнеры тома docker-volume)
+// и возвращает функцию их запуска
+func (e *Executor) quiesceServices(backupConfig *config.BackupConfig) (func() error, error) {
+	var list []services.Service
+	for _, serviceCfg := range backupConfig.Services {
+		service, err := services.New(serviceCfg.Type, serviceCfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, service)
+	}
+
+	// Контейнеры тома останавливаются последними и запускаются первыми
+	if volume := backupConfig.DockerVolume; backupConfig.Type == config.TypeDockerVolume && volume.Containers != "" {
+		service, err := services.NewVolumeContainers(volume.Volume, volume.Containers)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, service)
+	}
+
+	if len(list) == 0 {
+		return nil, nil
+	}
+
+	return services.Quiesce(list)
+}
+
+// engageBarrier выполняет sync/fsfreeze ФС источника и возвращает функцию снятия барьера
+func (e *Executor) engageBarrier(backupConfig *config.BackupConfig) (func() error, error) {
+	if backupConfig.Barrier == nil {
+		return nil, nil
+	}
+
+	path := backupConfig.Barrier.Path
+	if path == "" {
+		path = backupConfig.SourceDir
+	}
+
+	barrier, err := services.NewBarrier(backupConfig.Barrier.Mode, path, backupConfig.Barrier.Timeout)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// Запись архива или дампа на замороженную ФС заблокировалась бы до таймаута
+	writes := []string{e.globalConfig.BackupDir}
+	if backupConfig.OutputFile != "" && !backupConfig.Stream {
+		writes = append(writes, backupConfig.OutputFile)
+	}
+	for _, path := range writes {
+		if barrier.Freezes(path) {
+			return nil, fmt.Errorf("cannot freeze %s: %s is on the same filesystem", barrier.Mountpoint(), path)
+		}
+	}
+
+	if err := barrier.Engage(); err != nil {
+		return nil, err
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	return barrier.Release, nil
+}
+
+// startProgress начинает вывод хода сжатия бэкапа. Обновляемая строка выводится
+// только на терминал и только когда бэкапы выполняются по одному, иначе строки
+// разных бэкапов перезаписывали бы друг друга
+func (e *Executor) startProgress(backupConfig *config.BackupConfig) *progress.Reporter {
+	terminal := progress.IsTerminal(os.Stdout) && e.globalConfig.Parallelism <= 1
+	return progress.Start(backupConfig.Name, e.globalConfig.Progress, e.previousSourceSize(backupConfig), e.globalConfig.ProgressEvery(), terminal)
+}
+
+// previousSourceSize возвращает объем источника последнего архива бэкапа по его
+// описанию (0 - неизвестен); по нему оцениваются процент и ETA
+func (e *Executor) previousSourceSize(backupConfig *config.BackupConfig) int64 {
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
 	if err != nil {
-		return err
+		return 0
 	}
-	defer dstFile.Close()
+	for i := len(files) - 1; i >= 0; i-- {
+		if meta, ok, err := metadata.ReadArchive(files[i].Path); err == nil && ok {
+			return meta.UncompressedSize
+		}
+	}
+	return 0
+}
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+// previousSnapshot возвращает последний снимок-директорию бэкапа для incremental: hardlink;
+// пустая строка - снимков нет и первый снимок копируется целиком
+func (e *Executor) previousSnapshot(backupConfig *config.BackupConfig) string {
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		fmt.Printf("Warning: failed to find previous snapshot: %v\n", err)
+		return ""
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		if info, err := os.Stat(files[i].Path); err == nil && info.IsDir() {
+			return files[i].Path
+		}
+	}
+	return ""
+}
+
+// compressionType возвращает тип сжатия бэкапа; format: directory копирует
+// дерево через compression.DirectoryCompressor. Дамп базы пишется потоком,
+// поэтому при default_compression с tar или zip он сжимается gzip; так же и вывод
+// команды с stream: true
+func (e *Executor) compressionType(backupConfig *config.BackupConfig) string {
+	if backupConfig.IsDirectory() {
+		return config.FormatDirectory
+	}
+	if backupConfig.Compression != "" {
+		return backupConfig.Compression
+	}
+	if backupConfig.IsDump() || backupConfig.Stream {
+		switch e.globalConfig.DefaultCompression {
+		case "gzip", "zstd", "xz", "none":
+			return e.globalConfig.DefaultCompression
+		}
+		return "gzip"
+	}
+	return e.globalConfig.DefaultCompression
+}
+
+// compressionOptions возвращает параметры сжатия compressionType: compression_level
+// и compression_threads бэкапа (или глобальные), поверх которых действует секция
+// zstd или xz, и расписание лимитов скорости чтения источника
+func (e *Executor) compressionOptions(backupConfig *config.BackupConfig, compressionType string) compression.Options {
+	opts := compression.Options{
+		Level:          backupConfig.CompressionLevel,
+		Workers:        backupConfig.CompressionThreads,
+		Throttle:       e.globalConfig.ThrottleSchedule(),
+		NoAtime:        backupConfig.IsReadOnly(e.globalConfig),
+		FollowSymlinks: backupConfig.FollowsSymlinks(e.globalConfig),
+		SkipDirEntries: backupConfig.SkipsEmptyDirs(e.globalConfig),
+	}
+	if opts.Level == 0 {
+		opts.Level = e.globalConfig.CompressionLevel
+	}
+	if opts.Workers == 0 {
+		opts.Workers = e.globalConfig.CompressionThreads
+	}
+
+	var level, workers int
+	switch compression.Codec(compressionType) {
+	case "xz":
+		xz := e.globalConfig.Xz
+		if backupConfig.Xz != nil {
+			xz = backupConfig.Xz
+		}
+		if xz != nil {
+			level, workers = xz.Level, xz.Workers
+		}
+	case "zstd":
+		zstd := e.globalConfig.Zstd
+		if backupConfig.Zstd != nil {
+			zstd = backupConfig.Zstd
+		}
+		if zstd != nil {
+			level, workers = zstd.Level, zstd.Workers
+		}
+	}
+	if level > 0 {
+		opts.Level = level
+	}
+	if workers > 0 {
+		opts.Workers = workers
+	}
+
+	return opts
 }
 
+// copyOptions собирает параметры обхода источника; для сетевых ФС без явной
+// настройки включается щадящий режим
+func (e *Executor) copyOptions(backupConfig *config.BackupConfig) CopyOptions {
+	opts := CopyOptions{
+		ExcludePatterns: backupConfig.ExcludePatterns,
+		FollowSymlinks:  backupConfig.FollowsSymlinks(e.globalConfig),
+		Deadline:        e.deadline,
+		Parallelism:     1,
+		GentleDelay:     defaultGentleDelay,
+	}
+
+	walk := backupConfig.Walk
+	if walk != nil {
+		if walk.Parallelism > 0 {
+			opts.Parallelism = walk.Parallelism
+		}
+		if walk.GentleDelay > 0 {
+			opts.GentleDelay = walk.GentleDelay
+		}
+	}
+
+	if walk != nil && walk.Gentle != nil {
+		opts.Gentle = *walk.Gentle
+	} else if fsType := detectNetworkFilesystem(backupConfig.SourceDir); fsType != "" {
+		fmt.Printf("Source is on %s, using gentle walk mode\n", fsType)
+		opts.Gentle = true
+	}
+
+	return opts
+}
+
+// compressDirectory потоково упаковывает source_dir в архив, применяя exclude_patterns
+// и настройки обхода во время чтения. При включенном metadata_cache попутно
+// собирает снимок метаданных, который сохраняется после успешного бэкапа, и
+// отличия от предыдущего снимка для уведомлений
+func (e *Executor) compressDirectory(compressor compression.Compressor, compressionType string, backupConfig *config.BackupConfig, destinationPath string, stats *archiveStats, reporter *progress.Reporter) (*metadata.Snapshot, *metadata.Changes, error) {
+	treeCompressor, ok := compressor.(compression.TreeCompressor)
+	if !ok {
+		return nil, nil, fmt.Errorf("compression %s cannot archive a directory, use tar, tar.gz, tar.zst, tar.xz or zip", compressionType)
+	}
+
+	source, err := filepath.Abs(backupConfig.SourceDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path for source: %w", err)
+	}
+
+	var previous, snapshot *metadata.Snapshot
+	if e.globalConfig.MetadataCache {
+		previous, err = metadata.Load(e.globalConfig.MetadataPath(backupConfig.Name))
+		if err != nil {
+			fmt.Printf("Warning: ignoring metadata snapshot: %v\n", err)
+		}
+		if previous != nil {
+			fmt.Printf("Estimated size: %s in %d file(s) (as of %s)\n", utils.FormatSize(previous.TotalSize()), len(previous.Files), previous.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		snapshot = metadata.New(backupConfig.Name)
+	}
+
+	opts := e.copyOptions(backupConfig)
+	if e.verbose {
+		opts.Skipped = func(relPath, reason string) {
+			fmt.Printf("  skip %s: %s\n", relPath, reason)
+		}
+	}
+	walk := func(visit func(relPath string, info os.FileInfo) error) error {
+		return WalkDirectory(source, opts, func(relPath string, info os.FileInfo) error {
+			if snapshot != nil {
+				snapshot.Record(relPath, info)
+			}
+			stats.add(info)
+			if !info.IsDir() {
+				reporter.AddFile()
+			}
+			return visit(relPath, info)
+		})
+	}
+
+	if err := treeCompressor.CompressTree(source, walk, destinationPath); err != nil {
+		return nil, nil, err
+	}
+
+	var changes *metadata.Changes
+	if snapshot != nil && previous != nil {
+		diff := snapshot.Diff(previous)
+		changes = &diff
+		fmt.Printf("Changes since previous run: %s\n", changes)
+	}
+
+	return snapshot, changes, nil
+}
@@ -0,0 +1,83 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/config"
+)
+
+// Explanation - попадет ли путь в архив бэкапа и почему
+type Explanation struct {
+	// RelPath - путь относительно source_dir
+	RelPath  string
+	Included bool
+	Reason   string
+}
+
+// ExplainPath применяет к path те же правила, что и обход источника:
+// exclude_patterns проверяются для каждой родительской директории (исключенная
+// директория не обходится), затем для самого пути и его типа.
+// path задается абсолютным или относительно source_dir
+func ExplainPath(backupConfig *config.BackupConfig, path string) (Explanation, error) {
+	if backupConfig.SourceDir == "" {
+		return Explanation{}, fmt.Errorf("backup %s has no source_dir", backupConfig.Name)
+	}
+
+	source, err := filepath.Abs(backupConfig.SourceDir)
+	if err != nil {
+		return Explanation{}, fmt.Errorf("failed to get absolute path for source: %w", err)
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(source, path)
+	}
+	relPath, err := filepath.Rel(source, filepath.Clean(path))
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return Explanation{}, fmt.Errorf("%s is outside source_dir %s", path, source)
+	}
+
+	result := Explanation{RelPath: relPath}
+	if relPath == "." {
+		result.Included = true
+		result.Reason = "source_dir itself"
+		return result, nil
+	}
+
+	parts := strings.Split(relPath, string(filepath.Separator))
+	for i := range parts {
+		prefix := filepath.Join(parts[:i+1]...)
+		pattern, excluded := matchExclude(prefix, backupConfig.ExcludePatterns)
+		if !excluded {
+			continue
+		}
+		if prefix == relPath {
+			result.Reason = fmt.Sprintf("excluded by pattern %q", pattern)
+		} else {
+			result.Reason = fmt.Sprintf("parent directory %s is excluded by pattern %q", prefix, pattern)
+		}
+		return result, nil
+	}
+
+	info, err := os.Lstat(filepath.Join(source, relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.Included = true
+			result.Reason = "no exclude pattern matches (path does not exist yet)"
+			return result, nil
+		}
+		result.Reason = fmt.Sprintf("unreadable: %v", unwrapPathError(err))
+		return result, nil
+	}
+
+	if kind := specialFileKind(info.Mode()); kind != "" {
+		result.Reason = "special file (" + kind + ")"
+		return result, nil
+	}
+
+	result.Included = true
+	result.Reason = "no exclude pattern matches"
+	return result, nil
+}
@@ -0,0 +1,25 @@
+//go:build linux
+
+package backup
+
+import "syscall"
+
+// Magic-числа сетевых файловых систем из statfs(2)
+var networkFilesystems = map[int64]string{
+	0x6969:     "nfs",
+	0xFF534D42: "cifs",
+	0xFE534D42: "smb2",
+	0x517B:     "smb",
+	0x65735546: "fuse",
+	0x564c:     "ncp",
+}
+
+// detectNetworkFilesystem возвращает имя сетевой ФС, на которой находится path,
+// или пустую строку для локальных ФС
+func detectNetworkFilesystem(path string) string {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return ""
+	}
+	return networkFilesystems[int64(stat.Type)]
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package backup
+
+// detectNetworkFilesystem не поддерживается вне Linux
+func detectNetworkFilesystem(path string) string {
+	return ""
+}
@@ -0,0 +1,33 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"goback/config"
+	"goback/hold"
+	"goback/retention"
+)
+
+// heldArchives возвращает проверку удержаний (goback hold) для retention.
+// Удержанные архивы, которые политика удалила бы, выводятся вместе с удержанием.
+// Если файл удержаний не читается, retention не удаляет ничего
+func heldArchives(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, verb string) func(retention.BackupFile) bool {
+	holds, err := hold.Load(globalConfig.HoldsPath())
+	if err != nil {
+		fmt.Printf("Warning: %v; retention %s not remove any archives\n", err, verb)
+		return func(retention.BackupFile) bool { return true }
+	}
+
+	now := time.Now()
+	return func(file retention.BackupFile) bool {
+		name := filepath.Base(file.Path)
+		h := hold.Find(holds, backupConfig.Name, name, now)
+		if h == nil {
+			return false
+		}
+		fmt.Printf("Held by %s until %s: %s\n", h.ID, h.Until.Format("2006-01-02 15:04"), name)
+		return true
+	}
+}
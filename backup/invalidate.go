@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"goback/config"
+)
+
+// InvalidateState сбрасывает сохраненное состояние бэкапа: снимок метаданных
+// источника удаляется, а следующий снимок incremental: hardlink копируется
+// целиком, без жестких ссылок на предыдущий. Нужно, когда источник восстановлен
+// из другого места и старое состояние ему не соответствует. Возвращает список
+// сброшенного для вывода
+func InvalidateState(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig) ([]string, error) {
+	var dropped []string
+
+	metadataPath := globalConfig.MetadataPath(backupConfig.Name)
+	if err := os.Remove(metadataPath); err == nil {
+		dropped = append(dropped, "metadata snapshot")
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove metadata snapshot: %w", err)
+	}
+
+	// Метка живет до следующего успешного бэкапа, который ее снимает
+	markerPath := globalConfig.InvalidationPath(backupConfig.Name)
+	if err := os.MkdirAll(filepath.Dir(markerPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(markerPath, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to mark backup state invalidated: %w", err)
+	}
+	if backupConfig.Incremental == config.IncrementalHardlink {
+		dropped = append(dropped, "hardlink base (next snapshot is a full copy)")
+	}
+
+	return dropped, nil
+}
+
+// invalidated сообщает, что состояние бэкапа сброшено через InvalidateState
+func (e *Executor) invalidated(backupConfig *config.BackupConfig) bool {
+	_, err := os.Stat(e.globalConfig.InvalidationPath(backupConfig.Name))
+	return err == nil
+}
+
+// clearInvalidation снимает метку после успешного полного бэкапа
+func (e *Executor) clearInvalidation(backupConfig *config.BackupConfig) {
+	if err := os.Remove(e.globalConfig.InvalidationPath(backupConfig.Name)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Warning: failed to clear invalidation mark: %v\n", err)
+	}
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/catalog"
+	"goback/config"
+	"goback/hold"
+	"goback/retention"
+)
+
+// Уровни retention в инвентаре помимо уровней политики (daily, monthly, ...)
+const (
+	// TierHeld - архив удерживается goback hold
+	TierHeld = "held"
+	// TierExpiring - архив будет удален при следующем применении retention
+	TierExpiring = "expiring"
+	// TierUnpruned - архив в destination с prune: false, retention к нему не применяется
+	TierUnpruned = "unpruned"
+	// TierUnknown - бэкапа или destination архива больше нет в конфигурации
+	TierUnknown = "unknown"
+)
+
+// InventoryItem - архив из каталога с уровнем retention, который его сохраняет
+type InventoryItem struct {
+	Tenant      string    `json:"tenant,omitempty"`
+	Backup      string    `json:"backup"`
+	Destination string    `json:"destination"`
+	Name        string    `json:"name"`
+	Key         string    `json:"key"`
+	Time        time.Time `json:"time"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum,omitempty"`
+	// Tier - уровни политики через запятую (daily,monthly) или один из Tier*
+	Tier string `json:"tier"`
+}
+
+// Inventory собирает все известные каталогам архивы (всех разделов клиентов)
+// и определяет для каждого, почему retention его хранит
+func Inventory(cfg *config.Config) ([]InventoryItem, error) {
+	var items []InventoryItem
+	now := time.Now()
+
+	for _, scope := range cfg.Scopes() {
+		c, err := catalog.Load(scope.Global.CatalogPath())
+		if err != nil {
+			return nil, err
+		}
+		holds, err := hold.Load(scope.Global.HoldsPath())
+		if err != nil {
+			return nil, err
+		}
+
+		backups := make(map[string]*config.BackupConfig)
+		for i := range scope.Backups {
+			backups[scope.Backups[i].Name] = &scope.Backups[i]
+		}
+		if self := scope.Global.SelfBackup; scope.Tenant == "" && self != nil && self.Enabled {
+			backups[self.Name] = &config.BackupConfig{Name: self.Name, Subdirectory: self.Subdirectory, Retention: self.Retention}
+		}
+
+		// Retention применяется к архивам одного бэкапа в одном месте хранения
+		groups := make(map[[2]string][]catalog.Entry)
+		var order [][2]string
+		for _, entry := range c.Entries {
+			group := [2]string{entry.Backup, entry.Destination}
+			if _, ok := groups[group]; !ok {
+				order = append(order, group)
+			}
+			groups[group] = append(groups[group], entry)
+		}
+
+		for _, group := range order {
+			backupCfg := backups[group[0]]
+			tiers := inventoryTiers(&scope.Global, backupCfg, group[1], groups[group], holds, now)
+			for _, entry := range groups[group] {
+				items = append(items, InventoryItem{
+					Tenant:      scope.Tenant,
+					Backup:      entry.Backup,
+					Destination: entry.Destination,
+					Name:        path.Base(entry.Key),
+					Key:         entry.Key,
+					Time:        entry.Time,
+					Size:        entry.Size,
+					Checksum:    entry.Checksum,
+					Tier:        tiers[entry.Key],
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Backup != items[j].Backup {
+			return items[i].Backup < items[j].Backup
+		}
+		if items[i].Destination != items[j].Destination {
+			return items[i].Destination < items[j].Destination
+		}
+		return items[i].Time.Before(items[j].Time)
+	})
+
+	return items, nil
+}
+
+// inventoryTiers определяет уровень retention каждого архива (по ключу) одного
+// бэкапа в одном месте хранения
+func inventoryTiers(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, destination string, entries []catalog.Entry, holds []hold.Hold, now time.Time) map[string]string {
+	tiers := make(map[string]string, len(entries))
+	setAll := func(tier string) map[string]string {
+		for _, entry := range entries {
+			tiers[entry.Key] = tier
+		}
+		return tiers
+	}
+
+	if backupConfig == nil {
+		return setAll(TierUnknown)
+	}
+
+	policy := EffectiveRetention(globalConfig, backupConfig)
+	if destination != catalog.LocalDestination {
+		var dest *config.DestinationConfig
+		for i := range backupConfig.Destinations {
+			if backupConfig.Destinations[i].Name == destination {
+				dest = &backupConfig.Destinations[i]
+			}
+		}
+		if dest == nil {
+			return setAll(TierUnknown)
+		}
+		if !dest.PruneEnabled() {
+			return setAll(TierUnpruned)
+		}
+		policy = DestinationRetention(globalConfig, backupConfig, dest)
+	}
+
+	files := make([]retention.BackupFile, 0, len(entries))
+	for _, entry := range entries {
+		files = append(files, retention.BackupFile{Path: entry.Key, Time: entry.Time, Size: entry.Size})
+	}
+
+	held := func(file retention.BackupFile) bool {
+		return hold.Find(holds, backupConfig.Name, path.Base(file.Path), now) != nil
+	}
+	removing := make(map[string]bool)
+	for _, file := range retention.Plan(files, policy, held) {
+		removing[file.Path] = true
+	}
+	reasons := retention.KeepReasons(files, policy)
+
+	for _, file := range files {
+		switch {
+		case removing[file.Path]:
+			tiers[file.Path] = TierExpiring
+		case len(reasons[file.Path]) > 0:
+			tiers[file.Path] = strings.Join(reasons[file.Path], ",")
+		case held(file):
+			tiers[file.Path] = TierHeld
+		default:
+			tiers[file.Path] = TierExpiring
+		}
+	}
+
+	return tiers
+}
@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/config"
+	"goback/retention"
+	"goback/utils"
+)
+
+// LintWarning - рискованная, но допустимая настройка бэкапа
+type LintWarning struct {
+	Backup  string
+	Message string
+}
+
+func (w LintWarning) String() string {
+	return w.Backup + ": " + w.Message
+}
+
+// lintProbes - имена, на которых проверяется, что паттерн исключает любой путь
+var lintProbes = []string{"a", "file.txt", ".hidden", filepath.Join("dir", "file.txt")}
+
+// Lint ищет в проверенной конфигурации настройки, которые не являются ошибкой,
+// но обычно приводят к потере данных или пустым архивам
+func Lint(cfg *config.Config) []LintWarning {
+	var warnings []LintWarning
+
+	for i := range cfg.Backups {
+		backupConfig := &cfg.Backups[i]
+		warn := func(format string, args ...interface{}) {
+			warnings = append(warnings, LintWarning{Backup: backupConfig.Name, Message: fmt.Sprintf(format, args...)})
+		}
+
+		// Нулевая политика удаляет все архивы, включая только что созданный;
+		// max_total_size сам по себе ничего не сохраняет
+		policy := EffectiveRetention(cfg.GlobalFor(backupConfig), backupConfig)
+		policy.MaxTotalSize = 0
+		if policy == (retention.RetentionPolicy{}) {
+			warn("retention keeps nothing: every archive, including the new one, is removed after the run")
+		}
+
+		// Архивы, из имени которых не извлекается дата, не видит retention
+		global := cfg.GlobalFor(backupConfig)
+		naming := global.FileNaming()
+		sample := naming.Generate(backupConfig.Name, time.Now()) + ".tar.gz"
+		if _, ok := retention.MatchBackupFile(sample, backupConfig.Name, naming); !ok {
+			warn("archive names like %s cannot be parsed by naming: %s: retention and restore never see them (use naming: mask)", sample, namingName(global))
+		}
+
+		if backupConfig.SourceDir != "" {
+			for _, message := range lintExcludes(backupConfig) {
+				warn("%s", message)
+			}
+		}
+
+		if !backupConfig.Command.IsZero() && backupConfig.MinArchiveSize == "" {
+			warn("command backup without min_archive_size: an empty or truncated output_file is archived as a success")
+		}
+
+		for _, dest := range backupConfig.Destinations {
+			if !dest.PruneEnabled() {
+				warn("destination %s has prune: false: uploaded archives are never removed", dest.Name)
+			}
+		}
+	}
+
+	return warnings
+}
+
+// namingName возвращает действующую стратегию имен архивов
+func namingName(global *config.GlobalConfig) string {
+	if global.Naming == "" {
+		return utils.NamingHeuristic
+	}
+	return global.Naming
+}
+
+// lintExcludes проверяет, что exclude_patterns не исключают весь источник
+func lintExcludes(backupConfig *config.BackupConfig) []string {
+	var messages []string
+
+	for _, pattern := range backupConfig.ExcludePatterns {
+		matchesAll := strings.TrimSpace(pattern) != ""
+		for _, probe := range lintProbes {
+			if _, excluded := matchExclude(probe, []string{pattern}); !excluded {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			messages = append(messages, fmt.Sprintf("exclude pattern %q matches every path: the archive is empty", pattern))
+		}
+	}
+	if len(messages) > 0 || len(backupConfig.ExcludePatterns) == 0 {
+		return messages
+	}
+
+	// Паттерны по отдельности безопасны, но вместе могут исключить все
+	// содержимое source_dir верхнего уровня
+	entries, err := os.ReadDir(backupConfig.SourceDir)
+	if err != nil || len(entries) == 0 {
+		return messages
+	}
+	for _, entry := range entries {
+		if _, excluded := matchExclude(entry.Name(), backupConfig.ExcludePatterns); !excluded {
+			return messages
+		}
+	}
+
+	return append(messages, fmt.Sprintf("exclude_patterns exclude every entry of source_dir %s: the archive is empty", backupConfig.SourceDir))
+}
@@ -0,0 +1,152 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"goback/catalog"
+	"goback/config"
+	"goback/hold"
+	"goback/metadata"
+	"goback/retention"
+	"goback/utils"
+)
+
+// ListItem - архив бэкапа, найденный в backup_dir или в destination
+type ListItem struct {
+	Backup      string
+	Destination string
+	Name        string
+	Time        time.Time
+	Size        int64
+	// Compression - тип сжатия ("directory" для format: directory), для
+	// зашифрованных архивов с типом шифрования: tar.gz+age
+	Compression string
+	// Volumes - число томов разбитого архива
+	Volumes int
+	// Tier - уровни политики через запятую или один из Tier*, как в инвентаре
+	Tier string
+}
+
+// ListArchives находит архивы бэкапа сканированием backup_dir и, с remote, его
+// destinations (без каталога) и определяет уровень retention каждого. Ошибки
+// отдельных destinations выводятся предупреждениями
+func ListArchives(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, remote bool) ([]ListItem, error) {
+	holds, err := hold.Load(globalConfig.HoldsPath())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	local, err := listLocal(globalConfig, backupConfig)
+	if err != nil {
+		return nil, err
+	}
+	items := withTiers(globalConfig, backupConfig, catalog.LocalDestination, local, holds, now)
+
+	if !remote {
+		return items, nil
+	}
+	for i := range backupConfig.Destinations {
+		dest := &backupConfig.Destinations[i]
+		target, err := newStorage(dest, nil)
+		if err != nil {
+			fmt.Printf("Warning: %s: %v\n", dest.Name, err)
+			continue
+		}
+		entries, err := catalog.Scan(target, dest.Name, backupConfig.Subdirectory, backupConfig.Name, globalConfig.FileNaming())
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		remoteItems := make([]ListItem, 0, len(entries))
+		for _, entry := range entries {
+			name := filepath.Base(entry.Key)
+			remoteItems = append(remoteItems, ListItem{
+				Backup:      backupConfig.Name,
+				Destination: dest.Name,
+				Name:        name,
+				Time:        entry.Time,
+				Size:        entry.Size,
+				Compression: compressionLabel(name, ""),
+				Volumes:     entry.Volumes,
+			})
+		}
+		items = append(items, withTiers(globalConfig, backupConfig, dest.Name, remoteItems, holds, now)...)
+	}
+
+	return items, nil
+}
+
+// listLocal возвращает архивы бэкапа в backup_dir
+func listLocal(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig) ([]ListItem, error) {
+	files, err := retention.FindBackupFiles(globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, globalConfig.FileNaming())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	items := make([]ListItem, 0, len(files))
+	for _, file := range files {
+		name := filepath.Base(file.Path)
+		item := ListItem{
+			Backup:      backupConfig.Name,
+			Destination: catalog.LocalDestination,
+			Name:        name,
+			Time:        file.Time,
+			Volumes:     len(utils.VolumeParts(file.Path)),
+		}
+
+		info, err := utils.StatArchive(file.Path)
+		switch {
+		case err != nil:
+			// Архив удален, пока шло сканирование
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		case info.IsDir():
+			item.Compression = "directory"
+			item.Size, _ = utils.DirSize(file.Path)
+		default:
+			item.Size = info.Size()
+			// Тип сжатия берем из описания архива, если оно есть
+			meta, _, _ := metadata.ReadArchive(file.Path)
+			item.Compression = compressionLabel(name, meta.Compression)
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// withTiers определяет уровень retention архивов одного места хранения
+func withTiers(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, destination string, items []ListItem, holds []hold.Hold, now time.Time) []ListItem {
+	entries := make([]catalog.Entry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, catalog.Entry{Backup: item.Backup, Destination: destination, Key: item.Name, Time: item.Time, Size: item.Size})
+	}
+	tiers := inventoryTiers(globalConfig, backupConfig, destination, entries, holds, now)
+	for i := range items {
+		items[i].Tier = tiers[items[i].Name]
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Time.Before(items[j].Time)
+	})
+	return items
+}
+
+// compressionLabel возвращает тип сжатия архива name (или compression из его
+// описания) с типом шифрования
+func compressionLabel(name, compression string) string {
+	if compression == "" {
+		compression = utils.DetectCompression(name)
+	}
+	if ext := utils.EncryptionExtension(name); ext != "" {
+		compression += "+" + ext[1:]
+	}
+	return compression
+}
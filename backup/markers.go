@@ -0,0 +1,96 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/config"
+	"goback/notify"
+	"goback/utils"
+)
+
+// writeMarkers обновляет файлы-маркеры бэкапа в markers.dir:
+//
+//	<name>.last-run     - время, статус и ошибка последнего запуска
+//	<name>.last-success - время последнего успешного запуска (меняется только при успехе)
+//	<name>.prom         - метрики для textfile collector node_exporter (markers.prometheus)
+//
+// Файлы заменяются атомарно, чтобы мониторинг не прочитал недописанный маркер
+func (e *Executor) writeMarkers(backupConfig *config.BackupConfig, result notify.Result, now time.Time) error {
+	markers := e.globalConfig.Markers
+	if markers == nil || markers.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(markers.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create markers directory: %w", err)
+	}
+
+	base := filepath.Join(markers.Dir, backupConfig.Name)
+	status := "success"
+	if !result.Success {
+		status = "failure"
+	}
+
+	lastRun := fmt.Sprintf("time=%s\ntimestamp=%d\nstatus=%s\nrun_id=%s\njob_id=%s\n", now.Format(time.RFC3339), now.Unix(), status, result.RunID, result.JobID)
+	if result.Success {
+		lastRun += fmt.Sprintf("archive=%s\nsize=%d\n", result.Archive, result.Size)
+	} else {
+		// Ошибка может быть многострочной, а маркер читается построчно
+		lastRun += fmt.Sprintf("error=%s\n", strings.ReplaceAll(result.Error, "\n", " "))
+	}
+	if err := writeMarker(base+".last-run", lastRun); err != nil {
+		return err
+	}
+
+	if result.Success {
+		if err := writeMarker(base+".last-success", fmt.Sprintf("time=%s\ntimestamp=%d\n", now.Format(time.RFC3339), now.Unix())); err != nil {
+			return err
+		}
+	}
+
+	if !markers.Prometheus {
+		return nil
+	}
+
+	// Время последнего успеха берется из маркера: после неудачного запуска оно не меняется
+	var lastSuccess int64
+	if info, err := os.Stat(base + ".last-success"); err == nil {
+		lastSuccess = info.ModTime().Unix()
+	}
+	success := 0
+	if result.Success {
+		success = 1
+	}
+
+	label := fmt.Sprintf("{backup=%q}", backupConfig.Name)
+	var metrics strings.Builder
+	metrics.WriteString("# HELP goback_last_run_timestamp_seconds Time of the last backup run.\n")
+	metrics.WriteString("# TYPE goback_last_run_timestamp_seconds gauge\n")
+	fmt.Fprintf(&metrics, "goback_last_run_timestamp_seconds%s %d\n", label, now.Unix())
+	metrics.WriteString("# HELP goback_last_run_success Whether the last backup run succeeded.\n")
+	metrics.WriteString("# TYPE goback_last_run_success gauge\n")
+	fmt.Fprintf(&metrics, "goback_last_run_success%s %d\n", label, success)
+	metrics.WriteString("# HELP goback_last_success_timestamp_seconds Time of the last successful backup run.\n")
+	metrics.WriteString("# TYPE goback_last_success_timestamp_seconds gauge\n")
+	fmt.Fprintf(&metrics, "goback_last_success_timestamp_seconds%s %d\n", label, lastSuccess)
+	if result.Success {
+		metrics.WriteString("# HELP goback_last_archive_size_bytes Size of the last archive.\n")
+		metrics.WriteString("# TYPE goback_last_archive_size_bytes gauge\n")
+		fmt.Fprintf(&metrics, "goback_last_archive_size_bytes%s %d\n", label, result.Size)
+	}
+
+	return writeMarker(base+".prom", metrics.String())
+}
+
+// writeMarker атомарно заменяет файл маркера; временный файл не имеет
+// расширения .prom, поэтому node_exporter его не читает
+func writeMarker(path, content string) error {
+	if err := utils.WriteFileAtomic(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write marker: %w", err)
+	}
+	return nil
+}
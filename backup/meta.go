@@ -0,0 +1,100 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"goback/config"
+	"goback/metadata"
+	"goback/utils"
+)
+
+// archiveStats - сколько данных источника попало в архив
+type archiveStats struct {
+	files int64
+	bytes int64
+}
+
+// add учитывает элемент дерева источника; директории не считаются
+func (s *archiveStats) add(info os.FileInfo) {
+	if info.IsDir() {
+		return
+	}
+	s.files++
+	if info.Mode().IsRegular() {
+		s.bytes += info.Size()
+	}
+}
+
+// countingReader считает байты потока дампа
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// writeArchiveMeta сохраняет рядом с архивом <archive>.meta.json с описанием запуска
+func (e *Executor) writeArchiveMeta(backupConfig *config.BackupConfig, archivePath, compressionType, archiveChecksum string, size int64, stats archiveStats, startedAt time.Time) {
+	hostname, _ := os.Hostname()
+	meta := metadata.Archive{
+		Backup:           backupConfig.Name,
+		Source:           backupConfig.SourceDir,
+		StartedAt:        startedAt,
+		FinishedAt:       time.Now(),
+		Files:            stats.files,
+		UncompressedSize: stats.bytes,
+		CompressedSize:   size,
+		Compression:      compressionType,
+		Volumes:          len(utils.VolumeParts(archivePath)),
+		Checksum:         archiveChecksum,
+		RunID:            e.runID,
+		JobID:            e.jobID(backupConfig.Name),
+		GobackVersion:    utils.Version,
+		Hostname:         hostname,
+	}
+	if backupConfig.IsDump() {
+		meta.Dump = backupConfig.Type
+	} else if backupConfig.SourceDir == "" {
+		meta.Command = backupConfig.Command.String()
+	}
+	if backupConfig.Encryption != nil {
+		meta.Encryption = "age"
+		if backupConfig.Encryption.Type != "" {
+			meta.Encryption = backupConfig.Encryption.Type
+		}
+	}
+
+	if err := metadata.WriteArchive(archivePath, meta); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// moveArchiveMeta переносит описание архива oldPath к newPath, обновляя его через
+// update (после recompress или rekey); архив без описания пропускается
+func (e *Executor) moveArchiveMeta(oldPath, newPath string, update func(*metadata.Archive)) {
+	meta, ok, err := metadata.ReadArchive(oldPath)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if !ok {
+		return
+	}
+
+	update(&meta)
+	if err := metadata.WriteArchive(newPath, meta); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	if oldPath != newPath {
+		if err := metadata.ForgetArchive(oldPath); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
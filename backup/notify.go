@@ -0,0 +1,174 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"goback/config"
+	"goback/history"
+	"goback/notify"
+)
+
+// notify отправляет итог бэкапа в его notifications и в глобальные с events: [backup]
+// и запоминает его для итога запуска. Ошибка отправки не влияет на результат бэкапа
+func (e *Executor) notify(backupConfig *config.BackupConfig, result notify.Result, backupErr error) {
+	result.Success = backupErr == nil
+	result.Message = fmt.Sprintf("backup %s completed in %s", backupConfig.Name, result.Duration.Round(time.Second))
+	if backupErr != nil {
+		result.Error = backupErr.Error()
+		result.Message = result.Error
+	}
+
+	e.mu.Lock()
+	e.results = append(e.results, result)
+	e.mu.Unlock()
+
+	// История запусков - основа периодической сводки (digest)
+	now := time.Now()
+	var archivePath string
+	if result.Archive != "" {
+		archivePath = filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory, result.Archive)
+	}
+	if err := history.Append(e.globalConfig.HistoryPath(), history.Record{
+		Backup:   result.Backup,
+		RunID:    result.RunID,
+		JobID:    result.JobID,
+		Started:  now.Add(-result.Duration),
+		Time:     now,
+		Success:  result.Success,
+		Archive:  result.Archive,
+		Path:     archivePath,
+		Size:     result.Size,
+		Duration: result.Duration,
+		Error:    result.Error,
+	}); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if err := e.writeMarkers(backupConfig, result, now); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	var notifications []config.NotificationConfig
+	notifications = append(notifications, backupConfig.Notifications...)
+	for _, notification := range e.globalConfig.Notifications {
+		if notification.On("backup") {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	e.send(notifications, result.Success, func(notifier notify.Notifier) error {
+		return notifier.Notify(result)
+	})
+
+	event := notify.PingSuccess
+	if !result.Success {
+		event = notify.PingFail
+	}
+	e.pingHealthcheck(backupConfig, event, result.Message)
+}
+
+// pingHealthcheck отправляет событие в healthcheck_url бэкапа. Ошибка ping не влияет
+// на результат бэкапа: пропущенный ping сервис сам сочтет сбоем
+func (e *Executor) pingHealthcheck(backupConfig *config.BackupConfig, event, message string) {
+	if backupConfig.HealthcheckURL == "" || e.dryRun {
+		return
+	}
+	if e.offline {
+		fmt.Printf("Healthcheck ping skipped: offline mode\n")
+		return
+	}
+
+	healthcheck, err := notify.NewHealthcheck(backupConfig.HealthcheckURL)
+	if err == nil {
+		err = healthcheck.Ping(event, message)
+	}
+	if err != nil {
+		fmt.Printf("Warning: healthcheck %s ping failed: %v\n", event, err)
+	}
+}
+
+// NotifyRun отправляет итог всех выполненных бэкапов в глобальные notifications
+// (events: [run]); skipped - бэкапы, не запущенные из-за окна бэкапа, paused -
+// приостановленные в конфигурации
+func (e *Executor) NotifyRun(skipped, paused []string) {
+	if e.dryRun {
+		return
+	}
+
+	var notifications []config.NotificationConfig
+	for _, notification := range e.globalConfig.Notifications {
+		if notification.On("run") {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	e.mu.Lock()
+	summary := notify.Summary{
+		RunID:    e.runID,
+		Results:  append([]notify.Result(nil), e.results...),
+		Skipped:  skipped,
+		Paused:   paused,
+		Duration: time.Since(e.startedAt),
+	}
+	e.mu.Unlock()
+
+	e.send(notifications, summary.Success(), func(notifier notify.Notifier) error {
+		return notifier.NotifyRun(summary)
+	})
+}
+
+// NotifyReloadRejected сообщает глобальным notifications с events: [reload], что
+// демон не принял измененную конфигурацию path и продолжает работать со старой
+func (e *Executor) NotifyReloadRejected(path string, reloadErr error) {
+	var notifications []config.NotificationConfig
+	for _, notification := range e.globalConfig.Notifications {
+		if notification.On("reload") {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	reload := notify.Reload{Config: path, Error: reloadErr.Error()}
+	e.send(notifications, false, func(notifier notify.Notifier) error {
+		return notifier.NotifyReload(reload)
+	})
+}
+
+// send вызывает fn для каждого уведомления; only_on_failure пропускает успешные итоги
+func (e *Executor) send(notifications []config.NotificationConfig, success bool, fn func(notifier notify.Notifier) error) {
+	if success {
+		var filtered []config.NotificationConfig
+		for _, notification := range notifications {
+			if !notification.OnlyOnFailure {
+				filtered = append(filtered, notification)
+			}
+		}
+		notifications = filtered
+	}
+
+	if len(notifications) == 0 {
+		return
+	}
+
+	if e.offline {
+		fmt.Printf("Notifications skipped: offline mode\n")
+		return
+	}
+
+	for i := range notifications {
+		notification := &notifications[i]
+		name := notification.Name
+		if name == "" {
+			name = notification.Type
+		}
+
+		notifier, err := notify.New(notification.Type, notification.Options())
+		if err == nil {
+			err = fn(notifier)
+		}
+		if err != nil {
+			fmt.Printf("Warning: notification %s failed: %v\n", name, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package backup
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goback/catalog"
+	"goback/config"
+	"goback/retention"
+	"goback/storage"
+	"goback/utils"
+)
+
+// Prune применяет retention к архивам бэкапа в backup_dir и в destinations без
+// создания нового архива и выводит решение по каждому архиву: почему он удаляется
+// или какие уровни политики его сохраняют. В режиме dry-run ничего не удаляется.
+// Возвращает число удаленных (в dry-run - подлежащих удалению) архивов
+func (e *Executor) Prune(backupConfig *config.BackupConfig) (int, error) {
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	total := 0
+	var failed []string
+	if len(files) == 0 {
+		fmt.Printf("No archives\n")
+	} else {
+		n, err := e.pruneFiles(backupConfig, files, EffectiveRetention(e.globalConfig, backupConfig), true, func(toRemove []retention.BackupFile) []string {
+			removed := retention.RemoveFiles(toRemove)
+			keys := make([]string, 0, len(removed))
+			for _, path := range removed {
+				keys = append(keys, filepath.Join(backupConfig.Subdirectory, filepath.Base(path)))
+			}
+			e.forgetArchives(catalog.LocalDestination, keys)
+			return removed
+		})
+		total += n
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	for i := range backupConfig.Destinations {
+		dest := &backupConfig.Destinations[i]
+		if !dest.PruneEnabled() || (e.offline && isNetworkDestination(dest)) {
+			continue
+		}
+		fmt.Printf("Destination %s:\n", dest.Name)
+		n, err := e.pruneDestination(backupConfig, dest, true)
+		total += n
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", dest.Name, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return total, fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return total, nil
+}
+
+// applyRemoteRetention применяет retention к архивам бэкапа в destinations после
+// загрузки нового. skip - destinations, куда новый архив не попал в этом запуске:
+// их архивы не удаляются, пока там не появится свежая копия
+func (e *Executor) applyRemoteRetention(backupConfig *config.BackupConfig, skip map[string]bool) {
+	for i := range backupConfig.Destinations {
+		dest := &backupConfig.Destinations[i]
+		if !dest.PruneEnabled() || skip[dest.Name] || (e.offline && isNetworkDestination(dest)) {
+			continue
+		}
+		if _, err := e.pruneDestination(backupConfig, dest, false); err != nil {
+			fmt.Printf("Warning: retention in destination %s failed: %v\n", dest.Name, err)
+		}
+	}
+}
+
+// pruneDestination находит архивы бэкапа в destination по тому же соглашению об
+// именах, что и в backup_dir, и удаляет лишние по политике destination
+func (e *Executor) pruneDestination(backupConfig *config.BackupConfig, dest *config.DestinationConfig, explain bool) (int, error) {
+	target, err := newStorage(dest, e.globalConfig.ThrottleSchedule())
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := catalog.Scan(target, dest.Name, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		if explain {
+			fmt.Printf("No archives\n")
+		}
+		return 0, nil
+	}
+
+	files := make([]retention.BackupFile, 0, len(entries))
+	volumes := make(map[string]int)
+	for _, entry := range entries {
+		files = append(files, retention.BackupFile{Path: entry.Key, Time: entry.Time, Size: entry.Size})
+		volumes[entry.Key] = entry.Volumes
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.Before(files[j].Time)
+	})
+
+	return e.pruneFiles(backupConfig, files, DestinationRetention(e.globalConfig, backupConfig, dest), explain, func(toRemove []retention.BackupFile) []string {
+		var removed []string
+		for _, file := range toRemove {
+			// Разбитый архив удаляется всеми томами
+			keys := []string{file.Path}
+			if n := volumes[file.Path]; n > 0 {
+				keys = keys[:0]
+				for i := 1; i <= n; i++ {
+					keys = append(keys, utils.VolumeName(file.Path, i))
+				}
+			}
+			if err := deleteKeys(target, keys); err != nil {
+				fmt.Printf("Warning: failed to remove old backup from %s: %v\n", dest.Name, err)
+				continue
+			}
+			fmt.Printf("Removed old backup from %s: %s\n", dest.Name, filepath.Base(file.Path))
+			removed = append(removed, file.Path)
+		}
+		e.forgetArchives(dest.Name, removed)
+		return removed
+	})
+}
+
+// pruneFiles применяет policy к архивам files одного места хранения и удаляет
+// лишние через remove (возвращает удаленные). С explain выводит решение по
+// каждому архиву. Возвращает число удаленных (в dry-run - подлежащих удалению)
+func (e *Executor) pruneFiles(backupConfig *config.BackupConfig, files []retention.BackupFile, policy retention.RetentionPolicy, explain bool, remove func([]retention.BackupFile) []string) (int, error) {
+	verb := "will"
+	if e.dryRun {
+		verb = "would"
+	}
+
+	// Запоминаем архивы, которые политика удалила бы, но сохранило удержание
+	held := heldArchives(e.globalConfig, backupConfig, verb)
+	heldFiles := make(map[string]bool)
+	toRemove := retention.Plan(files, policy, func(file retention.BackupFile) bool {
+		if held(file) {
+			heldFiles[file.Path] = true
+			return true
+		}
+		return false
+	})
+	tiers := retention.KeepReasons(files, policy)
+
+	removing := make(map[string]bool, len(toRemove))
+	for _, file := range toRemove {
+		removing[file.Path] = true
+	}
+
+	for _, file := range files {
+		if explain {
+			explainDecision(file, removing[file.Path], heldFiles[file.Path], tiers[file.Path], policy)
+		}
+	}
+
+	if e.dryRun || len(toRemove) == 0 {
+		return len(toRemove), nil
+	}
+
+	removed := remove(toRemove)
+	if len(removed) < len(toRemove) {
+		return len(removed), fmt.Errorf("failed to remove %d archive(s)", len(toRemove)-len(removed))
+	}
+	return len(removed), nil
+}
+
+// explainDecision выводит, почему архив удаляется или что его сохраняет
+func explainDecision(file retention.BackupFile, remove, held bool, keptBy []string, policy retention.RetentionPolicy) {
+	name := filepath.Base(file.Path)
+	switch {
+	case remove && len(keptBy) > 0:
+		// Календарные правила архив сохраняют, но он не помещается в предел размера
+		fmt.Printf("  remove  %s  (over max_total_size %s)\n", name, utils.FormatSize(policy.MaxTotalSize))
+	case remove:
+		fmt.Printf("  remove  %s  (not kept by any tier)\n", name)
+	case held:
+		fmt.Printf("  keep    %s  (held)\n", name)
+	default:
+		fmt.Printf("  keep    %s  (%s)\n", name, strings.Join(keptBy, ", "))
+	}
+}
+
+// deleteKeys удаляет объекты keys, останавливаясь на первой ошибке
+func deleteKeys(target storage.Storage, keys []string) error {
+	for _, key := range keys {
+		if err := target.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/config"
+)
+
+// CheckReadOnly проверяет перед запуском бэкапа с read_only, что goback ничего
+// не запишет в source_dir. В отличие от проверки конфигурации пути сравниваются
+// после разрешения симлинков. hooks - будут ли выполняться хуки: они запускаются
+// в текущей директории, и относительные пути в них не должны вести в источник
+func CheckReadOnly(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, hooks bool) error {
+	if !backupConfig.IsReadOnly(globalConfig) {
+		return nil
+	}
+
+	source := resolvePath(backupConfig.SourceDir)
+	writes := []struct{ name, dir string }{
+		{"backup_dir", filepath.Join(globalConfig.BackupDir, backupConfig.Subdirectory)},
+		{"state_dir", globalConfig.StateDir},
+	}
+	if globalConfig.Markers != nil {
+		writes = append(writes, struct{ name, dir string }{"markers.dir", globalConfig.Markers.Dir})
+	}
+	for _, write := range writes {
+		dir := resolvePath(write.dir)
+		if isWithin(dir, source) || isWithin(source, dir) {
+			return fmt.Errorf("read_only: %s %s resolves into source_dir %s", write.name, write.dir, backupConfig.SourceDir)
+		}
+	}
+
+	if hooks {
+		if wd, err := os.Getwd(); err == nil && isWithin(resolvePath(wd), source) {
+			return fmt.Errorf("read_only: hooks would run with working directory %s inside source_dir %s", wd, backupConfig.SourceDir)
+		}
+	}
+
+	return nil
+}
+
+// WritesIntoSource сообщает, что запись в path попадет в source_dir бэкапа
+// с read_only (например, восстановление на исходное место)
+func WritesIntoSource(globalConfig *config.GlobalConfig, backupConfig *config.BackupConfig, path string) bool {
+	if !backupConfig.IsReadOnly(globalConfig) {
+		return false
+	}
+	target, source := resolvePath(path), resolvePath(backupConfig.SourceDir)
+	return isWithin(target, source) || isWithin(source, target)
+}
+
+// resolvePath возвращает абсолютный путь с разрешенными симлинками; для еще не
+// существующего пути разрешается ближайший существующий родитель
+func resolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if dir == filepath.Dir(dir) {
+			return abs
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// isWithin сообщает, что path совпадает с dir или вложен в него
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
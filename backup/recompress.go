@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/catalog"
+	"goback/checksum"
+	"goback/compression"
+	"goback/config"
+	"goback/metadata"
+	"goback/retention"
+	"goback/utils"
+)
+
+// RecompressArchives перепаковывает архивы бэкапа в backup_dir, сжатые from, в
+// алгоритм to (tar.gz -> tar.zst, .gz -> .zst и т.п.). Новый архив проверяется до
+// удаления старого; контрольные суммы и каталог обновляются под новое имя.
+// Возвращает число перепакованных архивов
+func (e *Executor) RecompressArchives(backupConfig *config.BackupConfig, from, to string) (int, error) {
+	dir := filepath.Join(e.globalConfig.BackupDir, backupConfig.Subdirectory)
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	converted := 0
+	var failed []string
+	for _, file := range files {
+		name := filepath.Base(file.Path)
+
+		if len(utils.VolumeParts(file.Path)) > 0 {
+			fmt.Printf("  skip %s: split archives cannot be recompressed\n", name)
+			continue
+		}
+		info, err := os.Stat(file.Path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		sourceType := utils.DetectCompression(name)
+		if compression.Codec(sourceType) != from {
+			continue
+		}
+		if utils.IsEncrypted(name) {
+			fmt.Printf("  skip %s: encrypted archives cannot be recompressed\n", name)
+			continue
+		}
+		targetType, ok := compression.ConvertType(sourceType, to)
+		if !ok {
+			fmt.Printf("  skip %s: %s cannot be converted to %s\n", name, sourceType, to)
+			continue
+		}
+
+		newName := strings.TrimSuffix(name, utils.GetExtension(sourceType)) + utils.GetExtension(targetType)
+		newPath := filepath.Join(dir, newName)
+		if _, err := os.Stat(newPath); err == nil {
+			fmt.Printf("  skip %s: %s already exists\n", name, newName)
+			continue
+		}
+
+		if err := e.recompressArchive(backupConfig, file, newPath, sourceType, targetType, info); err != nil {
+			utils.PrintError("  FAILED %s: %v", name, err)
+			failed = append(failed, name)
+			continue
+		}
+
+		converted++
+	}
+
+	if len(failed) > 0 {
+		return converted, fmt.Errorf("failed to recompress: %s", strings.Join(failed, ", "))
+	}
+
+	return converted, nil
+}
+
+// recompressArchive перепаковывает один архив через временный файл и заменяет им исходный
+func (e *Executor) recompressArchive(backupConfig *config.BackupConfig, file retention.BackupFile, newPath, sourceType, targetType string, info os.FileInfo) error {
+	// Временный файл игнорируется retention и сканированием до переименования
+	tmpPath := newPath + ".tmp"
+	if err := compression.Recompress(file.Path, tmpPath, sourceType, targetType, e.compressionOptions(backupConfig, targetType)); err != nil {
+		return err
+	}
+
+	// Время изменения сохраняется, чтобы архив остался "старым" для внешних инструментов
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		fmt.Printf("Warning: failed to preserve modification time: %v\n", err)
+	}
+
+	if err := utils.CommitArchive(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename recompressed archive: %w", err)
+	}
+
+	if err := os.Remove(file.Path); err != nil {
+		return fmt.Errorf("failed to remove original archive: %w", err)
+	}
+	if err := checksum.Forget(file.Path); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	newInfo, err := os.Stat(newPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat recompressed archive: %w", err)
+	}
+
+	sum := e.fileChecksum(newPath)
+	e.writeChecksumManifest(newPath, sum)
+	e.moveArchiveMeta(file.Path, newPath, func(meta *metadata.Archive) {
+		meta.Compression = targetType
+		meta.CompressedSize = newInfo.Size()
+		meta.Checksum = sum
+	})
+	e.forgetArchives(catalog.LocalDestination, []string{filepath.Join(backupConfig.Subdirectory, filepath.Base(file.Path))})
+	e.recordArchive(backupConfig.Name, catalog.LocalDestination, filepath.Join(backupConfig.Subdirectory, filepath.Base(newPath)), newInfo.Size(), sum, file.Time)
+
+	fmt.Printf("  %s -> %s (%s -> %s)\n", filepath.Base(file.Path), filepath.Base(newPath), utils.FormatSize(info.Size()), utils.FormatSize(newInfo.Size()))
+	return nil
+}
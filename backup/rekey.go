@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/catalog"
+	"goback/config"
+	"goback/encryption"
+	"goback/metadata"
+	"goback/retention"
+	"goback/utils"
+)
+
+// RekeyOptions - параметры goback rekey
+type RekeyOptions struct {
+	// IdentityFile и Passphrase - старые ключи age, которыми расшифровываются архивы
+	// (по умолчанию - пароль из конфигурации); архивы gpg расшифровываются ключами keyring
+	IdentityFile string
+	Passphrase   string
+	// All перешифровывает и архивы, уже зашифрованные текущими ключами
+	All    bool
+	DryRun bool
+}
+
+// RekeyArchives перешифровывает архивы бэкапа в backup_dir текущими ключами
+// encryption после их ротации. Архивы, запись о ключах которых совпадает с
+// конфигурацией, пропускаются. Возвращает число перешифрованных (в dry-run -
+// подлежащих перешифровке) архивов
+func (e *Executor) RekeyArchives(backupConfig *config.BackupConfig, opts RekeyOptions) (int, error) {
+	if backupConfig.Encryption == nil {
+		return 0, fmt.Errorf("backup %s has no encryption configured", backupConfig.Name)
+	}
+	encryptor, err := encryption.NewEncryptor(backupConfig.Encryption.Type, backupConfig.Encryption.Options())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create encryptor: %w", err)
+	}
+	record := encryption.NewKeyRecord(backupConfig.Encryption.Type, backupConfig.Encryption.Options())
+	if opts.Passphrase == "" {
+		opts.Passphrase = backupConfig.Encryption.Passphrase
+	}
+
+	files, err := retention.FindBackupFiles(e.globalConfig.BackupDir, backupConfig.Subdirectory, backupConfig.Name, e.globalConfig.FileNaming())
+	if err != nil {
+		return 0, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	rekeyed := 0
+	var failed []string
+	for _, file := range files {
+		name := filepath.Base(file.Path)
+		if !utils.IsEncrypted(name) {
+			continue
+		}
+		if ext := utils.EncryptionExtension(name); ext != encryptor.Extension() {
+			fmt.Printf("  skip %s: %s archives cannot be re-encrypted as %s\n", name, ext, encryptor.Extension())
+			continue
+		}
+
+		current, ok, err := encryption.ReadKeyRecord(file.Path)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		if ok && current.Matches(record.Keys) && !opts.All {
+			continue
+		}
+		previous := "unknown keys"
+		if ok {
+			previous = strings.Join(current.Keys, ", ")
+		}
+
+		if e.dryRun || opts.DryRun {
+			fmt.Printf("  would rekey %s (%s)\n", name, previous)
+			rekeyed++
+			continue
+		}
+
+		if err := e.rekeyArchive(backupConfig, file, encryptor, record, opts); err != nil {
+			utils.PrintError("  FAILED %s: %v", name, err)
+			failed = append(failed, name)
+			continue
+		}
+		fmt.Printf("  %s (%s -> %s)\n", name, previous, strings.Join(record.Keys, ", "))
+		rekeyed++
+	}
+
+	if len(failed) > 0 {
+		return rekeyed, fmt.Errorf("failed to rekey: %s", strings.Join(failed, ", "))
+	}
+	return rekeyed, nil
+}
+
+// rekeyArchive расшифровывает архив во временную директорию, шифрует заново
+// и заменяет исходный архив (и его тома) результатом
+func (e *Executor) rekeyArchive(backupConfig *config.BackupConfig, file retention.BackupFile, encryptor encryption.Encryptor, record encryption.KeyRecord, opts RekeyOptions) error {
+	// Префикс goback-decrypt-* - временную директорию прерванного запуска уберет goback repair
+	tmpDir, err := os.MkdirTemp("", "goback-decrypt-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	name := filepath.Base(file.Path)
+	oldParts := utils.VolumeParts(file.Path)
+	encryptedPath := file.Path
+	if len(oldParts) > 0 {
+		encryptedPath = filepath.Join(tmpDir, name)
+		if err := utils.JoinArchive(file.Path, encryptedPath); err != nil {
+			return err
+		}
+	}
+
+	plainPath := filepath.Join(tmpDir, strings.TrimSuffix(name, encryptor.Extension()))
+	if err := encryption.Decrypt(encryptedPath, plainPath, opts.IdentityFile, opts.Passphrase); err != nil {
+		return fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	// Новый архив шифруется во временный файл рядом с исходным и заменяет его
+	// переименованием, так что исходный архив цел до последнего шага
+	tmpPath := file.Path + ".tmp"
+	if err := encryptor.Encrypt(plainPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+	if err := replaceArchive(tmpPath, file.Path, oldParts, backupConfig.VolumeSize(e.globalConfig)); err != nil {
+		return err
+	}
+
+	if err := encryption.WriteKeyRecord(file.Path, record); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	info, err := utils.StatArchive(file.Path)
+	if err != nil {
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+	sum := e.fileChecksum(file.Path)
+	e.writeChecksumManifest(file.Path, sum)
+	e.moveArchiveMeta(file.Path, file.Path, func(meta *metadata.Archive) {
+		meta.CompressedSize = info.Size()
+		meta.Volumes = len(utils.VolumeParts(file.Path))
+		meta.Checksum = sum
+	})
+	e.recordArchive(backupConfig.Name, catalog.LocalDestination, filepath.Join(backupConfig.Subdirectory, name), info.Size(), sum, file.Time)
+	return nil
+}
+
+// replaceArchive заменяет архив path (с томами oldParts) файлом source,
+// разбивая его на тома по splitSize, и удаляет лишние старые тома
+func replaceArchive(source, path string, oldParts []string, splitSize int64) error {
+	defer os.Remove(source)
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	newParts := 0
+	if splitSize > 0 && info.Size() > splitSize {
+		src, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		// Тома пишутся как .partNNN.tmp и переименовываются поверх старых
+		writer := utils.CreateVolumes(path, splitSize)
+		if _, err := io.Copy(writer, src); err != nil {
+			writer.Abort()
+			return fmt.Errorf("failed to write volumes: %w", err)
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to write volumes: %w", err)
+		}
+		newParts = writer.Volumes()
+		// Архив был одним файлом - теперь его место заняли тома
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := os.Rename(source, path); err != nil {
+		return fmt.Errorf("failed to replace archive: %w", err)
+	}
+
+	for _, part := range oldParts[min(newParts, len(oldParts)):] {
+		if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/catalog"
+	"goback/checksum"
+	"goback/config"
+	"goback/lock"
+	"goback/storage"
+	"goback/utils"
+)
+
+// DefaultRepairAge - следы моложе этого считаются принадлежащими выполняющемуся запуску
+const DefaultRepairAge = time.Hour
+
+// tempDirPatterns - временные директории, которые goback создает в os.TempDir()
+var tempDirPatterns = []string{"backup-stage-*", "goback-self-*", "goback-drill-*", "goback-decrypt-*"}
+
+// RepairOptions - параметры goback repair
+type RepairOptions struct {
+	// OlderThan - минимальный возраст следа прерванной операции
+	OlderThan time.Duration
+	// DryRun только сообщает о найденном
+	DryRun bool
+	// Offline пропускает сетевые destinations
+	Offline bool
+}
+
+// RepairAction - найденный след прерванной операции или расхождение каталога
+type RepairAction struct {
+	// Kind - temp-dir, temp-file, lock, upload или catalog
+	Kind   string
+	Target string
+	Detail string
+}
+
+func (a RepairAction) String() string {
+	if a.Detail == "" {
+		return fmt.Sprintf("%s %s", a.Kind, a.Target)
+	}
+	return fmt.Sprintf("%s %s: %s", a.Kind, a.Target, a.Detail)
+}
+
+// RepairTempDirs удаляет временные директории прерванных запусков (staging
+// destinations, self backup, drill, расшифровка при восстановлении), которые
+// не менялись дольше opts.OlderThan
+func RepairTempDirs(opts RepairOptions) ([]RepairAction, error) {
+	var actions []RepairAction
+	var failed []string
+
+	for _, pattern := range tempDirPatterns {
+		dirs, err := filepath.Glob(filepath.Join(os.TempDir(), pattern))
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() || !olderThan(dir, opts.OlderThan) {
+				continue
+			}
+			if !opts.DryRun {
+				if err := os.RemoveAll(dir); err != nil {
+					failed = append(failed, dir)
+					continue
+				}
+			}
+			actions = append(actions, RepairAction{Kind: "temp-dir", Target: dir})
+		}
+	}
+
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("failed to remove: %s", strings.Join(failed, ", "))
+	}
+	return actions, nil
+}
+
+// Repair убирает следы прерванных запусков в backup_dir, state_dir и destinations
+// и сверяет каталог с фактическим содержимым хранилищ. Ошибки отдельных
+// хранилищ не прерывают проверку остальных
+func Repair(cfg *config.Config, opts RepairOptions) ([]RepairAction, error) {
+	var actions []RepairAction
+	var failed []string
+	report := func(found []RepairAction, err error) {
+		actions = append(actions, found...)
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	backups := repairBackups(cfg)
+
+	// Временные файлы: недописанные архивы в backup_dir и файлы состояния
+	var dirs []string
+	for _, backupCfg := range backups {
+		dirs = append(dirs, filepath.Join(cfg.Global.BackupDir, backupCfg.Subdirectory))
+	}
+	report(repairTempFiles(dirs, false, opts))
+	report(repairTempFiles([]string{cfg.Global.StateDir}, true, opts))
+
+	// Блокировки запусков, прерванных kill -9 или перезагрузкой
+	report(repairLocks(&cfg.Global, backups, opts))
+
+	// Прерванные загрузки в destinations
+	for _, backupCfg := range backups {
+		for i := range backupCfg.Destinations {
+			dest := &backupCfg.Destinations[i]
+			if opts.Offline && isNetworkDestination(dest) {
+				continue
+			}
+			report(repairUploads(dest, backupCfg.Subdirectory, opts))
+		}
+	}
+
+	report(repairCatalog(cfg, backups, opts))
+
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return actions, nil
+}
+
+// repairBackups возвращает бэкапы раздела вместе с self backup
+func repairBackups(cfg *config.Config) []config.BackupConfig {
+	backups := append([]config.BackupConfig(nil), cfg.Backups...)
+	if self := cfg.Global.SelfBackup; self != nil && self.Enabled && cfg.Tenant == "" {
+		backups = append(backups, config.BackupConfig{
+			Name:         self.Name,
+			Subdirectory: self.Subdirectory,
+			Destinations: allDestinations(cfg),
+		})
+	}
+	return backups
+}
+
+// repairTempFiles удаляет временные файлы (*.tmp) в dirs; recursive обходит
+// поддиректории - только для директорий, целиком принадлежащих goback
+func repairTempFiles(dirs []string, recursive bool, opts RepairOptions) ([]RepairAction, error) {
+	var actions []RepairAction
+	var failed []string
+	seen := make(map[string]bool)
+
+	for _, dir := range dirs {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			// Недописанная копия format: directory - временная директория целиком
+			tempDir := info.IsDir() && path != dir && utils.IsTempFile(info.Name())
+			if info.IsDir() && !tempDir {
+				if path != dir && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !utils.IsTempFile(info.Name()) || time.Since(info.ModTime()) < opts.OlderThan {
+				if tempDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			size := info.Size()
+			if tempDir {
+				size, _ = utils.DirSize(path)
+			}
+			if !opts.DryRun {
+				if err := os.RemoveAll(path); err != nil {
+					failed = append(failed, path)
+					return nil
+				}
+			}
+			actions = append(actions, RepairAction{Kind: "temp-file", Target: path, Detail: utils.FormatSize(size)})
+			if tempDir {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			failed = append(failed, dir)
+		}
+	}
+
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("failed to clean temp files in: %s", strings.Join(failed, ", "))
+	}
+	return actions, nil
+}
+
+// repairLocks удаляет блокировки, процессы которых на этом хосте уже завершились.
+// Возраст не учитывается: живой процесс держит блокировку сколько угодно долго
+func repairLocks(global *config.GlobalConfig, backups []config.BackupConfig, opts RepairOptions) ([]RepairAction, error) {
+	var actions []RepairAction
+	var failed []string
+
+	paths := []string{global.LockPath()}
+	for _, backupCfg := range backups {
+		paths = append(paths, global.BackupLockPath(backupCfg.Name))
+	}
+	for _, path := range paths {
+		info, err := lock.Read(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				failed = append(failed, path)
+			}
+			continue
+		}
+		if !lock.Stale(info) {
+			continue
+		}
+		if !opts.DryRun {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				failed = append(failed, path)
+				continue
+			}
+		}
+		actions = append(actions, RepairAction{Kind: "lock", Target: path, Detail: "stale, " + info.String()})
+	}
+
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("failed to clean locks: %s", strings.Join(failed, ", "))
+	}
+	return actions, nil
+}
+
+// repairUploads отменяет прерванные загрузки в destination
+func repairUploads(dest *config.DestinationConfig, subdirectory string, opts RepairOptions) ([]RepairAction, error) {
+	target, err := newStorage(dest, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dest.Name, err)
+	}
+	cleaner, ok := target.(storage.IncompleteCleaner)
+	if !ok {
+		return nil, nil
+	}
+
+	items, err := cleaner.Incomplete(subdirectory, opts.OlderThan)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dest.Name, err)
+	}
+
+	var actions []RepairAction
+	for _, item := range items {
+		if !opts.DryRun {
+			if err := cleaner.AbortIncomplete(item); err != nil {
+				return actions, fmt.Errorf("%s: %w", dest.Name, err)
+			}
+		}
+		actions = append(actions, RepairAction{
+			Kind:   "upload",
+			Target: dest.Name + ":" + item.Key,
+			Detail: "started " + item.Started.Local().Format("2006-01-02 15:04"),
+		})
+	}
+
+	return actions, nil
+}
+
+// repairCatalog удаляет из каталога записи об отсутствующих архивах и добавляет
+// найденные архивы, о которых каталог не знает
+func repairCatalog(cfg *config.Config, backups []config.BackupConfig, opts RepairOptions) ([]RepairAction, error) {
+	c, err := catalog.Load(cfg.Global.CatalogPath())
+	if err != nil {
+		return nil, err
+	}
+
+	type location struct{ destination, key string }
+	known := make(map[location]catalog.Entry)
+	for _, entry := range c.Entries {
+		known[location{entry.Destination, entry.Key}] = entry
+	}
+
+	var missing, found []catalog.Entry
+	var failed []string
+	for _, backupCfg := range backups {
+		dir := filepath.Join(cfg.Global.BackupDir, backupCfg.Subdirectory)
+		manifest, err := checksum.ReadManifest(dir)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+
+		type namedStore struct {
+			name  string
+			store storage.Storage
+		}
+		stores := []namedStore{{catalog.LocalDestination, storage.NewLocalStorage(cfg.Global.BackupDir)}}
+		for i := range backupCfg.Destinations {
+			dest := &backupCfg.Destinations[i]
+			if opts.Offline && isNetworkDestination(dest) {
+				continue
+			}
+			target, err := newStorage(dest, nil)
+			if err != nil {
+				failed = append(failed, dest.Name)
+				continue
+			}
+			stores = append(stores, namedStore{dest.Name, target})
+		}
+
+		for _, target := range stores {
+			name := target.name
+			entries, err := catalog.Scan(target.store, name, backupCfg.Subdirectory, backupCfg.Name, cfg.Global.FileNaming())
+			if err != nil {
+				failed = append(failed, name)
+				continue
+			}
+
+			present := make(map[string]bool)
+			for _, entry := range entries {
+				present[entry.Key] = true
+				if _, ok := known[location{name, entry.Key}]; ok {
+					continue
+				}
+				// Сумма берется из sidecar/MANIFEST или из записи локальной копии
+				if name == catalog.LocalDestination {
+					entry.Checksum, _ = ExpectedChecksum(filepath.Join(cfg.Global.BackupDir, entry.Key), backupCfg.Subdirectory, manifest, nil)
+				} else {
+					entry.Checksum = known[location{catalog.LocalDestination, entry.Key}].Checksum
+				}
+				found = append(found, entry)
+			}
+
+			for _, entry := range c.ForBackup(backupCfg.Name) {
+				if entry.Destination != name || present[entry.Key] {
+					continue
+				}
+				// Снимки format: directory - директории, Scan их не возвращает
+				if name == catalog.LocalDestination {
+					if _, err := os.Stat(filepath.Join(cfg.Global.BackupDir, entry.Key)); err == nil {
+						continue
+					}
+				}
+				missing = append(missing, entry)
+			}
+		}
+	}
+
+	var actions []RepairAction
+	for _, entry := range missing {
+		actions = append(actions, RepairAction{Kind: "catalog", Target: entry.Destination + ":" + entry.Key, Detail: "archive is missing, entry removed"})
+	}
+	for _, entry := range found {
+		actions = append(actions, RepairAction{Kind: "catalog", Target: entry.Destination + ":" + entry.Key, Detail: "archive was not cataloged, entry added"})
+	}
+
+	if !opts.DryRun && len(actions) > 0 {
+		err := catalog.Update(cfg.Global.CatalogPath(), func(c *catalog.Catalog) {
+			for _, entry := range missing {
+				c.Remove(entry.Destination, entry.Key)
+			}
+			for _, entry := range found {
+				c.Add(entry)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("failed to check catalog against: %s", strings.Join(failed, ", "))
+	}
+	return actions, nil
+}
+
+// olderThan сообщает, что ни один элемент дерева path не менялся последние age
+func olderThan(path string, age time.Duration) bool {
+	cutoff := time.Now().Add(-age)
+	recent := false
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(cutoff) {
+			recent = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return !recent
+}
@@ -0,0 +1,84 @@
+package backup
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewRunID создает идентификатор запуска: время начала и случайный суффикс
+// (например 20250101T020000-3f9a1c), уникальный между хостами и запусками
+func NewRunID(t time.Time) string {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		// Без случайности различаем запуски по наносекундам
+		return t.Format("20060102T150405.000000000")
+	}
+	return t.Format("20060102T150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// SetRunID задает идентификатор запуска: executors разделов одного запуска
+// должны иметь общий идентификатор
+func (e *Executor) SetRunID(runID string) {
+	e.runID = runID
+}
+
+// RunID возвращает идентификатор запуска
+func (e *Executor) RunID() string {
+	return e.runID
+}
+
+// jobID - идентификатор бэкапа в рамках запуска
+func (e *Executor) jobID(backupName string) string {
+	return e.runID + "-" + backupName
+}
+
+// runEnv - переменные окружения хуков с идентификаторами запуска и бэкапа
+func (e *Executor) runEnv(backupName string) []string {
+	return []string{
+		"GOBACK_RUN_ID=" + e.runID,
+		"GOBACK_JOB_ID=" + e.jobID(backupName),
+		"GOBACK_BACKUP=" + backupName,
+		"GOBACK_BACKUP_NAME=" + backupName,
+	}
+}
+
+// ResultEnv - переменные окружения post-хуков с итогом бэкапа или запуска:
+// GOBACK_STATUS (success или failure), GOBACK_DURATION в секундах и при ошибке
+// GOBACK_ERROR
+func ResultEnv(err error, duration time.Duration) []string {
+	env := []string{"GOBACK_DURATION=" + strconv.FormatInt(int64(duration.Seconds()), 10)}
+	if err != nil {
+		return append(env, "GOBACK_STATUS=failure", "GOBACK_ERROR="+err.Error())
+	}
+	return append(env, "GOBACK_STATUS=success")
+}
+
+// archiveEnv - переменные окружения post-хуков с созданным архивом
+func archiveEnv(archivePath string, size int64) []string {
+	return []string{
+		"GOBACK_ARCHIVE_PATH=" + archivePath,
+		"GOBACK_ARCHIVE=" + filepath.Base(archivePath),
+		"GOBACK_SIZE=" + strconv.FormatInt(size, 10),
+	}
+}
+
+// objectMetadata - метаданные загружаемого объекта для сопоставления его с запуском
+// и ключи, которыми он зашифрован
+func objectMetadata(runID, jobID string, keys []string) map[string]string {
+	metadata := make(map[string]string)
+	if runID != "" {
+		metadata["goback-run-id"] = runID
+		metadata["goback-job-id"] = jobID
+	}
+	if len(keys) > 0 {
+		metadata["goback-encryption-keys"] = strings.Join(keys, ",")
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
@@ -0,0 +1,106 @@
+package backup
+
+import "sync"
+
+// Scheduler запускает бэкапы параллельно с общим лимитом и лимитами пулов ресурсов,
+// чтобы тяжелые задачи на одном диске или канале не выполнялись одновременно
+type Scheduler struct {
+	parallelism int
+	limits      map[string]int
+	// deps - индексы задач, которые должны завершиться до запуска задачи
+	deps [][]int
+}
+
+// NewScheduler создает планировщик. Пул, для которого не задан лимит, выполняет
+// не больше одной задачи за раз
+func NewScheduler(parallelism int, limits map[string]int) *Scheduler {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &Scheduler{parallelism: parallelism, limits: limits}
+}
+
+// SetDependencies задает для каждой задачи индексы задач, после завершения
+// которых она может быть запущена (depends_on). Успех зависимостей проверяет сама задача
+func (s *Scheduler) SetDependencies(deps [][]int) {
+	s.deps = deps
+}
+
+// Run выполняет run(i) для i от 0 до n-1. Задачи запускаются в порядке списка;
+// задача, чей пул занят или зависимости еще выполняются, пропускается в пользу
+// следующих, а не блокирует их
+func (s *Scheduler) Run(n int, pool func(i int) string, run func(i int)) {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	started := make([]bool, n)
+	finished := make([]bool, n)
+	busy := make(map[string]int)
+	running, remaining := 0, n
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for remaining > 0 {
+		next := -1
+		if running < s.parallelism {
+			for i := 0; i < n; i++ {
+				if !started[i] && s.ready(i, finished) && s.available(pool(i), busy) {
+					next = i
+					break
+				}
+			}
+		}
+
+		if next == -1 {
+			cond.Wait()
+			continue
+		}
+
+		started[next] = true
+		running++
+		name := pool(next)
+		if name != "" {
+			busy[name]++
+		}
+
+		go func(i int) {
+			run(i)
+
+			mu.Lock()
+			running--
+			remaining--
+			finished[i] = true
+			if name != "" {
+				busy[name]--
+			}
+			mu.Unlock()
+			cond.Broadcast()
+		}(next)
+	}
+}
+
+// ready сообщает, что все зависимости задачи i завершились
+func (s *Scheduler) ready(i int, finished []bool) bool {
+	if i >= len(s.deps) {
+		return true
+	}
+	for _, dep := range s.deps[i] {
+		if !finished[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Scheduler) available(pool string, busy map[string]int) bool {
+	if pool == "" {
+		return true
+	}
+
+	limit, ok := s.limits[pool]
+	if !ok {
+		limit = 1
+	}
+
+	return busy[pool] < limit
+}
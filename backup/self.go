@@ -0,0 +1,127 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PrepareSelfBackup собирает во временной директории конфигурацию и состояние goback
+// и возвращает описание бэкапа, который доставляет их во все destinations.
+// cleanup удаляет временную директорию и должен быть вызван после выполнения бэкапа
+func PrepareSelfBackup(cfg *config.Config) (*config.BackupConfig, func(), error) {
+	self := cfg.Global.SelfBackup
+
+	stagingDir, err := os.MkdirTemp("", "goback-self-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(stagingDir) }
+
+	if err := stageSelfFiles(cfg, stagingDir); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	backupCfg := &config.BackupConfig{
+		Name:         self.Name,
+		Subdirectory: self.Subdirectory,
+		SourceDir:    stagingDir,
+		Compression:  self.Compression,
+		Retention:    self.Retention,
+		// Destinations клиентов не получают конфигурацию с данными других клиентов
+		Destinations: allDestinations(cfg.Scope("")),
+	}
+	if backupCfg.Compression == "" {
+		backupCfg.Compression = "tar.gz"
+	}
+
+	return backupCfg, cleanup, nil
+}
+
+func stageSelfFiles(cfg *config.Config, stagingDir string) error {
+	// Исходный файл конфигурации сохраняем как есть (в том числе зашифрованный)
+	if cfg.Path != "" {
+		configDir := filepath.Join(stagingDir, "config")
+		if err := os.MkdirAll(configDir, 0700); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := copyFile(cfg.Path, filepath.Join(configDir, filepath.Base(cfg.Path)), 0600); err != nil {
+			return fmt.Errorf("failed to copy config file: %w", err)
+		}
+
+		// Файлы include - рядом, с теми же путями относительно конфигурации
+		for _, path := range cfg.IncludedFiles {
+			rel, err := filepath.Rel(filepath.Dir(cfg.Path), path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				rel = filepath.Join("include-files", filepath.Base(path))
+			}
+			target := filepath.Join(configDir, rel)
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return fmt.Errorf("failed to create config directory: %w", err)
+			}
+			if err := copyFile(path, target, 0600); err != nil {
+				return fmt.Errorf("failed to copy included config file: %w", err)
+			}
+		}
+	}
+
+	// Итоговую конфигурацию (с бэкапами из include_dir) пишем только если исходная
+	// не была зашифрована и в нее не подставлялись переменные окружения, чтобы не
+	// выгружать расшифрованные секреты
+	if !cfg.Encrypted && !cfg.Interpolated {
+		// Бэкапы клиентов уже описаны в tenants
+		effective := *cfg
+		effective.Backups = cfg.Scope("").Backups
+		data, err := yaml.Marshal(&effective)
+		if err != nil {
+			return fmt.Errorf("failed to encode effective config: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(stagingDir, "effective-config.yaml"), data, 0600); err != nil {
+			return fmt.Errorf("failed to write effective config: %w", err)
+		}
+	}
+
+	if cfg.Global.IncludeDir != "" {
+		if err := CopyDirectory(cfg.Global.IncludeDir, filepath.Join(stagingDir, "include"), CopyOptions{Parallelism: 1}); err != nil {
+			return fmt.Errorf("failed to copy include_dir: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(cfg.Global.StateDir); err == nil {
+		// Spool содержит копии архивов, а снимки метаданных восстанавливаются
+		// следующим запуском - в self backup они не нужны
+		opts := CopyOptions{
+			Parallelism:     1,
+			ExcludePatterns: []string{filepath.Base(cfg.Global.SpoolDir()), "metadata"},
+		}
+		if err := CopyDirectory(cfg.Global.StateDir, filepath.Join(stagingDir, "state"), opts); err != nil {
+			return fmt.Errorf("failed to copy state_dir: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// allDestinations возвращает все destinations из конфигурации без повторов (по имени)
+func allDestinations(cfg *config.Config) []config.DestinationConfig {
+	seen := make(map[string]bool)
+	var result []config.DestinationConfig
+
+	for _, backupCfg := range cfg.Backups {
+		for _, dest := range backupCfg.Destinations {
+			if seen[dest.Name] {
+				continue
+			}
+			seen[dest.Name] = true
+			result = append(result, dest)
+		}
+	}
+
+	return result
+}
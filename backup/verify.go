@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"goback/catalog"
+	"goback/checksum"
+	"goback/config"
+	"goback/retention"
+	"goback/utils"
+)
+
+// CatalogChecksums возвращает суммы архивов backup_dir из каталога по ключу
+// subdirectory/имя - запасной источник для архивов без sidecar и MANIFEST
+func CatalogChecksums(globalConfig *config.GlobalConfig) (map[string]string, error) {
+	known := make(map[string]string)
+	c, err := catalog.Load(globalConfig.CatalogPath())
+	if err != nil {
+		return known, err
+	}
+	for _, entry := range c.Entries {
+		if entry.Destination == catalog.LocalDestination && entry.Checksum != "" {
+			known[entry.Key] = entry.Checksum
+		}
+	}
+	return known, nil
+}
+
+// ExpectedChecksum возвращает ожидаемую сумму архива в виде "algorithm:hex" или
+// пустую строку, если ее негде взять. Sidecar и MANIFEST всегда содержат SHA-256,
+// каталог (known) - сумму с алгоритмом
+func ExpectedChecksum(path, subdirectory string, manifest, known map[string]string) (string, error) {
+	name := filepath.Base(path)
+	sum, err := checksum.ReadSidecar(path)
+	if sum == "" {
+		sum = manifest[name]
+	}
+	if sum != "" {
+		return checksum.Format("sha256", sum), err
+	}
+	return known[filepath.Join(subdirectory, name)], err
+}
+
+// verifyTask - архив в очереди фоновой проверки
+type verifyTask struct {
+	backup   string
+	path     string
+	expected string
+}
+
+// BackgroundVerifier проверяет контрольные суммы архивов в фоне с низким
+// приоритетом: архивы читаются с ограничением скорости, а пока выполняется
+// хотя бы один бэкап, проверка приостанавливается (в том числе посреди архива),
+// чтобы не конкурировать с ним за диск
+type BackgroundVerifier struct {
+	rateLimit int64
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []verifyTask
+	backups int
+	closed  bool
+}
+
+// NewBackgroundVerifier создает очередь проверки; rateLimit - скорость чтения
+// в байтах в секунду (0 - без ограничения)
+func NewBackgroundVerifier(rateLimit int64) *BackgroundVerifier {
+	v := &BackgroundVerifier{rateLimit: rateLimit}
+	v.cond = sync.NewCond(&v.mu)
+	return v
+}
+
+// SetRateLimit меняет скорость чтения архивов (после перезагрузки конфигурации)
+func (v *BackgroundVerifier) SetRateLimit(rateLimit int64) {
+	v.mu.Lock()
+	v.rateLimit = rateLimit
+	v.mu.Unlock()
+}
+
+// Enqueue ставит в очередь все архивы backups с известной контрольной суммой.
+// Пока предыдущий проход не закончен, новый не добавляется.
+// Возвращает число поставленных в очередь архивов
+func (v *BackgroundVerifier) Enqueue(cfg *config.Config, backups []config.BackupConfig) (int, error) {
+	v.mu.Lock()
+	pending := len(v.queue)
+	v.mu.Unlock()
+	if pending > 0 {
+		return 0, fmt.Errorf("previous pass still has %d archive(s) to verify", pending)
+	}
+
+	catalogs := make(map[string]map[string]string)
+	var tasks []verifyTask
+	for i := range backups {
+		backupCfg := &backups[i]
+		global := cfg.GlobalFor(backupCfg)
+
+		known, ok := catalogs[global.CatalogPath()]
+		if !ok {
+			var err error
+			if known, err = CatalogChecksums(global); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			catalogs[global.CatalogPath()] = known
+		}
+
+		files, err := retention.FindBackupFiles(global.BackupDir, backupCfg.Subdirectory, backupCfg.Name, global.FileNaming())
+		if err != nil {
+			return 0, fmt.Errorf("failed to list archives of %s: %w", backupCfg.Name, err)
+		}
+		manifest, err := checksum.ReadManifest(filepath.Join(global.BackupDir, backupCfg.Subdirectory))
+		if err != nil {
+			return 0, err
+		}
+
+		for _, file := range files {
+			// У бэкапа с format: directory нет контрольной суммы архива
+			if info, err := utils.StatArchive(file.Path); err != nil || info.IsDir() {
+				continue
+			}
+			expected, err := ExpectedChecksum(file.Path, backupCfg.Subdirectory, manifest, known)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			if expected != "" {
+				tasks = append(tasks, verifyTask{backup: backupCfg.Name, path: file.Path, expected: expected})
+			}
+		}
+	}
+
+	v.mu.Lock()
+	v.queue = append(v.queue, tasks...)
+	v.mu.Unlock()
+	v.cond.Broadcast()
+
+	return len(tasks), nil
+}
+
+// BackupStarted приостанавливает проверку до окончания бэкапа
+func (v *BackgroundVerifier) BackupStarted() {
+	v.mu.Lock()
+	v.backups++
+	v.mu.Unlock()
+}
+
+// BackupFinished возобновляет проверку, когда не осталось выполняющихся бэкапов
+func (v *BackgroundVerifier) BackupFinished() {
+	v.mu.Lock()
+	v.backups--
+	v.mu.Unlock()
+	v.cond.Broadcast()
+}
+
+// Run обрабатывает очередь до отмены ctx. Результат каждого прохода выводится
+// сводкой; поврежденные архивы - ошибками
+func (v *BackgroundVerifier) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		v.mu.Lock()
+		v.closed = true
+		v.mu.Unlock()
+		v.cond.Broadcast()
+	}()
+
+	verified, failed := 0, 0
+	for {
+		task, last, ok := v.next()
+		if !ok {
+			return
+		}
+
+		err := v.verify(task)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case os.IsNotExist(err):
+			// Архив удален retention после постановки в очередь
+		case err != nil:
+			utils.PrintError("Background verify of %s: FAILED %s: %v", task.backup, filepath.Base(task.path), err)
+			failed++
+		default:
+			verified++
+		}
+
+		if last {
+			fmt.Printf("Background verify finished: verified %d, failed %d\n", verified, failed)
+			verified, failed = 0, 0
+		}
+	}
+}
+
+// next ждет следующий архив очереди; last - это последний архив прохода
+func (v *BackgroundVerifier) next() (verifyTask, bool, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for len(v.queue) == 0 && !v.closed {
+		v.cond.Wait()
+	}
+	if v.closed {
+		return verifyTask{}, false, false
+	}
+
+	task := v.queue[0]
+	v.queue = v.queue[1:]
+	return task, len(v.queue) == 0, true
+}
+
+// waitIdle блокируется, пока выполняется хотя бы один бэкап
+func (v *BackgroundVerifier) waitIdle() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.backups > 0 && !v.closed {
+		fmt.Printf("Background verify paused while backups are running\n")
+		for v.backups > 0 && !v.closed {
+			v.cond.Wait()
+		}
+		if !v.closed {
+			fmt.Printf("Background verify resumed\n")
+		}
+	}
+	if v.closed {
+		return context.Canceled
+	}
+	return nil
+}
+
+// verify пересчитывает сумму архива, приостанавливаясь на время бэкапов
+func (v *BackgroundVerifier) verify(task verifyTask) error {
+	if err := v.waitIdle(); err != nil {
+		return err
+	}
+
+	file, err := utils.OpenArchive(task.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	v.mu.Lock()
+	rateLimit := v.rateLimit
+	v.mu.Unlock()
+
+	algorithm, sum := checksum.Parse(task.expected)
+	reader := utils.NewRateLimitedReader(&idleReader{reader: file, verifier: v}, rateLimit)
+	actual, err := checksum.Reader(reader, algorithm)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, sum) {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", task.expected, checksum.Format(algorithm, actual))
+	}
+	return nil
+}
+
+// idleReader приостанавливает чтение архива на время бэкапов
+type idleReader struct {
+	reader   io.Reader
+	verifier *BackgroundVerifier
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if err := r.verifier.waitIdle(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
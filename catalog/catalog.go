@@ -0,0 +1,172 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"goback/lock"
+	"goback/utils"
+)
+
+// LocalDestination - имя destination для архивов в backup_dir
+const LocalDestination = "local"
+
+// Entry описывает один архив в одном месте хранения
+type Entry struct {
+	Backup      string    `json:"backup"`
+	Destination string    `json:"destination"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	Time        time.Time `json:"time"`
+	// Checksum - контрольная сумма в формате "algorithm:hex"
+	Checksum string `json:"checksum,omitempty"`
+	// Volumes - число томов (key.partNNN) разбитого архива; 0 - архив одним объектом
+	Volumes int `json:"volumes,omitempty"`
+}
+
+type Catalog struct {
+	Entries []Entry `json:"entries"`
+}
+
+// mu защищает файл каталога от одновременной записи внутри процесса
+var mu sync.Mutex
+
+// updateLockWait - сколько Update ждет файловую блокировку каталога, которую держит
+// другой процесс (бэкап, restore, catalog import); сама запись занимает доли секунды
+const updateLockWait = 30 * time.Second
+
+// Load читает каталог; отсутствующий файл означает пустой каталог
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Catalog{}, nil
+		}
+		return nil, fmt.Errorf("failed to read catalog: %w", err)
+	}
+
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse catalog: %w", err)
+	}
+
+	return &c, nil
+}
+
+// Write выводит каталог в JSON
+func (c *Catalog) Write(w io.Writer) error {
+	c.sort()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c)
+}
+
+// Save атомарно записывает каталог (через временный файл и rename)
+func (c *Catalog) Save(path string) error {
+	c.sort()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode catalog: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create catalog directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+
+	return nil
+}
+
+// Update загружает каталог, применяет fn и сохраняет результат. Файловая блокировка
+// path.lock не дает двум процессам goback затереть изменения друг друга
+func Update(path string, fn func(c *Catalog)) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	updateLock, err := lock.Acquire(path+".lock", "", updateLockWait)
+	if err != nil {
+		return fmt.Errorf("failed to lock catalog: %w", err)
+	}
+	defer updateLock.Release()
+
+	c, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	fn(c)
+
+	return c.Save(path)
+}
+
+// Add добавляет запись или заменяет существующую с тем же destination и key
+func (c *Catalog) Add(entry Entry) {
+	for i := range c.Entries {
+		if c.Entries[i].Destination == entry.Destination && c.Entries[i].Key == entry.Key {
+			c.Entries[i] = entry
+			return
+		}
+	}
+	c.Entries = append(c.Entries, entry)
+}
+
+// Remove удаляет запись по destination и key
+func (c *Catalog) Remove(destination, key string) {
+	result := c.Entries[:0]
+	for _, entry := range c.Entries {
+		if entry.Destination == destination && entry.Key == key {
+			continue
+		}
+		result = append(result, entry)
+	}
+	c.Entries = result
+}
+
+// Merge добавляет записи другого каталога (импорт)
+func (c *Catalog) Merge(other *Catalog) int {
+	before := len(c.Entries)
+	for _, entry := range other.Entries {
+		c.Add(entry)
+	}
+	return len(c.Entries) - before
+}
+
+// ForBackup возвращает записи бэкапа, отсортированные по времени
+func (c *Catalog) ForBackup(name string) []Entry {
+	var result []Entry
+	for _, entry := range c.Entries {
+		if entry.Backup == name {
+			result = append(result, entry)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result
+}
+
+func (c *Catalog) sort() {
+	sort.Slice(c.Entries, func(i, j int) bool {
+		a, b := c.Entries[i], c.Entries[j]
+		if a.Backup != b.Backup {
+			return a.Backup < b.Backup
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		return a.Time.Before(b.Time)
+	})
+}
@@ -0,0 +1,53 @@
+package catalog
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"goback/retention"
+	"goback/storage"
+	"goback/utils"
+)
+
+// Scan находит архивы бэкапа в хранилище и возвращает записи каталога для них
+func Scan(store storage.Storage, destination, subdirectory, backupName string, naming utils.Naming) ([]Entry, error) {
+	objects, err := store.List(subdirectory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", destination, err)
+	}
+
+	// Тома разбитого архива (key.partNNN) - одна запись с суммарным размером
+	var entries []Entry
+	volumes := make(map[string]int)
+	for _, object := range objects {
+		key := object.Key
+		if archive, _, ok := utils.ParseVolume(key); ok {
+			if i, seen := volumes[archive]; seen {
+				entries[i].Size += object.Size
+				entries[i].Volumes++
+				continue
+			}
+			key = archive
+		}
+		t, ok := retention.MatchBackupFile(filepath.Base(key), backupName, naming)
+		if !ok {
+			continue
+		}
+		count := 0
+		if key != object.Key {
+			volumes[key] = len(entries)
+			count = 1
+		}
+
+		entries = append(entries, Entry{
+			Backup:      backupName,
+			Destination: destination,
+			Key:         key,
+			Size:        object.Size,
+			Time:        t,
+			Volumes:     count,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"goback/backup"
+	"goback/catalog"
+	"goback/utils"
+)
+
+// catalogCommand: goback catalog export|import
+func catalogCommand(args []string) int {
+	fs := flag.NewFlagSet("catalog", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	toFile := fs.String("to", "", "Export catalog to file instead of stdout")
+	tenant := fs.String("tenant", "", "Use the catalog of a tenant")
+
+	// Флаги разбираются до действия: глобальные флаги (goback -c cfg.yaml catalog export)
+	// приходят перед ним
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 {
+		utils.PrintError("Usage: goback catalog export [--to file] | goback catalog import <file>")
+		return 2
+	}
+	action, positional := positional[0], positional[1:]
+
+	cfg := loadConfigOrExit(*configPath)
+	scope := cfg.Scope(*tenant)
+	if scope == nil {
+		utils.PrintError("tenant not found: %s", *tenant)
+		return 1
+	}
+	catalogPath := scope.Global.CatalogPath()
+
+	switch action {
+	case "export":
+		c, err := catalog.Load(catalogPath)
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+
+		if *toFile == "" {
+			if err := c.Write(os.Stdout); err != nil {
+				utils.PrintError("%v", err)
+				return 1
+			}
+			return 0
+		}
+
+		if err := c.Save(*toFile); err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		utils.PrintSuccess("Catalog exported to %s (%d entries)", *toFile, len(c.Entries))
+		return 0
+
+	case "import":
+		if len(positional) != 1 {
+			utils.PrintError("Usage: goback catalog import <file>")
+			return 2
+		}
+
+		imported, err := catalog.Load(positional[0])
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+
+		added := 0
+		if err := catalog.Update(catalogPath, func(c *catalog.Catalog) {
+			added = c.Merge(imported)
+		}); err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		utils.PrintSuccess("Imported %d entries (%d new)", len(imported.Entries), added)
+		return 0
+
+	default:
+		utils.PrintError("Unknown catalog command: %s", action)
+		return 2
+	}
+}
+
+// rebuildIndexCommand: goback rebuild-index - восстанавливает каталог сканированием хранилищ
+func rebuildIndexCommand(args []string) int {
+	fs := flag.NewFlagSet("rebuild-index", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+
+	// Каталог каждого клиента строится только из его backup_dir и destinations
+	for _, scope := range cfg.Scopes() {
+		if scope.Tenant != "" {
+			utils.PrintHeader("Tenant %s:", scope.Tenant)
+		}
+		utils.PrintHeader("Scanning backup directory and destinations...")
+		c, err := backup.RebuildCatalog(scope)
+		if err != nil {
+			utils.PrintError("Failed to rebuild catalog: %v", err)
+			return 1
+		}
+
+		// Замена идет через Update, чтобы не затереть запись параллельного бэкапа
+		if err := catalog.Update(scope.Global.CatalogPath(), func(current *catalog.Catalog) {
+			current.Entries = c.Entries
+		}); err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+
+		counts := make(map[string]int)
+		for _, entry := range c.Entries {
+			counts[entry.Destination]++
+		}
+		for destination, count := range counts {
+			fmt.Printf("  %s: %d archive(s)\n", destination, count)
+		}
+
+		utils.PrintSuccess("Catalog rebuilt: %s (%d entries)", scope.Global.CatalogPath(), len(c.Entries))
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"goback/utils"
+
+	"github.com/zeebo/xxh3"
+	"lukechampine.com/blake3"
+)
+
+// DefaultAlgorithm используется, если алгоритм не задан в конфигурации
+const DefaultAlgorithm = "sha256"
+
+// Algorithms - поддерживаемые алгоритмы хеширования
+var Algorithms = []string{"sha256", "blake3", "xxh3"}
+
+// New создает хешер для алгоритма
+func New(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "sha256", "":
+		return sha256.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
+	case "xxh3":
+		return xxh3.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", algorithm)
+	}
+}
+
+// Normalize возвращает каноническое имя алгоритма
+func Normalize(algorithm string) string {
+	algorithm = strings.ToLower(algorithm)
+	if algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return algorithm
+}
+
+// File вычисляет контрольную сумму файла в hex; у архива, разбитого на тома,
+// сумма считается по всем томам подряд
+func File(path, algorithm string) (string, error) {
+	if _, err := New(algorithm); err != nil {
+		return "", err
+	}
+
+	file, err := utils.OpenArchive(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return Reader(file, algorithm)
+}
+
+// Reader вычисляет контрольную сумму всего содержимого reader в hex
+func Reader(reader io.Reader, algorithm string) (string, error) {
+	hasher, err := New(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Format собирает значение вида "algorithm:hex", по которому видно, чем считалась сумма
+func Format(algorithm, sum string) string {
+	return Normalize(algorithm) + ":" + sum
+}
+
+// Parse разбирает значение "algorithm:hex"; без префикса считается sha256
+func Parse(value string) (string, string) {
+	if idx := strings.Index(value, ":"); idx != -1 {
+		return Normalize(value[:idx]), value[idx+1:]
+	}
+	return DefaultAlgorithm, value
+}
+
+// Verify пересчитывает контрольную сумму файла тем же алгоритмом и сравнивает
+func Verify(path, expected string) error {
+	algorithm, sum := Parse(expected)
+
+	actual, err := File(path, algorithm)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(actual, sum) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, expected, Format(algorithm, actual))
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package checksum
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"goback/utils"
+)
+
+// SidecarExtension - расширение файла с SHA-256 архива (<archive>.sha256)
+const SidecarExtension = ".sha256"
+
+// ManifestName - файл со списком SHA-256 всех архивов директории
+const ManifestName = "MANIFEST"
+
+// IsManifestFile проверяет, что файл является sidecar или MANIFEST, а не архивом
+func IsManifestFile(filename string) bool {
+	return filename == ManifestName || strings.HasSuffix(strings.ToLower(filename), SidecarExtension)
+}
+
+// WriteSidecar записывает <archive>.sha256 в формате sha256sum ("hex  имя"),
+// поэтому архив можно проверить и без goback: sha256sum -c <archive>.sha256
+func WriteSidecar(archivePath, sum string) error {
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(archivePath))
+	if err := utils.WriteFileAtomic(archivePath+SidecarExtension, []byte(line), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum sidecar: %w", err)
+	}
+	return nil
+}
+
+// ReadSidecar возвращает SHA-256 из sidecar архива; пустая строка - sidecar нет
+func ReadSidecar(archivePath string) (string, error) {
+	data, err := os.ReadFile(archivePath + SidecarExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to read checksum sidecar: %w", err)
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum sidecar %s", archivePath+SidecarExtension)
+	}
+	return fields[0], nil
+}
+
+// ReadManifest читает MANIFEST директории: имя архива -> SHA-256
+func ReadManifest(dir string) (map[string]string, error) {
+	file, err := os.Open(filepath.Join(dir, ManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer file.Close()
+
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// Формат sha256sum: "hex  имя" (имя может содержать пробелы)
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			continue
+		}
+		sums[name] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return sums, nil
+}
+
+// AddToManifest добавляет или заменяет SHA-256 архива в MANIFEST его директории
+func AddToManifest(archivePath, sum string) error {
+	return updateManifest(filepath.Dir(archivePath), func(sums map[string]string) {
+		sums[filepath.Base(archivePath)] = sum
+	})
+}
+
+// Forget удаляет sidecar архива и его строку в MANIFEST (после удаления архива)
+func Forget(archivePath string) error {
+	if err := os.Remove(archivePath + SidecarExtension); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove checksum sidecar: %w", err)
+	}
+
+	dir := filepath.Dir(archivePath)
+	if _, err := os.Stat(filepath.Join(dir, ManifestName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	return updateManifest(dir, func(sums map[string]string) {
+		delete(sums, filepath.Base(archivePath))
+	})
+}
+
+// manifestMu защищает MANIFEST от одновременного изменения параллельными бэкапами
+var manifestMu sync.Mutex
+
+func updateManifest(dir string, fn func(sums map[string]string)) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	sums, err := ReadManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	fn(sums)
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+	}
+
+	if err := utils.WriteFileAtomic(filepath.Join(dir, ManifestName), []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
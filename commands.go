@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"goback/config"
+	"goback/utils"
+)
+
+// command - подкоманда goback и ее описание для goback help
+type command struct {
+	run     func(args []string) int
+	summary string
+}
+
+// commands - подкоманды, доступные как ./goback <command> [flags]
+var commands = map[string]command{
+	"restore":       {restoreCommand, "Restore an archive into a directory"},
+	"catalog":       {catalogCommand, "Query or export the archive catalog"},
+	"rebuild-index": {rebuildIndexCommand, "Rebuild the catalog index from backup_dir"},
+	"retention":     {retentionCommand, "Simulate the retention policy over future dates"},
+	"upload":        {uploadCommand, "Upload deferred archives from the spool"},
+	"daemon":        {daemonCommand, "Run backups on their schedule"},
+	"verify":        {verifyCommand, "Verify archive checksums (--deep reads archives)"},
+	"explain":       {explainCommand, "Explain which paths a backup excludes"},
+	"drill":         {drillCommand, "Run a recovery drill into a scratch directory"},
+	"recompress":    {recompressCommand, "Recompress archives with another algorithm"},
+	"rekey":         {rekeyCommand, "Re-encrypt archives for new recipients"},
+	"digest":        {digestCommand, "Send the periodic digest of backup results"},
+	"invalidate":    {invalidateCommand, "Drop state so the next run is a clean full backup"},
+	"hold":          {holdCommand, "Place or release retention holds on archives"},
+	"validate":      {validateCommand, "Check the configuration without running anything"},
+	"prune":         {pruneCommand, "Apply retention on demand"},
+	"inventory":     {inventoryCommand, "Export all archives as CSV or JSON"},
+	"list":          {listCommand, "List archives of each backup"},
+	"history":       {historyCommand, "Show past runs and when each backup last succeeded"},
+	"status":        {statusCommand, "Show running backups, last runs and local archives"},
+	"show":          {showCommand, "List files in an archive without extracting"},
+	"repair":        {repairCommand, "Clean up after interrupted runs"},
+}
+
+// forwardedFlags - глобальные флаги, которые могут стоять перед подкомандой
+// (goback --config prod.yaml list) и передаются ей как есть. Флаги со значением
+// отмечены true
+var forwardedFlags = map[string]bool{
+	"config":  true,
+	"c":       true,
+	"verbose": false,
+	"v":       false,
+	"dry-run": false,
+}
+
+// globalOptions - флаги, которые действуют на весь вывод и принимаются в любом
+// месте командной строки
+type globalOptions struct {
+	quiet   bool
+	noColor bool
+}
+
+// extractGlobalOptions убирает из аргументов --quiet/-q и --no-color (до "--")
+func extractGlobalOptions(args []string) ([]string, globalOptions) {
+	var options globalOptions
+	var rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		switch strings.TrimLeft(arg, "-") {
+		case "quiet", "q":
+			if strings.HasPrefix(arg, "-") {
+				options.quiet = true
+				continue
+			}
+		case "no-color":
+			if strings.HasPrefix(arg, "-") {
+				options.noColor = true
+				continue
+			}
+		}
+		rest = append(rest, arg)
+	}
+	return rest, options
+}
+
+// splitLeadingFlags отделяет глобальные флаги перед подкомандой: возвращает их,
+// имя подкоманды (или "", если ее нет) и ее аргументы
+func splitLeadingFlags(args []string) ([]string, string, []string) {
+	var leading []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" || arg == "--" {
+			if _, exists := commands[arg]; exists || arg == "run" || arg == "help" {
+				return leading, arg, args[i+1:]
+			}
+			return nil, "", args
+		}
+
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		takesValue, known := forwardedFlags[name]
+		if !known {
+			return nil, "", args
+		}
+		leading = append(leading, arg)
+		if takesValue && !hasValue && i+1 < len(args) {
+			i++
+			leading = append(leading, args[i])
+		}
+	}
+	return nil, "", args
+}
+
+// printUsage выводит список подкоманд
+func printUsage() {
+	fmt.Printf("Usage: goback [global flags] [command] [flags]\n\n")
+	fmt.Printf("Without a command (or with \"run\") goback runs the configured backups.\n\n")
+	fmt.Println("Commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("  %-14s %s\n", "run", "Run backups (default)")
+	for _, name := range names {
+		fmt.Printf("  %-14s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Println("\nGlobal flags:")
+	fmt.Println("  -c, --config <file>  Path to configuration file (default: config.yaml)")
+	fmt.Println("  -v, --verbose        Verbose output (commands that support it)")
+	fmt.Println("  --dry-run            Do not change anything (commands that support it)")
+	fmt.Println("  -q, --quiet          Print only errors")
+	fmt.Println("  --no-color           Disable colored output (also NO_COLOR)")
+	fmt.Println("\nRun \"goback <command> -h\" for the flags of a command.")
+}
+
+// parseFlags разбирает флаги вперемешку с позиционными аргументами
+// (стандартный flag останавливается на первом позиционном аргументе)
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// loadConfigOrExit загружает конфигурацию для подкоманды
+func loadConfigOrExit(configPath string) *config.Config {
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		utils.PrintError("Error loading config: %v", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// findBackupConfig ищет бэкап по имени
+func findBackupConfig(cfg *config.Config, name string) (*config.BackupConfig, error) {
+	for i := range cfg.Backups {
+		if cfg.Backups[i].Name == name {
+			return &cfg.Backups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("backup not found: %s", name)
+}
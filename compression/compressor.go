@@ -3,19 +3,24 @@ package compression
 import (
 	"archive/tar"
 	"archive/zip"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"goback/progress"
+	"goback/utils"
 )
 
 type Compressor interface {
 	Compress(source, destination string) error
 }
 
-type GzipCompressor struct{}
+// GzipCompressor сжимает один файл в gzip
+type GzipCompressor struct {
+	Options Options
+}
 
 func (c *GzipCompressor) Compress(source, destination string) error {
 	srcFile, err := os.Open(source)
@@ -24,33 +29,41 @@ func (c *GzipCompressor) Compress(source, destination string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(destination)
+	dstFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer dstFile.Close()
 
-	writer := gzip.NewWriter(dstFile)
-	defer writer.Close()
-
-	_, err = io.Copy(writer, srcFile)
+	writer, err := newGzipWriter(dstFile, c.Options)
 	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, c.Options.Progress.Reader(srcFile)); err != nil {
+		writer.Close()
 		return fmt.Errorf("failed to compress: %w", err)
 	}
 
-	return nil
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return dstFile.Close()
 }
 
-type ZipCompressor struct{}
+type ZipCompressor struct {
+	Options Options
+}
 
 func (c *ZipCompressor) Compress(source, destination string) error {
-	zipFile, err := os.Create(destination)
+	zipFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create zip file: %w", err)
 	}
 	defer zipFile.Close()
 
-	writer := zip.NewWriter(zipFile)
+	writer := newZipWriter(zipFile, c.Options)
 	defer writer.Close()
 
 	// Если source - это файл
@@ -70,103 +83,81 @@ func (c *ZipCompressor) Compress(source, destination string) error {
 			return nil
 		}
 
-		// Пропускаем директории
-		if info.IsDir() {
-			return nil
-		}
-
-		// Пропускаем специальные файлы (socket, named pipe, device files)
-		mode := info.Mode()
-		if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
-			return nil
+		relPath, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
 		}
 
-		// Проверяем, является ли это симлинком, указывающим на директорию
-		if mode&os.ModeSymlink != 0 {
-			// Проверяем, куда указывает симлинк
-			target, err := os.Readlink(path)
-			if err != nil {
-				// Не удалось прочитать симлинк, пропускаем
-				return nil
-			}
-			// Получаем абсолютный путь цели
-			if !filepath.IsAbs(target) {
-				target = filepath.Join(filepath.Dir(path), target)
-			}
-			// Проверяем, находится ли цель внутри исходной директории
-			relTarget, err := filepath.Rel(source, target)
-			if err != nil || strings.HasPrefix(relTarget, "..") {
-				// Цель находится вне исходной директории, пропускаем
-				return nil
-			}
-			// Проверяем, является ли цель директорией
-			// Если цель не существует, просто пропускаем (не добавляем битый симлинк)
-			targetInfo, err := os.Stat(target)
-			if err != nil {
-				// Цель не существует, пропускаем
-				return nil
-			}
-			if targetInfo.IsDir() {
-				// Симлинк указывает на директорию, пропускаем
+		if info.IsDir() {
+			if relPath == "." || c.Options.SkipDirEntries {
 				return nil
 			}
+			return addDirToZip(writer, relPath, info)
 		}
 
-		relPath, err := filepath.Rel(source, path)
-		if err != nil {
-			return err
+		if skipZipEntry(info) {
+			return nil
 		}
 
 		return c.addFileToZip(writer, path, relPath)
 	})
 }
 
-func (c *ZipCompressor) addFileToZip(writer *zip.Writer, filePath, zipPath string) error {
-	// Используем Lstat, чтобы не следовать симлинкам
-	info, err := os.Lstat(filePath)
+// addDirToZip пишет запись директории, чтобы пустые директории пережили
+// восстановление вместе с правами
+func addDirToZip(writer *zip.Writer, zipPath string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
 	if err != nil {
-		// Файл не существует или недоступен, пропускаем
-		return nil
+		return err
 	}
+	header.Name = filepath.ToSlash(zipPath) + "/"
+	header.Method = zip.Store
 
-	// Дополнительная проверка: если это директория, пропускаем
+	_, err = writer.CreateHeader(header)
+	return err
+}
+
+// skipZipEntry отсекает директории (их пишет addDirToZip) и специальные файлы;
+// симлинки обрабатывает addFileToZip
+func skipZipEntry(info os.FileInfo) bool {
+	// Пропускаем директории
 	if info.IsDir() {
-		return nil
+		return true
 	}
 
 	// Пропускаем специальные файлы (socket, named pipe, device files)
 	mode := info.Mode()
-	if mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0 {
+	return mode&os.ModeSocket != 0 || mode&os.ModeNamedPipe != 0 || mode&os.ModeDevice != 0
+}
+
+func (c *ZipCompressor) addFileToZip(writer *zip.Writer, filePath, zipPath string) error {
+	// Используем Lstat, чтобы не следовать симлинкам
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		// Файл не существует или недоступен, пропускаем
 		return nil
 	}
 
-	// Если это симлинк, проверяем, что цель существует и это файл
-	if mode&os.ModeSymlink != 0 {
-		target, err := os.Readlink(filePath)
-		if err != nil {
-			// Не удалось прочитать симлинк, пропускаем
-			return nil
-		}
-		// Получаем абсолютный путь цели
-		if !filepath.IsAbs(target) {
-			target = filepath.Join(filepath.Dir(filePath), target)
-		}
-		// Проверяем, что цель существует и это файл
-		// Если цель не существует, просто добавляем симлинк как есть (без разыменования)
-		targetInfo, err := os.Stat(target)
-		if err != nil {
-			// Цель не существует, добавляем симлинк как есть
-			// Не меняем info, используем информацию о самом симлинке
-		} else if targetInfo.IsDir() {
-			// Цель - директория, пропускаем
+	if skipZipEntry(info) {
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		targetInfo, err := os.Stat(filePath)
+		switch {
+		case !c.Options.FollowSymlinks || err != nil:
+			// Симлинк (и битый симлинк при follow_symlinks) сохраняется как симлинк
+			return addSymlinkToZip(writer, filePath, zipPath, info)
+		case targetInfo.IsDir():
+			// Директории по симлинкам обходит Walker, здесь их не разыменовать
 			return nil
-		} else {
+		default:
 			// Используем информацию о цели для создания заголовка
 			info = targetInfo
 		}
 	}
 
-	file, err := os.Open(filePath)
+	file, err := openSource(filePath, c.Options.NoAtime)
 	if err != nil {
 		// Если не удалось открыть файл, пропускаем
 		return nil
@@ -186,14 +177,40 @@ func (c *ZipCompressor) addFileToZip(writer *zip.Writer, filePath, zipPath strin
 		return err
 	}
 
-	_, err = io.Copy(w, file)
+	_, err = io.Copy(w, c.Options.Progress.Reader(c.Options.Throttle.Reader(file, 0)))
+	return err
+}
+
+// addSymlinkToZip пишет симлинк записью с режимом symlink, содержимое которой -
+// путь цели (как zip -y из Info-ZIP)
+func addSymlinkToZip(writer *zip.Writer, filePath, zipPath string, info os.FileInfo) error {
+	target, err := os.Readlink(filePath)
+	if err != nil {
+		// Не удалось прочитать симлинк, пропускаем
+		return nil
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(zipPath)
+	header.Method = zip.Store
+
+	w, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, target)
 	return err
 }
 
-type TarCompressor struct{}
+type TarCompressor struct {
+	Options Options
+}
 
 func (c *TarCompressor) Compress(source, destination string) error {
-	tarFile, err := os.Create(destination)
+	tarFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create tar file: %w", err)
 	}
@@ -216,20 +233,42 @@ func (c *TarCompressor) Compress(source, destination string) error {
 			return err
 		}
 
-		if info.IsDir() {
-			return nil
-		}
-
 		relPath, err := filepath.Rel(source, path)
 		if err != nil {
 			return err
 		}
 
+		if info.IsDir() {
+			if relPath == "." || c.Options.SkipDirEntries {
+				return nil
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relPath) + "/"
+			return writer.WriteHeader(header)
+		}
+
 		return c.addFileToTar(writer, path, relPath)
 	})
 }
 
 func (c *TarCompressor) addFileToTar(writer *tar.Writer, filePath, tarPath string) error {
+	// Без follow_symlinks симлинк сохраняется записью typelink
+	if info, err := os.Lstat(filePath); err == nil && info.Mode()&os.ModeSymlink != 0 && !c.Options.FollowSymlinks {
+		target, err := os.Readlink(filePath)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, target)
+		if err != nil {
+			return err
+		}
+		header.Name = tarPath
+		return writer.WriteHeader(header)
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return err
@@ -252,16 +291,23 @@ func (c *TarCompressor) addFileToTar(writer *tar.Writer, filePath, tarPath strin
 		return err
 	}
 
-	_, err = io.Copy(writer, file)
+	_, err = io.Copy(writer, c.Options.Progress.Reader(file))
 	return err
 }
 
-type TarGzCompressor struct{}
+type TarGzCompressor struct {
+	Options Options
+}
 
 func (c *TarGzCompressor) Compress(source, destination string) error {
 	// Сначала создаем tar во временный файл
 	tmpTar := destination + ".tmp.tar"
-	if err := (&TarCompressor{}).Compress(source, tmpTar); err != nil {
+	// Временный tar не разбивается на тома - разбивается итоговый архив. Ход
+	// считается по чтению временного tar, чтобы не учитывать данные дважды
+	tarOpts := c.Options
+	tarOpts.SplitSize = 0
+	tarOpts.Progress = nil
+	if err := (&TarCompressor{Options: tarOpts}).Compress(source, tmpTar); err != nil {
 		return err
 	}
 	defer os.Remove(tmpTar)
@@ -273,24 +319,33 @@ func (c *TarGzCompressor) Compress(source, destination string) error {
 	}
 	defer tarFile.Close()
 
-	gzFile, err := os.Create(destination)
+	gzFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create gzip file: %w", err)
 	}
 	defer gzFile.Close()
 
-	writer := gzip.NewWriter(gzFile)
-	defer writer.Close()
-
-	_, err = io.Copy(writer, tarFile)
+	writer, err := newGzipWriter(gzFile, c.Options)
 	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, c.Options.Progress.Reader(tarFile)); err != nil {
+		writer.Close()
 		return fmt.Errorf("failed to compress tar: %w", err)
 	}
 
-	return nil
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress tar: %w", err)
+	}
+
+	return gzFile.Close()
 }
 
-type NoCompressor struct{}
+// NoCompressor копирует файл без сжатия
+type NoCompressor struct {
+	Options Options
+}
 
 func (c *NoCompressor) Compress(source, destination string) error {
 	srcFile, err := os.Open(source)
@@ -299,34 +354,89 @@ func (c *NoCompressor) Compress(source, destination string) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(destination)
+	dstFile, err := createArchive(destination, c.Options)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer dstFile.Close()
 
-	_, err = io.Copy(dstFile, srcFile)
+	_, err = io.Copy(dstFile, c.Options.Progress.Reader(srcFile))
 	if err != nil {
 		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	return nil
+	return dstFile.Close()
+}
+
+// Options - параметры сжатия для алгоритмов, которые их поддерживают
+type Options struct {
+	// Level - уровень сжатия (0 - по умолчанию для алгоритма)
+	Level int
+	// Workers - число потоков сжатия (0 - по умолчанию для алгоритма: zstd - по
+	// числу CPU, gzip и xz - один поток)
+	Workers int
+	// Throttle ограничивает скорость чтения файлов источника при упаковке дерева
+	Throttle *utils.Throttle
+	// LinkDest - предыдущий снимок для format: directory с incremental: hardlink
+	LinkDest string
+	// NoAtime открывает файлы источника с O_NOATIME (read_only): чтение не меняет
+	// время доступа, если ФС и права это позволяют
+	NoAtime bool
+	// FollowSymlinks архивирует содержимое целей симлинков; без него симлинки
+	// сохраняются записями-симлинками (tar typelink, zip с режимом symlink)
+	FollowSymlinks bool
+	// SplitSize - размер тома (split_size): архив пишется томами .part001, .part002,
+	// ...; 0 - одним файлом
+	SplitSize int64
+	// SkipDirEntries не пишет в tar и zip записи директорий (skip_empty_dirs):
+	// пустые директории не попадают в архив
+	SkipDirEntries bool
+	// Progress считает прочитанные байты источника и записанные байты архива
+	// для вывода хода бэкапа (nil - не считается)
+	Progress *progress.Reporter
+}
+
+// createArchive создает файл архива destination; с opts.SplitSize архив пишется
+// томами, а уместившийся в один том остается обычным файлом
+func createArchive(destination string, opts Options) (io.WriteCloser, error) {
+	if opts.SplitSize > 0 {
+		return opts.Progress.WriteCloser(utils.CreateVolumes(destination, opts.SplitSize)), nil
+	}
+	file, err := os.Create(destination)
+	if err != nil {
+		return nil, err
+	}
+	return opts.Progress.WriteCloser(file), nil
 }
 
 func NewCompressor(compressionType string) (Compressor, error) {
+	return NewCompressorWithOptions(compressionType, Options{})
+}
+
+// NewCompressorWithOptions создает компрессор с заданными параметрами сжатия
+func NewCompressorWithOptions(compressionType string, opts Options) (Compressor, error) {
 	switch strings.ToLower(compressionType) {
 	case "gzip":
-		return &GzipCompressor{}, nil
+		return &GzipCompressor{Options: opts}, nil
 	case "zip":
-		return &ZipCompressor{}, nil
+		return &ZipCompressor{Options: opts}, nil
 	case "tar":
-		return &TarCompressor{}, nil
+		return &TarCompressor{Options: opts}, nil
 	case "tar.gz":
-		return &TarGzCompressor{}, nil
+		return &TarGzCompressor{Options: opts}, nil
+	case "zstd":
+		return &ZstdCompressor{Options: opts}, nil
+	case "tar.zst":
+		return &TarZstdCompressor{Options: opts}, nil
+	case "xz":
+		return &XzCompressor{Options: opts}, nil
+	case "tar.xz":
+		return &TarXzCompressor{Options: opts}, nil
+	case "directory":
+		return &DirectoryCompressor{Options: opts}, nil
 	case "none", "":
-		return &NoCompressor{}, nil
+		return &NoCompressor{Options: opts}, nil
 	default:
 		return nil, fmt.Errorf("unsupported compression type: %s", compressionType)
 	}
 }
-
@@ -0,0 +1,146 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DirectoryCompressor не упаковывает данные, а копирует дерево источника в
+// директорию destination (format: directory). Права директорий выставляются
+// после копирования содержимого, иначе в директорию без права записи нельзя было бы писать.
+// С Options.LinkDest неизмененные файлы создаются жесткими ссылками на предыдущий снимок
+type DirectoryCompressor struct {
+	Options Options
+
+	// Linked и LinkedBytes - сколько файлов и байт взято из LinkDest жесткими ссылками
+	Linked      int
+	LinkedBytes int64
+}
+
+func (c *DirectoryCompressor) Compress(source, destination string) error {
+	if err := os.MkdirAll(destination, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to stat source file: %w", err)
+	}
+
+	return copyRegularFile(source, filepath.Join(destination, filepath.Base(source)), info, c.Options)
+}
+
+func (c *DirectoryCompressor) CompressTree(root string, walk Walker, destination string) error {
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	var dirs []string
+	var modes []os.FileMode
+
+	err := walk(func(relPath string, info os.FileInfo) error {
+		path := filepath.Join(root, relPath)
+		target := filepath.Join(destination, relPath)
+
+		if info.IsDir() {
+			dirs = append(dirs, target)
+			modes = append(modes, info.Mode()&modeMask)
+			return os.MkdirAll(target, 0700)
+		}
+
+		// Симлинки сохраняются как симлинки, как и в tar
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				// Не удалось прочитать симлинк, пропускаем
+				return nil
+			}
+			return os.Symlink(link, target)
+		}
+
+		if c.link(relPath, target, info) {
+			return nil
+		}
+
+		if err := copyRegularFile(path, target, info, c.Options); err != nil {
+			if os.IsNotExist(err) {
+				// Файл удален во время обхода
+				return nil
+			}
+			return fmt.Errorf("failed to copy %s: %w", relPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i], modes[i]); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", dirs[i], err)
+		}
+	}
+
+	return os.Chmod(destination, 0755)
+}
+
+// link создает target жесткой ссылкой на тот же файл в LinkDest, если он не изменился
+// (размер, время изменения и права совпадают). false - файл нужно скопировать
+func (c *DirectoryCompressor) link(relPath, target string, info os.FileInfo) bool {
+	if c.Options.LinkDest == "" {
+		return false
+	}
+
+	previous := filepath.Join(c.Options.LinkDest, relPath)
+	prevInfo, err := os.Lstat(previous)
+	if err != nil || !prevInfo.Mode().IsRegular() {
+		return false
+	}
+	if prevInfo.Size() != info.Size() || !prevInfo.ModTime().Equal(info.ModTime()) || prevInfo.Mode() != info.Mode() {
+		return false
+	}
+
+	// Другая ФС или превышен лимит ссылок на inode - файл просто копируется
+	if err := os.Link(previous, target); err != nil {
+		return false
+	}
+
+	c.Linked++
+	c.LinkedBytes += info.Size()
+	return true
+}
+
+// modeMask - биты прав, которые копируются точно (включая setuid/setgid/sticky)
+const modeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// copyRegularFile копирует файл с правами и временем изменения источника;
+// opts.Throttle ограничивает скорость чтения
+func copyRegularFile(source, destination string, info os.FileInfo, opts Options) error {
+	srcFile, err := openSource(source, opts.NoAtime)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(opts.Progress.WriteCloser(dstFile), opts.Progress.Reader(opts.Throttle.Reader(srcFile, 0))); err != nil {
+		return err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(destination, info.Mode()&modeMask); err != nil {
+		return err
+	}
+
+	return os.Chtimes(destination, info.ModTime(), info.ModTime())
+}
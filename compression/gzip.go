@@ -0,0 +1,53 @@
+package compression
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/pgzip"
+)
+
+// gzipBlockSize - размер блока, который сжимает один поток pgzip
+const gzipBlockSize = 1 << 20
+
+// newGzipWriter создает gzip-поток с уровнем opts.Level (1-9, 0 - по умолчанию).
+// С opts.Workers > 1 блоки сжимаются параллельно (pgzip); результат - обычный
+// gzip, который распаковывают gzip, tar -z и goback restore
+func newGzipWriter(w io.Writer, opts Options) (io.WriteCloser, error) {
+	level := gzip.DefaultCompression
+	if opts.Level > 0 {
+		level = opts.Level
+	}
+
+	if opts.Workers <= 1 {
+		writer, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip encoder: %w", err)
+		}
+		return writer, nil
+	}
+
+	writer, err := pgzip.NewWriterLevel(w, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip encoder: %w", err)
+	}
+	if err := writer.SetConcurrency(gzipBlockSize, opts.Workers); err != nil {
+		return nil, fmt.Errorf("failed to create gzip encoder: %w", err)
+	}
+	return writer, nil
+}
+
+// newZipWriter создает zip-архив, сжимающий записи с уровнем opts.Level
+func newZipWriter(w io.Writer, opts Options) *zip.Writer {
+	writer := zip.NewWriter(w)
+	if opts.Level > 0 {
+		level := opts.Level
+		writer.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return writer
+}
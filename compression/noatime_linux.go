@@ -0,0 +1,7 @@
+//go:build linux
+
+package compression
+
+import "syscall"
+
+const noAtimeFlag = syscall.O_NOATIME
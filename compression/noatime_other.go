@@ -0,0 +1,6 @@
+//go:build !linux
+
+package compression
+
+// O_NOATIME есть только в Linux
+const noAtimeFlag = 0
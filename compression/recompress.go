@@ -0,0 +1,191 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Codecs - алгоритмы внешнего потока архива, между которыми возможна перепаковка
+var Codecs = []string{"gzip", "zstd", "xz", "none"}
+
+// Codec возвращает алгоритм внешнего потока архива типа compressionType
+// (tar.gz - gzip, tar - none); для zip и директорий - пустую строку
+func Codec(compressionType string) string {
+	switch compressionType {
+	case "gzip", "tar.gz":
+		return "gzip"
+	case "zstd", "tar.zst":
+		return "zstd"
+	case "xz", "tar.xz":
+		return "xz"
+	case "tar", "none":
+		return "none"
+	default:
+		return ""
+	}
+}
+
+// ConvertType возвращает тип архива, который получится из compressionType при
+// замене внешнего потока на codec: tar.gz + zstd - tar.zst, gzip + zstd - zstd
+func ConvertType(compressionType, codec string) (string, bool) {
+	tar := compressionType == "tar" || compressionType == "tar.gz" || compressionType == "tar.zst" || compressionType == "tar.xz"
+	if Codec(compressionType) == "" {
+		return "", false
+	}
+
+	switch {
+	case codec == "gzip" && tar:
+		return "tar.gz", true
+	case codec == "gzip":
+		return "gzip", true
+	case codec == "zstd" && tar:
+		return "tar.zst", true
+	case codec == "zstd":
+		return "zstd", true
+	case codec == "xz" && tar:
+		return "tar.xz", true
+	case codec == "xz":
+		return "xz", true
+	case codec == "none" && tar:
+		return "tar", true
+	case codec == "none":
+		return "none", true
+	default:
+		return "", false
+	}
+}
+
+// Recompress перепаковывает внешний поток архива source (типа sourceType) в
+// destination (типа targetType) без распаковки содержимого tar. После записи
+// destination читается заново и сравнивается с исходными данными по SHA-256;
+// при несовпадении destination удаляется и возвращается ошибка
+func Recompress(source, destination, sourceType, targetType string, opts Options) error {
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer srcFile.Close()
+
+	reader, err := newCodecReader(srcFile, Codec(sourceType))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dstFile, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer dstFile.Close()
+
+	writer, err := newCodecWriter(dstFile, Codec(targetType), opts)
+	if err != nil {
+		os.Remove(destination)
+		return err
+	}
+
+	original := sha256.New()
+	if _, err := io.Copy(writer, io.TeeReader(reader, original)); err != nil {
+		writer.Close()
+		os.Remove(destination)
+		return fmt.Errorf("failed to recompress: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		os.Remove(destination)
+		return fmt.Errorf("failed to recompress: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
+		os.Remove(destination)
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	converted, err := streamSum(destination, Codec(targetType))
+	if err != nil {
+		os.Remove(destination)
+		return fmt.Errorf("failed to verify recompressed archive: %w", err)
+	}
+	if !bytes.Equal(converted, original.Sum(nil)) {
+		os.Remove(destination)
+		return fmt.Errorf("recompressed archive does not match the original")
+	}
+
+	return nil
+}
+
+// streamSum возвращает SHA-256 распакованного внешнего потока файла
+func streamSum(path, codec string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := newCodecReader(file, codec)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
+
+func newCodecReader(r io.Reader, codec string) (io.ReadCloser, error) {
+	switch codec {
+	case "gzip":
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return reader, nil
+	case "zstd":
+		reader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return reader.IOReadCloser(), nil
+	case "xz":
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xz stream: %w", err)
+		}
+		return io.NopCloser(reader), nil
+	case "none":
+		return io.NopCloser(r), nil
+	default:
+		return nil, fmt.Errorf("unsupported codec: %s", codec)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func newCodecWriter(w io.Writer, codec string, opts Options) (io.WriteCloser, error) {
+	switch codec {
+	case "gzip":
+		return newGzipWriter(w, opts)
+	case "zstd":
+		return newZstdWriter(w, opts)
+	case "xz":
+		return newXzWriter(w, opts)
+	case "none":
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec: %s", codec)
+	}
+}
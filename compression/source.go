@@ -0,0 +1,20 @@
+package compression
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// openSource открывает файл источника только на чтение. С noAtime чтение не
+// обновляет время доступа; O_NOATIME разрешен только владельцу файла (или с
+// CAP_FOWNER), поэтому при отказе файл открывается обычным образом
+func openSource(path string, noAtime bool) (*os.File, error) {
+	if noAtime && noAtimeFlag != 0 {
+		file, err := os.OpenFile(path, os.O_RDONLY|noAtimeFlag, 0)
+		if err == nil || !errors.Is(err, fs.ErrPermission) {
+			return file, err
+		}
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,219 @@
+package compression
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Walker обходит дерево источника и вызывает visit для каждого элемента
+// (relPath - путь относительно корня). Фильтрация (exclude_patterns и т.п.)
+// выполняется на стороне Walker
+type Walker func(visit func(relPath string, info os.FileInfo) error) error
+
+// TreeCompressor упаковывает дерево потоково, читая файлы прямо из источника
+// без промежуточной копии
+type TreeCompressor interface {
+	CompressTree(root string, walk Walker, destination string) error
+}
+
+// StreamCompressor сжимает поток без промежуточного файла (дамп базы данных,
+// который пишется сразу в архив)
+type StreamCompressor interface {
+	CompressStream(r io.Reader, destination string) error
+}
+
+func (c *GzipCompressor) CompressStream(r io.Reader, destination string) error {
+	return compressStream(r, destination, "gzip", c.Options)
+}
+
+func (c *ZstdCompressor) CompressStream(r io.Reader, destination string) error {
+	return compressStream(r, destination, "zstd", c.Options)
+}
+
+func (c *NoCompressor) CompressStream(r io.Reader, destination string) error {
+	return compressStream(r, destination, "none", c.Options)
+}
+
+// compressStream пишет поток r в destination через кодек codec
+func compressStream(r io.Reader, destination, codec string, opts Options) error {
+	file, err := createArchive(destination, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer file.Close()
+
+	writer, err := newCodecWriter(file, codec, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, opts.Progress.Reader(r)); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return file.Close()
+}
+
+func (c *TarCompressor) CompressTree(root string, walk Walker, destination string) error {
+	tarFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create tar file: %w", err)
+	}
+	defer tarFile.Close()
+
+	if err := writeTarTree(tarFile, root, walk, c.Options); err != nil {
+		return err
+	}
+
+	return tarFile.Close()
+}
+
+func (c *TarGzCompressor) CompressTree(root string, walk Walker, destination string) error {
+	gzFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip file: %w", err)
+	}
+	defer gzFile.Close()
+
+	// tar пишется сразу в gzip-поток, без временного .tar
+	gzWriter, err := newGzipWriter(gzFile, c.Options)
+	if err != nil {
+		return err
+	}
+	if err := writeTarTree(gzWriter, root, walk, c.Options); err != nil {
+		return err
+	}
+
+	if err := gzWriter.Close(); err != nil {
+		return fmt.Errorf("failed to compress tar: %w", err)
+	}
+
+	return gzFile.Close()
+}
+
+// writeTarTree пишет дерево в tar-поток w; opts.Throttle ограничивает скорость чтения файлов
+func writeTarTree(w io.Writer, root string, walk Walker, opts Options) error {
+	writer := tar.NewWriter(w)
+
+	err := walk(func(relPath string, info os.FileInfo) error {
+		// Директории сохраняются отдельными записями, чтобы восстановить их точные
+		// права и пустые директории
+		if info.IsDir() {
+			if opts.SkipDirEntries {
+				return nil
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relPath) + "/"
+			return writer.WriteHeader(header)
+		}
+
+		path := filepath.Join(root, relPath)
+
+		// Симлинки сохраняются как симлинки, как и при копировании дерева; с
+		// follow_symlinks Walker передает их цели, и сюда попадают только битые
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				// Не удалось прочитать симлинк, пропускаем
+				return nil
+			}
+
+			header, err := tar.FileInfoHeader(info, target)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(relPath)
+			return writer.WriteHeader(header)
+		}
+
+		file, err := openSource(path, opts.NoAtime)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Файл удален во время обхода
+				return nil
+			}
+			return err
+		}
+		defer file.Close()
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+
+		// Файл мог измениться после stat - пишем ровно объявленный размер
+		n, err := io.CopyN(writer, opts.Progress.Reader(opts.Throttle.Reader(file, 0)), header.Size)
+		if err == io.EOF {
+			// Файл уменьшился во время чтения - дополняем нулями, чтобы архив остался корректным
+			fmt.Printf("Warning: %s changed while archiving\n", relPath)
+			_, err = io.CopyN(writer, zeroReader{}, header.Size-n)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", relPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (c *ZipCompressor) CompressTree(root string, walk Walker, destination string) error {
+	zipFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer zipFile.Close()
+
+	writer := newZipWriter(zipFile, c.Options)
+
+	err = walk(func(relPath string, info os.FileInfo) error {
+		if info.IsDir() {
+			if c.Options.SkipDirEntries {
+				return nil
+			}
+			return addDirToZip(writer, relPath, info)
+		}
+
+		path := filepath.Join(root, relPath)
+		if skipZipEntry(info) {
+			return nil
+		}
+		return c.addFileToZip(writer, path, relPath)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize zip file: %w", err)
+	}
+
+	return zipFile.Close()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
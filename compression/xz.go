@@ -0,0 +1,197 @@
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ulikunitz/xz"
+)
+
+const (
+	// DefaultXzLevel - уровень сжатия xz по умолчанию (как у xz -6)
+	DefaultXzLevel = 6
+	// minXzBlock - минимальный размер блока при многопоточном сжатии
+	minXzBlock = 1 << 20
+)
+
+// xzDictSizes - размер словаря для уровней 0-9, как в пресетах утилиты xz
+var xzDictSizes = [10]int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// XzCompressor сжимает один файл в xz
+type XzCompressor struct {
+	Options Options
+}
+
+func (c *XzCompressor) Compress(source, destination string) error {
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	return compressStream(srcFile, destination, "xz", c.Options)
+}
+
+func (c *XzCompressor) CompressStream(r io.Reader, destination string) error {
+	return compressStream(r, destination, "xz", c.Options)
+}
+
+// TarXzCompressor упаковывает в tar и сжимает xz одним потоком
+type TarXzCompressor struct {
+	Options Options
+}
+
+func (c *TarXzCompressor) Compress(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	root, walk := filepath.Dir(source), func(visit func(relPath string, info os.FileInfo) error) error {
+		return visit(filepath.Base(source), info)
+	}
+	if info.IsDir() {
+		root, walk = source, walkAll(source)
+	}
+
+	return c.CompressTree(root, walk, destination)
+}
+
+func (c *TarXzCompressor) CompressTree(root string, walk Walker, destination string) error {
+	xzFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create xz file: %w", err)
+	}
+	defer xzFile.Close()
+
+	writer, err := newXzWriter(xzFile, c.Options)
+	if err != nil {
+		return err
+	}
+
+	if err := writeTarTree(writer, root, walk, c.Options); err != nil {
+		writer.Close()
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress tar: %w", err)
+	}
+
+	return xzFile.Close()
+}
+
+// newXzWriter создает xz-поток с уровнем opts.Level (1-9, 0 - DefaultXzLevel).
+// С opts.Workers > 1 данные сжимаются блоками параллельно, каждый блок - отдельный
+// xz-поток; такой файл распаковывают xz, tar -J и goback restore
+func newXzWriter(w io.Writer, opts Options) (io.WriteCloser, error) {
+	level := opts.Level
+	if level <= 0 || level > 9 {
+		level = DefaultXzLevel
+	}
+	config := xz.WriterConfig{DictCap: xzDictSizes[level]}
+	if err := config.Verify(); err != nil {
+		return nil, fmt.Errorf("failed to create xz encoder: %w", err)
+	}
+
+	if opts.Workers <= 1 {
+		writer, err := config.NewWriter(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create xz encoder: %w", err)
+		}
+		return writer, nil
+	}
+
+	// Как xz -T: блок - три словаря, чтобы потоки не теряли в степени сжатия
+	blockSize := 3 * config.DictCap
+	if blockSize < minXzBlock {
+		blockSize = minXzBlock
+	}
+	return &parallelXzWriter{w: w, config: config, blockSize: blockSize, workers: opts.Workers}, nil
+}
+
+// parallelXzWriter копит workers блоков, сжимает их одновременно и пишет по порядку
+type parallelXzWriter struct {
+	w         io.Writer
+	config    xz.WriterConfig
+	blockSize int
+	workers   int
+
+	current []byte
+	pending [][]byte
+	written bool
+}
+
+func (p *parallelXzWriter) Write(data []byte) (int, error) {
+	total := len(data)
+	for len(data) > 0 {
+		if p.current == nil {
+			p.current = make([]byte, 0, p.blockSize)
+		}
+		n := p.blockSize - len(p.current)
+		if n > len(data) {
+			n = len(data)
+		}
+		p.current = append(p.current, data[:n]...)
+		data = data[n:]
+
+		if len(p.current) == p.blockSize {
+			p.pending = append(p.pending, p.current)
+			p.current = nil
+			if len(p.pending) == p.workers {
+				if err := p.flush(); err != nil {
+					return total - len(data), err
+				}
+			}
+		}
+	}
+	return total, nil
+}
+
+func (p *parallelXzWriter) Close() error {
+	// Пустой вход - все равно корректный xz-файл из одного пустого потока
+	if len(p.current) > 0 || !p.written {
+		p.pending = append(p.pending, p.current)
+		p.current = nil
+	}
+	return p.flush()
+}
+
+// flush сжимает накопленные блоки параллельно и пишет их в исходном порядке
+func (p *parallelXzWriter) flush() error {
+	outputs := make([]bytes.Buffer, len(p.pending))
+	errs := make([]error, len(p.pending))
+
+	var wg sync.WaitGroup
+	for i, block := range p.pending {
+		wg.Add(1)
+		go func(i int, block []byte) {
+			defer wg.Done()
+			writer, err := p.config.NewWriter(&outputs[i])
+			if err == nil {
+				_, err = writer.Write(block)
+			}
+			if err == nil {
+				err = writer.Close()
+			}
+			errs[i] = err
+		}(i, block)
+	}
+	wg.Wait()
+	p.pending = p.pending[:0]
+
+	for i := range outputs {
+		if errs[i] != nil {
+			return fmt.Errorf("failed to compress xz block: %w", errs[i])
+		}
+		if _, err := outputs[i].WriteTo(p.w); err != nil {
+			return err
+		}
+		p.written = true
+	}
+	return nil
+}
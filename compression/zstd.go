@@ -0,0 +1,128 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ZstdCompressor сжимает один файл в zstd
+type ZstdCompressor struct {
+	Options Options
+}
+
+func (c *ZstdCompressor) Compress(source, destination string) error {
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dstFile.Close()
+
+	writer, err := newZstdWriter(dstFile, c.Options)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(writer, c.Options.Progress.Reader(srcFile)); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress: %w", err)
+	}
+
+	return dstFile.Close()
+}
+
+// TarZstdCompressor упаковывает в tar и сжимает zstd одним потоком
+type TarZstdCompressor struct {
+	Options Options
+}
+
+func (c *TarZstdCompressor) Compress(source, destination string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("failed to stat source: %w", err)
+	}
+
+	root, walk := filepath.Dir(source), func(visit func(relPath string, info os.FileInfo) error) error {
+		return visit(filepath.Base(source), info)
+	}
+	if info.IsDir() {
+		root, walk = source, walkAll(source)
+	}
+
+	return c.CompressTree(root, walk, destination)
+}
+
+func (c *TarZstdCompressor) CompressTree(root string, walk Walker, destination string) error {
+	zstFile, err := createArchive(destination, c.Options)
+	if err != nil {
+		return fmt.Errorf("failed to create zstd file: %w", err)
+	}
+	defer zstFile.Close()
+
+	writer, err := newZstdWriter(zstFile, c.Options)
+	if err != nil {
+		return err
+	}
+
+	if err := writeTarTree(writer, root, walk, c.Options); err != nil {
+		writer.Close()
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to compress tar: %w", err)
+	}
+
+	return zstFile.Close()
+}
+
+func newZstdWriter(w io.Writer, opts Options) (*zstd.Encoder, error) {
+	encoderOptions := []zstd.EOption{}
+	if opts.Level > 0 {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+	}
+	if opts.Workers > 0 {
+		encoderOptions = append(encoderOptions, zstd.WithEncoderConcurrency(opts.Workers))
+	}
+
+	writer, err := zstd.NewWriter(w, encoderOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+
+	return writer, nil
+}
+
+// walkAll обходит директорию целиком, без фильтрации
+func walkAll(root string) Walker {
+	return func(visit func(relPath string, info os.FileInfo) error) error {
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			return visit(relPath, info)
+		})
+	}
+}
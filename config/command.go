@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CommandLine - команда бэкапа. Строка выполняется через shell (пайпы,
+// перенаправления), список - напрямую как argv без shell и без экранирования:
+//
+//	command: ["pg_dump", "--format=custom", "app"]
+type CommandLine struct {
+	// Line - команда для shell
+	Line string
+	// Args - программа и аргументы для запуска без shell
+	Args []string
+}
+
+func (c *CommandLine) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = CommandLine{Line: value.Value}
+		return nil
+	}
+	var args []string
+	if err := value.Decode(&args); err != nil {
+		return fmt.Errorf("line %d: command must be a string or a list of arguments", value.Line)
+	}
+	*c = CommandLine{Args: args}
+	return nil
+}
+
+// MarshalYAML записывает команду в той же форме, в какой она была задана
+func (c CommandLine) MarshalYAML() (interface{}, error) {
+	if c.Args != nil {
+		return c.Args, nil
+	}
+	return c.Line, nil
+}
+
+// IsZero сообщает, что команда не задана
+func (c CommandLine) IsZero() bool {
+	return strings.TrimSpace(c.Line) == "" && len(c.Args) == 0
+}
+
+// IsArgv сообщает, что команда задана списком аргументов и выполняется без shell
+func (c CommandLine) IsArgv() bool {
+	return c.Args != nil
+}
+
+// String возвращает команду для вывода: строку как есть, аргументы - в кавычках
+// там, где это нужно
+func (c CommandLine) String() string {
+	if !c.IsArgv() {
+		return c.Line
+	}
+	quoted := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"goback/encryption"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Переменные окружения с ключами для расшифровки конфигурации
+const (
+	EnvConfigIdentity   = "GOBACK_CONFIG_IDENTITY"
+	EnvConfigPassphrase = "GOBACK_CONFIG_PASSPHRASE"
+)
+
+// decryptConfig расшифровывает конфигурацию в памяти, если она зашифрована age или sops.
+// Незашифрованные данные возвращаются как есть
+func decryptConfig(configPath string, data []byte) ([]byte, error) {
+	if encryption.IsAgeEncrypted(data) {
+		identityFile := os.Getenv(EnvConfigIdentity)
+		passphrase := os.Getenv(EnvConfigPassphrase)
+		if identityFile == "" && passphrase == "" {
+			return nil, fmt.Errorf("config is age-encrypted: set %s or %s", EnvConfigIdentity, EnvConfigPassphrase)
+		}
+
+		decrypted, err := encryption.DecryptBytes(data, identityFile, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt config: %w", err)
+		}
+		return decrypted, nil
+	}
+
+	if isSopsEncrypted(data) {
+		return decryptSops(configPath)
+	}
+
+	return data, nil
+}
+
+// isSopsEncrypted проверяет наличие метаданных sops в корне YAML
+func isSopsEncrypted(data []byte) bool {
+	var probe struct {
+		Sops map[string]interface{} `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	_, hasMac := probe.Sops["mac"]
+	return hasMac
+}
+
+// decryptSops вызывает sops; расшифрованный результат остается только в памяти
+func decryptSops(configPath string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command("sops", "--decrypt", "--input-type", "yaml", "--output-type", "yaml", configPath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("sops decryption failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dependsOn сообщает, что бэкап dependency указан в depends_on бэкапа backup:
+// по имени или по имени группы (все бэкапы mysql.per_database)
+func dependsOn(backup, dependency *BackupConfig) bool {
+	for _, name := range backup.DependsOn {
+		if dependency.Name == name || (dependency.Group != "" && dependency.Group == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortByDependencies упорядочивает бэкапы так, что каждый идет после бэкапов из
+// своего depends_on, и возвращает для каждого индексы его зависимостей в
+// результате. Зависимости, которых нет в backups (не выбраны для запуска), не
+// учитываются. Из готовых к запуску первым идет бэкап, раньше описанный в конфигурации
+func SortByDependencies(backups []BackupConfig) ([]BackupConfig, [][]int, error) {
+	placed := make([]bool, len(backups))
+	order := make([]int, 0, len(backups))
+	position := make([]int, len(backups))
+
+	for len(order) < len(backups) {
+		next := -1
+		for i := range backups {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for j := range backups {
+				if j != i && !placed[j] && dependsOn(&backups[i], &backups[j]) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+
+		if next == -1 {
+			var cycle []string
+			for i := range backups {
+				if !placed[i] {
+					cycle = append(cycle, backups[i].Name)
+				}
+			}
+			return nil, nil, fmt.Errorf("depends_on forms a cycle between: %s", strings.Join(cycle, ", "))
+		}
+
+		placed[next] = true
+		position[next] = len(order)
+		order = append(order, next)
+	}
+
+	sorted := make([]BackupConfig, len(order))
+	deps := make([][]int, len(order))
+	for k, i := range order {
+		sorted[k] = backups[i]
+		for j := range backups {
+			if j != i && dependsOn(&backups[i], &backups[j]) {
+				deps[k] = append(deps[k], position[j])
+			}
+		}
+	}
+	return sorted, deps, nil
+}
+
+// validateDependencies проверяет, что depends_on ссылается на существующие бэкапы
+// и не образует цикл
+func validateDependencies(backups []BackupConfig) error {
+	for i := range backups {
+		for _, name := range backups[i].DependsOn {
+			if name == backups[i].Name {
+				return fmt.Errorf("backup %s: depends_on cannot reference itself", backups[i].Name)
+			}
+			found := false
+			for j := range backups {
+				if backups[j].Name == name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("backup %s: depends_on references unknown backup %s", backups[i].Name, name)
+			}
+		}
+	}
+
+	_, _, err := SortByDependencies(backups)
+	return err
+}
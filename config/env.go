@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envExpander подставляет переменные окружения в значения конфигурации
+type envExpander struct {
+	// allowUnset - незаданная переменная подставляется пустой строкой, а не ошибкой
+	allowUnset bool
+	// expanded - была подставлена хотя бы одна переменная
+	expanded bool
+}
+
+// unmarshal разбирает YAML в out, подставляя переменные окружения в значения
+func (e *envExpander) unmarshal(data []byte, out interface{}) error {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	// Пустой документ
+	if root.Kind == 0 {
+		return nil
+	}
+	if err := e.expandNode(&root); err != nil {
+		return err
+	}
+	return root.Decode(out)
+}
+
+// expandNode подставляет переменные в скалярные значения дерева. Ключи не
+// меняются, алиасы указывают на уже обработанные якоря
+func (e *envExpander) expandNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := e.expandNode(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := e.expandNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "${") {
+			return nil
+		}
+		value, err := e.expand(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		// Тип незакавыченного значения определяется заново по подставленному
+		// тексту: port: ${DB_PORT} остается числом
+		if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+// expand заменяет ${NAME} значением переменной окружения и ${NAME:-default}
+// значением или default, если переменная не задана или пуста. $${ записывает
+// ${ как есть; $ без { не меняется
+func (e *envExpander) expand(value string) (string, error) {
+	var result strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start == -1 {
+			result.WriteString(value)
+			return result.String(), nil
+		}
+		if start > 0 && value[start-1] == '$' {
+			result.WriteString(value[:start])
+			result.WriteString("{")
+			value = value[start+2:]
+			continue
+		}
+		result.WriteString(value[:start])
+
+		end := strings.Index(value[start:], "}")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated ${ in %q", value[start:])
+		}
+		expr := value[start+2 : start+end]
+		value = value[start+end+1:]
+
+		name, fallback, hasDefault := strings.Cut(expr, ":-")
+		if !validEnvName(name) {
+			return "", fmt.Errorf("invalid environment variable name %q", name)
+		}
+
+		env, ok := os.LookupEnv(name)
+		switch {
+		case hasDefault && env == "":
+			env = fallback
+		case !ok && !e.allowUnset:
+			return "", fmt.Errorf("environment variable %s is not set (use ${%s:-} to allow empty)", name, name)
+		}
+		result.WriteString(env)
+		e.expanded = true
+	}
+}
+
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// allowUnsetEnv читает global.allow_unset_env до подстановки переменных:
+// от него зависит, как подставляются остальные значения
+func allowUnsetEnv(data []byte) bool {
+	var probe struct {
+		Global struct {
+			AllowUnsetEnv bool `yaml:"allow_unset_env"`
+		} `yaml:"global"`
+	}
+	if err := yaml.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.Global.AllowUnsetEnv
+}
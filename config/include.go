@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringList - список строк, который в YAML можно записать и одной строкой:
+// include: conf.d/*.yaml
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// ResolveIncludes возвращает файлы, подключаемые паттернами include. Относительные
+// паттерны отсчитываются от директории файла конфигурации. Паттерн без * ? [
+// должен указывать на существующий файл, маска может не совпасть ни с чем
+// (пустой conf.d)
+func ResolveIncludes(configPath string, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return nil, fmt.Errorf("include: empty pattern")
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(configPath), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("include %s: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("include %s: file does not exist", pattern)
+		}
+
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, fmt.Errorf("include %s: %w", path, err)
+			}
+			if info.IsDir() || seen[path] {
+				continue
+			}
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	return files, nil
+}
+
+// loadIncludedBackups читает бэкапы из подключенного файла. Файл содержит либо
+// список в backups (как основная конфигурация), либо один бэкап без обертки (как
+// файлы include_dir). Глобальные параметры задаются только в основном файле
+func loadIncludedBackups(path string, env *envExpander) ([]BackupConfig, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	decrypted, err := decryptConfig(path, data)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	encrypted := !bytes.Equal(decrypted, data)
+
+	var probe map[string]interface{}
+	if err := yaml.Unmarshal(decrypted, &probe); err != nil {
+		return nil, encrypted, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, key := range []string{"global", "tenants", "include"} {
+		if _, ok := probe[key]; ok {
+			return nil, encrypted, fmt.Errorf("%s: %s can only be set in the main config", path, key)
+		}
+	}
+
+	if _, ok := probe["backups"]; ok {
+		var file struct {
+			Backups []BackupConfig `yaml:"backups"`
+		}
+		if err := env.unmarshal(decrypted, &file); err != nil {
+			return nil, encrypted, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return file.Backups, encrypted, nil
+	}
+
+	// Пустой файл (например, все бэкапы сервиса закомментированы)
+	if len(probe) == 0 {
+		return nil, encrypted, nil
+	}
+
+	var backup BackupConfig
+	if err := env.unmarshal(decrypted, &backup); err != nil {
+		return nil, encrypted, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return []BackupConfig{backup}, encrypted, nil
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnknownKeys ищет в файле конфигурации и подключенных файлах (include_dir,
+// include) ключи, которых нет в конфигурации goback: опечатка в имени параметра
+// при обычной загрузке молча игнорируется. Файлы, которые не удалось прочитать
+// или разобрать, пропускаются - об этом сообщит LoadConfig
+func UnknownKeys(configPath string) []string {
+	var unknown []string
+
+	root, ok := readNode(configPath)
+	if !ok {
+		return nil
+	}
+	findUnknownKeys(root, reflect.TypeOf(Config{}), "", configPath, &unknown)
+
+	var top struct {
+		Global struct {
+			IncludeDir string `yaml:"include_dir"`
+		} `yaml:"global"`
+		Include StringList `yaml:"include"`
+	}
+	if err := root.Decode(&top); err != nil {
+		return unknown
+	}
+
+	backupType := reflect.TypeOf(BackupConfig{})
+	if dir := top.Global.IncludeDir; dir != "" {
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			if entry.IsDir() || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if node, ok := readNode(path); ok {
+				findUnknownKeys(node, backupType, "", path, &unknown)
+			}
+		}
+	}
+
+	files, _ := ResolveIncludes(configPath, top.Include)
+	for _, path := range files {
+		node, ok := readNode(path)
+		if !ok {
+			continue
+		}
+		// Файл include - список backups или один бэкап без обертки
+		fileType := backupType
+		if mapping := documentMapping(node); mapping != nil && mappingHasKey(mapping, "backups") {
+			fileType = reflect.TypeOf(struct {
+				Backups []BackupConfig `yaml:"backups"`
+			}{})
+		}
+		findUnknownKeys(node, fileType, "", path, &unknown)
+	}
+
+	return unknown
+}
+
+// readNode читает (и при необходимости расшифровывает) YAML-файл в дерево
+func readNode(path string) (*yaml.Node, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	data, err = decryptConfig(path, data)
+	if err != nil {
+		return nil, false
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, false
+	}
+	return &root, true
+}
+
+func documentMapping(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 && node.Content[0].Kind == yaml.MappingNode {
+		return node.Content[0]
+	}
+	return nil
+}
+
+func mappingHasKey(mapping *yaml.Node, key string) bool {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// findUnknownKeys сравнивает ключи YAML с полями типа t и дописывает в unknown
+// ключи без соответствующего поля в виде "file:line: path.key"
+func findUnknownKeys(node *yaml.Node, t reflect.Type, path, file string, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Типы со своим разбором (StringList) проверяют значение сами
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			findUnknownKeys(child, t, path, file, unknown)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, child := range node.Content {
+			findUnknownKeys(child, t.Elem(), fmt.Sprintf("%s[%d]", path, i), file, unknown)
+		}
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Map:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				findUnknownKeys(node.Content[i+1], t.Elem(), joinKey(path, node.Content[i].Value), file, unknown)
+			}
+		case reflect.Struct:
+			fields := yamlFields(t)
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				// Ключи слияния (<<: *defaults) разворачиваются yaml-декодером
+				if key.Value == "<<" {
+					continue
+				}
+				field, ok := fields[key.Value]
+				if !ok {
+					*unknown = append(*unknown, fmt.Sprintf("%s:%d: unknown key %s", file, key.Line, joinKey(path, key.Value)))
+					continue
+				}
+				findUnknownKeys(node.Content[i+1], field, joinKey(path, key.Value), file, unknown)
+			}
+		}
+	case yaml.AliasNode:
+		// Якорь проверяется там, где он объявлен
+	}
+}
+
+// yamlFields возвращает типы полей структуры по их ключам в YAML
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("yaml")
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") {
+			for key, fieldType := range yamlFields(field.Type) {
+				fields[key] = fieldType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func joinKey(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
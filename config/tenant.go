@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TenantConfig - раздел клиента: бэкапы клиента пишутся только в его backup_dir
+// и state_dir, используют его retention, destinations и notifications и не видят
+// глобальные notifications и бэкапы других клиентов
+type TenantConfig struct {
+	// Name - имя клиента; бэкапы раздела получают имена <tenant>-<name>
+	Name      string `yaml:"name"`
+	BackupDir string `yaml:"backup_dir"`
+	// StateDir - каталог, история, spool и метаданные клиента (по умолчанию backup_dir/.goback)
+	StateDir string `yaml:"state_dir"`
+	// Retention - политика по умолчанию для бэкапов клиента
+	Retention *RetentionPolicy `yaml:"retention"`
+	// Destinations (с учетными данными клиента) получают архивы бэкапов клиента,
+	// у которых нет собственных destinations
+	Destinations []DestinationConfig `yaml:"destinations"`
+	// Notifications получают итоги запусков и сводки только по бэкапам клиента
+	Notifications []NotificationConfig `yaml:"notifications"`
+	Backups       []BackupConfig       `yaml:"backups"`
+}
+
+var tenantNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// GlobalFor возвращает глобальные параметры, с которыми выполняется бэкап:
+// раздела клиента для бэкапа клиента, иначе общие
+func (c *Config) GlobalFor(backup *BackupConfig) *GlobalConfig {
+	if backup.Tenant != "" {
+		if global, ok := c.tenantGlobals[backup.Tenant]; ok {
+			return global
+		}
+	}
+	return &c.Global
+}
+
+// Scopes разбивает конфигурацию на независимые части: общие бэкапы и бэкапы
+// каждого клиента со своими глобальными параметрами. Операции над всеми бэкапами
+// (spool, каталог, сводка, уведомления о запуске) выполняются по частям, чтобы
+// данные клиентов не смешивались
+func (c *Config) Scopes() []*Config {
+	shared := &Config{Global: c.Global, Path: c.Path, Encrypted: c.Encrypted, Interpolated: c.Interpolated}
+	scopes := []*Config{shared}
+	byTenant := make(map[string]*Config)
+
+	for _, tenant := range c.Tenants {
+		scope := &Config{Global: *c.tenantGlobals[tenant.Name], Path: c.Path, Encrypted: c.Encrypted, Interpolated: c.Interpolated, Tenant: tenant.Name}
+		byTenant[tenant.Name] = scope
+		scopes = append(scopes, scope)
+	}
+
+	for _, backup := range c.Backups {
+		if scope, ok := byTenant[backup.Tenant]; ok {
+			scope.Backups = append(scope.Backups, backup)
+		} else {
+			shared.Backups = append(shared.Backups, backup)
+		}
+	}
+
+	return scopes
+}
+
+// Scope возвращает часть конфигурации из Scopes для клиента tenant ("" - общие
+// бэкапы) или nil, если такого клиента нет
+func (c *Config) Scope(tenant string) *Config {
+	for _, scope := range c.Scopes() {
+		if scope.Tenant == tenant {
+			return scope
+		}
+	}
+	return nil
+}
+
+// tenantGlobal строит глобальные параметры раздела клиента из общих: общие
+// хуки, self backup, API и notifications клиенту не достаются
+func tenantGlobal(global GlobalConfig, tenant *TenantConfig) GlobalConfig {
+	global.BackupDir = tenant.BackupDir
+	global.StateDir = tenant.StateDir
+	if tenant.Retention != nil {
+		global.Retention = *tenant.Retention
+	}
+	global.Notifications = tenant.Notifications
+	global.PreHooks = nil
+	global.PostHooks = nil
+	global.OnSuccess = nil
+	global.OnFailure = nil
+	global.IncludeDir = ""
+	global.SelfBackup = nil
+	global.API = nil
+	return global
+}
+
+// expandTenants проверяет разделы клиентов и добавляет их бэкапы в config.Backups
+// с именами <tenant>-<name>. Общие параметры к этому моменту уже проверены
+func expandTenants(config *Config) error {
+	config.tenantGlobals = make(map[string]*GlobalConfig)
+	dirs := map[string]string{"global backup_dir": config.Global.BackupDir}
+
+	for i := range config.Tenants {
+		tenant := &config.Tenants[i]
+		if !tenantNamePattern.MatchString(tenant.Name) {
+			return fmt.Errorf("tenants[%d]: name is required and may contain only letters, digits, '.', '_' and '-'", i)
+		}
+		if _, exists := config.tenantGlobals[tenant.Name]; exists {
+			return fmt.Errorf("tenants[%d]: duplicate tenant %s", i, tenant.Name)
+		}
+		if tenant.BackupDir == "" {
+			return fmt.Errorf("tenant %s: backup_dir is required", tenant.Name)
+		}
+
+		// Раздел не должен пересекаться с общим backup_dir и разделами других клиентов,
+		// иначе retention и каталог одного раздела увидят архивы другого
+		for owner, dir := range dirs {
+			if pathsOverlap(tenant.BackupDir, dir) {
+				return fmt.Errorf("tenant %s: backup_dir %s overlaps %s %s", tenant.Name, tenant.BackupDir, owner, dir)
+			}
+		}
+		dirs["backup_dir of tenant "+tenant.Name] = tenant.BackupDir
+		if tenant.StateDir != "" && pathsOverlap(tenant.StateDir, config.Global.StateDir) {
+			return fmt.Errorf("tenant %s: state_dir %s overlaps global state_dir %s", tenant.Name, tenant.StateDir, config.Global.StateDir)
+		}
+
+		// Бэкапы раздела проверяются вместе с его параметрами так же, как общие
+		scope := Config{Global: tenantGlobal(config.Global, tenant), Backups: tenant.Backups}
+		for j := range scope.Backups {
+			if len(scope.Backups[j].Destinations) == 0 {
+				scope.Backups[j].Destinations = tenant.Destinations
+			}
+		}
+		if err := validateConfig(&scope); err != nil {
+			return fmt.Errorf("tenant %s: %w", tenant.Name, err)
+		}
+		config.tenantGlobals[tenant.Name] = &scope.Global
+
+		for _, backup := range scope.Backups {
+			backup.Name = tenant.Name + "-" + backup.Name
+			backup.Tenant = tenant.Name
+			// depends_on ссылается на бэкапы того же раздела
+			dependsOn := make([]string, 0, len(backup.DependsOn))
+			for _, name := range backup.DependsOn {
+				dependsOn = append(dependsOn, tenant.Name+"-"+name)
+			}
+			backup.DependsOn = dependsOn
+			config.Backups = append(config.Backups, backup)
+		}
+	}
+
+	if len(config.Tenants) == 0 {
+		return nil
+	}
+
+	// Имя <tenant>-<name> не должно совпасть с общим бэкапом или бэкапом другого клиента
+	seen := make(map[string]bool)
+	for _, backup := range config.Backups {
+		if seen[backup.Name] {
+			return fmt.Errorf("duplicate backup name %s", backup.Name)
+		}
+		seen[backup.Name] = true
+	}
+
+	return nil
+}
+
+// pathsOverlap сообщает, что один путь совпадает с другим или вложен в него
+func pathsOverlap(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return a == b || strings.HasPrefix(a, b+string(filepath.Separator)) || strings.HasPrefix(b, a+string(filepath.Separator))
+}
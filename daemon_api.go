@@ -0,0 +1,93 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/utils"
+)
+
+// startAPI запускает HTTP API демона на listen; current возвращает действующую
+// конфигурацию (она меняется при перезагрузке):
+//
+//	POST /api/backups/<name>/invalidate - то же, что goback invalidate <name>
+func startAPI(listen string, current func() *config.Config) (*http.Server, error) {
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/backups/", func(w http.ResponseWriter, r *http.Request) {
+		cfg := current()
+		if cfg.Global.API == nil || !apiAuthorized(r, cfg.Global.API.Token) {
+			writeAPIError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+
+		name, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/backups/"), "/")
+		if !ok || name == "" || action != "invalidate" {
+			writeAPIError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAPIError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+
+		backupCfg, err := findBackupConfig(cfg, name)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		dropped, err := backup.InvalidateState(cfg.GlobalFor(backupCfg), backupCfg)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		fmt.Printf("Invalidated %s via API\n", name)
+
+		writeAPIJSON(w, http.StatusOK, map[string]interface{}{
+			"backup":      name,
+			"invalidated": dropped,
+		})
+	})
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			utils.PrintError("API server stopped: %v", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// apiAuthorized проверяет bearer-токен; без token в конфигурации API открыт
+// (допускается только на loopback-адресе)
+func apiAuthorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeAPIJSON(w, status, map[string]string{"error": message})
+}
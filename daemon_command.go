@@ -0,0 +1,452 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/utils"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduledBackup - бэкап с расписанием в режиме демона
+type scheduledBackup struct {
+	config   *config.BackupConfig
+	schedule cron.Schedule
+	next     time.Time
+	running  bool
+}
+
+// daemonSchedule - расписания бэкапов, сводки и фоновой проверки одной версии конфигурации
+type daemonSchedule struct {
+	jobs []*scheduledBackup
+
+	digest     cron.Schedule
+	digestNext time.Time
+	verify     cron.Schedule
+	verifyNext time.Time
+}
+
+// newDaemonSchedule строит расписания из конфигурации, уже проверенной при загрузке
+func newDaemonSchedule(cfg *config.Config, now time.Time) *daemonSchedule {
+	schedule := &daemonSchedule{}
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if backupCfg.Schedule == "" {
+			continue
+		}
+
+		parsed, _ := cron.ParseStandard(backupCfg.Schedule)
+		job := &scheduledBackup{config: backupCfg, schedule: parsed, next: parsed.Next(now)}
+		schedule.jobs = append(schedule.jobs, job)
+		fmt.Printf("Scheduled %s (%s), next run at %s\n", backupCfg.Name, backupCfg.Schedule, job.next.Format("2006-01-02 15:04:05"))
+	}
+
+	// Сводка отправляется по своему расписанию global.digest.schedule
+	if cfg.Global.Digest != nil && cfg.Global.Digest.Schedule != "" {
+		schedule.digest, _ = cron.ParseStandard(cfg.Global.Digest.Schedule)
+		schedule.digestNext = schedule.digest.Next(now)
+		fmt.Printf("Scheduled digest (%s), next at %s\n", cfg.Global.Digest.Schedule, schedule.digestNext.Format("2006-01-02 15:04:05"))
+	}
+
+	// Фоновая проверка архивов ставится в очередь по global.background_verify.schedule
+	if verify := cfg.Global.BackgroundVerify; verify != nil {
+		schedule.verify, _ = cron.ParseStandard(verify.Schedule)
+		schedule.verifyNext = schedule.verify.Next(now)
+		fmt.Printf("Scheduled background verify (%s), next at %s\n", verify.Schedule, schedule.verifyNext.Format("2006-01-02 15:04:05"))
+	}
+
+	return schedule
+}
+
+// next возвращает время ближайшего события расписания (нулевое, если расписаний нет)
+func (s *daemonSchedule) next() time.Time {
+	next := s.digestNext
+	if !s.verifyNext.IsZero() && (next.IsZero() || s.verifyNext.Before(next)) {
+		next = s.verifyNext
+	}
+	for _, job := range s.jobs {
+		if next.IsZero() || job.next.Before(next) {
+			next = job.next
+		}
+	}
+	return next
+}
+
+// verifyRateLimit возвращает background_verify.rate_limit в байтах в секунду
+func verifyRateLimit(cfg *config.Config) int64 {
+	var rateLimit int64
+	// rate_limit уже проверен при загрузке конфигурации
+	if verify := cfg.Global.BackgroundVerify; verify != nil && verify.RateLimit != "" {
+		rateLimit, _ = utils.ParseSize(verify.RateLimit)
+	}
+	return rateLimit
+}
+
+// configFingerprint описывает файл конфигурации, файлы include_dir и include (имя,
+// размер, время изменения): изменение любого из них означает, что конфигурацию пора
+// перечитать. Маски include раскрываются заново, чтобы заметить новые файлы
+func configFingerprint(path string, cfg *config.Config) string {
+	includeDir := cfg.Global.IncludeDir
+	var fingerprint strings.Builder
+	stamp := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&fingerprint, "%s:missing;", path)
+			return
+		}
+		fmt.Fprintf(&fingerprint, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	stamp(path)
+	if includeDir != "" {
+		entries, err := os.ReadDir(includeDir)
+		if err != nil {
+			fmt.Fprintf(&fingerprint, "%s:missing;", includeDir)
+		}
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name())
+			if entry.IsDir() || (!strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml")) {
+				continue
+			}
+			stamp(filepath.Join(includeDir, entry.Name()))
+		}
+	}
+	files, err := config.ResolveIncludes(path, cfg.Include)
+	if err != nil {
+		fmt.Fprintf(&fingerprint, "include:%v;", err)
+	}
+	for _, file := range files {
+		stamp(file)
+	}
+	return fingerprint.String()
+}
+
+// daemonCommand: goback daemon - запускает бэкапы по расписанию schedule
+func daemonCommand(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	offline := fs.Bool("offline", false, "Disable network operations, defer uploads to network destinations")
+	reloadInterval := fs.Duration("reload-interval", 10*time.Second, "How often to check the configuration for changes (0 - only on SIGHUP)")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	fingerprint := configFingerprint(*configPath, cfg)
+
+	schedule := newDaemonSchedule(cfg, time.Now())
+
+	var verifier *backup.BackgroundVerifier
+	if cfg.Global.BackgroundVerify != nil {
+		verifier = backup.NewBackgroundVerifier(verifyRateLimit(cfg))
+	}
+
+	if len(schedule.jobs) == 0 && schedule.digest == nil && verifier == nil && cfg.Global.API == nil {
+		utils.PrintError("No backups with schedule in %s", *configPath)
+		return 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	// postMu сериализует обработку spool и выгрузку каталога между бэкапами
+	var postMu sync.Mutex
+	// running - число выполняющихся бэкапов; новая конфигурация применяется, когда их нет
+	running := 0
+	finished := make(chan struct{}, 1)
+
+	// API всегда обслуживает текущую версию конфигурации
+	current := func() *config.Config {
+		mu.Lock()
+		defer mu.Unlock()
+		return cfg
+	}
+
+	var server *http.Server
+	if cfg.Global.API != nil {
+		var err error
+		if server, err = startAPI(cfg.Global.API.Listen, current); err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		fmt.Printf("API listening on %s\n", cfg.Global.API.Listen)
+	}
+	defer func() {
+		if server != nil {
+			server.Close()
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// SIGHUP перечитывает конфигурацию сразу, не дожидаясь reload-interval
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if *reloadInterval > 0 {
+		ticker := time.NewTicker(*reloadInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	utils.PrintHeader("goback daemon started with %d scheduled backup(s)", len(schedule.jobs))
+
+	startVerifier := func() {
+		wg.Add(1)
+		go func(verifier *backup.BackgroundVerifier) {
+			defer wg.Done()
+			verifier.Run(ctx)
+		}(verifier)
+	}
+	if verifier != nil {
+		startVerifier()
+	}
+
+	// pending - проверенная новая версия конфигурации, ожидающая окончания бэкапов
+	var pending *config.Config
+
+	// checkConfig перечитывает конфигурацию, если она изменилась (force - по SIGHUP).
+	// Невалидная версия отклоняется с уведомлением, демон продолжает работать со старой
+	checkConfig := func(force bool) {
+		latest := configFingerprint(*configPath, cfg)
+		if !force && latest == fingerprint {
+			return
+		}
+		fingerprint = latest
+
+		newCfg, err := config.LoadConfig(*configPath)
+		if err != nil {
+			pending = nil
+			utils.PrintError("Config reload rejected, keeping the running configuration: %v", err)
+			executor := backup.NewExecutor(&cfg.Global)
+			executor.SetOffline(*offline)
+			executor.NotifyReloadRejected(*configPath, err)
+			return
+		}
+		// include_dir и include могли измениться вместе с конфигурацией
+		fingerprint = configFingerprint(*configPath, newCfg)
+		pending = newCfg
+
+		mu.Lock()
+		busy := running > 0
+		mu.Unlock()
+		if busy {
+			fmt.Printf("Configuration changed, reload deferred until running backups finish\n")
+		}
+	}
+
+	// applyConfig переключает демон на новую конфигурацию между запусками
+	applyConfig := func(newCfg *config.Config) {
+		utils.PrintHeader("Reloading configuration from %s", *configPath)
+		oldAPI := cfg.Global.API
+
+		mu.Lock()
+		cfg = newCfg
+		schedule = newDaemonSchedule(cfg, time.Now())
+		mu.Unlock()
+
+		if cfg.Global.BackgroundVerify != nil {
+			if verifier == nil {
+				verifier = backup.NewBackgroundVerifier(verifyRateLimit(cfg))
+				startVerifier()
+			} else {
+				verifier.SetRateLimit(verifyRateLimit(cfg))
+			}
+		}
+
+		// Сервер API перезапускается, только если изменился адрес
+		newAPI := cfg.Global.API
+		if server != nil && (newAPI == nil || oldAPI == nil || newAPI.Listen != oldAPI.Listen) {
+			server.Close()
+			server = nil
+			fmt.Printf("API stopped\n")
+		}
+		if server == nil && newAPI != nil {
+			var err error
+			if server, err = startAPI(newAPI.Listen, current); err != nil {
+				utils.PrintError("%v", err)
+			} else {
+				fmt.Printf("API listening on %s\n", newAPI.Listen)
+			}
+		}
+
+		if len(schedule.jobs) == 0 && schedule.digest == nil && schedule.verify == nil && server == nil {
+			fmt.Printf("Warning: no backups with schedule in %s, waiting for the next configuration change\n", *configPath)
+		}
+		utils.PrintSuccess("Configuration reloaded: %d scheduled backup(s)", len(schedule.jobs))
+	}
+
+	for {
+		mu.Lock()
+		next := schedule.next()
+		mu.Unlock()
+
+		// Без расписаний демон только обслуживает API
+		if next.IsZero() {
+			next = time.Now().Add(24 * time.Hour)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		due := false
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Printf("Stopping daemon, waiting for running backups...\n")
+			wg.Wait()
+			return 0
+		case <-timer.C:
+			due = true
+		case <-hup:
+			timer.Stop()
+			checkConfig(true)
+		case <-poll:
+			timer.Stop()
+			checkConfig(false)
+		case <-finished:
+			timer.Stop()
+		}
+
+		if pending != nil {
+			mu.Lock()
+			idle := running == 0
+			mu.Unlock()
+			if idle {
+				applyConfig(pending)
+				pending = nil
+				continue
+			}
+		}
+		if !due {
+			continue
+		}
+
+		now := time.Now()
+		if schedule.digest != nil && !schedule.digestNext.After(now) {
+			schedule.digestNext = schedule.digest.Next(now)
+			wg.Add(1)
+			go func(cfg *config.Config) {
+				defer wg.Done()
+				sendScheduledDigest(cfg, *offline)
+			}(cfg)
+		}
+
+		if schedule.verify != nil && !schedule.verifyNext.After(now) {
+			schedule.verifyNext = schedule.verify.Next(now)
+			wg.Add(1)
+			go func(cfg *config.Config) {
+				defer wg.Done()
+				enqueueVerify(cfg, verifier)
+			}(cfg)
+		}
+
+		mu.Lock()
+		for _, job := range schedule.jobs {
+			if job.next.After(now) {
+				continue
+			}
+			job.next = job.schedule.Next(now)
+
+			if paused, reason := job.config.Paused(now); paused {
+				fmt.Printf("Skipping scheduled run of %s: %s\n", job.config.Name, reason)
+				continue
+			}
+
+			// Защита от наложения: пока предыдущий запуск не завершен, новый не начинается
+			if job.running {
+				utils.PrintError("Skipping scheduled run of %s: previous run is still in progress", job.config.Name)
+				continue
+			}
+			job.running = true
+			running++
+
+			wg.Add(1)
+			go func(cfg *config.Config, job *scheduledBackup, verifier *backup.BackgroundVerifier) {
+				defer wg.Done()
+				// Фоновая проверка не читает диск, пока идет бэкап
+				if verifier != nil {
+					verifier.BackupStarted()
+				}
+				runScheduledBackup(cfg, job.config, *offline, &postMu)
+				if verifier != nil {
+					verifier.BackupFinished()
+				}
+
+				mu.Lock()
+				job.running = false
+				running--
+				mu.Unlock()
+				fmt.Printf("Next run of %s at %s\n", job.config.Name, job.next.Format("2006-01-02 15:04:05"))
+
+				select {
+				case finished <- struct{}{}:
+				default:
+				}
+			}(cfg, job, verifier)
+		}
+		mu.Unlock()
+	}
+}
+
+// runScheduledBackup выполняет один бэкап и последующую обработку spool и каталога
+func runScheduledBackup(cfg *config.Config, backupCfg *config.BackupConfig, offline bool, postMu *sync.Mutex) {
+	// Spool, каталог и уведомления - только раздела клиента, которому принадлежит бэкап
+	scope := cfg.Scope(backupCfg.Tenant)
+	executor := backup.NewExecutor(&scope.Global)
+	executor.SetOffline(offline)
+	fmt.Printf("Run ID of %s: %s\n", backupCfg.Name, executor.RunID())
+	if cfg.Global.MaxWindow > 0 {
+		executor.SetDeadline(time.Now().Add(cfg.Global.MaxWindow))
+	}
+
+	if err := executor.ExecuteBackup(backupCfg); err != nil {
+		utils.PrintError("Error executing backup %s: %v", backupCfg.Name, err)
+	}
+
+	executor.NotifyRun(nil, nil)
+
+	postMu.Lock()
+	defer postMu.Unlock()
+
+	if uploaded, pending, err := executor.ProcessSpool(scope.Backups, false); err != nil {
+		fmt.Printf("Warning: failed to process upload spool: %v\n", err)
+	} else if uploaded > 0 || pending > 0 {
+		fmt.Printf("Upload spool: %d uploaded, %d pending\n", uploaded, pending)
+	}
+
+	if scope.Global.ExportCatalog {
+		if err := backup.ExportCatalog(scope, offline); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// enqueueVerify ставит в очередь фоновой проверки архивы всех бэкапов, включая self backup
+func enqueueVerify(cfg *config.Config, verifier *backup.BackgroundVerifier) {
+	backups := append([]config.BackupConfig(nil), cfg.Backups...)
+	if self := cfg.Global.SelfBackup; self != nil && self.Enabled {
+		backups = append(backups, config.BackupConfig{Name: self.Name, Subdirectory: self.Subdirectory})
+	}
+
+	queued, err := verifier.Enqueue(cfg, backups)
+	if err != nil {
+		fmt.Printf("Warning: skipping background verify: %v\n", err)
+		return
+	}
+	fmt.Printf("Background verify: %d archive(s) queued\n", queued)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/notify"
+	"goback/utils"
+)
+
+// digestCommand: goback digest [--send] - выводит сводку по бэкапам за период
+// и, с --send, отправляет ее в notifications с events: [digest]
+func digestCommand(args []string) int {
+	fs := flag.NewFlagSet("digest", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	period := fs.Duration("period", 0, "Digest period (default: digest.period from config or 168h)")
+	send := fs.Bool("send", false, "Send the digest to notifications with events: [digest]")
+	offline := fs.Bool("offline", false, "Disable network operations (the digest is only printed)")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return 2
+	}
+	if *period < 0 {
+		utils.PrintError("--period cannot be negative")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	if *period == 0 {
+		*period = cfg.Global.DigestPeriod()
+	}
+
+	// Каждый клиент получает сводку только по своим бэкапам в свои notifications
+	now := time.Now()
+	for _, scope := range cfg.Scopes() {
+		if scope.Tenant != "" {
+			if len(scope.Backups) == 0 {
+				continue
+			}
+			utils.PrintHeader("Tenant %s:", scope.Tenant)
+		}
+
+		digest, err := backup.BuildDigest(scope, now, *period)
+		if err != nil {
+			utils.PrintError("Failed to build digest: %v", err)
+			return 1
+		}
+		printDigest(digest)
+
+		if *send {
+			executor := backup.NewExecutor(&scope.Global)
+			executor.SetOffline(*offline)
+			executor.SendDigest(digest)
+		}
+	}
+
+	return 0
+}
+
+// printDigest выводит сводку в терминал
+func printDigest(digest notify.Digest) {
+	utils.PrintHeader("Digest %s - %s", digest.Since.Format("2006-01-02 15:04"), digest.Until.Format("2006-01-02 15:04"))
+	for _, entry := range digest.Backups {
+		line := entry.Backup + ": " + entry.Summary()
+		switch {
+		case entry.OK():
+			utils.PrintSuccess("%s", line)
+		case entry.Runs == 0:
+			utils.PrintError("%s", line)
+		default:
+			utils.PrintError("%s", line)
+			fmt.Printf("  last error: %s\n", entry.LastError)
+		}
+		if len(entry.Upcoming) > 0 {
+			fmt.Printf("  retention will remove: %s\n", strings.Join(entry.Upcoming, ", "))
+		}
+	}
+}
+
+// sendScheduledDigest собирает и отправляет сводку из goback daemon
+func sendScheduledDigest(cfg *config.Config, offline bool) {
+	utils.PrintHeader("Sending digest...")
+	now := time.Now()
+	for _, scope := range cfg.Scopes() {
+		if scope.Tenant != "" && len(scope.Backups) == 0 {
+			continue
+		}
+
+		digest, err := backup.BuildDigest(scope, now, cfg.Global.DigestPeriod())
+		if err != nil {
+			utils.PrintError("Failed to build digest: %v", err)
+			continue
+		}
+
+		executor := backup.NewExecutor(&scope.Global)
+		executor.SetOffline(offline)
+		executor.SendDigest(digest)
+	}
+}
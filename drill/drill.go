@@ -0,0 +1,105 @@
+package drill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"goback/history"
+	"goback/restore"
+)
+
+// DefaultTimeout ограничивает время проверочной команды, если timeout не задан
+const DefaultTimeout = 30 * time.Minute
+
+// Options - параметры проверки восстановления
+type Options struct {
+	// Restore описывает архив; TargetDir заполняется временной директорией
+	Restore restore.Options
+	// Command - проверочная команда (через sh -c) с рабочей директорией в месте восстановления
+	Command string
+	Timeout time.Duration
+	// Keep оставляет директорию восстановления для ручного разбора
+	Keep bool
+}
+
+// Run восстанавливает архив во временную директорию и выполняет проверочную команду.
+// Ошибка любого шага отражается в записи истории (history.KindDrill), а не возвращается
+func Run(opts Options) history.Record {
+	record := history.Record{
+		Kind:    history.KindDrill,
+		Backup:  opts.Restore.Name,
+		Started: time.Now(),
+	}
+
+	dir, err := run(opts, &record)
+	record.Time = time.Now()
+	record.Duration = record.Time.Sub(record.Started)
+	record.Success = err == nil
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	if dir != "" {
+		if opts.Keep {
+			fmt.Printf("Drill directory kept: %s\n", dir)
+		} else {
+			os.RemoveAll(dir)
+		}
+	}
+
+	return record
+}
+
+func run(opts Options, record *history.Record) (string, error) {
+	dir, err := os.MkdirTemp("", "goback-drill-"+opts.Restore.Name+"-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create drill directory: %w", err)
+	}
+
+	opts.Restore.TargetDir = dir
+	fmt.Printf("Restoring into %s...\n", dir)
+	archive, err := restore.RestoreLatest(opts.Restore)
+	if archive != "" {
+		record.Archive = filepath.Base(archive)
+	}
+	if err != nil {
+		return dir, fmt.Errorf("restore failed: %w", err)
+	}
+
+	if opts.Command == "" {
+		fmt.Printf("No drill command configured, only the restore was checked\n")
+		return dir, nil
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Printf("Running drill command: %s\n", opts.Command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", opts.Command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOBACK_DRILL_DIR="+dir,
+		"GOBACK_BACKUP="+opts.Restore.Name,
+		"GOBACK_ARCHIVE="+archive,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return dir, fmt.Errorf("drill command timed out after %s", timeout)
+		}
+		return dir, fmt.Errorf("drill command failed: %w", err)
+	}
+
+	return dir, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"goback/config"
+	"goback/drill"
+	"goback/history"
+	"goback/utils"
+)
+
+// drillCommand: goback drill <backup> - восстанавливает архив во временную директорию,
+// выполняет drill.command и записывает результат в историю проверок
+func drillCommand(args []string) int {
+	fs := flag.NewFlagSet("drill", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	archiveName := fs.String("archive", "", "Archive to test: file name, path or timestamp YYYYmmddHHMMSS (default: latest)")
+	identity := fs.String("identity", os.Getenv("GOBACK_IDENTITY"), "age identity file(s) for encrypted archives, comma-separated (default: $GOBACK_IDENTITY)")
+	keep := fs.Bool("keep", false, "Keep the restored directory for inspection")
+	showHistory := fs.Bool("history", false, "Show drill history (optionally for one backup) and exit")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+
+	if *showHistory {
+		return showDrillHistory(cfg, positional)
+	}
+
+	if len(positional) != 1 {
+		utils.PrintError("Usage: goback drill [--archive <name>] [--keep] <backup-name>")
+		return 2
+	}
+
+	backupCfg, err := findBackupConfig(cfg, positional[0])
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	opts := drill.Options{
+		Restore: restoreOptions(cfg, backupCfg, *identity),
+		Keep:    *keep,
+	}
+	opts.Restore.Archive = *archiveName
+	if backupCfg.Drill != nil {
+		opts.Command = backupCfg.Drill.Command
+		opts.Timeout = backupCfg.Drill.Timeout
+	}
+
+	utils.PrintHeader("Recovery drill: %s", backupCfg.Name)
+	record := drill.Run(opts)
+
+	// Проверка попадает в общую историю запусков и видна в goback history
+	if err := history.Append(cfg.GlobalFor(backupCfg).HistoryPath(), record); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	if !record.Success {
+		utils.PrintError("Drill failed for %s (%s): %s", backupCfg.Name, record.Archive, record.Error)
+		return 1
+	}
+
+	utils.PrintSuccess("Drill passed for %s (%s) in %s", backupCfg.Name, record.Archive, record.Duration.Round(100*time.Millisecond))
+	return 0
+}
+
+// showDrillHistory выводит проверки восстановления из истории запусков
+func showDrillHistory(cfg *config.Config, backupNames []string) int {
+	records, err := loadHistory(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	shown := 0
+	for _, record := range records {
+		if record.Kind != history.KindDrill {
+			continue
+		}
+		if len(backupNames) > 0 && !containsString(backupNames, record.Backup) {
+			continue
+		}
+
+		status := "PASSED"
+		if !record.Success {
+			status = "FAILED"
+		}
+		fmt.Printf("%s  %-6s  %-16s %s (%s)", record.Time.Format("2006-01-02 15:04:05"), status, record.Backup, record.Archive, record.Duration.Round(100*time.Millisecond))
+		if record.Error != "" {
+			fmt.Printf("  %s", record.Error)
+		}
+		fmt.Println()
+		shown++
+	}
+
+	fmt.Printf("%d drill(s)\n", shown)
+	return 0
+}
@@ -0,0 +1,87 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// DefaultDockerImage - образ вспомогательного контейнера с tar
+const DefaultDockerImage = "alpine"
+
+// DockerVolumeOptions - параметры снимка именованного тома Docker
+type DockerVolumeOptions struct {
+	// Volume - имя тома
+	Volume string
+	// Mode - helper (tar во временном контейнере, по умолчанию) или mountpoint
+	// (tar прямо из директории тома на хосте, нужен доступ к /var/lib/docker)
+	Mode string
+	// Image - образ вспомогательного контейнера (по умолчанию alpine)
+	Image string
+}
+
+// DockerVolumeDumper пишет содержимое тома в поток tar
+type DockerVolumeDumper struct {
+	opts DockerVolumeOptions
+}
+
+// NewDockerVolumeDumper проверяет имя тома и режим
+func NewDockerVolumeDumper(opts DockerVolumeOptions) (*DockerVolumeDumper, error) {
+	if opts.Volume == "" {
+		return nil, fmt.Errorf("volume is required")
+	}
+	if opts.Mode == "" {
+		opts.Mode = "helper"
+	}
+	if opts.Mode != "helper" && opts.Mode != "mountpoint" {
+		return nil, fmt.Errorf("mode must be helper or mountpoint")
+	}
+	if opts.Image == "" {
+		opts.Image = DefaultDockerImage
+	}
+	return &DockerVolumeDumper{opts: opts}, nil
+}
+
+// Dump пишет tar с содержимым тома в w. Том подключается к вспомогательному
+// контейнеру только на чтение и сам контейнер удаляется после копирования
+func (d *DockerVolumeDumper) Dump(w io.Writer) error {
+	if d.opts.Mode == "helper" {
+		return runDump(d.helperArgs(), nil, w, nil)
+	}
+
+	mountpoint, err := volumeMountpoint(d.opts.Volume)
+	if err != nil {
+		return err
+	}
+	return runDump(tarArgs(mountpoint), nil, w, nil)
+}
+
+func (d *DockerVolumeDumper) String() string {
+	if d.opts.Mode == "helper" {
+		return strings.Join(d.helperArgs(), " ")
+	}
+	return strings.Join(tarArgs("<mountpoint of "+d.opts.Volume+">"), " ")
+}
+
+func (d *DockerVolumeDumper) helperArgs() []string {
+	return append([]string{"docker", "run", "--rm", "--network", "none",
+		"--volume", d.opts.Volume + ":/volume:ro", d.opts.Image}, tarArgs("/volume")...)
+}
+
+// tarArgs - tar всего содержимого dir с относительными путями
+func tarArgs(dir string) []string {
+	return []string{"tar", "-C", dir, "-cf", "-", "."}
+}
+
+// volumeMountpoint возвращает директорию тома на хосте
+func volumeMountpoint(volume string) (string, error) {
+	output, err := exec.Command("docker", "volume", "inspect", "--format", "{{.Mountpoint}}", volume).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to inspect volume %s: %w", volume, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
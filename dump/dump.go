@@ -0,0 +1,105 @@
+package dump
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Dumper пишет дамп базы данных в поток, который сразу попадает в компрессор
+type Dumper interface {
+	Dump(w io.Writer) error
+	// String - описание команд дампа для логов (без паролей)
+	String() string
+}
+
+// runDump выполняет команду дампа с выводом в w; env добавляется к окружению
+// процесса (пароли передаются только так, а не в аргументах). Вывод stderr
+// печатается с заменой секретов на ***, его последняя строка попадает в ошибку
+func runDump(args []string, env []string, w io.Writer, secrets []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = w
+	stderr := &redactWriter{w: os.Stderr, secrets: secrets}
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	stderr.Flush()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("%s not found in PATH", args[0])
+	}
+	if err != nil {
+		if last := stderr.LastLine(); last != "" {
+			return fmt.Errorf("%s failed: %w: %s", args[0], err, last)
+		}
+		return fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// Redact заменяет в s непустые секреты на ***
+func Redact(s string, secrets []string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "***")
+		}
+	}
+	return s
+}
+
+// redactWriter построчно пишет вывод команды в w, скрывая секреты
+type redactWriter struct {
+	w       io.Writer
+	secrets []string
+
+	mu   sync.Mutex
+	buf  []byte
+	last string
+}
+
+func (r *redactWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf = append(r.buf, p...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			break
+		}
+		r.writeLine(string(r.buf[:i]))
+		r.buf = r.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush выводит последнюю строку без перевода строки
+func (r *redactWriter) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.buf) > 0 {
+		r.writeLine(string(r.buf))
+		r.buf = nil
+	}
+}
+
+// LastLine возвращает последнюю непустую строку вывода
+func (r *redactWriter) LastLine() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+func (r *redactWriter) writeLine(line string) {
+	line = Redact(line, r.secrets)
+	if strings.TrimSpace(line) != "" {
+		r.last = strings.TrimSpace(line)
+	}
+	fmt.Fprintln(r.w, line)
+}
@@ -0,0 +1,143 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// MongoDBOptions - параметры mongodump
+type MongoDBOptions struct {
+	// URI - строка подключения mongodb:// или mongodb+srv:// (может содержать пароль)
+	URI string
+	// Database - база для дампа; пусто - все базы
+	Database string
+	// Collection - единственная коллекция базы Database
+	Collection string
+	// ExcludeCollections - коллекции базы Database, которые не попадают в дамп
+	ExcludeCollections []string
+	// Oplog добавляет в дамп oplog на время выгрузки для согласованного снимка
+	// (только для дампа всего экземпляра)
+	Oplog bool
+	// ExtraArgs добавляются к команде дампа
+	ExtraArgs []string
+}
+
+// MongoDBDumper выполняет mongodump --archive с выводом в поток
+type MongoDBDumper struct {
+	opts MongoDBOptions
+}
+
+// NewMongoDBDumper проверяет сочетание фильтров, которое поддерживает mongodump
+func NewMongoDBDumper(opts MongoDBOptions) (*MongoDBDumper, error) {
+	if opts.URI != "" {
+		u, err := url.Parse(opts.URI)
+		if err != nil || (u.Scheme != "mongodb" && u.Scheme != "mongodb+srv") {
+			return nil, fmt.Errorf("uri must be a mongodb:// or mongodb+srv:// connection string")
+		}
+	}
+	if (opts.Collection != "" || len(opts.ExcludeCollections) > 0) && opts.Database == "" {
+		return nil, fmt.Errorf("collection and exclude_collections require database")
+	}
+	if opts.Collection != "" && len(opts.ExcludeCollections) > 0 {
+		return nil, fmt.Errorf("collection and exclude_collections cannot be used together")
+	}
+	if opts.Oplog && opts.Database != "" {
+		return nil, fmt.Errorf("oplog can only be captured for a full instance dump (without database)")
+	}
+	return &MongoDBDumper{opts: opts}, nil
+}
+
+// Dump пишет архив mongodump в w. URI с паролем передается через временный
+// файл --config (0600), а не в аргументах, видимых в списке процессов
+func (d *MongoDBDumper) Dump(w io.Writer) error {
+	var configFile string
+	if d.opts.URI != "" {
+		path, err := writeMongoConfig(d.opts.URI)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		configFile = path
+	}
+
+	return runDump(d.args(configFile), nil, w, d.secrets())
+}
+
+func (d *MongoDBDumper) String() string {
+	args := d.args("")
+	if d.opts.URI != "" {
+		args = append([]string{args[0], "--uri=" + redactURI(d.opts.URI)}, args[1:]...)
+	}
+	return strings.Join(args, " ")
+}
+
+func (d *MongoDBDumper) args(configFile string) []string {
+	args := []string{"mongodump", "--archive"}
+	if configFile != "" {
+		args = append(args, "--config="+configFile)
+	}
+	if d.opts.Database != "" {
+		args = append(args, "--db="+d.opts.Database)
+	}
+	if d.opts.Collection != "" {
+		args = append(args, "--collection="+d.opts.Collection)
+	}
+	for _, collection := range d.opts.ExcludeCollections {
+		args = append(args, "--excludeCollection="+collection)
+	}
+	if d.opts.Oplog {
+		args = append(args, "--oplog")
+	}
+	return append(args, d.opts.ExtraArgs...)
+}
+
+// secrets возвращает пароль и полный URI для скрытия в выводе mongodump
+func (d *MongoDBDumper) secrets() []string {
+	secrets := []string{d.opts.URI}
+	if u, err := url.Parse(d.opts.URI); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
+	}
+	return secrets
+}
+
+// redactURI заменяет пароль в строке подключения на ***
+func redactURI(uri string) string {
+	scheme := strings.Index(uri, "://")
+	if scheme < 0 {
+		return uri
+	}
+	at := strings.LastIndex(uri, "@")
+	if at < scheme {
+		return uri
+	}
+	colon := strings.Index(uri[scheme+3:at], ":")
+	if colon < 0 {
+		return uri
+	}
+	return uri[:scheme+3+colon+1] + "***" + uri[at:]
+}
+
+// writeMongoConfig создает временный файл --config mongodump со строкой подключения
+func writeMongoConfig(uri string) (string, error) {
+	file, err := os.CreateTemp("", "goback-mongodb-*.yaml")
+	if err != nil {
+		return "", fmt.Errorf("failed to create mongodump config: %w", err)
+	}
+	defer file.Close()
+
+	// Значение в одинарных кавычках YAML: единственное экранирование - удвоение '
+	if _, err := fmt.Fprintf(file, "uri: '%s'\n", strings.ReplaceAll(uri, "'", "''")); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write mongodump config: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write mongodump config: %w", err)
+	}
+	return file.Name(), nil
+}
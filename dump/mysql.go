@@ -0,0 +1,126 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// MySQLOptions - параметры подключения и дампа MySQL/MariaDB
+type MySQLOptions struct {
+	// Binary - mysqldump или mariadb-dump; пусто - mysqldump, а если его нет, mariadb-dump
+	Binary   string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// OptionFile - файл параметров клиента ([client] user, password, host),
+	// передается через --defaults-extra-file
+	OptionFile string
+	// Databases - базы для дампа; пустой список - --all-databases
+	Databases []string
+	// SingleTransaction - согласованный дамп InnoDB без блокировки таблиц
+	SingleTransaction bool
+	// ExtraArgs добавляются к команде дампа
+	ExtraArgs []string
+}
+
+// MySQLDumper выполняет mysqldump или mariadb-dump
+type MySQLDumper struct {
+	opts MySQLOptions
+}
+
+// NewMySQLDumper создает dumper; password и option_file взаимоисключающие
+func NewMySQLDumper(opts MySQLOptions) (*MySQLDumper, error) {
+	if opts.Password != "" && opts.OptionFile != "" {
+		return nil, fmt.Errorf("password and option_file cannot be used together")
+	}
+	return &MySQLDumper{opts: opts}, nil
+}
+
+// Dump пишет дамп в w. Пароль не передается в аргументах: он записывается во
+// временный файл параметров с правами 0600, который удаляется после дампа
+func (d *MySQLDumper) Dump(w io.Writer) error {
+	optionFile := d.opts.OptionFile
+	if d.opts.Password != "" {
+		path, err := writeOptionFile(d.opts.Password)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		optionFile = path
+	}
+
+	return runDump(d.args(optionFile), nil, w, []string{d.opts.Password})
+}
+
+func (d *MySQLDumper) String() string {
+	optionFile := d.opts.OptionFile
+	if d.opts.Password != "" {
+		optionFile = "<temporary option file>"
+	}
+	return strings.Join(d.args(optionFile), " ")
+}
+
+// args возвращает аргументы команды дампа; --defaults-extra-file должен быть первым
+func (d *MySQLDumper) args(optionFile string) []string {
+	args := []string{d.binary()}
+	if optionFile != "" {
+		args = append(args, "--defaults-extra-file="+optionFile)
+	}
+	if d.opts.Host != "" {
+		args = append(args, "--host="+d.opts.Host)
+	}
+	if d.opts.Port != 0 {
+		args = append(args, "--port="+strconv.Itoa(d.opts.Port))
+	}
+	if d.opts.User != "" {
+		args = append(args, "--user="+d.opts.User)
+	}
+	if d.opts.SingleTransaction {
+		args = append(args, "--single-transaction")
+	}
+	args = append(args, d.opts.ExtraArgs...)
+
+	// --databases добавляет CREATE DATABASE и USE, поэтому дамп загружается без указания базы
+	if len(d.opts.Databases) == 0 {
+		return append(args, "--all-databases")
+	}
+	return append(append(args, "--databases"), d.opts.Databases...)
+}
+
+func (d *MySQLDumper) binary() string {
+	if d.opts.Binary != "" {
+		return d.opts.Binary
+	}
+	if _, err := exec.LookPath("mysqldump"); err != nil {
+		if _, err := exec.LookPath("mariadb-dump"); err == nil {
+			return "mariadb-dump"
+		}
+	}
+	return "mysqldump"
+}
+
+// writeOptionFile создает временный файл параметров клиента с паролем
+func writeOptionFile(password string) (string, error) {
+	file, err := os.CreateTemp("", "goback-mysql-*.cnf")
+	if err != nil {
+		return "", fmt.Errorf("failed to create MySQL option file: %w", err)
+	}
+	defer file.Close()
+
+	// CreateTemp создает файл с правами 0600; в значении экранируются \ и "
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(password)
+	if _, err := fmt.Fprintf(file, "[client]\npassword=\"%s\"\n", escaped); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write MySQL option file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write MySQL option file: %w", err)
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,102 @@
+package dump
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PostgresOptions - параметры подключения и формат дампа PostgreSQL
+type PostgresOptions struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	// Databases - базы для pg_dump; пустой список - весь кластер через pg_dumpall
+	Databases []string
+	// Format - plain (SQL, по умолчанию), custom или tar (для pg_restore)
+	Format string
+	// ExtraArgs добавляются к каждой команде дампа
+	ExtraArgs []string
+}
+
+// PostgresDumper выполняет pg_dump для каждой базы или pg_dumpall
+type PostgresDumper struct {
+	opts PostgresOptions
+}
+
+// NewPostgresDumper создает dumper; custom и tar допускают только одну базу,
+// так как такие дампы нельзя склеить в один поток
+func NewPostgresDumper(opts PostgresOptions) (*PostgresDumper, error) {
+	if opts.Format == "" {
+		opts.Format = "plain"
+	}
+	switch opts.Format {
+	case "plain":
+	case "custom", "tar":
+		if len(opts.Databases) != 1 {
+			return nil, fmt.Errorf("format %s requires exactly one database", opts.Format)
+		}
+	default:
+		return nil, fmt.Errorf("format must be plain, custom or tar")
+	}
+
+	return &PostgresDumper{opts: opts}, nil
+}
+
+// Dump пишет дампы баз в w по очереди. Несколько баз в plain формате выгружаются
+// с --create, чтобы общий SQL-скрипт подключался к каждой базе при загрузке через psql
+func (d *PostgresDumper) Dump(w io.Writer) error {
+	var env []string
+	if d.opts.Password != "" {
+		env = append(env, "PGPASSWORD="+d.opts.Password)
+	}
+
+	for _, args := range d.commands() {
+		if err := runDump(args, env, w, []string{d.opts.Password}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *PostgresDumper) String() string {
+	var commands []string
+	for _, args := range d.commands() {
+		commands = append(commands, strings.Join(args, " "))
+	}
+	return strings.Join(commands, "; ")
+}
+
+// commands возвращает аргументы команд дампа; пароль в них не попадает
+func (d *PostgresDumper) commands() [][]string {
+	// --no-password: без пароля команда завершается ошибкой, а не ждет ввода
+	connection := []string{"--no-password"}
+	if d.opts.Host != "" {
+		connection = append(connection, "--host", d.opts.Host)
+	}
+	if d.opts.Port != 0 {
+		connection = append(connection, "--port", strconv.Itoa(d.opts.Port))
+	}
+	if d.opts.User != "" {
+		connection = append(connection, "--username", d.opts.User)
+	}
+
+	if len(d.opts.Databases) == 0 {
+		args := append([]string{"pg_dumpall"}, connection...)
+		return [][]string{append(args, d.opts.ExtraArgs...)}
+	}
+
+	var commands [][]string
+	for _, database := range d.opts.Databases {
+		args := append([]string{"pg_dump"}, connection...)
+		args = append(args, "--format", d.opts.Format)
+		if len(d.opts.Databases) > 1 {
+			args = append(args, "--create")
+		}
+		args = append(args, d.opts.ExtraArgs...)
+		commands = append(commands, append(args, "--dbname", database))
+	}
+	return commands
+}
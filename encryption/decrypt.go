@@ -0,0 +1,121 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	ageHeader      = "age-encryption.org/v1"
+	ageArmorHeader = "-----BEGIN AGE ENCRYPTED FILE-----"
+)
+
+// IsAgeEncrypted проверяет, что данные зашифрованы age (бинарный или armored формат)
+func IsAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageHeader)) || bytes.HasPrefix(bytes.TrimSpace(data), []byte(ageArmorHeader))
+}
+
+// LoadIdentities читает приватные ключи age из файла (формат age-keygen)
+func LoadIdentities(path string) ([]age.Identity, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open identity file: %w", err)
+	}
+	defer file.Close()
+
+	identities, err := age.ParseIdentities(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file %s: %w", path, err)
+	}
+
+	return identities, nil
+}
+
+// buildIdentities собирает ключи для расшифровки из файлов ключей (через запятую,
+// например старый и новый ключ после ротации) и/или пароля
+func buildIdentities(identityFile, passphrase string) ([]age.Identity, error) {
+	var identities []age.Identity
+
+	for _, path := range strings.Split(identityFile, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		parsed, err := LoadIdentities(path)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, parsed...)
+	}
+
+	if passphrase != "" {
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid passphrase: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no age identity or passphrase provided")
+	}
+
+	return identities, nil
+}
+
+// DecryptBytes расшифровывает данные age в памяти
+func DecryptBytes(data []byte, identityFile, passphrase string) ([]byte, error) {
+	identities, err := buildIdentities(identityFile, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if strings.HasPrefix(string(bytes.TrimSpace(data)), ageArmorHeader) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	reader, err := age.Decrypt(src, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return io.ReadAll(reader)
+}
+
+// DecryptFile расшифровывает файл age (например, архив) потоково
+func DecryptFile(source, destination, identityFile, passphrase string) error {
+	identities, err := buildIdentities(identityFile, passphrase)
+	if err != nil {
+		return err
+	}
+
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer srcFile.Close()
+
+	reader, err := age.Decrypt(srcFile, identities...)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	dstFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create decrypted file: %w", err)
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, reader); err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return dstFile.Close()
+}
@@ -0,0 +1,115 @@
+package encryption
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+type Encryptor interface {
+	Encrypt(source, destination string) error
+	Extension() string
+}
+
+type AgeEncryptor struct {
+	recipients []age.Recipient
+}
+
+// NewAgeEncryptor создает шифратор age по публичным ключам получателей или по паролю
+func NewAgeEncryptor(recipients []string, passphrase string) (*AgeEncryptor, error) {
+	var parsed []age.Recipient
+
+	for _, recipient := range recipients {
+		recipient = strings.TrimSpace(recipient)
+		if recipient == "" {
+			continue
+		}
+
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", recipient, err)
+		}
+		parsed = append(parsed, r)
+	}
+
+	if passphrase != "" {
+		// age не позволяет смешивать scrypt с другими получателями
+		if len(parsed) > 0 {
+			return nil, fmt.Errorf("age passphrase cannot be combined with recipients")
+		}
+
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age passphrase: %w", err)
+		}
+		parsed = append(parsed, r)
+	}
+
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("age encryption requires recipients or passphrase")
+	}
+
+	return &AgeEncryptor{recipients: parsed}, nil
+}
+
+func (e *AgeEncryptor) Encrypt(source, destination string) error {
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dstFile.Close()
+
+	writer, err := age.Encrypt(dstFile, e.recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to initialize age encryption: %w", err)
+	}
+
+	if _, err := io.Copy(writer, srcFile); err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+
+	// Close дописывает последний блок, без него файл не расшифруется
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+
+	// Ошибка закрытия (нет места, сбой NFS) означает обрезанный архив
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("failed to write encrypted file: %w", err)
+	}
+
+	return nil
+}
+
+func (e *AgeEncryptor) Extension() string {
+	return ".age"
+}
+
+// Options - ключи шифрования из конфигурации
+type Options struct {
+	// Recipients и Passphrase используются age
+	Recipients []string
+	Passphrase string
+	// KeyID - публичный ключ GPG (fingerprint, id или email)
+	KeyID string
+}
+
+func NewEncryptor(encryptionType string, opts Options) (Encryptor, error) {
+	switch strings.ToLower(encryptionType) {
+	case "age", "":
+		return NewAgeEncryptor(opts.Recipients, opts.Passphrase)
+	case "gpg":
+		return NewGPGEncryptor(opts.KeyID)
+	default:
+		return nil, fmt.Errorf("unsupported encryption type: %s", encryptionType)
+	}
+}
@@ -0,0 +1,55 @@
+package encryption
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GPGEncryptor шифрует архивы публичным ключом GPG через утилиту gpg,
+// поэтому ключ должен быть импортирован в keyring пользователя
+type GPGEncryptor struct {
+	keyID string
+}
+
+func NewGPGEncryptor(keyID string) (*GPGEncryptor, error) {
+	keyID = strings.TrimSpace(keyID)
+	if keyID == "" {
+		return nil, fmt.Errorf("gpg encryption requires key_id")
+	}
+
+	if _, err := exec.LookPath("gpg"); err != nil {
+		return nil, fmt.Errorf("gpg encryption requires the gpg binary: %w", err)
+	}
+
+	return &GPGEncryptor{keyID: keyID}, nil
+}
+
+func (e *GPGEncryptor) Encrypt(source, destination string) error {
+	// trust-model always: ключ задан явно в конфигурации, интерактивное
+	// подтверждение доверия в cron невозможно
+	return runGPG("encrypt", "--batch", "--yes", "--trust-model", "always",
+		"--recipient", e.keyID, "--output", destination, "--encrypt", source)
+}
+
+func (e *GPGEncryptor) Extension() string {
+	return ".gpg"
+}
+
+// DecryptGPGFile расшифровывает файл gpg приватным ключом из keyring (через gpg-agent)
+func DecryptGPGFile(source, destination string) error {
+	return runGPG("decrypt", "--batch", "--yes", "--output", destination, "--decrypt", source)
+}
+
+func runGPG(action string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", args...)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gpg %s failed: %w: %s", action, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package encryption
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/utils"
+)
+
+// KeysExtension - расширение sidecar с ключами, которыми зашифрован архив
+const KeysExtension = ".keys.json"
+
+// PassphraseKeyID - идентификатор ключа для архивов, зашифрованных паролем age:
+// сам пароль (и его хеш) в sidecar не попадает
+const PassphraseKeyID = "passphrase"
+
+// KeyRecord - sidecar <archive>.keys.json: тип шифрования и идентификаторы
+// ключей, которыми можно расшифровать архив. После ротации ключей по нему
+// видно, какой старый ключ нужен для восстановления и какие архивы перешифровать
+type KeyRecord struct {
+	Type string `json:"type"`
+	// Keys - получатели age (age1...), "passphrase" или ключ GPG
+	Keys      []string  `json:"keys"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyIDs возвращает идентификаторы ключей шифрования encryptionType с ключами opts
+func KeyIDs(encryptionType string, opts Options) []string {
+	var ids []string
+	switch strings.ToLower(encryptionType) {
+	case "gpg":
+		if keyID := strings.TrimSpace(opts.KeyID); keyID != "" {
+			ids = append(ids, keyID)
+		}
+	default:
+		for _, recipient := range opts.Recipients {
+			if recipient = strings.TrimSpace(recipient); recipient != "" {
+				ids = append(ids, recipient)
+			}
+		}
+		if opts.Passphrase != "" {
+			ids = append(ids, PassphraseKeyID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// NewKeyRecord создает запись о ключах архива, зашифрованного сейчас
+func NewKeyRecord(encryptionType string, opts Options) KeyRecord {
+	if encryptionType == "" {
+		encryptionType = "age"
+	}
+	return KeyRecord{
+		Type:      strings.ToLower(encryptionType),
+		Keys:      KeyIDs(encryptionType, opts),
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Matches сообщает, что архив зашифрован ровно ключами keys
+func (r KeyRecord) Matches(keys []string) bool {
+	if len(r.Keys) != len(keys) {
+		return false
+	}
+	sorted := append([]string(nil), r.Keys...)
+	sort.Strings(sorted)
+	for i := range sorted {
+		if sorted[i] != keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// IsKeysFile проверяет, что файл - sidecar с ключами архива
+func IsKeysFile(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), KeysExtension)
+}
+
+// WriteKeyRecord записывает sidecar с ключами рядом с архивом
+func WriteKeyRecord(archivePath string, record KeyRecord) error {
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := utils.WriteFileAtomic(archivePath+KeysExtension, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write key record: %w", err)
+	}
+	return nil
+}
+
+// ReadKeyRecord читает sidecar с ключами архива; ok=false - sidecar нет
+// (архив создан до появления записей о ключах)
+func ReadKeyRecord(archivePath string) (KeyRecord, bool, error) {
+	var record KeyRecord
+	data, err := os.ReadFile(archivePath + KeysExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return record, false, nil
+		}
+		return record, false, fmt.Errorf("failed to read key record: %w", err)
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, false, fmt.Errorf("failed to parse key record %s: %w", archivePath+KeysExtension, err)
+	}
+	return record, true, nil
+}
+
+// ForgetKeyRecord удаляет sidecar с ключами удаленного архива
+func ForgetKeyRecord(archivePath string) error {
+	if err := os.Remove(archivePath + KeysExtension); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove key record: %w", err)
+	}
+	return nil
+}
+
+// Decrypt расшифровывает архив source (.age или .gpg) в destination:
+// age - ключами из identityFile и/или паролем, gpg - ключами из keyring
+func Decrypt(source, destination, identityFile, passphrase string) error {
+	if strings.HasSuffix(strings.ToLower(source), ".gpg") {
+		return DecryptGPGFile(source, destination)
+	}
+	return DecryptFile(source, destination, identityFile, passphrase)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// explainCommand: goback explain <path> - показывает, какое правило включает
+// или исключает путь в каждом бэкапе, чей source_dir его содержит
+func explainCommand(args []string) int {
+	fs := flag.NewFlagSet("explain", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	backupName := fs.String("backup", "", "Explain for this backup only (path may be relative to its source_dir)")
+	fs.StringVar(backupName, "b", "", "Explain for this backup only (short)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 1 {
+		utils.PrintError("Usage: goback explain [-b name] <path>")
+		return 2
+	}
+	path := positional[0]
+
+	cfg := loadConfigOrExit(*configPath)
+
+	// Без -b относительный путь считается от текущей директории
+	if *backupName == "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+
+	matched := 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if *backupName != "" && backupCfg.Name != *backupName {
+			continue
+		}
+		if backupCfg.SourceDir == "" {
+			continue
+		}
+
+		explanation, err := backup.ExplainPath(backupCfg, path)
+		if err != nil {
+			if *backupName != "" {
+				utils.PrintError("%v", err)
+				return 1
+			}
+			continue
+		}
+		matched++
+
+		if explanation.Included {
+			utils.PrintSuccess("%s: %s is included (%s)", backupCfg.Name, explanation.RelPath, explanation.Reason)
+		} else {
+			utils.PrintError("%s: %s is skipped (%s)", backupCfg.Name, explanation.RelPath, explanation.Reason)
+		}
+	}
+
+	if matched == 0 {
+		if *backupName != "" {
+			utils.PrintError("Backup not found or has no source_dir: %s", *backupName)
+		} else {
+			fmt.Printf("%s is not inside the source_dir of any backup\n", path)
+		}
+		return 1
+	}
+
+	return 0
+}
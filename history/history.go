@@ -0,0 +1,197 @@
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/lock"
+)
+
+// Виды записей истории
+const (
+	// KindBackup - запуск бэкапа
+	KindBackup = ""
+	// KindDrill - проверка восстановления (goback drill)
+	KindDrill = "drill"
+)
+
+// Record - итог одного запуска бэкапа или проверки восстановления
+type Record struct {
+	// Kind - вид записи: KindBackup или KindDrill
+	Kind   string `json:"kind,omitempty"`
+	Backup string `json:"backup"`
+	RunID  string `json:"run_id,omitempty"`
+	JobID  string `json:"job_id,omitempty"`
+	// Started - начало бэкапа (первой попытки), Time - его окончание
+	Started time.Time `json:"started"`
+	Time    time.Time `json:"time"`
+	Success bool      `json:"success"`
+	Archive string    `json:"archive,omitempty"`
+	// Path - локальный путь созданного архива (мог быть удален после доставки
+	// с keep_local: false или позже retention)
+	Path     string        `json:"path,omitempty"`
+	Size     int64         `json:"size"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Status возвращает итог записи: success или failure
+func (r Record) Status() string {
+	if r.Success {
+		return "success"
+	}
+	return "failure"
+}
+
+// LastRun возвращает последний запуск бэкапа, успешный или нет
+func LastRun(records []Record, backup string) (Record, bool) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Kind == KindBackup && records[i].Backup == backup {
+			return records[i], true
+		}
+	}
+	return Record{}, false
+}
+
+// LastSuccess возвращает последний успешный запуск бэкапа
+func LastSuccess(records []Record, backup string) (Record, bool) {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Kind == KindBackup && records[i].Backup == backup && records[i].Success {
+			return records[i], true
+		}
+	}
+	return Record{}, false
+}
+
+// RecentSizes возвращает размеры архивов последних limit успешных запусков бэкапа,
+// от новых к старым
+func RecentSizes(records []Record, backup string, limit int) []int64 {
+	var sizes []int64
+	for i := len(records) - 1; i >= 0 && len(sizes) < limit; i-- {
+		if records[i].Kind == KindBackup && records[i].Backup == backup && records[i].Success && records[i].Size > 0 {
+			sizes = append(sizes, records[i].Size)
+		}
+	}
+	return sizes
+}
+
+// maxFileSize - размер файла истории, после которого он ротируется: текущий файл
+// становится предыдущим (<name>.1.jsonl), так что хранится не меньше maxFileSize записей
+const maxFileSize = 4 << 20
+
+// Load читает историю запусков: записи предыдущего файла после ротации и текущего.
+// Отсутствующие файлы означают пустую историю
+func Load(path string) ([]Record, error) {
+	var records []Record
+	for _, file := range []string{rotatedPath(path), path} {
+		fileRecords, err := loadLines(file)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+
+	return records, nil
+}
+
+// loadLines читает файл JSONL: одна запись на строку. Оборванная последняя строка
+// (процесс завершился во время записи) пропускается
+func loadLines(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+
+	return records, nil
+}
+
+// Append дописывает запись в конец истории одной операцией записи в файл,
+// открытый с O_APPEND: одновременные запуски (демон, cron, ручной запуск) не
+// теряют записи друг друга
+func Append(path string, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode run history: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open run history: %w", err)
+	}
+	_, err = file.Write(append(data, '\n'))
+	var size int64
+	if info, statErr := file.Stat(); statErr == nil {
+		size = info.Size()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+
+	if size > maxFileSize {
+		return rotate(path)
+	}
+	return nil
+}
+
+// rotate переименовывает заполненный файл истории в предыдущий. Ротацию выполняет
+// один процесс под блокировкой; запись, дописанная другим процессом в уже
+// переименованный файл, остается в предыдущем файле и не теряется
+func rotate(path string) error {
+	rotateLock, err := lock.Acquire(path+".lock", "", 0)
+	if err != nil {
+		// Файл ротирует другой процесс
+		return nil
+	}
+	defer rotateLock.Release()
+
+	// Пока ждали блокировку, файл мог уже быть ротирован
+	if info, err := os.Stat(path); err != nil || info.Size() <= maxFileSize {
+		return nil
+	}
+
+	if err := os.Rename(path, rotatedPath(path)); err != nil {
+		return fmt.Errorf("failed to rotate run history: %w", err)
+	}
+	return nil
+}
+
+// rotatedPath возвращает путь предыдущего файла истории: history.jsonl -> history.1.jsonl
+func rotatedPath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".1" + ext
+}
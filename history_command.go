@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"goback/config"
+	"goback/history"
+	"goback/utils"
+)
+
+// historyCommand: goback history [backup-name...] [--limit N] [--failed]
+// [--last-success] [--json] - выводит историю запусков бэкапов из state_dir
+func historyCommand(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	limit := fs.Int("limit", 20, "Show at most N most recent runs (0 - all)")
+	failedOnly := fs.Bool("failed", false, "Show only failed runs")
+	lastSuccess := fs.Bool("last-success", false, "Show when each backup last succeeded; exit code 1 if one never did")
+	asJSON := fs.Bool("json", false, "Print records as JSON")
+
+	backupNames, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if *limit < 0 {
+		utils.PrintError("--limit cannot be negative")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	records, err := loadHistory(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	if *lastSuccess {
+		return printLastSuccess(cfg, records, backupNames, *asJSON)
+	}
+
+	var selected []history.Record
+	for _, record := range records {
+		if len(backupNames) > 0 && !containsString(backupNames, record.Backup) {
+			continue
+		}
+		if *failedOnly && record.Success {
+			continue
+		}
+		selected = append(selected, record)
+	}
+	if *limit > 0 && len(selected) > *limit {
+		selected = selected[len(selected)-*limit:]
+	}
+
+	if *asJSON {
+		if selected == nil {
+			selected = []history.Record{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(selected); err != nil {
+			utils.PrintError("Failed to write history: %v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if len(selected) == 0 {
+		fmt.Println("No runs recorded")
+		return 0
+	}
+	// Новые запуски сверху
+	for i := len(selected) - 1; i >= 0; i-- {
+		record := selected[i]
+		detail := record.Archive
+		if record.Kind == history.KindDrill {
+			detail = "drill of " + record.Archive
+		}
+		if !record.Success {
+			detail = record.Error
+			if record.Kind == history.KindDrill {
+				detail = "drill: " + record.Error
+			}
+		}
+		line := fmt.Sprintf("%s  %-20s  %-7s  %8s  %10s  %s", record.Time.Format("2006-01-02 15:04:05"), record.Backup, record.Status(), record.Duration.Round(time.Second), formatRecordSize(record), detail)
+		if record.Success {
+			fmt.Println(line)
+		} else {
+			utils.PrintError("%s", line)
+		}
+	}
+	return 0
+}
+
+// printLastSuccess выводит время последнего успешного запуска каждого бэкапа
+// (по умолчанию - всех бэкапов конфигурации). Код 1 - какой-то бэкап ни разу не
+// завершился успешно
+func printLastSuccess(cfg *config.Config, records []history.Record, backupNames []string, asJSON bool) int {
+	names := backupNames
+	if len(names) == 0 {
+		for _, backupCfg := range cfg.Backups {
+			names = append(names, backupCfg.Name)
+		}
+	}
+
+	type lastSuccess struct {
+		Backup string          `json:"backup"`
+		Record *history.Record `json:"last_success"`
+	}
+	var result []lastSuccess
+	never := 0
+	for _, name := range names {
+		item := lastSuccess{Backup: name}
+		if record, ok := history.LastSuccess(records, name); ok {
+			item.Record = &record
+		} else {
+			never++
+		}
+		result = append(result, item)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			utils.PrintError("Failed to write history: %v", err)
+			return 1
+		}
+	} else {
+		now := time.Now()
+		for _, item := range result {
+			if item.Record == nil {
+				utils.PrintError("%-20s  never succeeded", item.Backup)
+				continue
+			}
+			fmt.Printf("%-20s  %s  (%s ago)  %s\n", item.Backup, item.Record.Time.Format("2006-01-02 15:04:05"), formatAge(now.Sub(item.Record.Time)), item.Record.Archive)
+		}
+	}
+
+	if never > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadHistory читает историю запусков всех частей конфигурации (у клиентов
+// tenants может быть свой state_dir) и упорядочивает записи по времени
+func loadHistory(cfg *config.Config) ([]history.Record, error) {
+	var records []history.Record
+	seen := make(map[string]bool)
+	for _, scope := range cfg.Scopes() {
+		path := scope.Global.HistoryPath()
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		scopeRecords, err := history.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, scopeRecords...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+	return records, nil
+}
+
+// formatRecordSize возвращает размер архива запуска ("-" - архив не создан или
+// запись о проверке восстановления)
+func formatRecordSize(record history.Record) string {
+	if record.Archive == "" || record.Kind == history.KindDrill {
+		return "-"
+	}
+	return utils.FormatSize(record.Size)
+}
@@ -0,0 +1,152 @@
+package hold
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"goback/utils"
+)
+
+// Hold запрещает retention удалять архивы бэкапа до Until (юридическое
+// удержание, разбор инцидента)
+type Hold struct {
+	ID     string `json:"id"`
+	Backup string `json:"backup"`
+	// Pattern - glob по имени архива; пусто - все архивы бэкапа
+	Pattern   string    `json:"pattern,omitempty"`
+	Until     time.Time `json:"until"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active сообщает, что удержание действует в момент now
+func (h Hold) Active(now time.Time) bool {
+	return now.Before(h.Until)
+}
+
+// Covers сообщает, что удержание действует на архив filename бэкапа backup
+func (h Hold) Covers(backup, filename string, now time.Time) bool {
+	if h.Backup != backup || !h.Active(now) {
+		return false
+	}
+	if h.Pattern == "" {
+		return true
+	}
+	matched, _ := filepath.Match(h.Pattern, filename)
+	return matched
+}
+
+// Find возвращает действующее удержание архива filename или nil
+func Find(holds []Hold, backup, filename string, now time.Time) *Hold {
+	for i := range holds {
+		if holds[i].Covers(backup, filename, now) {
+			return &holds[i]
+		}
+	}
+	return nil
+}
+
+// ParseUntil разбирает дату окончания удержания: YYYY-MM-DD (удержание действует
+// до конца этого дня по локальному времени) или RFC3339
+func ParseUntil(value string) (time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC3339)", value)
+	}
+	return until, nil
+}
+
+// mu защищает файл удержаний от одновременного изменения
+var mu sync.Mutex
+
+// Load читает удержания; отсутствующий файл означает, что удержаний нет
+func Load(path string) ([]Hold, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read holds: %w", err)
+	}
+
+	var holds []Hold
+	if err := json.Unmarshal(data, &holds); err != nil {
+		return nil, fmt.Errorf("failed to parse holds: %w", err)
+	}
+
+	return holds, nil
+}
+
+// Add сохраняет новое удержание и возвращает его с присвоенным ID
+func Add(path string, h Hold) (Hold, error) {
+	if h.Pattern != "" {
+		if _, err := filepath.Match(h.Pattern, ""); err != nil {
+			return h, fmt.Errorf("invalid pattern %q: %w", h.Pattern, err)
+		}
+	}
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return h, fmt.Errorf("failed to generate hold id: %w", err)
+	}
+	h.ID = hex.EncodeToString(id)
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now()
+	}
+
+	return h, update(path, func(holds []Hold) ([]Hold, error) {
+		return append(holds, h), nil
+	})
+}
+
+// Remove удаляет удержание по ID
+func Remove(path, id string) error {
+	return update(path, func(holds []Hold) ([]Hold, error) {
+		for i := range holds {
+			if holds[i].ID == id {
+				return append(holds[:i], holds[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("hold not found: %s", id)
+	})
+}
+
+// update изменяет файл удержаний атомарно
+func update(path string, fn func([]Hold) ([]Hold, error)) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	holds, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	holds, err = fn(holds)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(holds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode holds: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write holds: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"goback/hold"
+	"goback/utils"
+)
+
+// holdCommand: goback hold add|list|remove - удержания архивов от retention
+// (юридические запросы, разбор инцидентов)
+func holdCommand(args []string) int {
+	usage := "Usage: goback hold add <backup> --until <date> [--pattern <glob>] [--reason <text>] | goback hold list [backup] [--all] | goback hold remove <id>"
+	fs := flag.NewFlagSet("hold", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	until := fs.String("until", "", "End of the hold: YYYY-MM-DD (through the end of that day) or RFC3339")
+	pattern := fs.String("pattern", "", "Glob of archive file names to hold (default: all archives of the backup)")
+	reason := fs.String("reason", "", "Reason of the hold, shown in hold list")
+	all := fs.Bool("all", false, "List expired holds too")
+
+	// Флаги разбираются до действия: глобальные флаги (goback -c cfg.yaml hold list)
+	// приходят перед ним
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 {
+		utils.PrintError("%s", usage)
+		return 2
+	}
+	action, positional := positional[0], positional[1:]
+
+	cfg := loadConfigOrExit(*configPath)
+
+	switch action {
+	case "add":
+		if len(positional) != 1 || *until == "" {
+			utils.PrintError("Usage: goback hold add <backup> --until <date> [--pattern <glob>] [--reason <text>]")
+			return 2
+		}
+		backupCfg, err := findBackupConfig(cfg, positional[0])
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		untilTime, err := hold.ParseUntil(*until)
+		if err != nil {
+			utils.PrintError("--until: %v", err)
+			return 2
+		}
+		if !untilTime.After(time.Now()) {
+			utils.PrintError("--until must be in the future")
+			return 2
+		}
+
+		h, err := hold.Add(cfg.GlobalFor(backupCfg).HoldsPath(), hold.Hold{
+			Backup:  backupCfg.Name,
+			Pattern: *pattern,
+			Until:   untilTime,
+			Reason:  *reason,
+		})
+		if err != nil {
+			utils.PrintError("Failed to add hold: %v", err)
+			return 1
+		}
+		utils.PrintSuccess("Hold %s added: retention keeps %s until %s", h.ID, describeHold(h), h.Until.Format("2006-01-02 15:04"))
+		return 0
+
+	case "list":
+		now := time.Now()
+		shown := 0
+		for _, scope := range cfg.Scopes() {
+			holds, err := hold.Load(scope.Global.HoldsPath())
+			if err != nil {
+				utils.PrintError("%v", err)
+				return 1
+			}
+			for _, h := range holds {
+				if len(positional) > 0 && !containsString(positional, h.Backup) {
+					continue
+				}
+				status := "active"
+				if !h.Active(now) {
+					if !*all {
+						continue
+					}
+					status = "expired"
+				}
+				fmt.Printf("%s  %-7s until %s  %s", h.ID, status, h.Until.Format("2006-01-02 15:04"), describeHold(h))
+				if h.Reason != "" {
+					fmt.Printf("  (%s)", h.Reason)
+				}
+				fmt.Println()
+				shown++
+			}
+		}
+		fmt.Printf("%d hold(s)\n", shown)
+		return 0
+
+	case "remove":
+		if len(positional) != 1 {
+			utils.PrintError("Usage: goback hold remove <id>")
+			return 2
+		}
+		// ID уникален, а в каком разделе клиента лежит удержание, заранее неизвестно
+		for _, scope := range cfg.Scopes() {
+			holds, err := hold.Load(scope.Global.HoldsPath())
+			if err != nil {
+				utils.PrintError("%v", err)
+				return 1
+			}
+			for _, h := range holds {
+				if h.ID != positional[0] {
+					continue
+				}
+				if err := hold.Remove(scope.Global.HoldsPath(), h.ID); err != nil {
+					utils.PrintError("Failed to remove hold: %v", err)
+					return 1
+				}
+				utils.PrintSuccess("Hold %s removed: %s", h.ID, describeHold(h))
+				return 0
+			}
+		}
+		utils.PrintError("hold not found: %s", positional[0])
+		return 1
+
+	default:
+		utils.PrintError("%s", usage)
+		return 2
+	}
+}
+
+// describeHold - какие архивы удерживаются
+func describeHold(h hold.Hold) string {
+	if h.Pattern == "" {
+		return "all archives of " + h.Backup
+	}
+	return fmt.Sprintf("archives of %s matching %s", h.Backup, h.Pattern)
+}
@@ -1,29 +1,229 @@
 package hooks
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
-func RunHooks(hooks []string) error {
-	for _, hook := range hooks {
-		hook = strings.TrimSpace(hook)
-		if hook == "" {
-			continue
+// ErrHookFailed - хотя бы один хук завершился с ошибкой
+var ErrHookFailed = errors.New("hook failed")
+
+// Действия при ошибке хука (on_hook_failure)
+const (
+	// FailureWarn - ошибка выводится, остальные хуки и бэкап выполняются (по умолчанию)
+	FailureWarn = "warn"
+	// FailureAbort - остальные хуки не выполняются, бэкап завершается ошибкой
+	FailureAbort = "abort"
+	// FailureSkipBackup - остальные хуки не выполняются, бэкап пропускается без ошибки
+	FailureSkipBackup = "skip-backup"
+)
+
+// Hook - команда хука. В YAML записывается строкой или, чтобы задать
+// параметры отдельного хука, объектом с command либо одним из встроенных
+// действий (docker_stop, docker_start, systemd_stop, systemd_start)
+type Hook struct {
+	Command string `yaml:"command"`
+	// DockerStop, DockerStart - остановить или запустить контейнер Docker
+	DockerStop  string `yaml:"docker_stop"`
+	DockerStart string `yaml:"docker_start"`
+	// SystemdStop, SystemdStart - остановить или запустить юнит systemd
+	SystemdStop  string `yaml:"systemd_stop"`
+	SystemdStart string `yaml:"systemd_start"`
+	// OnHookFailure переопределяет on_hook_failure бэкапа для этого хука
+	OnHookFailure string `yaml:"on_hook_failure"`
+	// Timeout - предельное время хука, после которого он завершается и считается
+	// неудавшимся (0 - без ограничения)
+	Timeout time.Duration `yaml:"timeout"`
+	// WorkingDir - рабочая директория хука (по умолчанию текущая директория goback)
+	WorkingDir string `yaml:"working_dir"`
+	// CaptureOutput=false передает stdout и stderr хука напрямую в вывод goback по
+	// мере появления; по умолчанию вывод собирается и печатается после завершения
+	CaptureOutput *bool `yaml:"capture_output"`
+}
+
+// hookWaitDelay - сколько ждать закрытия вывода хука после его завершения по
+// timeout (дочерние процессы хука могут держать вывод открытым)
+const hookWaitDelay = 5 * time.Second
+
+func (h *Hook) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*h = Hook{Command: value.Value}
+		return nil
+	}
+	type plain Hook
+	return value.Decode((*plain)(h))
+}
+
+// MarshalYAML записывает хук без параметров строкой, как он обычно задается
+func (h Hook) MarshalYAML() (interface{}, error) {
+	if h == (Hook{Command: h.Command}) {
+		return h.Command, nil
+	}
+	type plain Hook
+	return plain(h), nil
+}
+
+// String возвращает команду хука или встроенное действие в виде "docker_stop: name"
+func (h Hook) String() string {
+	if key, name := h.builtin(); key != "" {
+		return key + ": " + name
+	}
+	return strings.TrimSpace(h.Command)
+}
+
+// builtin возвращает ключ и объект встроенного действия ("" - хук с command)
+func (h Hook) builtin() (string, string) {
+	switch {
+	case h.DockerStop != "":
+		return "docker_stop", h.DockerStop
+	case h.DockerStart != "":
+		return "docker_start", h.DockerStart
+	case h.SystemdStop != "":
+		return "systemd_stop", h.SystemdStop
+	case h.SystemdStart != "":
+		return "systemd_start", h.SystemdStart
+	}
+	return "", ""
+}
+
+// args возвращает программу и аргументы хука. Встроенные действия вызывают docker
+// и systemctl напрямую с именем одним аргументом, команда разбивается по пробелам
+func (h Hook) args() []string {
+	switch key, name := h.builtin(); key {
+	case "docker_stop":
+		return []string{"docker", "stop", name}
+	case "docker_start":
+		return []string{"docker", "start", name}
+	case "systemd_stop":
+		return []string{"systemctl", "stop", name}
+	case "systemd_start":
+		return []string{"systemctl", "start", name}
+	}
+	return strings.Fields(h.Command)
+}
+
+// Validate проверяет параметры хука
+func (h Hook) Validate() error {
+	var set []string
+	for key, value := range map[string]string{
+		"command":       h.Command,
+		"docker_stop":   h.DockerStop,
+		"docker_start":  h.DockerStart,
+		"systemd_stop":  h.SystemdStop,
+		"systemd_start": h.SystemdStart,
+	} {
+		if strings.TrimSpace(value) != "" {
+			set = append(set, key)
 		}
+	}
+	if len(set) > 1 {
+		sort.Strings(set)
+		return fmt.Errorf("hook can set only one of command, docker_stop, docker_start, systemd_stop, systemd_start (got %s)", strings.Join(set, ", "))
+	}
+	if err := ValidateFailure(h.OnHookFailure); err != nil {
+		return err
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	return nil
+}
+
+// capture сообщает, собирается ли вывод хука (по умолчанию да)
+func (h Hook) capture() bool {
+	return h.CaptureOutput == nil || *h.CaptureOutput
+}
+
+// ValidateFailure проверяет значение on_hook_failure ("" - по умолчанию)
+func ValidateFailure(action string) error {
+	switch action {
+	case "", FailureWarn, FailureAbort, FailureSkipBackup:
+		return nil
+	default:
+		return fmt.Errorf("on_hook_failure must be warn, abort or skip-backup")
+	}
+}
+
+// Commands строит хуки из строк команд
+func Commands(commands ...string) []Hook {
+	result := make([]Hook, len(commands))
+	for i, command := range commands {
+		result[i] = Hook{Command: command}
+	}
+	return result
+}
+
+// FailedError - ошибка хуков с действием, которое выбрал упавший хук
+type FailedError struct {
+	// Action - warn, abort или skip-backup
+	Action string
+	Failed []string
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrHookFailed, strings.Join(e.Failed, "; "))
+}
+
+func (e *FailedError) Unwrap() error {
+	return ErrHookFailed
+}
+
+// FailureAction возвращает действие для ошибки RunHooks (warn, если это не ошибка хука)
+func FailureAction(err error) string {
+	var failed *FailedError
+	if errors.As(err, &failed) {
+		return failed.Action
+	}
+	return FailureWarn
+}
 
-		// Разбиваем команду на части
-		parts := strings.Fields(hook)
-		if len(parts) == 0 {
+// RunHooks выполняет хуки по порядку с окружением goback, дополненным env
+// ("NAME=value"). Действие при ошибке берется из on_hook_failure хука, иначе из
+// policy. При warn остальные хуки выполняются, а ошибка возвращается в конце;
+// при abort и skip-backup выполнение сразу прекращается. Ошибка - *FailedError
+// (оборачивает ErrHookFailed)
+func RunHooks(hooks []Hook, policy string, env ...string) error {
+	failed := &FailedError{Action: FailureWarn}
+
+	for _, hook := range hooks {
+		command := hook.String()
+		if command == "" {
 			continue
 		}
 
-		cmd := exec.Command(parts[0], parts[1:]...)
-		output, err := cmd.CombinedOutput()
+		output, err := runHook(hook, env)
+		if key, name := hook.builtin(); key != "" {
+			// Вывод docker и systemctl - часть ошибки, при успехе он не нужен
+			if err != nil && len(bytes.TrimSpace(output)) > 0 {
+				err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(output))
+			}
+			output = nil
+			if err == nil {
+				fmt.Printf("Hook %s: %s done\n", key, name)
+			}
+		}
 		if err != nil {
-			// Логируем ошибку, но не прерываем процесс
-			fmt.Printf("Hook failed: %s\nOutput: %s\nError: %v\n", hook, string(output), err)
+			if hook.capture() && len(output) > 0 {
+				fmt.Printf("Hook failed: %s\nOutput: %s\nError: %v\n", command, string(output), err)
+			} else {
+				fmt.Printf("Hook failed: %s\nError: %v\n", command, err)
+			}
+			failed.Failed = append(failed.Failed, fmt.Sprintf("%s (%v)", command, err))
+
+			action := hookAction(hook, policy)
+			if action != FailureWarn {
+				failed.Action = action
+				return failed
+			}
 			continue
 		}
 
@@ -32,6 +232,104 @@ func RunHooks(hooks []string) error {
 		}
 	}
 
+	if len(failed.Failed) > 0 {
+		return failed
+	}
+
 	return nil
 }
 
+// runHook выполняет хук с его timeout и рабочей директорией и возвращает собранный
+// вывод (при capture_output: false вывод идет прямо в stdout и stderr goback)
+func runHook(hook Hook, env []string) ([]byte, error) {
+	ctx := context.Background()
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	cmd := buildCommand(ctx, hook, env)
+	cmd.WaitDelay = hookWaitDelay
+
+	var output []byte
+	var err error
+	if hook.capture() {
+		output, err = cmd.CombinedOutput()
+	} else {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output, fmt.Errorf("timed out after %s", hook.Timeout)
+	}
+	return output, err
+}
+
+// hookAction - действие при ошибке хука: его собственное, иначе policy, иначе warn
+func hookAction(hook Hook, policy string) string {
+	if hook.OnHookFailure != "" {
+		return hook.OnHookFailure
+	}
+	if policy != "" {
+		return policy
+	}
+	return FailureWarn
+}
+
+// DryRunHooks выводит для каждого хука команду, окружение и рабочую директорию,
+// с которыми он был бы выполнен, не запуская его
+func DryRunHooks(hooks []Hook, policy string, env ...string) {
+	for _, hook := range hooks {
+		command := hook.String()
+		if command == "" {
+			continue
+		}
+		cmd := buildCommand(context.Background(), hook, env)
+
+		fmt.Printf("Would run hook: %s\n", command)
+		fmt.Printf("  on failure: %s\n", hookAction(hook, policy))
+		if hook.Timeout > 0 {
+			fmt.Printf("  timeout: %s\n", hook.Timeout)
+		}
+		if !hook.capture() {
+			fmt.Printf("  output: passed through (capture_output: false)\n")
+		}
+
+		if path, err := exec.LookPath(cmd.Args[0]); err != nil {
+			fmt.Printf("  executable: %s (not found, the hook would fail)\n", cmd.Args[0])
+		} else {
+			fmt.Printf("  executable: %s\n", path)
+		}
+		fmt.Printf("  args: %q\n", cmd.Args)
+
+		dir := cmd.Dir
+		if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		fmt.Printf("  working directory: %s\n", dir)
+
+		if len(env) == 0 {
+			fmt.Printf("  environment: inherited from goback\n")
+		} else {
+			fmt.Printf("  environment: inherited from goback, plus:\n")
+			for _, variable := range env {
+				fmt.Printf("    %s\n", variable)
+			}
+		}
+	}
+}
+
+// buildCommand строит команду хука так же, как она будет выполнена: строка разбивается
+// на аргументы по пробелам без участия shell. Команда хука не должна быть пустой
+func buildCommand(ctx context.Context, hook Hook, env []string) *exec.Cmd {
+	parts := hook.args()
+
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Dir = hook.WorkingDir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
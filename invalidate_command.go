@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// invalidateCommand: goback invalidate <name>... - сбрасывает сохраненное состояние
+// бэкапа (снимок метаданных, база hardlink), чтобы следующий запуск был полным
+func invalidateCommand(args []string) int {
+	fs := flag.NewFlagSet("invalidate", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+
+	names, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(names) == 0 {
+		utils.PrintError("Usage: goback invalidate [-c config.yaml] <backup-name>...")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+
+	failed := 0
+	for _, name := range names {
+		backupCfg, err := findBackupConfig(cfg, name)
+		if err != nil {
+			utils.PrintError("%v", err)
+			failed++
+			continue
+		}
+
+		dropped, err := backup.InvalidateState(cfg.GlobalFor(backupCfg), backupCfg)
+		if err != nil {
+			utils.PrintError("Failed to invalidate %s: %v", name, err)
+			failed++
+			continue
+		}
+		if len(dropped) == 0 {
+			dropped = []string{"nothing cached"}
+		}
+		utils.PrintSuccess("Invalidated %s: %s; the next run is a clean full backup", name, strings.Join(dropped, ", "))
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// inventoryCommand: goback inventory [--format csv|json] [--to file] - выгружает все
+// известные каталогу архивы для CMDB и отчетов о соответствии
+func inventoryCommand(args []string) int {
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	format := fs.String("format", "csv", "Output format: csv or json")
+	toFile := fs.String("to", "", "Write inventory to file instead of stdout")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) != 0 || (*format != "csv" && *format != "json") {
+		utils.PrintError("Usage: goback inventory [-c config.yaml] [--format csv|json] [--to file]")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	items, err := backup.Inventory(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	out := io.Writer(os.Stdout)
+	if *toFile != "" {
+		file, err := os.Create(*toFile)
+		if err != nil {
+			utils.PrintError("Failed to create %s: %v", *toFile, err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if *format == "json" {
+		err = writeInventoryJSON(out, items)
+	} else {
+		err = writeInventoryCSV(out, items)
+	}
+	if err != nil {
+		utils.PrintError("Failed to write inventory: %v", err)
+		return 1
+	}
+
+	if *toFile != "" {
+		utils.PrintSuccess("Inventory exported to %s (%d archive(s))", *toFile, len(items))
+	}
+	return 0
+}
+
+func writeInventoryJSON(out io.Writer, items []backup.InventoryItem) error {
+	if items == nil {
+		items = []backup.InventoryItem{}
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(items)
+}
+
+func writeInventoryCSV(out io.Writer, items []backup.InventoryItem) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"tenant", "backup", "destination", "name", "key", "time", "size", "checksum", "tier"}); err != nil {
+		return err
+	}
+	for _, item := range items {
+		record := []string{
+			item.Tenant,
+			item.Backup,
+			item.Destination,
+			item.Name,
+			item.Key,
+			item.Time.Format(time.RFC3339),
+			strconv.FormatInt(item.Size, 10),
+			item.Checksum,
+			item.Tier,
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// listCommand: goback list [backup-name...] [--local] - таблица архивов каждого
+// бэкапа в backup_dir и destinations с размером, возрастом, типом сжатия и уровнем
+// retention, который их сохраняет
+func listCommand(args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	localOnly := fs.Bool("local", false, "Only scan backup_dir, skip destinations")
+
+	backupNames, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	now := time.Now()
+	found, failed := 0, 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+		found++
+
+		items, err := backup.ListArchives(cfg.GlobalFor(backupCfg), backupCfg, !*localOnly)
+		if err != nil {
+			utils.PrintError("%s: %v", backupCfg.Name, err)
+			failed++
+			continue
+		}
+
+		utils.PrintHeader("%s (%d archive(s))", backupCfg.Name, len(items))
+		destination := ""
+		for _, item := range items {
+			if item.Destination != destination {
+				destination = item.Destination
+				fmt.Printf("%s:\n", destination)
+			}
+			size := utils.FormatSize(item.Size)
+			if item.Volumes > 0 {
+				size += fmt.Sprintf(" (%d vol)", item.Volumes)
+			}
+			fmt.Printf("  %s  %-6s  %-16s  %-14s  %-15s  %s\n", item.Time.Format("2006-01-02 15:04"), formatAge(now.Sub(item.Time)), size, item.Compression, item.Tier, item.Name)
+		}
+	}
+
+	if len(backupNames) > 0 && found == 0 {
+		utils.PrintError("No backups matching %v", backupNames)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// formatAge возвращает возраст архива: 45m, 5h, 12d
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,203 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// ErrLocked - блокировку держит другой запуск goback
+var ErrLocked = errors.New("locked by another goback run")
+
+// pollInterval - как часто проверяется освободившаяся блокировка при ожидании
+const pollInterval = time.Second
+
+// Info - содержимое файла блокировки: кто и когда ее взял
+type Info struct {
+	PID       int       `json:"pid"`
+	Hostname  string    `json:"hostname"`
+	RunID     string    `json:"run_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("pid %d on %s since %s", i.PID, i.Hostname, i.StartedAt.Format("2006-01-02 15:04:05"))
+}
+
+// Lock - взятая блокировка
+type Lock struct {
+	path string
+}
+
+// HeldError - блокировка занята; оборачивает ErrLocked
+type HeldError struct {
+	Path string
+	Info Info
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("%s: %v (%s)", e.Path, ErrLocked, e.Info)
+}
+
+func (e *HeldError) Unwrap() error {
+	return ErrLocked
+}
+
+// Acquire берет блокировку path, ожидая ее освобождения не дольше wait (0 - не
+// ждать). Блокировка завершившегося процесса на этом же хосте считается
+// брошенной и забирается
+func Acquire(path, runID string, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	info := Info{PID: os.Getpid(), Hostname: hostname, RunID: runID, StartedAt: time.Now()}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err := create(path, data)
+		if err == nil {
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock %s: %w", path, err)
+		}
+
+		held, err := Read(path)
+		if err != nil {
+			// Файл удален между попытками - пробуем снова
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if Stale(held) {
+			if err := takeOver(path, held); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, &HeldError{Path: path, Info: held}
+		}
+		time.Sleep(min(pollInterval, time.Until(deadline)))
+	}
+}
+
+// takeOver удаляет брошенную блокировку held. Между Read и удалением другой запуск
+// мог уже забрать ее и создать свою, поэтому файл сначала атомарно переименовывается
+// в имя, известное только этому процессу, и удаляется, только если в нем та же
+// брошенная блокировка. Чужая свежая блокировка возвращается на место
+func takeOver(path string, held Info) error {
+	claimed := fmt.Sprintf("%s.stale.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, claimed); err != nil {
+		// Блокировку уже забрал другой запуск
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove stale lock: %w", err)
+	}
+
+	current, err := Read(claimed)
+	if err == nil && current.sameRun(held) {
+		fmt.Printf("Removing stale lock %s (%s)\n", path, held)
+		if err := os.Remove(claimed); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock: %w", err)
+		}
+		return nil
+	}
+
+	// Link, в отличие от Rename, не заменит блокировку, которую успел создать
+	// третий запуск
+	if err := os.Link(claimed, path); err != nil && !os.IsExist(err) {
+		return fmt.Errorf("failed to restore lock %s: %w", path, err)
+	}
+	os.Remove(claimed)
+	return nil
+}
+
+// sameRun сообщает, что обе записи описывают одну и ту же блокировку
+func (i Info) sameRun(other Info) bool {
+	return i.PID == other.PID && i.Hostname == other.Hostname && i.RunID == other.RunID && i.StartedAt.Equal(other.StartedAt)
+}
+
+// create атомарно создает файл блокировки; занятая блокировка - os.ErrExist
+func create(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// Release снимает блокировку
+func (l *Lock) Release() {
+	if l == nil {
+		return
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Warning: failed to remove lock %s: %v\n", l.path, err)
+	}
+}
+
+// Read читает файл блокировки. Поврежденный файл (запуск прервался при записи)
+// возвращается как Info без PID
+func Read(path string) (Info, error) {
+	var info Info
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return info, err
+		}
+		return info, fmt.Errorf("failed to read lock %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		info = Info{}
+	}
+	if info.StartedAt.IsZero() {
+		if stat, err := os.Stat(path); err == nil {
+			info.StartedAt = stat.ModTime()
+		}
+	}
+	return info, nil
+}
+
+// Stale сообщает, что процесс, взявший блокировку, уже завершился. Блокировка
+// другого хоста (общий state_dir на NFS) брошенной не считается - проверить ее
+// процесс нельзя, и снимает ее администратор
+func Stale(info Info) bool {
+	hostname, _ := os.Hostname()
+	if info.Hostname != "" && info.Hostname != hostname {
+		return false
+	}
+	// Файл без PID мог быть только что создан и еще не записан
+	if info.PID <= 0 {
+		return time.Since(info.StartedAt) > time.Minute
+	}
+	return !alive(info.PID)
+}
+
+// alive проверяет, что процесс pid существует (сигнал 0 ничего не доставляет)
+func alive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
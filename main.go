@@ -1,23 +1,63 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"goback/backup"
 	"goback/config"
 	"goback/hooks"
+	"goback/lock"
 	"goback/utils"
 )
 
 func main() {
+	// --quiet и --no-color действуют на весь вывод и допустимы в любом месте
+	cliArgs, options := extractGlobalOptions(os.Args[1:])
+	// Отчет --report json выводится и при --quiet, поэтому запоминаем настоящий stdout
+	reportOutput := os.Stdout
+	if options.noColor {
+		utils.SetColors(false)
+	}
+	if options.quiet {
+		// Весь обычный вывод идет в stdout, ошибки - в stderr
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout = devNull
+		}
+	}
+
+	// Подкоманды: ./goback [global flags] <command> [flags]
+	leading, commandName, commandArgs := splitLeadingFlags(cliArgs)
+	switch commandName {
+	case "help":
+		printUsage()
+		os.Exit(0)
+	case "run":
+		// "run" - явная форма запуска бэкапов, эквивалентная вызову без подкоманды
+		cliArgs = append(leading, commandArgs...)
+	case "":
+	default:
+		os.Exit(commands[commandName].run(append(leading, commandArgs...)))
+	}
+	os.Args = append(os.Args[:1], cliArgs...)
+
 	// Парсим флаги командной строки
 	var configPath string
 	var backupNames flagArray
 	var skipGlobalPreHooks bool
 	var skipGlobalPostHooks bool
+	var dryRun bool
+	var offline bool
+	var verbose bool
+	var lockWait time.Duration
+	var noWait bool
+	var onlyNames, tags, skipNames listFlag
+	var reportFormat string
 
 	flag.StringVar(&configPath, "config", "config.yaml", "Path to configuration file")
 	flag.StringVar(&configPath, "c", "config.yaml", "Path to configuration file (short)")
@@ -27,23 +67,50 @@ func main() {
 	flag.BoolVar(&skipGlobalPreHooks, "skip-pre-hooks", false, "Skip global pre-hooks execution (short)")
 	flag.BoolVar(&skipGlobalPostHooks, "skip-global-post-hooks", false, "Skip global post-hooks execution")
 	flag.BoolVar(&skipGlobalPostHooks, "skip-post-hooks", false, "Skip global post-hooks execution (short)")
+	flag.BoolVar(&verbose, "verbose", false, "Log every skipped file with the matching pattern or error")
+	flag.BoolVar(&verbose, "v", false, "Log every skipped file (short)")
+	flag.BoolVar(&offline, "offline", false, "Disable network operations, defer uploads to network destinations")
+	flag.BoolVar(&dryRun, "dry-run", false, "Show what would be archived and removed without writing anything")
+	flag.DurationVar(&lockWait, "wait", 0, "Wait up to this long for another goback run to finish (default: lock_wait from config)")
+	flag.BoolVar(&noWait, "no-wait", false, "Exit immediately if another goback run holds the lock")
+	flag.Var(&onlyNames, "only", "Run only these backups, comma-separated (same as -b)")
+	flag.Var(&tags, "tag", "Run only backups with one of these tags, comma-separated or repeated")
+	flag.Var(&skipNames, "skip", "Do not run these backups (names, groups or tenants), comma-separated or repeated")
+	flag.StringVar(&reportFormat, "report", "", "Print a machine-readable summary of the run to stdout (json); other output goes to stderr")
 
+	flag.Usage = func() {
+		printUsage()
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags of run:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
+	if reportFormat != "" && reportFormat != "json" {
+		utils.PrintError("Unknown report format %q (supported: json)", reportFormat)
+		os.Exit(2)
+	}
+	if reportFormat != "" && !options.quiet {
+		// В stdout идет только отчет, чтобы его можно было передать в jq; обычный
+		// вывод запуска (и вывод хуков) уходит в stderr
+		os.Stdout = os.Stderr
+	}
 
 	// Обрабатываем позиционные аргументы для обратной совместимости
 	// Формат: ./goback [config.yaml] [backup1] [backup2] ...
 	args := flag.Args()
 	if len(args) > 0 {
 		// Первый аргумент может быть конфигом, если заканчивается на .yaml/.yml
-		firstArg := args[0]
-		if strings.HasSuffix(strings.ToLower(firstArg), ".yaml") || strings.HasSuffix(strings.ToLower(firstArg), ".yml") {
-			configPath = firstArg
+		// (или .enc/.age для зашифрованной конфигурации)
+		firstArg := strings.ToLower(args[0])
+		if strings.HasSuffix(firstArg, ".yaml") || strings.HasSuffix(firstArg, ".yml") ||
+			strings.HasSuffix(firstArg, ".enc") || strings.HasSuffix(firstArg, ".age") {
+			configPath = args[0]
 			args = args[1:] // Остальные аргументы - имена бэкапов
 		}
 
 		// Все оставшиеся аргументы - имена бэкапов
 		backupNames = append(backupNames, args...)
 	}
+	backupNames = append(backupNames, onlyNames...)
 
 	utils.PrintHeader("Loading configuration from %s...", configPath)
 	cfg, err := config.LoadConfig(configPath)
@@ -55,16 +122,23 @@ func main() {
 	// Фильтруем бэкапы по указанным именам
 	backupsToProcess := cfg.Backups
 	if len(backupNames) > 0 {
-		backupMap := make(map[string]*config.BackupConfig)
+		// Имя группы (бэкап с mysql.per_database) или клиента выбирает все его бэкапы
+		backupMap := make(map[string][]config.BackupConfig)
 		for i := range cfg.Backups {
-			backupMap[cfg.Backups[i].Name] = &cfg.Backups[i]
+			backupMap[cfg.Backups[i].Name] = append(backupMap[cfg.Backups[i].Name], cfg.Backups[i])
+			if cfg.Backups[i].Group != "" {
+				backupMap[cfg.Backups[i].Group] = append(backupMap[cfg.Backups[i].Group], cfg.Backups[i])
+			}
+			if cfg.Backups[i].Tenant != "" {
+				backupMap[cfg.Backups[i].Tenant] = append(backupMap[cfg.Backups[i].Tenant], cfg.Backups[i])
+			}
 		}
 
 		backupsToProcess = []config.BackupConfig{}
 		var notFound []string
 		for _, name := range backupNames {
-			if backup, exists := backupMap[name]; exists {
-				backupsToProcess = append(backupsToProcess, *backup)
+			if backups, exists := backupMap[name]; exists {
+				backupsToProcess = append(backupsToProcess, backups...)
 			} else {
 				notFound = append(notFound, name)
 			}
@@ -76,39 +150,365 @@ func main() {
 		}
 	}
 
+	// --tag оставляет бэкапы хотя бы с одной из меток, --skip исключает бэкапы
+	// по имени, группе или клиенту
+	if len(tags) > 0 {
+		tagged := backupsToProcess[:0:0]
+		for _, backupCfg := range backupsToProcess {
+			if backupCfg.HasTag(tags) {
+				tagged = append(tagged, backupCfg)
+			}
+		}
+		if len(tagged) == 0 {
+			utils.PrintError("No backups with tag(s): %s", strings.Join(tags, ", "))
+			os.Exit(1)
+		}
+		backupsToProcess = tagged
+	}
+	for _, name := range skipNames {
+		if !backupExists(cfg, name) {
+			fmt.Printf("Warning: --skip %s matches no backup\n", name)
+		}
+	}
+	if len(skipNames) > 0 {
+		kept := backupsToProcess[:0:0]
+		for _, backupCfg := range backupsToProcess {
+			if containsString(skipNames, backupCfg.Name) || (backupCfg.Group != "" && containsString(skipNames, backupCfg.Group)) || (backupCfg.Tenant != "" && containsString(skipNames, backupCfg.Tenant)) {
+				continue
+			}
+			kept = append(kept, backupCfg)
+		}
+		backupsToProcess = kept
+	}
+	// Запуск выбранных бэкапов не берет общую блокировку и не выполняет self backup
+	selective := len(backupNames) > 0 || len(tags) > 0
+
+	// tenants - клиент каждого бэкапа, чтобы итоги запуска попали только в его notifications
+	tenants := make(map[string]string)
+	for _, backupCfg := range cfg.Backups {
+		tenants[backupCfg.Name] = backupCfg.Tenant
+	}
+
+	// Приостановленные бэкапы не запускаются, даже если указаны по имени
+	var paused, pausedReasons []string
+	active := backupsToProcess[:0:0]
+	now := time.Now()
+	report := newRunReport(now, dryRun)
+	for _, backupCfg := range backupsToProcess {
+		if isPaused, reason := backupCfg.Paused(now); isPaused {
+			paused = append(paused, backupCfg.Name)
+			pausedReasons = append(pausedReasons, fmt.Sprintf("%s (%s)", backupCfg.Name, reason))
+			report.add(backupCfg.Name, backupCfg.Tenant, "paused", reason)
+			continue
+		}
+		active = append(active, backupCfg)
+	}
+	backupsToProcess = active
+
+	// Общий идентификатор запуска попадает в логи, отчеты, уведомления,
+	// метаданные загруженных объектов и окружение хуков
+	runID := backup.NewRunID(now)
+	report.RunID = runID
 	utils.PrintHeader("Found %d backup(s) to process", len(backupsToProcess))
+	fmt.Printf("Run ID: %s\n", runID)
+	if len(paused) > 0 {
+		fmt.Printf("Paused: %s\n", strings.Join(pausedReasons, ", "))
+	}
+
+	// В режиме dry run хуки только выводятся, а self backup, spool и каталог не трогаются
+	if dryRun {
+		utils.PrintHeader("Dry run: nothing will be written or removed")
+	}
+
+	// Блокировки не дают запуску из cron начаться, пока предыдущий еще копирует:
+	// полный запуск берет общую блокировку, каждый бэкап - свою
+	wait := cfg.Global.LockWait
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "wait" {
+			wait = lockWait
+		}
+	})
+	if noWait {
+		wait = 0
+	}
+	var runLock *lock.Lock
+	if !selective && !dryRun {
+		runLock, err = lock.Acquire(cfg.Global.LockPath(), runID, wait)
+		if err != nil {
+			utils.PrintError("Another goback run is in progress: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Глобальные хуки выполняются в текущей директории, которая не должна быть
+	// внутри source_dir бэкапа с read_only
+	if (!skipGlobalPreHooks && len(cfg.Global.PreHooks) > 0) || (!skipGlobalPostHooks && len(cfg.Global.PostHooks)+len(cfg.Global.OnSuccess)+len(cfg.Global.OnFailure) > 0) {
+		for i := range backupsToProcess {
+			if err := backup.CheckReadOnly(cfg.GlobalFor(&backupsToProcess[i]), &backupsToProcess[i], true); err != nil {
+				utils.PrintError("Refusing to run %s: %v", backupsToProcess[i].Name, err)
+				runLock.Release()
+				os.Exit(1)
+			}
+		}
+	}
 
 	// Выполняем глобальные pre-hooks перед всеми бэкапами
 	if !skipGlobalPreHooks && len(cfg.Global.PreHooks) > 0 {
-		utils.PrintHeader("Running global pre-hooks...")
-		if err := hooks.RunHooks(cfg.Global.PreHooks); err != nil {
-			fmt.Printf("Warning: global pre-hooks completed with errors\n")
+		if dryRun {
+			utils.PrintHeader("Global pre-hooks:")
+			hooks.DryRunHooks(cfg.Global.PreHooks, cfg.Global.OnHookFailure, "GOBACK_RUN_ID="+runID)
+		} else {
+			utils.PrintHeader("Running global pre-hooks...")
+			if err := hooks.RunHooks(cfg.Global.PreHooks, cfg.Global.OnHookFailure, "GOBACK_RUN_ID="+runID); err != nil {
+				// При abort и skip-backup бэкапы без результата глобальных хуков не запускаются
+				switch hooks.FailureAction(err) {
+				case hooks.FailureAbort:
+					utils.PrintError("Global pre-hooks failed, aborting the run: %v", err)
+					runLock.Release()
+					os.Exit(1)
+				case hooks.FailureSkipBackup:
+					utils.PrintError("Global pre-hooks failed, skipping all backups: %v", err)
+					runLock.Release()
+					os.Exit(0)
+				default:
+					fmt.Printf("Warning: global pre-hooks completed with errors\n")
+				}
+			}
 		}
 	}
 
-	executor := backup.NewExecutor(&cfg.Global)
+	// Каждый раздел клиента выполняется своим executor со своими backup_dir,
+	// state_dir и notifications; общие бэкапы - executor с глобальными параметрами
+	var deadline time.Time
+	if cfg.Global.MaxWindow > 0 {
+		deadline = time.Now().Add(cfg.Global.MaxWindow)
+		fmt.Printf("Backup window: until %s\n", deadline.Format("2006-01-02 15:04:05"))
+	}
+	scopes := cfg.Scopes()
+	executors := make(map[string]*backup.Executor)
+	for _, scope := range scopes {
+		scopeExecutor := backup.NewExecutor(&scope.Global)
+		scopeExecutor.SetRunID(runID)
+		scopeExecutor.SetDryRun(dryRun)
+		scopeExecutor.SetOffline(offline)
+		scopeExecutor.SetVerbose(verbose)
+		scopeExecutor.SetLockWait(wait)
+		if !deadline.IsZero() {
+			scopeExecutor.SetDeadline(deadline)
+		}
+		executors[scope.Tenant] = scopeExecutor
+	}
+	executor := executors[""]
 
 	successCount := 0
 	errorCount := 0
+	var skipped []string
+	// locked - бэкапы, которые еще выполняет другой запуск
+	var locked []string
+	// failures - причины ошибок для итоговой сводки
+	var failures []string
+	// hookSkipped - бэкапы, пропущенные по on_hook_failure: skip-backup
+	var hookSkipped []string
+
+	// Бэкапы запускаются с учетом parallelism, лимитов пулов ресурсов и depends_on:
+	// зависимый бэкап ждет свои зависимости и пропускается, если они не удались
+	var mu sync.Mutex
+	sorted, deps, err := config.SortByDependencies(backupsToProcess)
+	if err != nil {
+		utils.PrintError("%v", err)
+		runLock.Release()
+		os.Exit(1)
+	}
+	backupsToProcess = sorted
+	succeeded := make([]bool, len(backupsToProcess))
+	// blocked - бэкапы, пропущенные из-за неудачной зависимости
+	var blocked []string
+	scheduler := backup.NewScheduler(cfg.Global.Parallelism, cfg.Global.Pools)
+	scheduler.SetDependencies(deps)
+	scheduler.Run(len(backupsToProcess), func(i int) string {
+		return backupsToProcess[i].Pool
+	}, func(i int) {
+		backupCfg := &backupsToProcess[i]
+
+		// После окончания окна оставшиеся бэкапы не запускаем
+		if executor.WindowExceeded() {
+			mu.Lock()
+			skipped = append(skipped, backupCfg.Name)
+			report.add(backupCfg.Name, backupCfg.Tenant, "skipped", "backup window exceeded")
+			mu.Unlock()
+			return
+		}
+
+		mu.Lock()
+		failedDependency := ""
+		for _, dep := range deps[i] {
+			if !succeeded[dep] {
+				failedDependency = backupsToProcess[dep].Name
+				break
+			}
+		}
+		if failedDependency != "" {
+			utils.PrintError("Backup %s skipped: dependency %s did not succeed", backupCfg.Name, failedDependency)
+			blocked = append(blocked, fmt.Sprintf("%s (%s)", backupCfg.Name, failedDependency))
+			report.add(backupCfg.Name, backupCfg.Tenant, "blocked", fmt.Sprintf("dependency %s did not succeed", failedDependency))
+			mu.Unlock()
+			return
+		}
+		mu.Unlock()
 
-	for i, backupCfg := range backupsToProcess {
 		utils.PrintHeaderf("\n[%d/%d] Processing backup: %s\n", i+1, len(backupsToProcess), backupCfg.Name)
 
-		if err := executor.ExecuteBackup(&backupCfg); err != nil {
+		err := executors[backupCfg.Tenant].ExecuteBackup(backupCfg)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			if errors.Is(err, backup.ErrWindowExceeded) {
+				utils.PrintError("Backup %s aborted: backup window exceeded", backupCfg.Name)
+				skipped = append(skipped, backupCfg.Name)
+				report.add(backupCfg.Name, backupCfg.Tenant, "skipped", "backup window exceeded")
+				return
+			}
+			if errors.Is(err, backup.ErrSkippedByHook) {
+				utils.PrintError("Backup %s skipped: %v", backupCfg.Name, err)
+				hookSkipped = append(hookSkipped, backupCfg.Name)
+				report.add(backupCfg.Name, backupCfg.Tenant, "hook_skipped", err.Error())
+				return
+			}
+			if errors.Is(err, backup.ErrLocked) {
+				utils.PrintError("Backup %s skipped: %v", backupCfg.Name, err)
+				locked = append(locked, backupCfg.Name)
+				report.add(backupCfg.Name, backupCfg.Tenant, "locked", err.Error())
+				return
+			}
 			utils.PrintError("Error executing backup %s: %v", backupCfg.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", backupCfg.Name, err))
+			report.add(backupCfg.Name, backupCfg.Tenant, "failure", err.Error())
 			errorCount++
-			continue
+			return
 		}
 
 		successCount++
+		succeeded[i] = true
+		report.add(backupCfg.Name, backupCfg.Tenant, "success", "")
+	})
+
+	// Архивируем собственную конфигурацию и состояние goback
+	if cfg.Global.SelfBackup != nil && cfg.Global.SelfBackup.Enabled && !selective && !containsString(skipNames, cfg.Global.SelfBackup.Name) && !executor.WindowExceeded() && !dryRun {
+		utils.PrintHeader("\nProcessing self backup: %s", cfg.Global.SelfBackup.Name)
+		selfCfg, cleanup, err := backup.PrepareSelfBackup(cfg)
+		if err != nil {
+			utils.PrintError("Error preparing self backup: %v", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", cfg.Global.SelfBackup.Name, err))
+			report.add(cfg.Global.SelfBackup.Name, "", "failure", err.Error())
+			errorCount++
+		} else {
+			if err := executor.ExecuteBackup(selfCfg); err != nil {
+				utils.PrintError("Error executing self backup: %v", err)
+				failures = append(failures, fmt.Sprintf("%s: %v", selfCfg.Name, err))
+				report.add(selfCfg.Name, "", "failure", err.Error())
+				errorCount++
+			} else {
+				successCount++
+				report.add(selfCfg.Name, "", "success", "")
+			}
+			cleanup()
+		}
+	}
+
+	// Итог запуска для глобальных хуков: failure, если хотя бы один бэкап не удался
+	// или не был выполнен
+	runOutcome := func() ([]string, bool) {
+		var runErr error
+		if notRun := len(skipped) + len(locked) + len(blocked); errorCount > 0 || notRun > 0 {
+			runErr = fmt.Errorf("%d backup(s) failed, %d not run", errorCount, notRun)
+		}
+		env := []string{
+			"GOBACK_RUN_ID=" + runID,
+			fmt.Sprintf("GOBACK_SUCCEEDED=%d", successCount),
+			fmt.Sprintf("GOBACK_FAILED=%d", errorCount),
+		}
+		return append(env, backup.ResultEnv(runErr, time.Since(now))...), runErr != nil
 	}
 
 	// Выполняем глобальные post-hooks после всех бэкапов
 	if !skipGlobalPostHooks && len(cfg.Global.PostHooks) > 0 {
-		utils.PrintHeader("\nRunning global post-hooks...")
-		if err := hooks.RunHooks(cfg.Global.PostHooks); err != nil {
-			fmt.Printf("Warning: global post-hooks completed with errors\n")
+		if dryRun {
+			utils.PrintHeader("\nGlobal post-hooks:")
+			hooks.DryRunHooks(cfg.Global.PostHooks, cfg.Global.OnHookFailure, "GOBACK_RUN_ID="+runID)
+		} else {
+			utils.PrintHeader("\nRunning global post-hooks...")
+			env, _ := runOutcome()
+			if err := hooks.RunHooks(cfg.Global.PostHooks, cfg.Global.OnHookFailure, env...); err != nil {
+				if hooks.FailureAction(err) == hooks.FailureAbort {
+					utils.PrintError("Global post-hooks failed: %v", err)
+					failures = append(failures, fmt.Sprintf("global post-hooks: %v", err))
+					errorCount++
+				} else {
+					fmt.Printf("Warning: global post-hooks completed with errors\n")
+				}
+			}
+		}
+	}
+
+	// Глобальные on_success или on_failure - по итогу запуска, включая post-hooks
+	if !skipGlobalPostHooks && len(cfg.Global.OnSuccess)+len(cfg.Global.OnFailure) > 0 {
+		if dryRun {
+			if len(cfg.Global.OnSuccess) > 0 {
+				utils.PrintHeader("\nGlobal on_success hooks:")
+				hooks.DryRunHooks(cfg.Global.OnSuccess, hooks.FailureWarn, "GOBACK_RUN_ID="+runID)
+			}
+			if len(cfg.Global.OnFailure) > 0 {
+				utils.PrintHeader("\nGlobal on_failure hooks:")
+				hooks.DryRunHooks(cfg.Global.OnFailure, hooks.FailureWarn, "GOBACK_RUN_ID="+runID)
+			}
+		} else {
+			env, failed := runOutcome()
+			outcomeHooks, name := cfg.Global.OnSuccess, "on_success"
+			if failed {
+				outcomeHooks, name = cfg.Global.OnFailure, "on_failure"
+			}
+			if len(outcomeHooks) > 0 {
+				utils.PrintHeader("\nRunning global %s hooks...", name)
+				if err := hooks.RunHooks(outcomeHooks, hooks.FailureWarn, env...); err != nil {
+					fmt.Printf("Warning: global %s hooks completed with errors\n", name)
+				}
+			}
+		}
+	}
+
+	var deferred []string
+	for _, scope := range scopes {
+		scopeExecutor := executors[scope.Tenant]
+		prefix := ""
+		if scope.Tenant != "" {
+			prefix = scope.Tenant + ": "
+		}
+
+		// Загружаем отложенные архивы, для которых уже открылось окно загрузки
+		if !dryRun {
+			if uploaded, pending, err := scopeExecutor.ProcessSpool(scope.Backups, false); err != nil {
+				fmt.Printf("Warning: %sfailed to process upload spool: %v\n", prefix, err)
+			} else if uploaded > 0 || pending > 0 {
+				fmt.Printf("%sUpload spool: %d uploaded, %d pending\n", prefix, uploaded, pending)
+			}
+		}
+
+		// Выгружаем копию каталога в destinations
+		if scope.Global.ExportCatalog && !dryRun {
+			if err := backup.ExportCatalog(scope, offline); err != nil {
+				fmt.Printf("Warning: %s%v\n", prefix, err)
+			}
+		}
+
+		// С разделами клиентов итог запуска получают только те, чьи бэкапы в нем участвовали
+		scopeSkipped := filterTenant(skipped, tenants, scope.Tenant)
+		scopePaused := filterTenant(paused, tenants, scope.Tenant)
+		if len(cfg.Tenants) == 0 || scopeExecutor.Ran() || len(scopeSkipped) > 0 || len(scopePaused) > 0 {
+			scopeExecutor.NotifyRun(scopeSkipped, scopePaused)
 		}
+		deferred = append(deferred, scopeExecutor.Deferred()...)
 	}
 
 	utils.PrintHeader("\n=== Summary ===")
@@ -122,12 +522,95 @@ func main() {
 	} else {
 		fmt.Printf("Failed: %d\n", errorCount)
 	}
+	for _, failure := range failures {
+		utils.PrintError("  %s", failure)
+	}
 
-	if errorCount > 0 {
+	if len(deferred) > 0 {
+		fmt.Printf("Deferred (offline): %s\n", strings.Join(deferred, ", "))
+	}
+
+	if len(paused) > 0 {
+		fmt.Printf("Paused: %s\n", strings.Join(pausedReasons, ", "))
+	}
+
+	if len(skipped) > 0 {
+		utils.PrintError("Skipped (backup window exceeded): %s", strings.Join(skipped, ", "))
+	}
+
+	if len(locked) > 0 {
+		utils.PrintError("Skipped (still running in another goback run): %s", strings.Join(locked, ", "))
+	}
+
+	if len(blocked) > 0 {
+		utils.PrintError("Skipped (dependency did not succeed): %s", strings.Join(blocked, ", "))
+	}
+
+	if len(hookSkipped) > 0 {
+		fmt.Printf("Skipped (pre-hook failed, on_hook_failure: skip-backup): %s\n", strings.Join(hookSkipped, ", "))
+	}
+
+	// Машиночитаемый итог - после всех шагов запуска, включая post-hooks и spool
+	if reportFormat != "" || cfg.Global.ReportFile != "" {
+		report.finish(executors, successCount, errorCount, len(skipped)+len(locked)+len(blocked), failures)
+		if cfg.Global.ReportFile != "" && !dryRun {
+			if err := report.save(cfg.Global.ReportFile); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
+		if reportFormat != "" {
+			if err := report.write(reportOutput); err != nil {
+				utils.PrintError("%v", err)
+			}
+		}
+	}
+
+	runLock.Release()
+	if errorCount > 0 || len(skipped) > 0 || len(locked) > 0 || len(blocked) > 0 {
 		os.Exit(1)
 	}
 }
 
+// backupExists проверяет, что name - имя бэкапа, группы, клиента или self backup
+func backupExists(cfg *config.Config, name string) bool {
+	if self := cfg.Global.SelfBackup; self != nil && self.Enabled && self.Name == name {
+		return true
+	}
+	for _, backupCfg := range cfg.Backups {
+		if backupCfg.Name == name || backupCfg.Group == name || backupCfg.Tenant == name {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTenant возвращает имена бэкапов клиента tenant ("" - общие бэкапы)
+func filterTenant(names []string, tenants map[string]string, tenant string) []string {
+	var filtered []string
+	for _, name := range names {
+		if tenants[name] == tenant {
+			filtered = append(filtered, name)
+		}
+	}
+	return filtered
+}
+
+// listFlag - флаг со списком через запятую, который можно указать несколько раз
+type listFlag []string
+
+func (f *listFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
 // flagArray для поддержки множественных значений флага
 type flagArray []string
 
@@ -139,4 +622,3 @@ func (f *flagArray) Set(value string) error {
 	*f = append(*f, value)
 	return nil
 }
-
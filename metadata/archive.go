@@ -0,0 +1,86 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"goback/utils"
+)
+
+// ArchiveExtension - расширение sidecar с описанием архива
+const ArchiveExtension = ".meta.json"
+
+// Archive - sidecar <archive>.meta.json: как и из чего создан архив. Внешние
+// инструменты и goback list читают его вместо разбора имени файла
+type Archive struct {
+	Backup string `json:"backup"`
+	// Source - source_dir, Command - команда бэкапа через output_file,
+	// Dump - тип дампа базы (postgres, mysql, mongodb, docker-volume)
+	Source  string `json:"source,omitempty"`
+	Command string `json:"command,omitempty"`
+	Dump    string `json:"dump,omitempty"`
+
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	// Files и UncompressedSize - файлы источника, попавшие в архив
+	// (для дампа и output_file - один файл и размер потока)
+	Files            int64  `json:"files"`
+	UncompressedSize int64  `json:"uncompressed_size"`
+	CompressedSize   int64  `json:"compressed_size"`
+	Compression      string `json:"compression"`
+	Encryption       string `json:"encryption,omitempty"`
+	// Volumes - число томов архива, разбитого по split_size
+	Volumes  int    `json:"volumes,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+
+	RunID         string `json:"run_id,omitempty"`
+	JobID         string `json:"job_id,omitempty"`
+	GobackVersion string `json:"goback_version"`
+	Hostname      string `json:"hostname"`
+}
+
+// IsArchiveFile проверяет, что файл - sidecar с описанием архива
+func IsArchiveFile(filename string) bool {
+	return strings.HasSuffix(strings.ToLower(filename), ArchiveExtension)
+}
+
+// WriteArchive записывает описание архива рядом с ним
+func WriteArchive(archivePath string, meta Archive) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := utils.WriteFileAtomic(archivePath+ArchiveExtension, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write archive metadata: %w", err)
+	}
+	return nil
+}
+
+// ReadArchive читает описание архива; ok=false - sidecar нет
+func ReadArchive(archivePath string) (Archive, bool, error) {
+	var meta Archive
+	data, err := os.ReadFile(archivePath + ArchiveExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, false, nil
+		}
+		return meta, false, fmt.Errorf("failed to read archive metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, false, fmt.Errorf("failed to parse %s: %w", archivePath+ArchiveExtension, err)
+	}
+	return meta, true, nil
+}
+
+// ForgetArchive удаляет описание удаленного архива
+func ForgetArchive(archivePath string) error {
+	if err := os.Remove(archivePath + ArchiveExtension); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove archive metadata: %w", err)
+	}
+	return nil
+}
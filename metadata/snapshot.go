@@ -0,0 +1,212 @@
+package metadata
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/utils"
+)
+
+// FileMeta - метаданные файла на момент бэкапа
+type FileMeta struct {
+	Size    int64       `json:"s"`
+	ModTime time.Time   `json:"m"`
+	Mode    os.FileMode `json:"p"`
+}
+
+// Snapshot - метаданные дерева источника после очередного запуска. Сохраняется
+// между запусками, чтобы оценивать размер и находить изменения без повторного
+// полного обхода
+type Snapshot struct {
+	Backup    string              `json:"backup"`
+	CreatedAt time.Time           `json:"created_at"`
+	Files     map[string]FileMeta `json:"files"`
+}
+
+// topGrowthDirs - сколько быстрее всего растущих директорий попадает в Changes
+const topGrowthDirs = 3
+
+// Changes - отличия текущего дерева от предыдущего снимка
+type Changes struct {
+	Added    int `json:"added"`
+	Modified int `json:"modified"`
+	Removed  int `json:"removed"`
+	// ChangedBytes - суммарный размер новых и измененных файлов
+	ChangedBytes int64 `json:"changed_bytes"`
+	// Growth - директории верхнего уровня, сильнее всего выросшие с прошлого снимка
+	Growth []DirGrowth `json:"growth,omitempty"`
+}
+
+// DirGrowth - прирост размера директории верхнего уровня ("." - файлы в корне)
+type DirGrowth struct {
+	Dir   string `json:"dir"`
+	Bytes int64  `json:"bytes"`
+}
+
+// String - краткое описание изменений для логов и уведомлений
+func (c Changes) String() string {
+	text := fmt.Sprintf("%d added, %d modified, %d removed (%s changed)", c.Added, c.Modified, c.Removed, utils.FormatSize(c.ChangedBytes))
+	if len(c.Growth) > 0 {
+		growth := make([]string, len(c.Growth))
+		for i, dir := range c.Growth {
+			growth[i] = fmt.Sprintf("%s +%s", dir.Dir, utils.FormatSize(dir.Bytes))
+		}
+		text += "; top growth: " + strings.Join(growth, ", ")
+	}
+	return text
+}
+
+func New(backup string) *Snapshot {
+	return &Snapshot{
+		Backup:    backup,
+		CreatedAt: time.Now(),
+		Files:     make(map[string]FileMeta),
+	}
+}
+
+// Record добавляет файл в снимок; директории не сохраняются
+func (s *Snapshot) Record(relPath string, info os.FileInfo) {
+	if info.IsDir() {
+		return
+	}
+
+	s.Files[filepath.ToSlash(relPath)] = FileMeta{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}
+}
+
+// Changed проверяет, изменился ли файл с момента снимка (новый файл тоже считается измененным)
+func (s *Snapshot) Changed(relPath string, info os.FileInfo) bool {
+	if s == nil {
+		return true
+	}
+
+	meta, ok := s.Files[filepath.ToSlash(relPath)]
+	if !ok {
+		return true
+	}
+
+	return meta.Size != info.Size() || !meta.ModTime.Equal(info.ModTime()) || meta.Mode != info.Mode()
+}
+
+// TotalSize возвращает суммарный размер файлов снимка
+func (s *Snapshot) TotalSize() int64 {
+	var total int64
+	for _, meta := range s.Files {
+		total += meta.Size
+	}
+	return total
+}
+
+// Diff сравнивает снимок с предыдущим (previous может быть nil)
+func (s *Snapshot) Diff(previous *Snapshot) Changes {
+	var changes Changes
+	growth := make(map[string]int64)
+
+	for path, meta := range s.Files {
+		old, ok := files(previous)[path]
+		growth[topDir(path)] += meta.Size - old.Size
+		switch {
+		case !ok:
+			changes.Added++
+			changes.ChangedBytes += meta.Size
+		case old.Size != meta.Size || !old.ModTime.Equal(meta.ModTime) || old.Mode != meta.Mode:
+			changes.Modified++
+			changes.ChangedBytes += meta.Size
+		}
+	}
+
+	for path, old := range files(previous) {
+		if _, ok := s.Files[path]; !ok {
+			changes.Removed++
+			growth[topDir(path)] -= old.Size
+		}
+	}
+
+	for dir, bytes := range growth {
+		if bytes > 0 {
+			changes.Growth = append(changes.Growth, DirGrowth{Dir: dir, Bytes: bytes})
+		}
+	}
+	sort.Slice(changes.Growth, func(i, j int) bool {
+		if changes.Growth[i].Bytes != changes.Growth[j].Bytes {
+			return changes.Growth[i].Bytes > changes.Growth[j].Bytes
+		}
+		return changes.Growth[i].Dir < changes.Growth[j].Dir
+	})
+	if len(changes.Growth) > topGrowthDirs {
+		changes.Growth = changes.Growth[:topGrowthDirs]
+	}
+
+	return changes
+}
+
+// topDir возвращает директорию верхнего уровня пути из снимка
+func topDir(path string) string {
+	if dir, _, ok := strings.Cut(path, "/"); ok {
+		return dir
+	}
+	return "."
+}
+
+// files возвращает файлы снимка; для nil - пустой набор
+func files(s *Snapshot) map[string]FileMeta {
+	if s == nil {
+		return nil
+	}
+	return s.Files
+}
+
+// Load читает снимок; отсутствующий файл означает, что предыдущего запуска не было (nil)
+func Load(path string) (*Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open metadata snapshot: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata snapshot: %w", err)
+	}
+	defer reader.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// Save атомарно записывает снимок (gzip JSON)
+func (s *Snapshot) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	err := utils.WriteAtomic(path, 0644, func(w io.Writer) error {
+		writer := gzip.NewWriter(w)
+		if err := json.NewEncoder(writer).Encode(s); err != nil {
+			return err
+		}
+		return writer.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write metadata snapshot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"goback/utils"
+)
+
+// Digest - периодическая сводка по бэкапам (обычно за неделю)
+type Digest struct {
+	Since   time.Time     `json:"since"`
+	Until   time.Time     `json:"until"`
+	Backups []DigestEntry `json:"backups"`
+}
+
+// DigestEntry - сводка по одному бэкапу за период
+type DigestEntry struct {
+	Backup    string `json:"backup"`
+	Runs      int    `json:"runs"`
+	Succeeded int    `json:"succeeded"`
+	// SuccessRate - доля успешных запусков в процентах (0 без запусков)
+	SuccessRate float64 `json:"success_rate"`
+	// Bytes - суммарный размер архивов, созданных за период
+	Bytes int64 `json:"bytes"`
+	// LastSize - размер последнего архива за период
+	LastSize int64 `json:"last_size"`
+	// Growth - изменение размера архива от первого к последнему за период, в процентах
+	Growth float64 `json:"growth"`
+	// Paused - причина паузы (enabled: false, paused_until); такой бэкап без
+	// запусков не считается проблемой
+	Paused string `json:"paused,omitempty"`
+	// LastError - ошибка последнего неудачного запуска
+	LastError string `json:"last_error,omitempty"`
+	// Upcoming - архивы, которые retention удалит в следующий такой же период
+	Upcoming []string `json:"upcoming_deletions,omitempty"`
+}
+
+// Success сообщает, что каждый бэкап запускался и все запуски успешны
+func (d Digest) Success() bool {
+	for _, entry := range d.Backups {
+		if !entry.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// OK сообщает, что бэкап запускался (или приостановлен) и все запуски успешны
+func (entry DigestEntry) OK() bool {
+	if entry.Runs == 0 {
+		return entry.Paused != ""
+	}
+	return entry.Succeeded == entry.Runs
+}
+
+// Message - краткий итог сводки для человека
+func (d Digest) Message() string {
+	runs, succeeded, idle := 0, 0, 0
+	for _, entry := range d.Backups {
+		runs += entry.Runs
+		succeeded += entry.Succeeded
+		if entry.Runs == 0 && entry.Paused == "" {
+			idle++
+		}
+	}
+
+	msg := fmt.Sprintf("digest %s - %s: %d backup(s), %d of %d run(s) succeeded",
+		d.Since.Format("2006-01-02"), d.Until.Format("2006-01-02"), len(d.Backups), succeeded, runs)
+	if idle > 0 {
+		msg += fmt.Sprintf(", %d without runs", idle)
+	}
+	return msg
+}
+
+// Summary - итог бэкапа за период без имени: запуски, объем, размер и рост
+func (entry DigestEntry) Summary() string {
+	var summary string
+	switch {
+	case entry.Runs == 0 && entry.Paused != "":
+		return entry.Paused
+	case entry.Runs == 0:
+		return "no runs"
+	case entry.Succeeded == 0:
+		summary = fmt.Sprintf("0/%d run(s) succeeded", entry.Runs)
+	default:
+		summary = fmt.Sprintf("%d/%d run(s) succeeded (%.0f%%), %s written, last archive %s (%+.1f%%)",
+			entry.Succeeded, entry.Runs, entry.SuccessRate, utils.FormatSize(entry.Bytes), utils.FormatSize(entry.LastSize), entry.Growth)
+	}
+
+	if entry.Paused != "" {
+		summary += ", now " + entry.Paused
+	}
+	return summary
+}
+
+func digestReport(digest Digest) Report {
+	report := Report{
+		Event:    "digest",
+		Host:     hostname(),
+		Name:     "digest",
+		Status:   status(digest.Success()),
+		Duration: digest.Until.Sub(digest.Since).Seconds(),
+		Message:  digest.Message(),
+		Digest:   &digest,
+	}
+	for _, entry := range digest.Backups {
+		report.Size += entry.Bytes
+	}
+	return report
+}
@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// События ping healthcheck: начало бэкапа, успех, сбой и запись в журнал без смены статуса
+const (
+	PingStart   = "start"
+	PingSuccess = "success"
+	PingFail    = "fail"
+	PingLog     = "log"
+)
+
+// maxPingBody - сколько байт сообщения уходит с ping
+const maxPingBody = 10 * 1024
+
+// Healthcheck пингует URL dead man's switch (healthchecks.io и совместимые сервисы):
+// если ping об успехе не пришел вовремя, сервис сам поднимает тревогу
+type Healthcheck struct {
+	pingURL *url.URL
+}
+
+// NewHealthcheck создает healthcheck для ping URL (https://hc-ping.com/<uuid>)
+func NewHealthcheck(pingURL string) (*Healthcheck, error) {
+	u, err := url.Parse(pingURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid healthcheck URL: %s", pingURL)
+	}
+	return &Healthcheck{pingURL: u}, nil
+}
+
+// Ping отправляет событие по соглашению healthchecks.io: <url>/start, <url> (успех),
+// <url>/fail и <url>/log; message уходит телом запроса и видно в журнале проверки
+func (h *Healthcheck) Ping(event, message string) error {
+	u := *h.pingURL
+	if event != PingSuccess {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + event
+	}
+	if len(message) > maxPingBody {
+		message = message[:maxPingBody]
+	}
+
+	resp, err := client.Post(u.String(), "text/plain; charset=utf-8", strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("failed to ping healthcheck: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("healthcheck ping returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// KumaNotifier отправляет результат в push-монитор Uptime Kuma
+// (https://kuma.example.com/api/push/<token>)
+type KumaNotifier struct {
+	pushURL *url.URL
+	// template задает msg вместо итога по умолчанию
+	template *template.Template
+}
+
+// NewKumaNotifier создает notifier для push URL; tmpl - шаблон msg над Report
+func NewKumaNotifier(pushURL, tmpl string) (*KumaNotifier, error) {
+	u, err := url.Parse(pushURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Uptime Kuma push URL: %s", pushURL)
+	}
+
+	k := &KumaNotifier{pushURL: u}
+	if k.template, err = parseTemplate("uptime-kuma", tmpl); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *KumaNotifier) Notify(result Result) error {
+	msg, err := k.message(resultReport(result))
+	if err != nil {
+		return err
+	}
+	return k.push(result.Success, msg, result.Duration)
+}
+
+// NotifyRun отправляет один push за весь запуск: down, если хоть один бэкап не выполнен
+func (k *KumaNotifier) NotifyRun(summary Summary) error {
+	msg, err := k.message(runReport(summary))
+	if err != nil {
+		return err
+	}
+	return k.push(summary.Success(), msg, summary.Duration)
+}
+
+// NotifyDigest отправляет сводку push-ом: down, если были неудачные запуски или бэкапы без запусков
+func (k *KumaNotifier) NotifyDigest(digest Digest) error {
+	msg, err := k.message(digestReport(digest))
+	if err != nil {
+		return err
+	}
+	return k.push(digest.Success(), msg, 0)
+}
+
+// NotifyReload отправляет push down: демон работает со старой конфигурацией
+func (k *KumaNotifier) NotifyReload(reload Reload) error {
+	msg, err := k.message(reloadReport(reload))
+	if err != nil {
+		return err
+	}
+	return k.push(false, msg, 0)
+}
+
+// message возвращает msg для push: итог отчета или результат шаблона
+func (k *KumaNotifier) message(report Report) (string, error) {
+	if k.template == nil {
+		return report.Message, nil
+	}
+	msg, err := render(k.template, report)
+	return strings.TrimSpace(msg), err
+}
+
+// push вызывает push URL с параметрами status (up/down), msg и ping (длительность в мс).
+// Параметры из скопированного из Kuma URL (?status=up&msg=OK&ping=) заменяются
+func (k *KumaNotifier) push(success bool, msg string, duration time.Duration) error {
+	status := "up"
+	if !success {
+		status = "down"
+	}
+
+	u := *k.pushURL
+	query := u.Query()
+	query.Set("status", status)
+	query.Set("msg", msg)
+	query.Set("ping", strconv.FormatInt(duration.Milliseconds(), 10))
+	u.RawQuery = query.Encode()
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to push to Uptime Kuma: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Kuma отвечает {"ok":true} или {"ok":false,"msg":"..."} (например, для неактивного монитора)
+	var reply struct {
+		OK  bool   `json:"ok"`
+		Msg string `json:"msg"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(body, &reply); err == nil && !reply.OK && reply.Msg != "" {
+		return fmt.Errorf("push to Uptime Kuma rejected: %s", reply.Msg)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("push to Uptime Kuma returned %s", resp.Status)
+	}
+
+	return nil
+}
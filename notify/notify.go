@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"goback/metadata"
+)
+
+// requestTimeout ограничивает время отправки одного уведомления
+const requestTimeout = 15 * time.Second
+
+// Result - итог одного бэкапа, о котором сообщают уведомления
+type Result struct {
+	Backup string
+	// RunID и JobID - идентификаторы запуска и бэкапа в нем
+	RunID   string
+	JobID   string
+	Success bool
+	// Message - итог для человека: имя архива при успехе или текст ошибки
+	Message  string
+	Archive  string
+	Size     int64
+	Duration time.Duration
+	Error    string
+	// Removed - архивы, удаленные retention после этого бэкапа
+	Removed []string
+	// Changes - что изменилось в источнике с прошлого бэкапа (при metadata_cache)
+	Changes *metadata.Changes
+	// Deliveries - итог доставки архива в каждый destination бэкапа
+	Deliveries []Delivery
+}
+
+// Delivery - итог доставки архива в один destination
+type Delivery struct {
+	Destination string `json:"destination"`
+	// Status - uploaded, deferred (загрузка отложена в spool) или failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Summary - итог всего запуска
+type Summary struct {
+	RunID   string
+	Results []Result
+	// Skipped - бэкапы, не запущенные из-за окна бэкапа
+	Skipped []string
+	// Paused - бэкапы, приостановленные в конфигурации (enabled: false, paused_until);
+	// на успех запуска не влияют
+	Paused   []string
+	Duration time.Duration
+}
+
+// Success сообщает, что все бэкапы запуска выполнены
+func (s Summary) Success() bool {
+	if len(s.Skipped) > 0 {
+		return false
+	}
+	for _, result := range s.Results {
+		if !result.Success {
+			return false
+		}
+	}
+	return true
+}
+
+// Message - краткий итог запуска для человека
+func (s Summary) Message() string {
+	failed := 0
+	for _, result := range s.Results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	msg := fmt.Sprintf("%d backup(s) succeeded, %d failed", len(s.Results)-failed, failed)
+	if len(s.Skipped) > 0 {
+		msg += fmt.Sprintf(", %d skipped", len(s.Skipped))
+	}
+	if len(s.Paused) > 0 {
+		msg += fmt.Sprintf(", %d paused", len(s.Paused))
+	}
+	return msg
+}
+
+// Reload - отклоненная перезагрузка конфигурации демона
+type Reload struct {
+	// Config - путь к файлу конфигурации
+	Config string
+	Error  string
+}
+
+// Message - краткий итог для человека
+func (r Reload) Message() string {
+	return fmt.Sprintf("config reload rejected, keeping the running configuration: %s", r.Error)
+}
+
+// Notifier отправляет итоги во внешний сервис
+type Notifier interface {
+	// Notify сообщает итог одного бэкапа
+	Notify(result Result) error
+	// NotifyRun сообщает итог всего запуска
+	NotifyRun(summary Summary) error
+	// NotifyDigest отправляет периодическую сводку
+	NotifyDigest(digest Digest) error
+	// NotifyReload сообщает, что новая версия конфигурации не принята демоном
+	NotifyReload(reload Reload) error
+}
+
+// Options - параметры уведомления (набор зависит от типа)
+type Options struct {
+	URL string
+	// Method и Headers используются webhook
+	Method  string
+	Headers map[string]string
+	// Template - шаблон тела уведомления над Report (webhook - тело запроса,
+	// telegram - текст, email - текст письма, uptime-kuma - msg)
+	Template string
+	// BotToken и ChatID используются telegram (URL - адрес Bot API)
+	BotToken string
+	ChatID   string
+	// SMTP используется email
+	SMTP SMTPOptions
+}
+
+// New создает notifier по типу
+func New(notifierType string, opts Options) (Notifier, error) {
+	switch strings.ToLower(notifierType) {
+	case "uptime-kuma", "kuma":
+		return NewKumaNotifier(opts.URL, opts.Template)
+	case "webhook":
+		return NewWebhookNotifier(opts.URL, opts.Method, opts.Headers, opts.Template)
+	case "telegram":
+		return NewTelegramNotifier(opts.URL, opts.BotToken, opts.ChatID, opts.Template)
+	case "email", "smtp":
+		return NewSMTPNotifier(opts.SMTP, opts.Template)
+	default:
+		return nil, fmt.Errorf("unsupported notification type: %s", notifierType)
+	}
+}
+
+var client = &http.Client{Timeout: requestTimeout}
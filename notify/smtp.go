@@ -0,0 +1,239 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"goback/utils"
+)
+
+// DefaultSMTPSubject - тема письма по умолчанию
+const DefaultSMTPSubject = "[goback] {{.Host}}: {{.Status}} - {{.Message}}"
+
+// SMTPOptions - параметры отправки отчета по почте
+type SMTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// Subject - шаблон text/template темы над Report ({{.Host}}, {{.Status}}, {{.Message}}, ...)
+	Subject string
+	// TLS - starttls (по умолчанию), tls (SMTPS) или none
+	TLS                string
+	InsecureSkipVerify bool
+}
+
+// SMTPNotifier отправляет отчет о запуске письмом
+type SMTPNotifier struct {
+	opts    SMTPOptions
+	subject *template.Template
+	// body задает текст письма вместо отчета по умолчанию
+	body *template.Template
+}
+
+// NewSMTPNotifier создает notifier; tmpl - шаблон текста письма над Report
+func NewSMTPNotifier(opts SMTPOptions, tmpl string) (*SMTPNotifier, error) {
+	if opts.Host == "" {
+		return nil, fmt.Errorf("smtp notification requires smtp.host")
+	}
+	if opts.From == "" || len(opts.To) == 0 {
+		return nil, fmt.Errorf("smtp notification requires smtp.from and smtp.to")
+	}
+
+	switch opts.TLS {
+	case "", "starttls":
+		opts.TLS = "starttls"
+		if opts.Port == 0 {
+			opts.Port = 587
+		}
+	case "tls":
+		if opts.Port == 0 {
+			opts.Port = 465
+		}
+	case "none":
+		if opts.Port == 0 {
+			opts.Port = 25
+		}
+	default:
+		return nil, fmt.Errorf("smtp.tls must be starttls, tls or none")
+	}
+
+	if opts.Subject == "" {
+		opts.Subject = DefaultSMTPSubject
+	}
+	subject, err := parseTemplate("smtp.subject", opts.Subject)
+	if err != nil {
+		return nil, err
+	}
+	body, err := parseTemplate("email", tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPNotifier{opts: opts, subject: subject, body: body}, nil
+}
+
+func (s *SMTPNotifier) Notify(result Result) error {
+	var body strings.Builder
+	writeTextResult(&body, result)
+	return s.send(resultReport(result), body.String())
+}
+
+// NotifyRun отправляет отчет о запуске: статус, размер, длительность и ошибка каждого бэкапа
+func (s *SMTPNotifier) NotifyRun(summary Summary) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s in %s\n\n", summary.Message(), summary.Duration.Round(time.Second))
+
+	for _, result := range summary.Results {
+		writeTextResult(&body, result)
+	}
+	if len(summary.Skipped) > 0 {
+		fmt.Fprintf(&body, "SKIPPED  %s (backup window exceeded)\n", strings.Join(summary.Skipped, ", "))
+	}
+	if len(summary.Paused) > 0 {
+		fmt.Fprintf(&body, "PAUSED   %s\n", strings.Join(summary.Paused, ", "))
+	}
+
+	return s.send(runReport(summary), body.String())
+}
+
+// NotifyDigest отправляет сводку письмом
+func (s *SMTPNotifier) NotifyDigest(digest Digest) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "%s\n\n", digest.Message())
+	for _, entry := range digest.Backups {
+		fmt.Fprintf(&body, "%s: %s\n", entry.Backup, entry.Summary())
+		if entry.LastError != "" {
+			fmt.Fprintf(&body, "         last error: %s\n", entry.LastError)
+		}
+		if len(entry.Upcoming) > 0 {
+			fmt.Fprintf(&body, "         retention will remove: %s\n", strings.Join(entry.Upcoming, ", "))
+		}
+	}
+	return s.send(digestReport(digest), body.String())
+}
+
+// NotifyReload сообщает письмом об отклоненной конфигурации
+func (s *SMTPNotifier) NotifyReload(reload Reload) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "Config reload rejected: %s\n\n", reload.Config)
+	fmt.Fprintf(&body, "%s\n\n", reload.Error)
+	body.WriteString("The daemon keeps running with the previous configuration.\n")
+	return s.send(reloadReport(reload), body.String())
+}
+
+// writeTextResult добавляет строку отчета об одном бэкапе
+func writeTextResult(body *strings.Builder, result Result) {
+	if !result.Success {
+		fmt.Fprintf(body, "FAILED   %s after %s: %s\n", result.Backup, result.Duration.Round(time.Second), result.Error)
+		return
+	}
+
+	fmt.Fprintf(body, "OK       %s: %s, %s in %s\n", result.Backup, result.Archive, utils.FormatSize(result.Size), result.Duration.Round(time.Second))
+	if len(result.Removed) > 0 {
+		fmt.Fprintf(body, "         retention removed: %s\n", strings.Join(result.Removed, ", "))
+	}
+	if result.Changes != nil {
+		fmt.Fprintf(body, "         changes: %s\n", result.Changes)
+	}
+}
+
+// send отправляет письмо; body - отчет по умолчанию, если не задан шаблон
+func (s *SMTPNotifier) send(report Report, body string) error {
+	subject, err := render(s.subject, report)
+	if err != nil {
+		return err
+	}
+	if s.body != nil {
+		if body, err = render(s.body, report); err != nil {
+			return err
+		}
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.opts.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.opts.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	if err := s.deliver(msg.Bytes()); err != nil {
+		return fmt.Errorf("failed to send email via %s: %w", s.opts.Host, err)
+	}
+	return nil
+}
+
+// deliver подключается к серверу с учетом режима TLS и отправляет письмо
+func (s *SMTPNotifier) deliver(msg []byte) error {
+	addr := net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	tlsConfig := &tls.Config{ServerName: s.opts.Host, InsecureSkipVerify: s.opts.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: requestTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.opts.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	client, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.opts.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("server does not support STARTTLS (set smtp.tls: none to send without encryption)")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(s.opts.From); err != nil {
+		return err
+	}
+	for _, to := range s.opts.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
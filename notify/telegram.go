@@ -0,0 +1,203 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"goback/utils"
+)
+
+// DefaultTelegramAPI - адрес Telegram Bot API (для собственного сервера Bot API задается url)
+const DefaultTelegramAPI = "https://api.telegram.org"
+
+// telegramMessageLimit - максимальная длина текста сообщения Telegram
+const telegramMessageLimit = 4096
+
+// TelegramNotifier отправляет итоги сообщением от бота в чат
+type TelegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+	// template задает текст сообщения (HTML) вместо сводки по умолчанию
+	template *template.Template
+}
+
+// NewTelegramNotifier создает notifier для бота token и чата chatID
+// (числовой id или @channel); apiURL по умолчанию DefaultTelegramAPI,
+// tmpl - шаблон текста над Report
+func NewTelegramNotifier(apiURL, token, chatID, tmpl string) (*TelegramNotifier, error) {
+	if token == "" {
+		return nil, fmt.Errorf("telegram notification requires bot_token")
+	}
+	if chatID == "" {
+		return nil, fmt.Errorf("telegram notification requires chat_id")
+	}
+
+	if apiURL == "" {
+		apiURL = DefaultTelegramAPI
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Telegram Bot API URL: %s", apiURL)
+	}
+
+	t := &TelegramNotifier{apiURL: strings.TrimSuffix(apiURL, "/"), token: token, chatID: chatID}
+	if t.template, err = parseTemplate("telegram", tmpl); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *TelegramNotifier) Notify(result Result) error {
+	if t.template != nil {
+		return t.sendTemplate(resultReport(result))
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s <b>goback: %s</b>\n", statusIcon(result.Success), html.EscapeString(result.Backup))
+	writeResult(&text, result)
+	return t.send(text.String())
+}
+
+// NotifyRun отправляет сводку запуска: статус, размер и удаленные retention архивы каждого бэкапа
+func (t *TelegramNotifier) NotifyRun(summary Summary) error {
+	if t.template != nil {
+		return t.sendTemplate(runReport(summary))
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s <b>goback: %s</b>\n", statusIcon(summary.Success()), html.EscapeString(summary.Message()))
+	fmt.Fprintf(&text, "Duration: %s\n", summary.Duration.Round(time.Second))
+
+	for _, result := range summary.Results {
+		fmt.Fprintf(&text, "\n%s <b>%s</b>\n", statusIcon(result.Success), html.EscapeString(result.Backup))
+		writeResult(&text, result)
+	}
+
+	if len(summary.Skipped) > 0 {
+		fmt.Fprintf(&text, "\n⏭ Skipped (backup window exceeded): %s\n", html.EscapeString(strings.Join(summary.Skipped, ", ")))
+	}
+	if len(summary.Paused) > 0 {
+		fmt.Fprintf(&text, "\n⏸ Paused: %s\n", html.EscapeString(strings.Join(summary.Paused, ", ")))
+	}
+
+	return t.send(text.String())
+}
+
+// NotifyDigest отправляет сводку: итог каждого бэкапа и предстоящие удаления retention
+func (t *TelegramNotifier) NotifyDigest(digest Digest) error {
+	if t.template != nil {
+		return t.sendTemplate(digestReport(digest))
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s <b>goback %s</b>\n", statusIcon(digest.Success()), html.EscapeString(digest.Message()))
+	for _, entry := range digest.Backups {
+		fmt.Fprintf(&text, "\n%s <b>%s</b>: %s\n", statusIcon(entry.OK()), html.EscapeString(entry.Backup), entry.Summary())
+		if entry.LastError != "" {
+			fmt.Fprintf(&text, "Last error: %s\n", html.EscapeString(entry.LastError))
+		}
+		if len(entry.Upcoming) > 0 {
+			fmt.Fprintf(&text, "Retention will remove: %s\n", html.EscapeString(strings.Join(entry.Upcoming, ", ")))
+		}
+	}
+	return t.send(text.String())
+}
+
+// NotifyReload сообщает об отклоненной конфигурации
+func (t *TelegramNotifier) NotifyReload(reload Reload) error {
+	if t.template != nil {
+		return t.sendTemplate(reloadReport(reload))
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s <b>goback config reload rejected</b>\n", statusIcon(false))
+	fmt.Fprintf(&text, "Config: %s\n", html.EscapeString(reload.Config))
+	fmt.Fprintf(&text, "Error: %s\n", html.EscapeString(reload.Error))
+	text.WriteString("The daemon keeps running with the previous configuration.")
+	return t.send(text.String())
+}
+
+func (t *TelegramNotifier) sendTemplate(report Report) error {
+	text, err := render(t.template, report)
+	if err != nil {
+		return err
+	}
+	return t.send(text)
+}
+
+// writeResult добавляет строки итога одного бэкапа
+func writeResult(text *strings.Builder, result Result) {
+	if !result.Success {
+		fmt.Fprintf(text, "Error: %s\n", html.EscapeString(result.Error))
+		return
+	}
+
+	fmt.Fprintf(text, "%s, %s in %s\n", html.EscapeString(result.Archive), utils.FormatSize(result.Size), result.Duration.Round(time.Second))
+	if len(result.Removed) > 0 {
+		fmt.Fprintf(text, "Retention removed: %s\n", html.EscapeString(strings.Join(result.Removed, ", ")))
+	}
+	if result.Changes != nil {
+		fmt.Fprintf(text, "Changes: %s\n", html.EscapeString(result.Changes.String()))
+	}
+}
+
+func statusIcon(success bool) string {
+	if success {
+		return "✅"
+	}
+	return "❌"
+}
+
+// send вызывает sendMessage; слишком длинная сводка обрезается до лимита Telegram
+func (t *TelegramNotifier) send(text string) error {
+	if len(text) > telegramMessageLimit {
+		// Обрезаем по границе строки, чтобы не разорвать HTML-разметку
+		text = text[:telegramMessageLimit-4]
+		if i := strings.LastIndex(text, "\n"); i > 0 {
+			text = text[:i]
+		}
+		text += "\n…"
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chat_id":                  t.chatID,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode Telegram message: %w", err)
+	}
+
+	resp, err := client.Post(t.apiURL+"/bot"+t.token+"/sendMessage", "application/json", bytes.NewReader(body))
+	if err != nil {
+		// Текст ошибки net/http содержит URL с токеном бота
+		return fmt.Errorf("failed to send Telegram message: %s", strings.ReplaceAll(err.Error(), t.token, "***"))
+	}
+	defer resp.Body.Close()
+
+	// Bot API отвечает {"ok":true,...} или {"ok":false,"description":"..."}
+	var reply struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err := json.Unmarshal(data, &reply); err == nil && !reply.OK && reply.Description != "" {
+		return fmt.Errorf("telegram rejected message: %s", reply.Description)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"goback/metadata"
+	"goback/utils"
+)
+
+// Report - модель данных отчета: тело webhook по умолчанию и данные для template
+// любого канала ({{.Name}}, {{.Status}}, {{range .Backups}} и т.д.)
+type Report struct {
+	// Event - backup (итог одного бэкапа), run (итог запуска), digest (сводка)
+	// или reload (отклоненная перезагрузка конфигурации)
+	Event string `json:"event"`
+	// RunID - идентификатор запуска, JobID - бэкапа в нем (только для события backup)
+	RunID    string  `json:"run_id,omitempty"`
+	JobID    string  `json:"job_id,omitempty"`
+	Host     string  `json:"host"`
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Archive  string  `json:"archive,omitempty"`
+	Size     int64   `json:"size"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+	Message  string  `json:"message"`
+	// Removed - архивы, удаленные retention
+	Removed []string `json:"removed,omitempty"`
+	// Changes - изменения источника с прошлого бэкапа
+	Changes *metadata.Changes `json:"changes,omitempty"`
+	// Destinations - итог доставки архива в каждый destination
+	Destinations []Delivery `json:"destinations,omitempty"`
+	// Backups, Skipped и Paused заполняются только для события run
+	Backups []Report `json:"backups,omitempty"`
+	Skipped []string `json:"skipped,omitempty"`
+	Paused  []string `json:"paused,omitempty"`
+	// Digest заполняется только для события digest
+	Digest *Digest `json:"digest,omitempty"`
+}
+
+// Success сообщает, что бэкап или весь запуск выполнен
+func (r Report) Success() bool {
+	return r.Status == "success"
+}
+
+func resultReport(result Result) Report {
+	return Report{
+		Event:    "backup",
+		RunID:    result.RunID,
+		JobID:    result.JobID,
+		Host:     hostname(),
+		Name:     result.Backup,
+		Status:   status(result.Success),
+		Archive:  result.Archive,
+		Size:     result.Size,
+		Duration: result.Duration.Seconds(),
+		Error:    result.Error,
+		Message:  result.Message,
+		Removed:  result.Removed,
+		Changes:  result.Changes,
+
+		Destinations: result.Deliveries,
+	}
+}
+
+func runReport(summary Summary) Report {
+	report := Report{
+		Event:    "run",
+		RunID:    summary.RunID,
+		Host:     hostname(),
+		Name:     "run",
+		Status:   status(summary.Success()),
+		Duration: summary.Duration.Seconds(),
+		Message:  summary.Message(),
+		Skipped:  summary.Skipped,
+		Paused:   summary.Paused,
+	}
+	for _, result := range summary.Results {
+		report.Size += result.Size
+		report.Backups = append(report.Backups, resultReport(result))
+	}
+	return report
+}
+
+func reloadReport(reload Reload) Report {
+	return Report{
+		Event:   "reload",
+		Host:    hostname(),
+		Name:    reload.Config,
+		Status:  status(false),
+		Error:   reload.Error,
+		Message: reload.Message(),
+	}
+}
+
+func hostname() string {
+	host, _ := os.Hostname()
+	return host
+}
+
+func status(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+// templateFuncs - функции, доступные в шаблонах уведомлений помимо встроенных (html, js, ...)
+var templateFuncs = template.FuncMap{
+	// json экранирует значение для вставки в JSON-шаблон: {{json .Error}}
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// size выводит размер в человекочитаемом виде: {{size .Size}}
+	"size": utils.FormatSize,
+	// duration выводит длительность в секундах как 1m30s: {{duration .Duration}}
+	"duration": func(seconds float64) string {
+		return (time.Duration(seconds * float64(time.Second))).Round(time.Second).String()
+	},
+	"join": strings.Join,
+}
+
+// parseTemplate разбирает шаблон тела уведомления; пустой текст - шаблона нет
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// render выполняет шаблон над отчетом
+func render(tmpl *template.Template, report Report) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
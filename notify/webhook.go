@@ -0,0 +1,91 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// WebhookNotifier отправляет итоги HTTP-запросом с JSON Report (или телом по шаблону)
+type WebhookNotifier struct {
+	url      string
+	method   string
+	headers  map[string]string
+	template *template.Template
+}
+
+// NewWebhookNotifier создает webhook; method по умолчанию POST,
+// tmpl - шаблон text/template тела запроса над Report (пустой - JSON Report)
+func NewWebhookNotifier(rawURL, method string, headers map[string]string, tmpl string) (*WebhookNotifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid webhook URL: %s", rawURL)
+	}
+
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	w := &WebhookNotifier{url: rawURL, method: strings.ToUpper(method), headers: headers}
+	if w.template, err = parseTemplate("webhook", tmpl); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *WebhookNotifier) Notify(result Result) error {
+	return w.send(resultReport(result))
+}
+
+func (w *WebhookNotifier) NotifyRun(summary Summary) error {
+	return w.send(runReport(summary))
+}
+
+func (w *WebhookNotifier) NotifyDigest(digest Digest) error {
+	return w.send(digestReport(digest))
+}
+
+func (w *WebhookNotifier) NotifyReload(reload Reload) error {
+	return w.send(reloadReport(reload))
+}
+
+func (w *WebhookNotifier) send(report Report) error {
+	var body bytes.Buffer
+	if w.template != nil {
+		text, err := render(w.template, report)
+		if err != nil {
+			return err
+		}
+		body.WriteString(text)
+	} else if err := json.NewEncoder(&body).Encode(report); err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequest(w.method, w.url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"goback/utils"
+)
+
+// Режимы вывода хода бэкапа (global.progress)
+const (
+	// ModeAuto - обновляемая строка на терминале, иначе строки лога (по умолчанию)
+	ModeAuto = "auto"
+	// ModeLog - всегда строки лога раз в interval
+	ModeLog = "log"
+	// ModeOff - ход не выводится
+	ModeOff = "off"
+)
+
+// terminalInterval - как часто обновляется строка хода на терминале
+const terminalInterval = time.Second
+
+// Reporter считает обработанные файлы, прочитанные байты источника и записанные
+// байты архива и выводит ход бэкапа: на терминале - одной обновляемой строкой,
+// иначе - строкой лога раз в interval. Методы nil-Reporter ничего не делают,
+// поэтому код сжатия вызывает их без проверок
+type Reporter struct {
+	label    string
+	total    int64
+	terminal bool
+	interval time.Duration
+	start    time.Time
+
+	files   atomic.Int64
+	read    atomic.Int64
+	written atomic.Int64
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Start начинает вывод хода бэкапа label. total - ожидаемый объем источника в
+// байтах для процента и ETA (0 - неизвестен). terminal - вывод идет на терминал,
+// где строка обновляется на месте. Для ModeOff возвращает nil
+func Start(label, mode string, total int64, interval time.Duration, terminal bool) *Reporter {
+	switch mode {
+	case ModeOff:
+		return nil
+	case ModeLog:
+		terminal = false
+	}
+	if terminal {
+		interval = terminalInterval
+	}
+	if interval <= 0 {
+		return nil
+	}
+
+	r := &Reporter{
+		label:    label,
+		total:    total,
+		terminal: terminal,
+		interval: interval,
+		start:    time.Now(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// IsTerminal сообщает, что file - терминал
+func IsTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// AddFile учитывает файл источника
+func (r *Reporter) AddFile() {
+	if r != nil {
+		r.files.Add(1)
+	}
+}
+
+// Reader считает байты, прочитанные из источника
+func (r *Reporter) Reader(reader io.Reader) io.Reader {
+	if r == nil {
+		return reader
+	}
+	return &countingReader{reader: reader, n: &r.read}
+}
+
+// WriteCloser считает байты, записанные в архив
+func (r *Reporter) WriteCloser(writer io.WriteCloser) io.WriteCloser {
+	if r == nil {
+		return writer
+	}
+	return &countingWriter{WriteCloser: writer, n: &r.written}
+}
+
+// Stop прекращает вывод; на терминале строка хода стирается
+func (r *Reporter) Stop() {
+	if r == nil {
+		return
+	}
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		<-r.done
+		if r.terminal {
+			fmt.Print("\r\033[K")
+		}
+	})
+}
+
+func (r *Reporter) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if r.terminal {
+				fmt.Printf("\r\033[K%s", r.line())
+			} else {
+				fmt.Printf("Progress: %s\n", r.line())
+			}
+		}
+	}
+}
+
+// line описывает текущий ход: файлы, байты, скорость и, если известен объем, процент и ETA
+func (r *Reporter) line() string {
+	elapsed := time.Since(r.start)
+	read := r.read.Load()
+
+	parts := []string{r.label}
+	if files := r.files.Load(); files > 0 {
+		parts = append(parts, fmt.Sprintf("%d file(s)", files))
+	}
+	if r.total > 0 {
+		parts = append(parts, fmt.Sprintf("%s of ~%s read", utils.FormatSize(read), utils.FormatSize(r.total)))
+	} else {
+		parts = append(parts, fmt.Sprintf("%s read", utils.FormatSize(read)))
+	}
+	parts = append(parts, fmt.Sprintf("%s written", utils.FormatSize(r.written.Load())))
+
+	var rate float64
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		rate = float64(read) / seconds
+	}
+	parts = append(parts, fmt.Sprintf("%s/s", utils.FormatSize(int64(rate))))
+
+	// Объем берется из прошлого запуска: источник мог вырасти, тогда ETA неизвестен
+	if r.total > 0 && read < r.total && rate > 0 {
+		eta := time.Duration(float64(r.total-read) / rate * float64(time.Second))
+		parts = append(parts, fmt.Sprintf("%d%%", read*100/r.total), "ETA "+eta.Round(time.Second).String())
+	}
+	parts = append(parts, "elapsed "+elapsed.Round(time.Second).String())
+
+	return strings.Join(parts, ", ")
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	io.WriteCloser
+	n *atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.WriteCloser.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
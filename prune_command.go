@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// pruneCommand: goback prune [--dry-run] [-b name] - применяет retention к
+// существующим архивам без создания новых
+func pruneCommand(args []string) int {
+	fs := flag.NewFlagSet("prune", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	dryRun := fs.Bool("dry-run", false, "Show which archives would be removed without removing them")
+	var backupNames flagArray
+	fs.Var(&backupNames, "backup", "Name of backup to prune (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to prune (short)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	backupNames = append(backupNames, positional...)
+
+	cfg := loadConfigOrExit(*configPath)
+	for _, name := range backupNames {
+		if _, err := findBackupConfig(cfg, name); err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+	}
+
+	total, failed := 0, 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+
+		utils.PrintHeader("\n%s", backupCfg.Name)
+		executor := backup.NewExecutor(cfg.GlobalFor(backupCfg))
+		executor.SetDryRun(*dryRun)
+		n, err := executor.Prune(backupCfg)
+		total += n
+		if err != nil {
+			utils.PrintError("Failed to prune %s: %v", backupCfg.Name, err)
+			failed++
+		}
+	}
+
+	utils.PrintHeader("\n=== Prune summary ===")
+	if *dryRun {
+		fmt.Printf("Would remove: %d archive(s)\n", total)
+	} else {
+		fmt.Printf("Removed: %d archive(s)\n", total)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"goback/backup"
+	"goback/compression"
+	"goback/utils"
+)
+
+// recompressCommand: goback recompress --from gzip --to zstd - перепаковывает
+// существующие архивы в backup_dir в другой алгоритм сжатия, сохраняя историю
+func recompressCommand(args []string) int {
+	fs := flag.NewFlagSet("recompress", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	from := fs.String("from", "", "Current compression of archives: gzip, zstd, xz or none")
+	to := fs.String("to", "", "New compression: gzip, zstd, xz or none")
+	var backupNames flagArray
+	fs.Var(&backupNames, "backup", "Name of backup to recompress (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to recompress (short)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	backupNames = append(backupNames, positional...)
+
+	if !containsString(compression.Codecs, *from) || !containsString(compression.Codecs, *to) || *from == *to {
+		utils.PrintError("Usage: goback recompress --from gzip|zstd|xz|none --to gzip|zstd|xz|none [-b name]")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	converted, failed := 0, 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+
+		utils.PrintHeader("Recompressing %s (%s -> %s)", backupCfg.Name, *from, *to)
+		n, err := backup.NewExecutor(cfg.GlobalFor(backupCfg)).RecompressArchives(backupCfg, *from, *to)
+		converted += n
+		if err != nil {
+			utils.PrintError("%v", err)
+			failed++
+		}
+	}
+
+	utils.PrintHeader("\n=== Recompress summary ===")
+	fmt.Printf("Recompressed: %d archive(s)\n", converted)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
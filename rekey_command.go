@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// rekeyCommand: goback rekey [-b name] --identity old.key - перешифровывает
+// архивы в backup_dir текущими ключами encryption после ротации ключей
+func rekeyCommand(args []string) int {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	identity := fs.String("identity", os.Getenv("GOBACK_IDENTITY"), "age identity file(s) with the old keys, comma-separated (default: $GOBACK_IDENTITY)")
+	passphraseFile := fs.String("passphrase-file", "", "File with the old age passphrase (default: passphrase from the config)")
+	all := fs.Bool("all", false, "Re-encrypt archives that already use the current keys")
+	dryRun := fs.Bool("dry-run", false, "Only list archives that would be re-encrypted")
+	var backupNames flagArray
+	fs.Var(&backupNames, "backup", "Name of backup to rekey (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to rekey (short)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	backupNames = append(backupNames, positional...)
+
+	opts := backup.RekeyOptions{IdentityFile: *identity, All: *all, DryRun: *dryRun}
+	if *passphraseFile != "" {
+		data, err := os.ReadFile(*passphraseFile)
+		if err != nil {
+			utils.PrintError("Failed to read passphrase file: %v", err)
+			return 1
+		}
+		opts.Passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	rekeyed, failed := 0, 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+		// Без явного выбора пропускаем бэкапы без шифрования
+		if backupCfg.Encryption == nil && len(backupNames) == 0 {
+			continue
+		}
+
+		utils.PrintHeader("Rekeying %s", backupCfg.Name)
+		n, err := backup.NewExecutor(cfg.GlobalFor(backupCfg)).RekeyArchives(backupCfg, opts)
+		rekeyed += n
+		if err != nil {
+			utils.PrintError("%v", err)
+			failed++
+		}
+	}
+
+	utils.PrintHeader("\n=== Rekey summary ===")
+	if *dryRun {
+		fmt.Printf("Would rekey: %d archive(s)\n", rekeyed)
+	} else {
+		fmt.Printf("Rekeyed: %d archive(s)\n", rekeyed)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
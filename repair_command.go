@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"goback/backup"
+	"goback/utils"
+)
+
+// repairCommand: goback repair [--dry-run] [--older-than 1h] [--offline] - убирает
+// следы прерванных запусков и исправляет расхождения каталога
+func repairCommand(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	dryRun := fs.Bool("dry-run", false, "Report what would be repaired without changing anything")
+	olderThan := fs.Duration("older-than", backup.DefaultRepairAge, "Leave leftovers younger than this alone (they may belong to a running backup)")
+	offline := fs.Bool("offline", false, "Skip network destinations")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	opts := backup.RepairOptions{OlderThan: *olderThan, DryRun: *dryRun, Offline: *offline}
+
+	utils.PrintHeader("Looking for leftovers of interrupted runs...")
+	actions, err := backup.RepairTempDirs(opts)
+	failed := err != nil
+	if err != nil {
+		utils.PrintError("%v", err)
+	}
+	printRepairActions(actions)
+	total := len(actions)
+
+	for _, scope := range cfg.Scopes() {
+		if scope.Tenant != "" {
+			utils.PrintHeader("Tenant %s:", scope.Tenant)
+		}
+		actions, err := backup.Repair(scope, opts)
+		printRepairActions(actions)
+		total += len(actions)
+		if err != nil {
+			utils.PrintError("%v", err)
+			failed = true
+		}
+	}
+
+	switch {
+	case total == 0:
+		utils.PrintSuccess("Nothing to repair")
+	case *dryRun:
+		fmt.Printf("Would repair: %d issue(s)\n", total)
+	default:
+		utils.PrintSuccess("Repaired %d issue(s)", total)
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func printRepairActions(actions []backup.RepairAction) {
+	for _, action := range actions {
+		fmt.Printf("  %s\n", action)
+	}
+}
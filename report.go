@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"goback/backup"
+	"goback/notify"
+	"goback/utils"
+)
+
+// runReport - машиночитаемый итог запуска для CI и дашбордов (--report json, report_file)
+type runReport struct {
+	RunID    string    `json:"run_id"`
+	Host     string    `json:"host"`
+	Status   string    `json:"status"`
+	DryRun   bool      `json:"dry_run,omitempty"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Duration float64   `json:"duration_seconds"`
+	// Succeeded, Failed и NotRun - те же счетчики, что в итоговой сводке и GOBACK_* хуков
+	Succeeded int   `json:"succeeded"`
+	Failed    int   `json:"failed"`
+	NotRun    int   `json:"not_run"`
+	Size      int64 `json:"size"`
+	// Errors - все ошибки запуска, включая не связанные с бэкапом (глобальные post-hooks)
+	Errors  []string       `json:"errors,omitempty"`
+	Backups []backupReport `json:"backups"`
+}
+
+// backupReport - итог одного бэкапа в runReport
+type backupReport struct {
+	Name   string `json:"name"`
+	Tenant string `json:"tenant,omitempty"`
+	// Status - success, failure, skipped (окно бэкапа закончилось), locked (выполняется
+	// другим запуском), blocked (не удалась зависимость), hook_skipped
+	// (on_hook_failure: skip-backup) или paused
+	Status string `json:"status"`
+	// Reason - почему бэкап не выполнялся, Error - причина сбоя
+	Reason   string  `json:"reason,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	JobID    string  `json:"job_id,omitempty"`
+	Archive  string  `json:"archive,omitempty"`
+	Size     int64   `json:"size"`
+	Duration float64 `json:"duration_seconds"`
+	// Removed - архивы, удаленные retention после бэкапа
+	Removed      []string          `json:"removed,omitempty"`
+	Destinations []notify.Delivery `json:"destinations,omitempty"`
+}
+
+func newRunReport(started time.Time, dryRun bool) *runReport {
+	host, _ := os.Hostname()
+	return &runReport{Host: host, Started: started, DryRun: dryRun, Backups: []backupReport{}}
+}
+
+// add добавляет в отчет итог бэкапа; reason - причина сбоя или пропуска
+func (r *runReport) add(name, tenant, status, reason string) {
+	entry := backupReport{Name: name, Tenant: tenant, Status: status}
+	if status == "failure" {
+		entry.Error = reason
+	} else {
+		entry.Reason = reason
+	}
+	r.Backups = append(r.Backups, entry)
+}
+
+// finish дополняет бэкапы отчета архивами, размерами и доставками из итогов executors
+// и подводит итог запуска
+func (r *runReport) finish(executors map[string]*backup.Executor, succeeded, failed, notRun int, failures []string) {
+	results := make(map[string]notify.Result)
+	for _, executor := range executors {
+		for _, result := range executor.Results() {
+			results[result.Backup] = result
+		}
+	}
+
+	r.Size = 0
+	for i := range r.Backups {
+		entry := &r.Backups[i]
+		result, ok := results[entry.Name]
+		if !ok {
+			continue
+		}
+		entry.JobID = result.JobID
+		entry.Archive = result.Archive
+		entry.Size = result.Size
+		entry.Duration = result.Duration.Seconds()
+		entry.Removed = result.Removed
+		entry.Destinations = result.Deliveries
+		if entry.Status == "failure" && result.Error != "" {
+			entry.Error = result.Error
+		}
+		r.Size += result.Size
+	}
+
+	r.Finished = time.Now()
+	r.Duration = r.Finished.Sub(r.Started).Seconds()
+	r.Succeeded, r.Failed, r.NotRun = succeeded, failed, notRun
+	r.Errors = failures
+	r.Status = "success"
+	if failed > 0 || notRun > 0 {
+		r.Status = "failure"
+	}
+}
+
+// write выводит отчет в w как JSON
+func (r *runReport) write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("failed to encode run report: %w", err)
+	}
+	return nil
+}
+
+// save атомарно записывает отчет в path: читатель файла не увидит его наполовину записанным
+func (r *runReport) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	if err := utils.WriteAtomic(path, 0644, r.write); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+
+	return nil
+}
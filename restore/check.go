@@ -0,0 +1,49 @@
+package restore
+
+import (
+	"fmt"
+	"io"
+)
+
+// CheckResult - итог пробного чтения архива
+type CheckResult struct {
+	// Files - записи архива кроме директорий, Bytes - размер данных обычных файлов
+	Files int64
+	Bytes int64
+}
+
+// Check читает архив целиком через распаковщик без записи на диск. Обрезанный
+// или поврежденный поток, CRC-32 записей zip и контрольные суммы gzip/xz/zstd
+// обнаруживаются как ошибки
+func Check(archivePath, plainName string) (CheckResult, error) {
+	var result CheckResult
+	err := walkArchive(archivePath, plainName, func(entry Entry, r io.Reader) error {
+		n, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name, err)
+		}
+		if entry.Mode.IsDir() {
+			return nil
+		}
+		result.Files++
+		if entry.Mode.IsRegular() {
+			if entry.Size >= 0 && n != entry.Size {
+				return fmt.Errorf("%s is truncated: expected %d bytes, read %d", entry.Name, entry.Size, n)
+			}
+			result.Bytes += n
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Check проверяет архив пробным чтением, расшифровывая .age/.gpg во временный файл
+func (o Options) Check(archivePath string) (CheckResult, error) {
+	var result CheckResult
+	err := o.decrypted(archivePath, func(plainPath string) error {
+		var err error
+		result, err = Check(plainPath, o.PlainName)
+		return err
+	})
+	return result, err
+}
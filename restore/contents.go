@@ -0,0 +1,224 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/utils"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Entry - запись архива, как ее видит goback show
+type Entry struct {
+	Name     string
+	Size     int64
+	Mode     os.FileMode
+	ModTime  time.Time
+	Linkname string
+}
+
+// Contents перечисляет записи архива без распаковки на диск. Для архивов из
+// одного файла (gzip, none) возвращается одна запись plainName с размером
+// распакованных данных
+func Contents(archivePath, plainName string, fn func(Entry) error) error {
+	return walkArchive(archivePath, plainName, func(entry Entry, r io.Reader) error {
+		if entry.Size < 0 {
+			size, err := io.Copy(io.Discard, r)
+			if err != nil {
+				return fmt.Errorf("failed to read archive: %w", err)
+			}
+			entry.Size = size
+		}
+		return fn(entry)
+	})
+}
+
+// walkArchive вызывает fn для каждой записи архива с потоком ее данных. Размер
+// записи однофайлового архива заранее неизвестен (-1)
+func walkArchive(archivePath, plainName string, fn func(Entry, io.Reader) error) error {
+	// Бэкап с format: directory - дерево файлов, которое просто обходится
+	if info, err := os.Stat(archivePath); err == nil && info.IsDir() {
+		return walkTree(archivePath, fn)
+	}
+
+	compression := utils.DetectCompression(archivePath)
+	if compression == "zip" {
+		return zipContents(archivePath, fn)
+	}
+
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	stream, err := decompressStream(file, compression)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	switch compression {
+	case "tar", "tar.gz", "tar.zst", "tar.xz":
+		if err := tarContents(stream, fn); err != nil {
+			return err
+		}
+		// Дочитываем поток после конца tar: контрольная сумма gzip/xz в его конце
+		if _, err := io.Copy(io.Discard, stream); err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		return nil
+	default:
+		var modTime time.Time
+		if info, err := utils.StatArchive(archivePath); err == nil {
+			modTime = info.ModTime()
+		}
+		return fn(Entry{Name: plainName, Size: -1, Mode: 0644, ModTime: modTime}, stream)
+	}
+}
+
+// decompressStream возвращает распакованный поток архива по типу сжатия
+func decompressStream(r io.Reader, compression string) (io.ReadCloser, error) {
+	switch compression {
+	case "tar.gz", "gzip":
+		reader, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		return reader, nil
+	case "tar.zst", "zstd":
+		reader, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open zstd stream: %w", err)
+		}
+		return reader.IOReadCloser(), nil
+	case "tar.xz", "xz":
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open xz stream: %w", err)
+		}
+		return io.NopCloser(reader), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
+
+func tarContents(r io.Reader, fn func(Entry, io.Reader) error) error {
+	reader := tar.NewReader(r)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+
+		if err := fn(Entry{
+			Name:     header.Name,
+			Size:     header.Size,
+			Mode:     header.FileInfo().Mode(),
+			ModTime:  header.ModTime,
+			Linkname: header.Linkname,
+		}, reader); err != nil {
+			return err
+		}
+	}
+}
+
+func zipContents(archivePath string, fn func(Entry, io.Reader) error) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(file, file.Size())
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	for _, entry := range reader.File {
+		item := Entry{
+			Name:    entry.Name,
+			Size:    int64(entry.UncompressedSize64),
+			Mode:    entry.Mode(),
+			ModTime: entry.Modified,
+		}
+		// Данные записи читаются лениво; при чтении до конца zip проверяет CRC-32
+		data := &zipEntryReader{entry: entry}
+		// Цель симлинка в zip хранится как содержимое записи
+		if item.Mode&os.ModeSymlink != 0 {
+			linkname, _ := io.ReadAll(io.LimitReader(data, 4096))
+			item.Linkname = string(linkname)
+		}
+		err := fn(item, data)
+		data.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zipEntryReader открывает запись zip при первом чтении
+type zipEntryReader struct {
+	entry  *zip.File
+	reader io.ReadCloser
+}
+
+func (r *zipEntryReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		reader, err := r.entry.Open()
+		if err != nil {
+			return 0, fmt.Errorf("failed to open %s: %w", r.entry.Name, err)
+		}
+		r.reader = reader
+	}
+	return r.reader.Read(p)
+}
+
+func (r *zipEntryReader) Close() {
+	if r.reader != nil {
+		r.reader.Close()
+	}
+}
+
+func walkTree(root string, fn func(Entry, io.Reader) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil || name == "." {
+			return err
+		}
+
+		item := Entry{Name: filepath.ToSlash(name), Mode: info.Mode(), ModTime: info.ModTime()}
+		if info.Mode()&os.ModeSymlink != 0 {
+			item.Linkname, _ = os.Readlink(path)
+		}
+		if !info.Mode().IsRegular() {
+			return fn(item, strings.NewReader(""))
+		}
+
+		item.Size = info.Size()
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", name, err)
+		}
+		defer file.Close()
+		return fn(item, file)
+	})
+}
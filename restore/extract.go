@@ -0,0 +1,382 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"goback/utils"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Extract распаковывает архив в targetDir в соответствии с его расширением.
+// plainName используется для архивов из одного файла (gzip, none), у которых
+// имя исходного файла не сохраняется. Без unsafe записи, выходящие за пределы
+// targetDir (абсолютные пути, "../", симлинки наружу), отклоняются. С include
+// распаковываются только записи, попадающие под паттерны (см. matchInclude)
+func Extract(archivePath, targetDir, plainName string, unsafe bool, include []string) error {
+	x, err := newExtractor(targetDir, unsafe, include)
+	if err != nil {
+		return err
+	}
+
+	// Бэкап с format: directory - дерево файлов, которое копируется как есть
+	if info, err := os.Stat(archivePath); err == nil && info.IsDir() {
+		return copyTree(archivePath, x)
+	}
+
+	switch utils.DetectCompression(archivePath) {
+	case "tar.gz":
+		return extractTarGz(archivePath, x)
+	case "tar.zst":
+		return extractTarZstd(archivePath, x)
+	case "tar.xz":
+		return extractTarXz(archivePath, x)
+	case "tar":
+		return extractTar(archivePath, x)
+	case "zip":
+		return extractZip(archivePath, x)
+	}
+
+	// Архив из одного файла - фильтр include применяется к plainName
+	if !x.want(plainName) {
+		return x.finish()
+	}
+	switch utils.DetectCompression(archivePath) {
+	case "gzip":
+		return extractGzip(archivePath, filepath.Join(x.root, plainName))
+	case "zstd":
+		return extractZstd(archivePath, filepath.Join(x.root, plainName))
+	case "xz":
+		return extractXz(archivePath, filepath.Join(x.root, plainName))
+	default:
+		return copyPlain(archivePath, filepath.Join(x.root, plainName))
+	}
+}
+
+func extractTarGz(archivePath string, x *extractor) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	defer reader.Close()
+
+	return extractTarStream(reader, x)
+}
+
+func extractTarZstd(archivePath string, x *extractor) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := zstd.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd stream: %w", err)
+	}
+	defer reader.Close()
+
+	return extractTarStream(reader, x)
+}
+
+func extractTarXz(archivePath string, x *extractor) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := xz.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open xz stream: %w", err)
+	}
+
+	return extractTarStream(reader, x)
+}
+
+func extractTar(archivePath string, x *extractor) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	return extractTarStream(file, x)
+}
+
+func extractTarStream(r io.Reader, x *extractor) error {
+	reader := tar.NewReader(r)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return x.finish()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar entry: %w", err)
+		}
+		if !x.want(header.Name) {
+			continue
+		}
+
+		path, err := x.path(header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", header.Name, err)
+			}
+			x.setDirMode(path, header.FileInfo().Mode()&modeMask)
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", header.Name, err)
+			}
+			if err := x.checkLink(header.Name, path, header.Linkname); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, path); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", header.Name, err)
+			}
+		case tar.TypeReg:
+			if err := writeFile(reader, path, header.FileInfo().Mode()&modeMask); err != nil {
+				return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+			}
+		default:
+			// Остальные типы записей (устройства, fifo) бэкап не создает
+			continue
+		}
+	}
+}
+
+func extractZip(archivePath string, x *extractor) error {
+	// Оглавление zip в конце архива - тома читаются с произвольного смещения
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(file, file.Size())
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+
+	for _, entry := range reader.File {
+		if !x.want(entry.Name) {
+			continue
+		}
+		path, err := x.path(entry.Name)
+		if err != nil {
+			return err
+		}
+
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", entry.Name, err)
+			}
+			x.setDirMode(path, entry.Mode()&modeMask)
+			continue
+		}
+
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if err := extractZipSymlink(entry, path, x); err != nil {
+				return err
+			}
+			continue
+		}
+
+		src, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", entry.Name, err)
+		}
+
+		err = writeFile(src, path, entry.Mode()&modeMask)
+		src.Close()
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", entry.Name, err)
+		}
+	}
+
+	return x.finish()
+}
+
+// extractZipSymlink создает симлинк из записи zip: содержимое записи - путь цели
+func extractZipSymlink(entry *zip.File, path string, x *extractor) error {
+	src, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", entry.Name, err)
+	}
+	linkname, err := io.ReadAll(io.LimitReader(src, 4096))
+	src.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read symlink %s: %w", entry.Name, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", entry.Name, err)
+	}
+	if err := x.checkLink(entry.Name, path, string(linkname)); err != nil {
+		return err
+	}
+	if err := os.Symlink(string(linkname), path); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", entry.Name, err)
+	}
+	return nil
+}
+
+// copyTree копирует бэкап-директорию с теми же проверками, что и распаковка архива
+func copyTree(source string, x *extractor) error {
+	err := filepath.Walk(source, func(src string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(source, src)
+		if err != nil || name == "." {
+			return err
+		}
+		// Невыбранную директорию не пропускаем целиком - в ней могут быть выбранные файлы
+		if !x.want(name) {
+			return nil
+		}
+
+		path, err := x.path(name)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(path, 0700); err != nil {
+				return fmt.Errorf("failed to create directory %s: %w", name, err)
+			}
+			x.setDirMode(path, info.Mode()&modeMask)
+		case info.Mode()&os.ModeSymlink != 0:
+			linkname, err := os.Readlink(src)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", name, err)
+			}
+			if err := x.checkLink(name, path, linkname); err != nil {
+				return err
+			}
+			if err := os.Symlink(linkname, path); err != nil {
+				return fmt.Errorf("failed to create symlink %s: %w", name, err)
+			}
+		case info.Mode().IsRegular():
+			file, err := os.Open(src)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", name, err)
+			}
+			err = writeFile(file, path, info.Mode()&modeMask)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return x.finish()
+}
+
+func extractGzip(archivePath, destination string) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip stream: %w", err)
+	}
+	defer reader.Close()
+
+	return writeFile(reader, destination, 0644)
+}
+
+func extractZstd(archivePath, destination string) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := zstd.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open zstd stream: %w", err)
+	}
+	defer reader.Close()
+
+	return writeFile(reader, destination, 0644)
+}
+
+func extractXz(archivePath, destination string) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := xz.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to open xz stream: %w", err)
+	}
+
+	return writeFile(reader, destination, 0644)
+}
+
+func copyPlain(archivePath, destination string) error {
+	file, err := utils.OpenArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	return writeFile(file, destination, 0644)
+}
+
+// modeMask - биты прав, которые восстанавливаются точно (включая setuid/setgid/sticky)
+const modeMask = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// writeFile записывает файл и выставляет mode явно, независимо от umask
+func writeFile(r io.Reader, path string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, mode)
+}
@@ -0,0 +1,53 @@
+package restore
+
+import (
+	"path"
+	"strings"
+)
+
+// matchInclude проверяет, что запись архива name попадает под один из паттернов
+// --include. Паттерн сравнивается по сегментам пути: * и ? не пересекают "/",
+// ** совпадает с любым числом сегментов. Совпадение с директорией включает все
+// ее содержимое, так что "etc/nginx" и "etc/nginx/**" эквивалентны
+func matchInclude(name string, patterns []string) bool {
+	segments := splitPath(name)
+	for _, pattern := range patterns {
+		if matchSegments(splitPath(pattern), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPath разбивает путь записи на сегменты, отбрасывая "./" и крайние "/"
+func splitPath(name string) []string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.TrimPrefix(name, "./")
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+func matchSegments(pattern, name []string) bool {
+	// Паттерн исчерпан - name совпадает или лежит внутри совпавшей директории
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], name[1:])
+}
@@ -0,0 +1,145 @@
+package restore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extractor проверяет, что записи архива не выходят за пределы целевой директории:
+// отклоняются абсолютные пути, "../" и запись через симлинки, указывающие наружу.
+// unsafe отключает проверки для доверенных архивов
+type extractor struct {
+	root   string
+	unsafe bool
+	// include - паттерны --include; пустой список - распаковываются все записи
+	include []string
+	// selected - сколько записей прошло фильтр include
+	selected int
+	// dirs - директории, чьи права применяются после распаковки содержимого
+	// (директория без права записи иначе не даст создать в ней файлы)
+	dirs []dirMode
+}
+
+type dirMode struct {
+	path string
+	mode os.FileMode
+}
+
+func newExtractor(targetDir string, unsafe bool, include []string) (*extractor, error) {
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create target directory: %w", err)
+	}
+
+	root, err := filepath.Abs(targetDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for target: %w", err)
+	}
+
+	// Сама целевая директория может быть симлинком - сравниваем с реальным путем
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	return &extractor{root: root, unsafe: unsafe, include: include}, nil
+}
+
+// want проверяет, что запись name нужно распаковать (попадает под --include)
+func (x *extractor) want(name string) bool {
+	if len(x.include) > 0 && !matchInclude(name, x.include) {
+		return false
+	}
+	x.selected++
+	return true
+}
+
+// path возвращает путь для записи name внутри целевой директории
+func (x *extractor) path(name string) (string, error) {
+	if x.unsafe {
+		return filepath.Join(x.root, name), nil
+	}
+
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("unsafe entry %s: absolute path (use --unsafe to allow)", name)
+	}
+
+	path := filepath.Join(x.root, name)
+	if !x.within(path) {
+		return "", fmt.Errorf("unsafe entry %s: path escapes target directory (use --unsafe to allow)", name)
+	}
+
+	// Родительская директория могла быть создана симлинком из этого же архива
+	if resolved := resolveExisting(filepath.Dir(path)); !x.within(resolved) {
+		return "", fmt.Errorf("unsafe entry %s: written through a symlink outside target directory (use --unsafe to allow)", name)
+	}
+
+	return path, nil
+}
+
+// checkLink проверяет, что симлинк path -> linkname указывает внутрь целевой директории
+func (x *extractor) checkLink(name, path, linkname string) error {
+	if x.unsafe {
+		return nil
+	}
+
+	if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+		return fmt.Errorf("unsafe symlink %s -> %s: absolute target (use --unsafe to allow)", name, linkname)
+	}
+
+	if !x.within(filepath.Join(filepath.Dir(path), linkname)) {
+		return fmt.Errorf("unsafe symlink %s -> %s: target outside target directory (use --unsafe to allow)", name, linkname)
+	}
+
+	// Цель может проходить через уже созданные симлинки ("link/..") - проверяем
+	// реальный путь, если он существует
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(path) + string(filepath.Separator) + linkname); err == nil && !x.within(resolved) {
+		return fmt.Errorf("unsafe symlink %s -> %s: target outside target directory (use --unsafe to allow)", name, linkname)
+	}
+
+	return nil
+}
+
+func (x *extractor) within(path string) bool {
+	rel, err := filepath.Rel(x.root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// resolveExisting раскрывает симлинки в существующей части пути
+func resolveExisting(path string) string {
+	missing := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return filepath.Join(resolved, missing)
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing)
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// setDirMode запоминает точные права директории для применения в finish
+func (x *extractor) setDirMode(path string, mode os.FileMode) {
+	x.dirs = append(x.dirs, dirMode{path: path, mode: mode})
+}
+
+// finish выставляет права директорий от вложенных к корню, независимо от umask.
+// Если ни одна запись не попала под --include, это ошибка, а не пустое восстановление
+func (x *extractor) finish() error {
+	if len(x.include) > 0 && x.selected == 0 {
+		return fmt.Errorf("no archive entries match --include %s", strings.Join(x.include, ", "))
+	}
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(x.dirs[i].path, x.dirs[i].mode); err != nil {
+			return fmt.Errorf("failed to set mode of %s: %w", x.dirs[i].path, err)
+		}
+	}
+	return nil
+}
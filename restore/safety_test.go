@@ -0,0 +1,243 @@
+package restore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testEntry - запись тестового архива: файл с body или симлинк на link
+type testEntry struct {
+	name string
+	link string
+	body string
+}
+
+func writeTestTar(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := tar.NewWriter(file)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(entry.body))}
+		if entry.link != "" {
+			header = &tar.Header{Name: entry.name, Mode: 0777, Typeflag: tar.TypeSymlink, Linkname: entry.link}
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(entry.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, entries []testEntry) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Store}
+		content := entry.body
+		header.SetMode(0644)
+		if entry.link != "" {
+			// Цель симлинка в zip хранится содержимым записи
+			header.SetMode(os.ModeSymlink | 0777)
+			content = entry.link
+		}
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestExtractMaliciousArchives проверяет, что записи, выходящие за пределы целевой
+// директории, по умолчанию отклоняются, а с unsafe распаковываются как есть
+func TestExtractMaliciousArchives(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		// outsideLink создает в целевой директории симлинк link на директорию снаружи
+		outsideLink bool
+		// wantErr - фрагмент ошибки без unsafe
+		wantErr string
+		// unsafeCheck проверяет результат распаковки с unsafe; base - родитель target
+		unsafeCheck func(t *testing.T, base, target string)
+	}{
+		{
+			name:    "parent directory traversal",
+			entries: []testEntry{{name: "../escape.txt", body: "evil"}},
+			wantErr: "path escapes target directory",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertContent(t, filepath.Join(base, "escape.txt"), "evil")
+			},
+		},
+		{
+			name:    "nested traversal",
+			entries: []testEntry{{name: "dir/../../escape.txt", body: "evil"}},
+			wantErr: "path escapes target directory",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertContent(t, filepath.Join(base, "escape.txt"), "evil")
+			},
+		},
+		{
+			name:    "absolute path",
+			entries: []testEntry{{name: "/etc/evil.txt", body: "evil"}},
+			wantErr: "absolute path",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				// С unsafe абсолютный путь распаковывается относительно target
+				assertContent(t, filepath.Join(target, "etc", "evil.txt"), "evil")
+			},
+		},
+		{
+			name:    "symlink to absolute path",
+			entries: []testEntry{{name: "link", link: "/etc"}},
+			wantErr: "absolute target",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertLink(t, filepath.Join(target, "link"), "/etc")
+			},
+		},
+		{
+			name:    "symlink outside target",
+			entries: []testEntry{{name: "link", link: "../outside"}},
+			wantErr: "target outside target directory",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertLink(t, filepath.Join(target, "link"), "../outside")
+			},
+		},
+		{
+			name: "write through an earlier symlink",
+			entries: []testEntry{
+				{name: "sub", link: "."},
+				{name: "sub/up", link: ".."},
+				{name: "sub/up/outside/evil.txt", body: "evil"},
+			},
+			wantErr: "target outside target directory",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertContent(t, filepath.Join(base, "outside", "evil.txt"), "evil")
+			},
+		},
+		{
+			name:        "write through an existing symlink",
+			entries:     []testEntry{{name: "link/evil.txt", body: "evil"}},
+			outsideLink: true,
+			wantErr:     "written through a symlink",
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertContent(t, filepath.Join(base, "outside", "evil.txt"), "evil")
+			},
+		},
+		{
+			name: "symlink inside target",
+			entries: []testEntry{
+				{name: "data/file.txt", body: "ok"},
+				{name: "data/link", link: "file.txt"},
+			},
+			unsafeCheck: func(t *testing.T, base, target string) {
+				assertContent(t, filepath.Join(target, "data", "link"), "ok")
+			},
+		},
+	}
+
+	formats := []struct {
+		ext   string
+		write func(t *testing.T, path string, entries []testEntry)
+	}{
+		{".tar", writeTestTar},
+		{".zip", writeTestZip},
+	}
+
+	for _, format := range formats {
+		for _, tt := range tests {
+			for _, unsafe := range []bool{false, true} {
+				name := format.ext + "/" + tt.name
+				if unsafe {
+					name += "/unsafe"
+				}
+				t.Run(name, func(t *testing.T) {
+					base := t.TempDir()
+					target := filepath.Join(base, "target")
+					if err := os.MkdirAll(filepath.Join(base, "outside"), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.MkdirAll(target, 0755); err != nil {
+						t.Fatal(err)
+					}
+					if tt.outsideLink {
+						if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(target, "link")); err != nil {
+							t.Fatal(err)
+						}
+					}
+
+					archive := filepath.Join(base, "archive"+format.ext)
+					format.write(t, archive, tt.entries)
+
+					err := Extract(archive, target, "", unsafe, nil)
+					if unsafe || tt.wantErr == "" {
+						if err != nil {
+							t.Fatalf("Extract() error = %v, want nil", err)
+						}
+						tt.unsafeCheck(t, base, target)
+						return
+					}
+
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("Extract() error = %v, want %q", err, tt.wantErr)
+					}
+					// Ничего не должно появиться за пределами target
+					for _, path := range []string{
+						filepath.Join(base, "escape.txt"),
+						filepath.Join(base, "outside", "evil.txt"),
+					} {
+						if _, err := os.Lstat(path); err == nil {
+							t.Fatalf("%s was written outside the target directory", path)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+func assertContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	if string(data) != want {
+		t.Fatalf("%s = %q, want %q", path, data, want)
+	}
+}
+
+func assertLink(t *testing.T, path, want string) {
+	t.Helper()
+	link, err := os.Readlink(path)
+	if err != nil {
+		t.Fatalf("readlink %s: %v", path, err)
+	}
+	if link != want {
+		t.Fatalf("%s -> %s, want %s", path, link, want)
+	}
+}
@@ -0,0 +1,244 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goback/encryption"
+	"goback/retention"
+	"goback/utils"
+)
+
+type Options struct {
+	// BackupDir - корневая директория с архивами (backup_dir или директория destination)
+	BackupDir    string
+	Subdirectory string
+	Name         string
+	// Naming извлекает дату архива из имени (global.naming)
+	Naming utils.Naming
+	// PlainName - имя файла при восстановлении однофайловых архивов
+	PlainName string
+	TargetDir string
+	// Archive - имя файла, путь или метка времени (YYYYmmddHHMMSS) архива;
+	// пустое значение - последний архив
+	Archive string
+	// IdentityFile и Passphrase - ключи для расшифровки архивов .age
+	IdentityFile string
+	Passphrase   string
+	// Unsafe отключает защиту от записей архива, выходящих за пределы TargetDir
+	Unsafe bool
+	// Include - паттерны путей внутри архива ("etc/nginx/**"); пустой список -
+	// архив восстанавливается целиком
+	Include []string
+}
+
+// extract распаковывает архив, предварительно расшифровывая .age/.gpg во временный файл
+func (o Options) extract(archivePath, targetDir string) error {
+	return o.decrypted(archivePath, func(plainPath string) error {
+		return Extract(plainPath, targetDir, o.PlainName, o.Unsafe, o.Include)
+	})
+}
+
+// Contents перечисляет записи архива, расшифровывая .age/.gpg во временный файл
+func (o Options) Contents(archivePath string, fn func(Entry) error) error {
+	return o.decrypted(archivePath, func(plainPath string) error {
+		return Contents(plainPath, o.PlainName, fn)
+	})
+}
+
+// decrypted вызывает fn с путем к расшифрованному архиву; незашифрованный
+// архив передается как есть
+func (o Options) decrypted(archivePath string, fn func(plainPath string) error) error {
+	if !utils.IsEncrypted(archivePath) {
+		return fn(archivePath)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "goback-decrypt-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	ext := utils.EncryptionExtension(archivePath)
+	base := filepath.Base(archivePath)
+	plainPath := filepath.Join(tmpDir, base[:len(base)-len(ext)])
+
+	// Расшифровка читает один файл - тома собираются перед ней
+	encryptedPath := archivePath
+	if len(utils.VolumeParts(archivePath)) > 0 {
+		encryptedPath = filepath.Join(tmpDir, base)
+		if err := utils.JoinArchive(archivePath, encryptedPath); err != nil {
+			return err
+		}
+	}
+
+	if err := encryption.Decrypt(encryptedPath, plainPath, o.IdentityFile, o.Passphrase); err != nil {
+		// После ротации ключей запись о ключах подсказывает, какой ключ нужен
+		if record, ok, _ := encryption.ReadKeyRecord(archivePath); ok {
+			return fmt.Errorf("failed to decrypt archive (encrypted for %s): %w", strings.Join(record.Keys, ", "), err)
+		}
+		return fmt.Errorf("failed to decrypt archive: %w", err)
+	}
+
+	return fn(plainPath)
+}
+
+type StandbyOptions struct {
+	Options
+	// Interval - период опроса директории с архивами
+	Interval time.Duration
+	// Settle - сколько архив должен не изменяться, прежде чем его можно восстанавливать
+	Settle time.Duration
+}
+
+// LatestArchive возвращает путь к самому свежему архиву бэкапа
+func LatestArchive(backupDir, subdirectory, name string, naming utils.Naming) (retention.BackupFile, error) {
+	files, err := retention.FindBackupFiles(backupDir, subdirectory, name, naming)
+	if err != nil {
+		return retention.BackupFile{}, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	if len(files) == 0 {
+		return retention.BackupFile{}, fmt.Errorf("no archives found for backup %s", name)
+	}
+
+	return files[len(files)-1], nil
+}
+
+// FindArchive ищет архив бэкапа по имени файла, пути или метке времени
+func FindArchive(backupDir, subdirectory, name, selector string, naming utils.Naming) (retention.BackupFile, error) {
+	if selector == "" {
+		return LatestArchive(backupDir, subdirectory, name, naming)
+	}
+
+	// Явный путь к архиву (например, скачанному вручную)
+	if strings.ContainsRune(selector, filepath.Separator) {
+		if _, err := utils.StatArchive(selector); err != nil {
+			return retention.BackupFile{}, fmt.Errorf("archive not found: %w", err)
+		}
+		createdAt, _ := naming.ParseDate(filepath.Base(selector), name)
+		return retention.BackupFile{Path: selector, Time: createdAt}, nil
+	}
+
+	files, err := retention.FindBackupFiles(backupDir, subdirectory, name, naming)
+	if err != nil {
+		return retention.BackupFile{}, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	for _, file := range files {
+		if filepath.Base(file.Path) == selector || file.Time.Format("20060102150405") == selector {
+			return file, nil
+		}
+	}
+
+	return retention.BackupFile{}, fmt.Errorf("archive %s not found for backup %s", selector, name)
+}
+
+// RestoreLatest восстанавливает архив opts.Archive (по умолчанию последний) в TargetDir
+func RestoreLatest(opts Options) (string, error) {
+	archive, err := FindArchive(opts.BackupDir, opts.Subdirectory, opts.Name, opts.Archive, opts.Naming)
+	if err != nil {
+		return "", err
+	}
+
+	if err := opts.extract(archive.Path, opts.TargetDir); err != nil {
+		return "", err
+	}
+
+	return archive.Path, nil
+}
+
+// RunStandby поддерживает TargetDir в актуальном состоянии: каждый новый архив
+// распаковывается во временную директорию рядом с целевой и подменяет ее целиком
+func RunStandby(ctx context.Context, opts StandbyOptions) error {
+	targetDir, err := filepath.Abs(opts.TargetDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path for target: %w", err)
+	}
+
+	parent := filepath.Dir(targetDir)
+	base := filepath.Base(targetDir)
+	stagingDir := filepath.Join(parent, "."+base+".goback-staging")
+	previousDir := filepath.Join(parent, "."+base+".goback-previous")
+	// Маркер последнего восстановленного архива хранится рядом с целевой директорией,
+	// чтобы не засорять восстановленные данные и пережить перезапуск
+	markerPath := filepath.Join(parent, "."+base+".goback-restored")
+
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	lastRestored := ""
+	if data, err := os.ReadFile(markerPath); err == nil {
+		lastRestored = strings.TrimSpace(string(data))
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		latest, err := LatestArchive(opts.BackupDir, opts.Subdirectory, opts.Name, opts.Naming)
+		if err != nil {
+			fmt.Printf("Waiting for archives: %v\n", err)
+		} else if filepath.Base(latest.Path) != lastRestored && isSettled(latest.Path, opts.Settle) {
+			utils.PrintHeader("Restoring %s into %s...", filepath.Base(latest.Path), targetDir)
+			if err := swapIn(opts.Options, latest.Path, targetDir, stagingDir, previousDir); err != nil {
+				utils.PrintError("Standby restore failed: %v", err)
+			} else {
+				lastRestored = filepath.Base(latest.Path)
+				if err := os.WriteFile(markerPath, []byte(lastRestored+"\n"), 0644); err != nil {
+					fmt.Printf("Warning: failed to write restore marker: %v\n", err)
+				}
+				utils.PrintSuccess("Standby updated: %s", lastRestored)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// isSettled проверяет, что архив не изменялся в течение settle (запись завершена)
+func isSettled(path string, settle time.Duration) bool {
+	info, err := utils.StatArchive(path)
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) >= settle
+}
+
+func swapIn(opts Options, archivePath, targetDir, stagingDir, previousDir string) error {
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return fmt.Errorf("failed to clean staging directory: %w", err)
+	}
+
+	if err := opts.extract(archivePath, stagingDir); err != nil {
+		os.RemoveAll(stagingDir)
+		return err
+	}
+
+	if err := os.RemoveAll(previousDir); err != nil {
+		return fmt.Errorf("failed to clean previous directory: %w", err)
+	}
+
+	if _, err := os.Stat(targetDir); err == nil {
+		if err := os.Rename(targetDir, previousDir); err != nil {
+			return fmt.Errorf("failed to move current standby aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(stagingDir, targetDir); err != nil {
+		// Возвращаем предыдущую версию на место
+		os.Rename(previousDir, targetDir)
+		return fmt.Errorf("failed to activate new standby: %w", err)
+	}
+
+	return os.RemoveAll(previousDir)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/restore"
+	"goback/retention"
+	"goback/utils"
+)
+
+func restoreCommand(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	targetDir := fs.String("to", "", "Directory to restore into (default: original source_dir or output_file directory)")
+	fromDir := fs.String("from", "", "Directory with archives (default: backup_dir from config)")
+	archiveName := fs.String("archive", "", "Archive to restore: file name, path or timestamp YYYYmmddHHMMSS (default: latest)")
+	list := fs.Bool("list", false, "List available archives and exit")
+	contents := fs.Bool("contents", false, "List files inside the archive and exit (same as goback show)")
+	force := fs.Bool("force", false, "Allow restoring over the original location")
+	unsafe := fs.Bool("unsafe", false, "Allow archive entries with absolute paths, ../ or symlinks pointing outside the target")
+	identity := fs.String("identity", os.Getenv("GOBACK_IDENTITY"), "age identity file(s) for encrypted archives, comma-separated (default: $GOBACK_IDENTITY)")
+	continuous := fs.Bool("continuous", false, "Keep target directory updated with the latest archive (warm standby)")
+	interval := fs.Duration("interval", time.Minute, "Polling interval for --continuous")
+	settle := fs.Duration("settle", 30*time.Second, "Minimum archive age before it is restored in --continuous mode")
+	var include flagArray
+	fs.Var(&include, "include", "Restore only archive entries matching the pattern, e.g. 'etc/nginx/**' (can be specified multiple times)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	if len(positional) != 1 {
+		utils.PrintError("Usage: goback restore [--archive <name>] [--continuous] <backup-name> [--to <dir>]")
+		return 2
+	}
+
+	if *continuous && *targetDir == "" {
+		utils.PrintError("--to is required with --continuous")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	backupCfg, err := findBackupConfig(cfg, positional[0])
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	opts := restoreOptions(cfg, backupCfg, *identity)
+	opts.TargetDir = *targetDir
+	opts.Archive = *archiveName
+	opts.Unsafe = *unsafe
+	opts.Include = include
+	if *fromDir != "" {
+		opts.BackupDir = *fromDir
+	}
+
+	if *list {
+		return listArchives(opts)
+	}
+	if *contents {
+		archive, err := restore.FindArchive(opts.BackupDir, opts.Subdirectory, opts.Name, opts.Archive, opts.Naming)
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		return showContents(opts, archive.Path)
+	}
+
+	if opts.TargetDir == "" && backupCfg.Type == config.TypeDockerVolume {
+		// Том заполняется из tar уже после восстановления файла, при остановленных контейнерах
+		utils.PrintError("Backup %s is a docker volume snapshot; use --to <dir> and extract %s into the volume", backupCfg.Name, backupCfg.DumpFileName())
+		return 2
+	}
+	if opts.TargetDir == "" && backupCfg.Stream && backupCfg.OutputFile == "" {
+		// Без output_file у потокового вывода команды нет исходного места
+		utils.PrintError("Backup %s streams command output without output_file; use --to <dir>", backupCfg.Name)
+		return 2
+	}
+	if opts.TargetDir == "" && backupCfg.IsDump() {
+		// Дамп восстанавливается в файл, а в базу загружается через psql или pg_restore
+		utils.PrintError("Backup %s is a %s dump; use --to <dir> and load %s into the database", backupCfg.Name, backupCfg.Type, backupCfg.DumpFileName())
+		return 2
+	}
+	toOriginal := opts.TargetDir == ""
+	if toOriginal {
+		opts.TargetDir = originalLocation(backupCfg)
+	}
+	// Бэкап с read_only не восстанавливается в source_dir даже с --force
+	if backup.WritesIntoSource(cfg.GlobalFor(backupCfg), backupCfg, opts.TargetDir) {
+		utils.PrintError("Backup %s is read_only: restoring into its source_dir %s is not allowed; use --to <dir> outside of it", backupCfg.Name, backupCfg.SourceDir)
+		return 2
+	}
+	if toOriginal && !*force {
+		// Восстановление на исходное место перезаписывает текущие данные
+		utils.PrintError("Restoring into the original location %s overwrites existing files; use --force or --to <dir>", opts.TargetDir)
+		return 2
+	}
+
+	if !*continuous {
+		archive, err := restore.RestoreLatest(opts)
+		if err != nil {
+			utils.PrintError("Restore failed: %v", err)
+			return 1
+		}
+		utils.PrintSuccess("Restored %s into %s", filepath.Base(archive), opts.TargetDir)
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	utils.PrintHeader("Watching %s for new archives of %s (every %s)...", filepath.Join(opts.BackupDir, opts.Subdirectory), opts.Name, *interval)
+	if err := restore.RunStandby(ctx, restore.StandbyOptions{
+		Options:  opts,
+		Interval: *interval,
+		Settle:   *settle,
+	}); err != nil {
+		utils.PrintError("Standby failed: %v", err)
+		return 1
+	}
+
+	return 0
+}
+
+// restoreOptions описывает архивы бэкапа для восстановления
+func restoreOptions(cfg *config.Config, backupCfg *config.BackupConfig, identity string) restore.Options {
+	global := cfg.GlobalFor(backupCfg)
+	opts := restore.Options{
+		BackupDir:    global.BackupDir,
+		Subdirectory: backupCfg.Subdirectory,
+		Name:         backupCfg.Name,
+		Naming:       global.FileNaming(),
+		PlainName:    filepath.Base(backupCfg.OutputFile),
+		IdentityFile: identity,
+	}
+	if backupCfg.IsDump() {
+		opts.PlainName = backupCfg.DumpFileName()
+	} else if backupCfg.Stream && backupCfg.OutputFile == "" {
+		opts.PlainName = backupCfg.Name + ".out"
+	}
+	// Архивы, зашифрованные паролем, расшифровываются без дополнительных параметров
+	if backupCfg.Encryption != nil {
+		opts.Passphrase = backupCfg.Encryption.Passphrase
+	}
+	return opts
+}
+
+// originalLocation возвращает место, откуда были взяты данные бэкапа
+func originalLocation(backupCfg *config.BackupConfig) string {
+	if backupCfg.SourceDir != "" {
+		return backupCfg.SourceDir
+	}
+	return filepath.Dir(backupCfg.OutputFile)
+}
+
+func listArchives(opts restore.Options) int {
+	files, err := retention.FindBackupFiles(opts.BackupDir, opts.Subdirectory, opts.Name, opts.Naming)
+	if err != nil {
+		utils.PrintError("Failed to list archives: %v", err)
+		return 1
+	}
+
+	if len(files) == 0 {
+		fmt.Printf("No archives found for backup %s\n", opts.Name)
+		return 0
+	}
+
+	for _, file := range files {
+		size := "?"
+		if info, err := utils.StatArchive(file.Path); err == nil && info.IsDir() {
+			if dirSize, err := utils.DirSize(file.Path); err == nil {
+				size = utils.FormatSize(dirSize) + " (directory)"
+			}
+		} else if err == nil {
+			size = utils.FormatSize(info.Size())
+			if volumes := len(utils.VolumeParts(file.Path)); volumes > 0 {
+				size += fmt.Sprintf(" (%d volumes)", volumes)
+			}
+		}
+		fmt.Printf("%s  %s  %s\n", file.Time.Format("2006-01-02 15:04:05"), size, filepath.Base(file.Path))
+	}
+
+	return 0
+}
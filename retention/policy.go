@@ -5,45 +5,92 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
+	"goback/checksum"
+	"goback/encryption"
+	"goback/metadata"
 	"goback/utils"
 )
 
 type RetentionPolicy struct {
-	Daily   int
-	Weekly  int
-	Monthly int
-	Yearly  int
+	// KeepLast - последние архивы, которые сохраняются независимо от периодов
+	KeepLast int
+	Hourly   int
+	Daily    int
+	Weekly   int
+	Monthly  int
+	Yearly   int
+	// MaxTotalSize - предел суммарного размера архивов бэкапа в байтах (0 - без предела).
+	// Применяется после календарных правил и удаляет самые старые из оставшихся архивов
+	MaxTotalSize int64
 }
 
 type BackupFile struct {
+	// Path - путь к архиву или ключ объекта в destination
 	Path string
 	Time time.Time
+	// Size - размер объекта в destination; для локальных архивов 0, размер
+	// определяется по файлу только для max_total_size
+	Size int64
 }
 
-// ApplyRetention применяет политику хранения к бэкапам
-func ApplyRetention(backupDir, subdirectory, backupName string, policy RetentionPolicy) error {
+// ApplyRetention применяет политику хранения к бэкапам и возвращает пути удаленных файлов.
+// Архивы, для которых held возвращает true, не удаляются
+func ApplyRetention(backupDir, subdirectory, backupName string, naming utils.Naming, policy RetentionPolicy, held func(BackupFile) bool) ([]string, error) {
 	backupPath := filepath.Join(backupDir, subdirectory)
 	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
-		return nil // Директория не существует, нечего чистить
+		return nil, nil // Директория не существует, нечего чистить
 	}
 
 	// Получаем все файлы бэкапов, фильтруя по имени бэкапа
-	files, err := getBackupFiles(backupPath, backupName)
+	files, err := getBackupFiles(backupPath, backupName, naming)
 	if err != nil {
-		return fmt.Errorf("failed to get backup files: %w", err)
+		return nil, fmt.Errorf("failed to get backup files: %w", err)
 	}
 
 	if len(files) == 0 {
-		return nil
+		return nil, nil
+	}
+
+	// Удаляем файлы, которые не нужно сохранять
+	return RemoveFiles(Plan(files, policy, held)), nil
+}
+
+// RemoveFiles удаляет архивы вместе с их контрольными суммами и возвращает пути
+// удаленных; ошибки удаления выводятся как предупреждения
+func RemoveFiles(files []BackupFile) []string {
+	var removed []string
+	for _, file := range files {
+		// Бэкапы с format: directory удаляются вместе с содержимым, разбитые
+		// архивы - со всеми томами
+		if err := utils.RemoveArchive(file.Path); err != nil {
+			fmt.Printf("Warning: failed to remove old backup %s: %v\n", file.Path, err)
+		} else {
+			fmt.Printf("Removed old backup: %s\n", filepath.Base(file.Path))
+			removed = append(removed, file.Path)
+			if err := checksum.Forget(file.Path); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			if err := encryption.ForgetKeyRecord(file.Path); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			if err := metadata.ForgetArchive(file.Path); err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+		}
 	}
 
-	// Определяем файлы для сохранения
+	return removed
+}
+
+// Plan возвращает архивы из files, которые политика удалит, ничего не удаляя.
+// Архивы, для которых held возвращает true, не удаляются, но учитываются в max_total_size
+func Plan(files []BackupFile, policy RetentionPolicy, held func(BackupFile) bool) []BackupFile {
 	toKeep := determineFilesToKeep(files, policy)
 
-	// Удаляем файлы, которые не нужно сохранять
+	var result []BackupFile
+	var survivors []BackupFile
 	for _, file := range files {
 		shouldKeep := false
 		for _, keepFile := range toKeep {
@@ -53,53 +100,120 @@ func ApplyRetention(backupDir, subdirectory, backupName string, policy Retention
 			}
 		}
 
-		if !shouldKeep {
-			if err := os.Remove(file.Path); err != nil {
-				fmt.Printf("Warning: failed to remove old backup %s: %v\n", file.Path, err)
-			} else {
-				fmt.Printf("Removed old backup: %s\n", filepath.Base(file.Path))
-			}
+		if !shouldKeep && (held == nil || !held(file)) {
+			result = append(result, file)
+		} else {
+			survivors = append(survivors, file)
+		}
+	}
+
+	if policy.MaxTotalSize <= 0 || len(survivors) == 0 {
+		return result
+	}
+
+	// Предел размера: удаляем самые старые из оставшихся, пока сумма больше предела.
+	// Самый новый архив не удаляется никогда, даже если он один больше предела
+	sort.Slice(survivors, func(i, j int) bool {
+		return survivors[i].Time.Before(survivors[j].Time)
+	})
+	sizes := make([]int64, len(survivors))
+	var total int64
+	for i, file := range survivors {
+		sizes[i] = file.Size
+		if sizes[i] == 0 {
+			sizes[i] = fileSize(file.Path)
 		}
+		total += sizes[i]
+	}
+	for i, file := range survivors[:len(survivors)-1] {
+		if total <= policy.MaxTotalSize {
+			break
+		}
+		if held != nil && held(file) {
+			continue
+		}
+		result = append(result, file)
+		total -= sizes[i]
 	}
 
-	return nil
+	return result
 }
 
-func getBackupFiles(dir, backupName string) ([]BackupFile, error) {
-	entries, err := os.ReadDir(dir)
+// fileSize возвращает размер архива или суммарный размер файлов бэкапа
+// с format: directory (или всех томов разбитого архива); недоступные файлы
+// считаются пустыми
+func fileSize(path string) int64 {
+	info, err := utils.StatArchive(path)
+	if err != nil {
+		return 0
+	}
+	if !info.IsDir() {
+		return info.Size()
+	}
+
+	size, _ := utils.DirSize(path)
+	return size
+}
+
+// FindBackupFiles возвращает архивы бэкапа, отсортированные от старых к новым
+func FindBackupFiles(backupDir, subdirectory, backupName string, naming utils.Naming) ([]BackupFile, error) {
+	backupPath := filepath.Join(backupDir, subdirectory)
+	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	files, err := getBackupFiles(backupPath, backupName, naming)
 	if err != nil {
 		return nil, err
 	}
 
-	// Префикс для фильтрации файлов по имени бэкапа
-	namePrefix := backupName + "-"
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Time.Before(files[j].Time)
+	})
+
+	return files, nil
+}
+
+// MatchBackupFile проверяет, что файл является архивом бэкапа backupName,
+// и возвращает дату его создания из имени по стратегии naming
+func MatchBackupFile(filename, backupName string, naming utils.Naming) (time.Time, bool) {
+	// Архивы в процессе записи и sidecar-файлы (суммы, ключи, описание) не считаются бэкапами
+	if utils.IsTempFile(filename) || checksum.IsManifestFile(filename) || encryption.IsKeysFile(filename) || metadata.IsArchiveFile(filename) {
+		return time.Time{}, false
+	}
+
+	t, err := naming.ParseDate(filename, backupName)
+	if err != nil {
+		// Пропускаем файлы, из которых нельзя извлечь дату
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+func getBackupFiles(dir, backupName string, naming utils.Naming) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
 
 	var files []BackupFile
 	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		// Фильтруем файлы по префиксу имени бэкапа
+		// Директории с датой в имени - бэкапы с format: directory
 		entryName := entry.Name()
-		// Убираем расширение для проверки префикса
-		baseName := entryName
-		if idx := strings.LastIndex(entryName, "."); idx != -1 {
-			baseName = entryName[:idx]
+		// Разбитый на тома архив - один бэкап, его представляет первый том
+		if archive, n, ok := utils.ParseVolume(entryName); ok {
+			if n != 1 {
+				continue
+			}
+			entryName = archive
 		}
-
-		// Проверяем, что имя файла начинается с {backupName}-
-		if !strings.HasPrefix(baseName, namePrefix) {
+		t, ok := MatchBackupFile(entryName, backupName, naming)
+		if !ok {
 			continue
 		}
 
 		path := filepath.Join(dir, entryName)
-		t, err := utils.ParseDateFromFilename(entryName)
-		if err != nil {
-			// Пропускаем файлы, из которых нельзя извлечь дату
-			continue
-		}
-
 		files = append(files, BackupFile{
 			Path: path,
 			Time: t,
@@ -114,14 +228,35 @@ func determineFilesToKeep(files []BackupFile, policy RetentionPolicy) []BackupFi
 		return files
 	}
 
+	reasons := KeepReasons(files, policy)
+
+	result := make([]BackupFile, 0, len(reasons))
+	for _, file := range files {
+		if _, keep := reasons[file.Path]; keep {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// KeepReasons возвращает для каждого сохраняемого файла (по пути) список уровней
+// политики, которые его удерживают (hourly, daily, weekly, monthly, yearly, last)
+func KeepReasons(files []BackupFile, policy RetentionPolicy) map[string][]string {
+	reasons := make(map[string][]string)
+	if len(files) == 0 {
+		return reasons
+	}
+
 	// Сортируем по времени (от старых к новым)
 	sort.Slice(files, func(i, j int) bool {
 		return files[i].Time.Before(files[j].Time)
 	})
 
-	var toKeep []BackupFile
-
 	// Группируем по периодам
+	hourlyAnchors := getAnchors(files, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	})
 	dailyAnchors := getAnchors(files, func(t time.Time) time.Time {
 		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 	})
@@ -141,23 +276,37 @@ func determineFilesToKeep(files []BackupFile, policy RetentionPolicy) []BackupFi
 	})
 
 	// Берем N последних якорных точек каждого типа
-	toKeep = append(toKeep, getLastN(dailyAnchors, policy.Daily)...)
-	toKeep = append(toKeep, getLastN(weeklyAnchors, policy.Weekly)...)
-	toKeep = append(toKeep, getLastN(monthlyAnchors, policy.Monthly)...)
-	toKeep = append(toKeep, getLastN(yearlyAnchors, policy.Yearly)...)
+	tiers := []struct {
+		name    string
+		anchors []BackupFile
+		count   int
+	}{
+		{"hourly", hourlyAnchors, policy.Hourly},
+		{"daily", dailyAnchors, policy.Daily},
+		{"weekly", weeklyAnchors, policy.Weekly},
+		{"monthly", monthlyAnchors, policy.Monthly},
+		{"yearly", yearlyAnchors, policy.Yearly},
+	}
 
-	// Убираем дубликаты
-	unique := make(map[string]BackupFile)
-	for _, file := range toKeep {
-		unique[file.Path] = file
+	for _, tier := range tiers {
+		for _, file := range getLastN(tier.anchors, tier.count) {
+			reasons[file.Path] = append(reasons[file.Path], tier.name)
+		}
 	}
 
-	result := make([]BackupFile, 0, len(unique))
-	for _, file := range unique {
-		result = append(result, file)
+	// Несколько архивов за один час или день сводятся к одному якорю, поэтому
+	// последние keep_last архивов сохраняются отдельно
+	if policy.KeepLast > 0 {
+		start := len(files) - policy.KeepLast
+		if start < 0 {
+			start = 0
+		}
+		for _, file := range files[start:] {
+			reasons[file.Path] = append(reasons[file.Path], "last")
+		}
 	}
 
-	return result
+	return reasons
 }
 
 // getAnchors возвращает последний бэкап для каждого периода
@@ -166,7 +315,7 @@ func getAnchors(files []BackupFile, periodFunc func(time.Time) time.Time) []Back
 
 	for _, file := range files {
 		periodStart := periodFunc(file.Time)
-		periodKey := periodStart.Format("2006-01-02")
+		periodKey := periodStart.Format("2006-01-02T15")
 		if existing, exists := anchors[periodKey]; !exists || file.Time.After(existing.Time) {
 			anchors[periodKey] = file
 		}
@@ -196,4 +345,3 @@ func getLastN(files []BackupFile, n int) []BackupFile {
 
 	return files[len(files)-n:]
 }
-
@@ -0,0 +1,69 @@
+package retention
+
+import (
+	"sort"
+	"time"
+)
+
+// Simulation - результат прогона политики хранения на синтетических датах
+type Simulation struct {
+	// Created - сколько архивов было бы создано за период
+	Created int
+	// Deleted - сколько архивов retention удалил бы за период
+	Deleted int
+	// MaxKept - максимальное число архивов, хранившихся одновременно
+	MaxKept int
+	// Kept - архивы, оставшиеся в конце периода, с уровнями политики
+	Kept []SimulatedFile
+}
+
+type SimulatedFile struct {
+	Time  time.Time
+	Tiers []string
+}
+
+// Simulate повторяет то, что делает каждый запуск бэкапа: создает архив в момент
+// из schedule и сразу применяет политику. existing - архивы, которые уже есть на диске
+func Simulate(policy RetentionPolicy, existing []time.Time, schedule []time.Time) Simulation {
+	var sim Simulation
+
+	files := make([]BackupFile, 0, len(existing)+len(schedule))
+	for _, t := range existing {
+		files = append(files, simulatedFile(t))
+	}
+
+	for _, t := range schedule {
+		files = append(files, simulatedFile(t))
+		sim.Created++
+
+		kept := determineFilesToKeep(files, policy)
+		sim.Deleted += len(files) - len(kept)
+		files = kept
+
+		if len(files) > sim.MaxKept {
+			sim.MaxKept = len(files)
+		}
+	}
+
+	reasons := KeepReasons(files, policy)
+	for _, file := range files {
+		sim.Kept = append(sim.Kept, SimulatedFile{
+			Time:  file.Time,
+			Tiers: reasons[file.Path],
+		})
+	}
+
+	sort.Slice(sim.Kept, func(i, j int) bool {
+		return sim.Kept[i].Time.Before(sim.Kept[j].Time)
+	})
+
+	return sim
+}
+
+// simulatedFile создает запись без реального файла; путь нужен только как ключ
+func simulatedFile(t time.Time) BackupFile {
+	return BackupFile{
+		Path: t.Format(time.RFC3339Nano),
+		Time: t,
+	}
+}
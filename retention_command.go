@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"goback/backup"
+	"goback/retention"
+	"goback/utils"
+)
+
+// retentionCommand: goback retention simulate
+func retentionCommand(args []string) int {
+	fs := flag.NewFlagSet("retention simulate", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	days := fs.Int("days", 365, "Number of days to simulate")
+	every := fs.Duration("every", 0, "Interval between backup runs, overrides schedule (default: the backup's schedule, daily without one)")
+	fresh := fs.Bool("fresh", false, "Ignore archives that already exist in backup_dir")
+	verbose := fs.Bool("verbose", false, "Print every archive kept at the end of the period")
+	var backupNames flagArray
+	fs.Var(&backupNames, "backup", "Name of backup to simulate (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to simulate (short)")
+
+	// Флаги разбираются до действия: глобальные флаги (goback -c cfg.yaml retention
+	// simulate) приходят перед ним
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 || positional[0] != "simulate" {
+		utils.PrintError("Usage: goback retention simulate [--days 365] [--every 1h] [-b name]")
+		return 2
+	}
+	backupNames = append(backupNames, positional[1:]...)
+
+	if *days <= 0 || *every < 0 {
+		utils.PrintError("--days must be positive and --every cannot be negative")
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+
+	now := time.Now()
+	until := now.AddDate(0, 0, *days)
+	utils.PrintHeader("Simulating runs until %s", until.Format("2006-01-02"))
+
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+
+		global := cfg.GlobalFor(backupCfg)
+		policy := backup.EffectiveRetention(global, backupCfg)
+
+		var existing []time.Time
+		if !*fresh {
+			files, err := retention.FindBackupFiles(global.BackupDir, backupCfg.Subdirectory, backupCfg.Name, global.FileNaming())
+			if err != nil {
+				utils.PrintError("Failed to list archives of %s: %v", backupCfg.Name, err)
+				return 1
+			}
+			for _, file := range files {
+				existing = append(existing, file.Time)
+			}
+		}
+
+		// Запуски идут по cron schedule бэкапа; --every задает интервал явно, а без
+		// расписания бэкап запускается раз в сутки
+		var runs []time.Time
+		var cadence string
+		switch {
+		case *every > 0:
+			runs = backup.RunTimes("", *every, now, until)
+			cadence = "every " + every.String()
+		case backupCfg.Schedule != "":
+			runs = backup.RunTimes(backupCfg.Schedule, 24*time.Hour, now, until)
+			cadence = "schedule " + backupCfg.Schedule
+		default:
+			runs = backup.RunTimes("", 24*time.Hour, now, until)
+			cadence = "daily, no schedule"
+		}
+
+		sim := retention.Simulate(policy, existing, runs)
+
+		utils.PrintHeader("\n%s (keep_last=%d hourly=%d daily=%d weekly=%d monthly=%d yearly=%d)", backupCfg.Name, policy.KeepLast, policy.Hourly, policy.Daily, policy.Weekly, policy.Monthly, policy.Yearly)
+		fmt.Printf("  Runs: %d (%s)\n", len(runs), cadence)
+		if policy.MaxTotalSize > 0 {
+			// Размер будущих архивов неизвестен, поэтому предел не моделируется
+			fmt.Printf("  max_total_size=%s is not simulated: the cap can only remove more archives\n", utils.FormatSize(policy.MaxTotalSize))
+		}
+		fmt.Printf("  Existing archives: %d\n", len(existing))
+		fmt.Printf("  Created: %d, deleted: %d\n", sim.Created, sim.Deleted)
+		fmt.Printf("  Max archives kept at once: %d\n", sim.MaxKept)
+		fmt.Printf("  Kept at the end: %d\n", len(sim.Kept))
+
+		if len(sim.Kept) > 0 {
+			fmt.Printf("  Oldest kept: %s\n", sim.Kept[0].Time.Format("2006-01-02 15:04"))
+		}
+
+		if *verbose {
+			for _, file := range sim.Kept {
+				fmt.Printf("    %s  %s\n", file.Time.Format("2006-01-02 15:04"), strings.Join(file.Tiers, ", "))
+			}
+		}
+
+		if len(sim.Kept) == 0 {
+			utils.PrintError("  Policy keeps no archives!")
+		}
+	}
+
+	return 0
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
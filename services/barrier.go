@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultBarrierTimeout - время, после которого ФС размораживается автоматически
+const DefaultBarrierTimeout = 10 * time.Minute
+
+// Barrier сбрасывает буферы ФС источника (sync) или замораживает ее (fsfreeze)
+// на время копирования, чтобы получить согласованный снимок данных
+type Barrier struct {
+	mode       string
+	mountpoint string
+	timeout    time.Duration
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	frozen bool
+}
+
+// NewBarrier создает барьер для ФС, на которой находится path
+func NewBarrier(mode, path string, timeout time.Duration) (*Barrier, error) {
+	if mode != "sync" && mode != "fsfreeze" {
+		return nil, fmt.Errorf("unsupported barrier mode: %s", mode)
+	}
+	if timeout <= 0 {
+		timeout = DefaultBarrierTimeout
+	}
+
+	b := &Barrier{mode: mode, timeout: timeout}
+	if mode == "fsfreeze" {
+		mountpoint, err := mountPoint(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find mount point of %s: %w", path, err)
+		}
+		// Замороженный корень блокирует сам goback, логи и большую часть системы
+		if mountpoint == "/" {
+			return nil, fmt.Errorf("refusing to freeze the root filesystem (%s is not on a separate mount)", path)
+		}
+		b.mountpoint = mountpoint
+	}
+
+	return b, nil
+}
+
+// Mountpoint возвращает замораживаемую точку монтирования (пусто для sync)
+func (b *Barrier) Mountpoint() string {
+	return b.mountpoint
+}
+
+// Freezes проверяет, что запись в path заблокируется, пока ФС заморожена
+func (b *Barrier) Freezes(path string) bool {
+	if b.mode != "fsfreeze" {
+		return false
+	}
+	return sameFilesystem(b.mountpoint, existingParent(path))
+}
+
+// Engage сбрасывает буферы и в режиме fsfreeze замораживает ФС. Если Release
+// не будет вызван за timeout, ФС размораживается автоматически
+func (b *Barrier) Engage() error {
+	fmt.Printf("Syncing filesystems...\n")
+	if err := run("sync"); err != nil {
+		return fmt.Errorf("failed to sync filesystems: %w", err)
+	}
+
+	if b.mode != "fsfreeze" {
+		return nil
+	}
+
+	fmt.Printf("Freezing filesystem %s (auto-unfreeze after %s)...\n", b.mountpoint, b.timeout)
+	if err := run("fsfreeze", "--freeze", b.mountpoint); err != nil {
+		return fmt.Errorf("failed to freeze %s: %w", b.mountpoint, err)
+	}
+
+	b.mu.Lock()
+	b.frozen = true
+	b.timer = time.AfterFunc(b.timeout, func() {
+		fmt.Printf("Warning: filesystem %s is still frozen after %s, unfreezing it; the copy may not be consistent\n", b.mountpoint, b.timeout)
+		if err := b.unfreeze(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	})
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Release размораживает ФС; повторный вызов ничего не делает
+func (b *Barrier) Release() error {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.mu.Unlock()
+
+	return b.unfreeze()
+}
+
+func (b *Barrier) unfreeze() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.frozen {
+		return nil
+	}
+
+	fmt.Printf("Unfreezing filesystem %s...\n", b.mountpoint)
+	if err := run("fsfreeze", "--unfreeze", b.mountpoint); err != nil {
+		return fmt.Errorf("failed to unfreeze %s: %w", b.mountpoint, err)
+	}
+	b.frozen = false
+
+	return nil
+}
+
+// existingParent возвращает ближайший существующий путь (файл может еще не существовать)
+func existingParent(path string) string {
+	path, _ = filepath.Abs(path)
+	for {
+		if _, err := deviceID(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func sameFilesystem(a, b string) bool {
+	devA, err := deviceID(a)
+	if err != nil {
+		return false
+	}
+	devB, err := deviceID(b)
+	if err != nil {
+		return false
+	}
+	return devA == devB
+}
+
+// mountPoint поднимается от path вверх, пока родитель находится на том же устройстве
+func mountPoint(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	dev, err := deviceID(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		parentDev, err := deviceID(parent)
+		if err != nil || parentDev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
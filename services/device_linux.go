@@ -0,0 +1,14 @@
+//go:build linux
+
+package services
+
+import "syscall"
+
+// deviceID возвращает номер устройства, на котором находится path
+func deviceID(path string) (uint64, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Dev, nil
+}
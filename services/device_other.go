@@ -0,0 +1,10 @@
+//go:build !linux
+
+package services
+
+import "errors"
+
+// deviceID не поддерживается вне Linux (fsfreeze есть только в Linux)
+func deviceID(path string) (uint64, error) {
+	return 0, errors.New("filesystem freeze is only supported on Linux")
+}
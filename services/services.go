@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Service - сервис, который останавливается на время чтения данных бэкапа
+type Service interface {
+	// Stop останавливает сервис; false означает, что сервис и так не работал
+	// и запускать его после бэкапа не нужно
+	Stop() (bool, error)
+	Start() error
+	String() string
+}
+
+// New создает сервис по типу: systemd (имя юнита) или compose (директория
+// проекта или путь к docker-compose.yml)
+func New(serviceType, name string) (Service, error) {
+	switch strings.ToLower(serviceType) {
+	case "systemd":
+		return &systemdUnit{unit: name}, nil
+	case "compose":
+		return &composeProject{project: name}, nil
+	default:
+		return nil, fmt.Errorf("unsupported service type: %s", serviceType)
+	}
+}
+
+// Quiesce останавливает сервисы по порядку и возвращает функцию, которая
+// запускает остановленные в обратном порядке. Если остановить сервис не удалось,
+// уже остановленные запускаются сразу
+func Quiesce(list []Service) (func() error, error) {
+	var stopped []Service
+
+	resume := func() error {
+		var failed []string
+		for i := len(stopped) - 1; i >= 0; i-- {
+			fmt.Printf("Starting service %s...\n", stopped[i])
+			if err := stopped[i].Start(); err != nil {
+				failed = append(failed, fmt.Sprintf("%s (%v)", stopped[i], err))
+			}
+		}
+		stopped = nil
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to start service(s): %s", strings.Join(failed, "; "))
+		}
+		return nil
+	}
+
+	for _, service := range list {
+		fmt.Printf("Stopping service %s...\n", service)
+		wasRunning, err := service.Stop()
+		if err != nil {
+			stopErr := fmt.Errorf("failed to stop service %s: %w", service, err)
+			if resumeErr := resume(); resumeErr != nil {
+				return nil, fmt.Errorf("%w; %v", stopErr, resumeErr)
+			}
+			return nil, stopErr
+		}
+		if wasRunning {
+			stopped = append(stopped, service)
+		} else {
+			fmt.Printf("Service %s is not running, it will not be started after backup\n", service)
+		}
+	}
+
+	return resume, nil
+}
+
+type systemdUnit struct {
+	unit string
+}
+
+func (s *systemdUnit) String() string {
+	return "systemd:" + s.unit
+}
+
+func (s *systemdUnit) Stop() (bool, error) {
+	// is-active возвращает ненулевой код для остановленного юнита
+	if err := exec.Command("systemctl", "is-active", "--quiet", s.unit).Run(); err != nil {
+		return false, nil
+	}
+	return true, run("systemctl", "stop", s.unit)
+}
+
+func (s *systemdUnit) Start() error {
+	return run("systemctl", "start", s.unit)
+}
+
+type composeProject struct {
+	project string
+}
+
+func (c *composeProject) String() string {
+	return "compose:" + c.project
+}
+
+func (c *composeProject) args(command string) []string {
+	if info, err := os.Stat(c.project); err == nil && !info.IsDir() {
+		return []string{"compose", "-f", c.project, command}
+	}
+	return []string{"compose", "--project-directory", c.project, command}
+}
+
+func (c *composeProject) Stop() (bool, error) {
+	// Проект без запущенных контейнеров не останавливаем и не запускаем
+	output, err := exec.Command("docker", append(c.args("ps"), "-q")...).Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list containers: %w", err)
+	}
+	if strings.TrimSpace(string(output)) == "" {
+		return false, nil
+	}
+	return true, run("docker", c.args("stop")...)
+}
+
+func (c *composeProject) Start() error {
+	return run("docker", c.args("start")...)
+}
+
+func run(name string, args ...string) error {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// NewVolumeContainers возвращает запущенные контейнеры, подключающие том Docker:
+// на время копирования они приостанавливаются (pause) или останавливаются (stop)
+func NewVolumeContainers(volume, action string) (Service, error) {
+	if action != "pause" && action != "stop" {
+		return nil, fmt.Errorf("unsupported container action: %s", action)
+	}
+	return &volumeContainers{volume: volume, action: action}, nil
+}
+
+type volumeContainers struct {
+	volume string
+	action string
+	// ids - контейнеры, приостановленные или остановленные в Stop
+	ids []string
+}
+
+func (v *volumeContainers) String() string {
+	return "containers of volume " + v.volume
+}
+
+func (v *volumeContainers) Stop() (bool, error) {
+	// Уже приостановленные контейнеры не трогаем, чтобы не запустить их после бэкапа
+	output, err := exec.Command("docker", "ps", "-q", "--filter", "volume="+v.volume, "--filter", "status=running").Output()
+	if err != nil {
+		return false, fmt.Errorf("failed to list containers: %w", err)
+	}
+	v.ids = strings.Fields(string(output))
+	if len(v.ids) == 0 {
+		return false, nil
+	}
+	return true, run("docker", append([]string{v.action}, v.ids...)...)
+}
+
+func (v *volumeContainers) Start() error {
+	command := "start"
+	if v.action == "pause" {
+		command = "unpause"
+	}
+	return run("docker", append([]string{command}, v.ids...)...)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goback/restore"
+	"goback/utils"
+)
+
+// showCommand: goback show <backup-name> [archive] - список файлов архива с
+// размерами и датами изменения без распаковки (проверить exclude_patterns до
+// того, как понадобится восстановление)
+func showCommand(args []string) int {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	fromDir := fs.String("from", "", "Directory with archives (default: backup_dir from config)")
+	archiveName := fs.String("archive", "", "Archive to show: file name, path or timestamp YYYYmmddHHMMSS (default: latest)")
+	identity := fs.String("identity", os.Getenv("GOBACK_IDENTITY"), "age identity file(s) for encrypted archives, comma-separated (default: $GOBACK_IDENTITY)")
+	passphraseFile := fs.String("passphrase-file", "", "File with the age passphrase (default: passphrase from the config)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 2 && *archiveName == "" {
+		*archiveName, positional = positional[1], positional[:1]
+	}
+	if len(positional) != 1 {
+		utils.PrintError("Usage: goback show [-c config.yaml] <backup-name> [archive] | goback show <path/to/archive>")
+		return 2
+	}
+
+	var opts restore.Options
+	archivePath := ""
+	if isArchivePath(positional[0]) && *archiveName == "" {
+		// Архив указан путем - конфигурация не нужна
+		archivePath = positional[0]
+		opts.IdentityFile = *identity
+	} else {
+		cfg := loadConfigOrExit(*configPath)
+		backupCfg, err := findBackupConfig(cfg, positional[0])
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		opts = restoreOptions(cfg, backupCfg, *identity)
+		if *fromDir != "" {
+			opts.BackupDir = *fromDir
+		}
+		archive, err := restore.FindArchive(opts.BackupDir, opts.Subdirectory, opts.Name, *archiveName, opts.Naming)
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+		archivePath = archive.Path
+	}
+	if opts.PlainName == "" {
+		// Имя файла внутри однофайлового архива - имя архива без расширений
+		name := filepath.Base(archivePath)
+		name = strings.TrimSuffix(name, utils.EncryptionExtension(name))
+		opts.PlainName = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if *passphraseFile != "" {
+		data, err := os.ReadFile(*passphraseFile)
+		if err != nil {
+			utils.PrintError("Failed to read passphrase file: %v", err)
+			return 1
+		}
+		opts.Passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	return showContents(opts, archivePath)
+}
+
+// showContents выводит записи архива в формате tar -tv и итог
+func showContents(opts restore.Options, archivePath string) int {
+	utils.PrintHeader("%s", filepath.Base(archivePath))
+
+	var files, total int64
+	err := opts.Contents(archivePath, func(entry restore.Entry) error {
+		name := entry.Name
+		if entry.Linkname != "" {
+			name += " -> " + entry.Linkname
+		}
+		fmt.Printf("%s  %10s  %s  %s\n", entry.Mode, utils.FormatSize(entry.Size), entry.ModTime.Format("2006-01-02 15:04"), name)
+		if entry.Mode.IsRegular() {
+			files++
+			total += entry.Size
+		}
+		return nil
+	})
+	if err != nil {
+		utils.PrintError("Failed to read archive: %v", err)
+		return 1
+	}
+
+	fmt.Printf("\n%d file(s), %s uncompressed\n", files, utils.FormatSize(total))
+	return 0
+}
+
+// isArchivePath проверяет, что аргумент - путь к существующему архиву, а не имя бэкапа
+func isArchivePath(arg string) bool {
+	if !strings.ContainsRune(arg, filepath.Separator) && utils.DetectCompression(arg) == "none" {
+		return false
+	}
+	_, err := utils.StatArchive(arg)
+	return err == nil
+}
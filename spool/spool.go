@@ -0,0 +1,209 @@
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goback/utils"
+)
+
+// Job - отложенная загрузка архива в destination
+type Job struct {
+	ID          string `json:"id"`
+	Backup      string `json:"backup"`
+	Destination string `json:"destination"`
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum,omitempty"`
+	// RunID и JobID - запуск, создавший архив; сохраняются в метаданных объекта
+	RunID     string    `json:"run_id,omitempty"`
+	JobID     string    `json:"job_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Attempts - сколько раз загрузка уже завершилась ошибкой
+	Attempts    int       `json:"attempts,omitempty"`
+	LastAttempt time.Time `json:"last_attempt,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Keys - идентификаторы ключей, которыми зашифрован архив
+	Keys []string `json:"keys,omitempty"`
+}
+
+// Expired проверяет, что задача находится в очереди дольше maxAge (0 - без ограничения)
+func (j Job) Expired(maxAge time.Duration, now time.Time) bool {
+	return maxAge > 0 && now.Sub(j.CreatedAt) > maxAge
+}
+
+// Spool - персистентная очередь загрузок: для каждой задачи хранится
+// копия архива (<id>.data) и описание (<id>.json)
+type Spool struct {
+	dir string
+}
+
+func Open(dir string) *Spool {
+	return &Spool{dir: dir}
+}
+
+// Enqueue помещает файл в очередь и сохраняет задачу. При move=true файл
+// перемещается (промежуточный результат), иначе копируется (исходный архив остается).
+// Тома разбитого архива source сохраняются томами DataPath
+func (s *Spool) Enqueue(source string, job Job, move bool) (Job, error) {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return job, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+	if job.ID == "" {
+		job.ID = fmt.Sprintf("%d-%s-%s", job.CreatedAt.UnixNano(), sanitize(job.Backup), sanitize(job.Destination))
+	}
+
+	transfer := copyFile
+	if move {
+		transfer = moveFile
+	}
+
+	sources, targets := []string{source}, []string{s.DataPath(job)}
+	if parts := utils.VolumeParts(source); len(parts) > 0 {
+		sources, targets = parts, nil
+		for i := range parts {
+			targets = append(targets, utils.VolumeName(s.DataPath(job), i+1))
+		}
+	}
+	for i := range sources {
+		if err := transfer(sources[i], targets[i]); err != nil {
+			utils.RemoveArchive(s.DataPath(job))
+			return job, fmt.Errorf("failed to spool archive: %w", err)
+		}
+	}
+
+	if err := s.Save(job); err != nil {
+		utils.RemoveArchive(s.DataPath(job))
+		return job, err
+	}
+
+	return job, nil
+}
+
+// Save записывает описание задачи (атомарно)
+func (s *Spool) Save(job Job) error {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode spool job: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(s.jobPath(job), data, 0600); err != nil {
+		return fmt.Errorf("failed to write spool job: %w", err)
+	}
+
+	return nil
+}
+
+// Jobs возвращает задачи очереди от старых к новым
+func (s *Spool) Jobs() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var jobs []Job
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			fmt.Printf("Warning: skipping corrupted spool job %s: %v\n", entry.Name(), err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+
+	return jobs, nil
+}
+
+// DataPath возвращает путь к архиву задачи
+func (s *Spool) DataPath(job Job) string {
+	return filepath.Join(s.dir, job.ID+".data")
+}
+
+// Remove удаляет задачу вместе с архивом (и его томами)
+func (s *Spool) Remove(job Job) error {
+	if err := utils.RemoveArchive(s.DataPath(job)); err != nil {
+		return err
+	}
+	if err := os.Remove(s.jobPath(job)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Spool) jobPath(job Job) string {
+	return filepath.Join(s.dir, job.ID+".json")
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// moveFile переименовывает файл, а если источник на другой ФС - копирует и удаляет
+func moveFile(source, destination string) error {
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+
+	if err := copyFile(source, destination); err != nil {
+		return err
+	}
+
+	return os.Remove(source)
+}
+
+func copyFile(source, destination string) error {
+	srcFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		os.Remove(destination)
+		return err
+	}
+
+	if err := dstFile.Close(); err != nil {
+		os.Remove(destination)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"goback/backup"
+	"goback/config"
+	"goback/history"
+	"goback/lock"
+	"goback/utils"
+)
+
+// backupStatus - состояние бэкапа для goback status
+type backupStatus struct {
+	Backup string `json:"backup"`
+	// Running - блокировка бэкапа, которую держит выполняющийся запуск
+	Running     *lock.Info      `json:"running,omitempty"`
+	LastRun     *history.Record `json:"last_run,omitempty"`
+	LastSuccess *history.Record `json:"last_success,omitempty"`
+	// Archives и Newest - завершенные архивы в backup_dir
+	Archives int        `json:"archives"`
+	Newest   *time.Time `json:"newest,omitempty"`
+	Size     int64      `json:"size"`
+}
+
+// statusCommand: goback status [backup-name...] [--json] - идет ли сейчас бэкап,
+// итог последнего запуска, последний успех и архивы в backup_dir. Команда только
+// читает файлы блокировок и состояние и сама блокировку не берет, поэтому ее можно
+// вызывать из мониторинга во время долгого бэкапа
+func statusCommand(args []string) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	asJSON := fs.Bool("json", false, "Print the status as JSON")
+
+	backupNames, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+	records, err := loadHistory(cfg)
+	if err != nil {
+		utils.PrintError("%v", err)
+		return 1
+	}
+
+	var result []backupStatus
+	failed := 0
+	for i := range cfg.Backups {
+		backupCfg := &cfg.Backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+
+		status, err := readBackupStatus(cfg.GlobalFor(backupCfg), backupCfg, records)
+		if err != nil {
+			utils.PrintError("%s: %v", backupCfg.Name, err)
+			failed++
+			continue
+		}
+		result = append(result, status)
+	}
+
+	if len(backupNames) > 0 && len(result) == 0 && failed == 0 {
+		utils.PrintError("No backups matching %v", backupNames)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			utils.PrintError("Failed to write status: %v", err)
+			return 1
+		}
+	} else {
+		printStatus(cfg, result)
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readBackupStatus собирает состояние бэкапа. Архивы, которые еще пишутся (*.tmp),
+// и архивы, удаленные retention во время сканирования, пропускаются
+func readBackupStatus(global *config.GlobalConfig, backupCfg *config.BackupConfig, records []history.Record) (backupStatus, error) {
+	status := backupStatus{Backup: backupCfg.Name}
+	status.Running = heldLock(global.BackupLockPath(backupCfg.Name))
+	if record, ok := history.LastRun(records, backupCfg.Name); ok {
+		status.LastRun = &record
+	}
+	if record, ok := history.LastSuccess(records, backupCfg.Name); ok {
+		status.LastSuccess = &record
+	}
+
+	items, err := backup.ListArchives(global, backupCfg, false)
+	if err != nil {
+		return status, err
+	}
+	status.Archives = len(items)
+	for _, item := range items {
+		status.Size += item.Size
+		if status.Newest == nil || item.Time.After(*status.Newest) {
+			newest := item.Time
+			status.Newest = &newest
+		}
+	}
+	return status, nil
+}
+
+// heldLock возвращает владельца блокировки path; nil - блокировки нет или она
+// брошена завершившимся процессом
+func heldLock(path string) *lock.Info {
+	info, err := lock.Read(path)
+	if err != nil || lock.Stale(info) {
+		return nil
+	}
+	return &info
+}
+
+func printStatus(cfg *config.Config, result []backupStatus) {
+	now := time.Now()
+	if run := heldLock(cfg.Global.LockPath()); run != nil {
+		fmt.Printf("Run in progress: %s\n", run)
+	}
+
+	for _, status := range result {
+		utils.PrintHeader("%s", status.Backup)
+		if status.Running != nil {
+			fmt.Printf("  running:       %s\n", status.Running)
+		}
+
+		switch {
+		case status.LastRun == nil:
+			fmt.Printf("  last run:      never\n")
+		case status.LastRun.Success:
+			fmt.Printf("  last run:      success  %s (%s ago)\n", status.LastRun.Time.Format("2006-01-02 15:04:05"), formatAge(now.Sub(status.LastRun.Time)))
+		default:
+			utils.PrintError("  last run:      failure  %s (%s ago): %s", status.LastRun.Time.Format("2006-01-02 15:04:05"), formatAge(now.Sub(status.LastRun.Time)), status.LastRun.Error)
+		}
+		if status.LastSuccess == nil {
+			fmt.Printf("  last success:  never\n")
+		} else if status.LastRun == nil || !status.LastRun.Success {
+			fmt.Printf("  last success:  %s (%s ago)\n", status.LastSuccess.Time.Format("2006-01-02 15:04:05"), formatAge(now.Sub(status.LastSuccess.Time)))
+		}
+
+		if status.Archives == 0 {
+			fmt.Printf("  local:         no archives\n")
+		} else {
+			fmt.Printf("  local:         %d archive(s), %s, newest %s (%s ago)\n", status.Archives, utils.FormatSize(status.Size), status.Newest.Format("2006-01-02 15:04"), formatAge(now.Sub(*status.Newest)))
+		}
+	}
+}
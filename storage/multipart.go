@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"goback/utils"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// DefaultPartSize - размер части при загрузке по частям
+	DefaultPartSize = 16 << 20
+	// MinPartSize - минимальный размер части в S3 (кроме последней)
+	MinPartSize = 5 << 20
+	// DefaultUploadConcurrency - сколько частей загружается одновременно
+	DefaultUploadConcurrency = 4
+	// maxParts - предел числа частей одной загрузки в S3
+	maxParts = 10000
+)
+
+// uploadParts загружает архив частями, concurrency частей одновременно. Для каждой
+// части заранее считаются MD5 и SHA-256: сервер проверяет их при приеме части
+// (Content-MD5 и подпись запроса), а после сборки объекта его ETag сверяется
+// с ожидаемым по MD5 частей. При ошибке незавершенная загрузка отменяется
+func (s *S3Storage) uploadParts(file *os.File, size int64, object string, metadata map[string]string) error {
+	partSize := s.partSize
+	if minSize := (size + maxParts - 1) / maxParts; partSize < minSize {
+		partSize = minSize
+	}
+	count := int((size + partSize - 1) / partSize)
+	workers := s.concurrency
+	if workers > count {
+		workers = count
+	}
+
+	core := &minio.Core{Client: s.client}
+	opts := minio.PutObjectOptions{ContentType: "application/octet-stream", UserMetadata: metadata}
+
+	// Таймаут попытки действует на всю загрузку, включая сборку объекта
+	attemptCtx, cancelAttempt := s.uploadContext()
+	defer cancelAttempt()
+
+	uploadID, err := core.NewMultipartUpload(attemptCtx, s.bucket, object, opts)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload to s3://%s/%s: %w", s.bucket, object, err)
+	}
+
+	ctx, cancel := context.WithCancel(attemptCtx)
+	defer cancel()
+
+	parts := make([]minio.CompletePart, count)
+	digests := make([][]byte, count)
+	var (
+		wg       sync.WaitGroup
+		failOnce sync.Once
+		firstErr error
+	)
+
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				part, digest, err := s.uploadPart(ctx, core, file, uploadID, object, index, partSize, size, workers)
+				if err != nil {
+					// Первая ошибка останавливает остальные части
+					failOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				parts[index] = part
+				digests[index] = digest
+			}
+		}()
+	}
+
+send:
+	for index := 0; index < count; index++ {
+		select {
+		case jobs <- index:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		s.abortUpload(core, object, uploadID)
+		return firstErr
+	}
+
+	info, err := core.CompleteMultipartUpload(attemptCtx, s.bucket, object, uploadID, parts, opts)
+	if err != nil {
+		s.abortUpload(core, object, uploadID)
+		return fmt.Errorf("failed to complete multipart upload to s3://%s/%s: %w", s.bucket, object, err)
+	}
+
+	// ETag составного объекта - MD5 от MD5 частей и число частей. Серверы с
+	// шифрованием на своей стороне возвращают ETag другого вида, его не сверяем
+	etag := strings.Trim(info.ETag, `"`)
+	if expected := multipartETag(digests); strings.Contains(etag, "-") && etag != expected {
+		return fmt.Errorf("uploaded object s3://%s/%s has ETag %s, expected %s", s.bucket, object, etag, expected)
+	}
+
+	return nil
+}
+
+// uploadPart загружает часть index архива и возвращает ее для сборки объекта
+// вместе с MD5
+func (s *S3Storage) uploadPart(ctx context.Context, core *minio.Core, file *os.File, uploadID, object string, index int, partSize, size int64, workers int) (minio.CompletePart, []byte, error) {
+	number := index + 1
+	offset := int64(index) * partSize
+	length := partSize
+	if offset+length > size {
+		length = size - offset
+	}
+
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), io.NewSectionReader(file, offset, length)); err != nil {
+		return minio.CompletePart{}, nil, fmt.Errorf("failed to read part %d: %w", number, err)
+	}
+	digest := md5Hash.Sum(nil)
+
+	reader := utils.NewThrottledReader(io.NewSectionReader(file, offset, length), s.partLimit(workers))
+	part, err := core.PutObjectPart(ctx, s.bucket, object, uploadID, number, reader, length, minio.PutObjectPartOptions{
+		Md5Base64: base64.StdEncoding.EncodeToString(digest),
+		Sha256Hex: hex.EncodeToString(sha256Hash.Sum(nil)),
+	})
+	if err != nil {
+		return minio.CompletePart{}, nil, fmt.Errorf("failed to upload part %d of s3://%s/%s: %w", number, s.bucket, object, err)
+	}
+
+	return minio.CompletePart{PartNumber: number, ETag: part.ETag}, digest, nil
+}
+
+// partLimit делит лимит скорости загрузки между одновременно загружаемыми частями
+func (s *S3Storage) partLimit(workers int) func() int64 {
+	return func() int64 {
+		limit := s.throttle.Effective(time.Now(), s.rateLimit)
+		if limit <= 0 {
+			return 0
+		}
+		if limit /= int64(workers); limit < 1 {
+			limit = 1
+		}
+		return limit
+	}
+}
+
+// abortUpload отменяет незавершенную загрузку, чтобы загруженные части не
+// хранились (и не оплачивались) в бакете
+func (s *S3Storage) abortUpload(core *minio.Core, object, uploadID string) {
+	if err := core.AbortMultipartUpload(context.Background(), s.bucket, object, uploadID); err != nil {
+		fmt.Printf("Warning: failed to abort multipart upload to s3://%s/%s: %v\n", s.bucket, object, err)
+	}
+}
+
+// multipartETag - ETag, который S3 присваивает объекту, собранному из частей
+func multipartETag(digests [][]byte) string {
+	hash := md5.New()
+	for _, digest := range digests {
+		hash.Write(digest)
+	}
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(hash.Sum(nil)), len(digests))
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"goback/utils"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Incomplete - след прерванной загрузки: недописанный файл или незавершенная
+// загрузка по частям
+type Incomplete struct {
+	Key string
+	// UploadID - идентификатор загрузки по частям (только S3)
+	UploadID string
+	Started  time.Time
+}
+
+// IncompleteCleaner - хранилище, в котором прерванные загрузки оставляют следы
+type IncompleteCleaner interface {
+	// Incomplete возвращает прерванные загрузки внутри prefix, начатые раньше olderThan назад
+	Incomplete(prefix string, olderThan time.Duration) ([]Incomplete, error)
+	// AbortIncomplete удаляет след прерванной загрузки
+	AbortIncomplete(item Incomplete) error
+}
+
+// Incomplete находит временные файлы Upload, которые не были переименованы
+func (s *LocalStorage) Incomplete(prefix string, olderThan time.Duration) ([]Incomplete, error) {
+	entries, err := os.ReadDir(filepath.Join(s.basePath, prefix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var result []Incomplete
+	for _, entry := range entries {
+		if entry.IsDir() || !utils.IsTempFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < olderThan {
+			continue
+		}
+		result = append(result, Incomplete{Key: filepath.Join(prefix, entry.Name()), Started: info.ModTime()})
+	}
+
+	return result, nil
+}
+
+func (s *LocalStorage) AbortIncomplete(item Incomplete) error {
+	return s.Delete(item.Key)
+}
+
+// Incomplete находит незавершенные загрузки по частям: их части хранятся
+// (и оплачиваются), пока загрузку не отменить
+func (s *S3Storage) Incomplete(prefix string, olderThan time.Duration) ([]Incomplete, error) {
+	listPrefix := s.objectName(prefix) + "/"
+
+	var result []Incomplete
+	for upload := range s.client.ListIncompleteUploads(context.Background(), s.bucket, listPrefix, false) {
+		if upload.Err != nil {
+			return nil, fmt.Errorf("failed to list incomplete uploads in s3://%s/%s: %w", s.bucket, listPrefix, upload.Err)
+		}
+		if time.Since(upload.Initiated) < olderThan {
+			continue
+		}
+		result = append(result, Incomplete{
+			Key:      path.Join(prefix, path.Base(upload.Key)),
+			UploadID: upload.UploadID,
+			Started:  upload.Initiated,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *S3Storage) AbortIncomplete(item Incomplete) error {
+	core := &minio.Core{Client: s.client}
+	if err := core.AbortMultipartUpload(context.Background(), s.bucket, s.objectName(item.Key), item.UploadID); err != nil {
+		return fmt.Errorf("failed to abort upload of s3://%s/%s: %w", s.bucket, s.objectName(item.Key), err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// permanentCodes - коды ошибок S3, которые не исправятся повторной попыткой:
+// неверные ключи, нет доступа, нет бакета
+var permanentCodes = map[string]bool{
+	"AccessDenied":          true,
+	"AllAccessDisabled":     true,
+	"InvalidAccessKeyId":    true,
+	"SignatureDoesNotMatch": true,
+	"NoSuchBucket":          true,
+	"InvalidBucketName":     true,
+	"AccountProblem":        true,
+	"InvalidObjectState":    true,
+}
+
+// Retryable сообщает, имеет ли смысл повторить загрузку после ошибки err.
+// Сетевые ошибки, таймауты, ответы 5xx, 408 и 429 - временные; ошибки
+// авторизации, отсутствующий бакет, прочие ответы 4xx и локальные ошибки
+// (нет архива, нет места, нет прав) - постоянные
+func Retryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) ||
+		errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, syscall.EROFS) {
+		return false
+	}
+
+	var response minio.ErrorResponse
+	if errors.As(err, &response) {
+		if permanentCodes[response.Code] {
+			return false
+		}
+		switch {
+		case response.StatusCode == http.StatusRequestTimeout, response.StatusCode == http.StatusTooManyRequests:
+			return true
+		case response.StatusCode >= 400 && response.StatusCode < 500:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"goback/utils"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// DefaultS3Endpoint используется, если endpoint не указан
+const DefaultS3Endpoint = "https://s3.amazonaws.com"
+
+type S3Options struct {
+	// Endpoint - URL S3-совместимого сервиса (например http://minio:9000)
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	// PathStyle включает path-style адресацию (нужна большинству self-hosted серверов)
+	PathStyle bool
+	// PartSize - размер части при загрузке по частям (по умолчанию DefaultPartSize);
+	// архивы не больше одной части загружаются одним запросом
+	PartSize int64
+	// Concurrency - сколько частей загружается одновременно (по умолчанию DefaultUploadConcurrency)
+	Concurrency int
+}
+
+type S3Storage struct {
+	client      *minio.Client
+	bucket      string
+	prefix      string
+	partSize    int64
+	concurrency int
+	rateLimit   int64
+	throttle    *utils.Throttle
+	timeout     time.Duration
+}
+
+func NewS3Storage(opts S3Options) (*S3Storage, error) {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultS3Endpoint
+	}
+
+	// Endpoint без схемы считаем https
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", opts.Endpoint, err)
+	}
+
+	// Без явных ключей используем стандартную цепочку: переменные окружения
+	// AWS_*/MINIO_*, ~/.aws/credentials, IAM роль
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+	if opts.AccessKey != "" || opts.SecretKey != "" {
+		creds = credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, "")
+	}
+
+	lookup := minio.BucketLookupAuto
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       u.Scheme == "https",
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+
+	return &S3Storage{
+		client:      client,
+		bucket:      opts.Bucket,
+		prefix:      strings.Trim(opts.Prefix, "/"),
+		partSize:    partSize,
+		concurrency: concurrency,
+	}, nil
+}
+
+// SetRateLimit ограничивает скорость загрузки (байт в секунду, 0 - без ограничения)
+func (s *S3Storage) SetRateLimit(bytesPerSecond int64) {
+	s.rateLimit = bytesPerSecond
+}
+
+// SetThrottle задает расписание лимитов скорости загрузки по времени суток;
+// вместе с SetRateLimit действует меньший из лимитов
+func (s *S3Storage) SetThrottle(throttle *utils.Throttle) {
+	s.throttle = throttle
+}
+
+// SetAttemptTimeout ограничивает время одной загрузки (0 - без ограничения)
+func (s *S3Storage) SetAttemptTimeout(timeout time.Duration) {
+	s.timeout = timeout
+}
+
+// uploadContext - контекст одной загрузки с учетом SetAttemptTimeout
+func (s *S3Storage) uploadContext() (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(context.Background(), s.timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+func (s *S3Storage) objectName(key string) string {
+	return path.Join(s.prefix, key)
+}
+
+// Upload загружает архив; metadata сохраняется как пользовательские метаданные
+// объекта (x-amz-meta-*)
+func (s *S3Storage) Upload(localPath, key string, metadata map[string]string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat archive: %w", err)
+	}
+
+	if info.Size() > s.partSize {
+		return s.uploadParts(file, info.Size(), s.objectName(key), metadata)
+	}
+
+	ctx, cancel := s.uploadContext()
+	defer cancel()
+
+	_, err = s.client.PutObject(ctx, s.bucket, s.objectName(key), s.throttle.Reader(file, s.rateLimit), info.Size(), minio.PutObjectOptions{
+		ContentType:  "application/octet-stream",
+		UserMetadata: metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to s3://%s/%s: %w", s.bucket, s.objectName(key), err)
+	}
+
+	return nil
+}
+
+// Delete удаляет объект; S3 не сообщает об ошибке для отсутствующего ключа
+func (s *S3Storage) Delete(key string) error {
+	if err := s.client.RemoveObject(context.Background(), s.bucket, s.objectName(key), minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove s3://%s/%s: %w", s.bucket, s.objectName(key), err)
+	}
+	return nil
+}
+
+func (s *S3Storage) List(prefix string) ([]Object, error) {
+	listPrefix := s.objectName(prefix) + "/"
+
+	var objects []Object
+	for object := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{
+		Prefix:    listPrefix,
+		Recursive: false,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", s.bucket, listPrefix, object.Err)
+		}
+
+		// Пропускаем "поддиректории" и незавершенные загрузки
+		name := path.Base(object.Key)
+		if strings.HasSuffix(object.Key, "/") || utils.IsTempFile(name) {
+			continue
+		}
+
+		objects = append(objects, Object{
+			Key:     path.Join(prefix, name),
+			Size:    object.Size,
+			ModTime: object.LastModified,
+		})
+	}
+
+	return objects, nil
+}
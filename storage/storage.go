@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"goback/utils"
+)
+
+// Storage - место назначения, куда доставляется готовый архив
+type Storage interface {
+	// Upload загружает файл как объект key; metadata сохраняется вместе
+	// с объектом там, где это поддерживается (S3)
+	Upload(localPath, key string, metadata map[string]string) error
+	// List возвращает объекты непосредственно внутри prefix (без рекурсии)
+	List(prefix string) ([]Object, error)
+	// Delete удаляет объект key; отсутствующий объект не считается ошибкой
+	Delete(key string) error
+}
+
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type LocalStorage struct {
+	basePath  string
+	rateLimit int64
+	throttle  *utils.Throttle
+}
+
+func NewLocalStorage(basePath string) *LocalStorage {
+	return &LocalStorage{basePath: basePath}
+}
+
+// SetRateLimit ограничивает скорость загрузки (байт в секунду, 0 - без ограничения)
+func (s *LocalStorage) SetRateLimit(bytesPerSecond int64) {
+	s.rateLimit = bytesPerSecond
+}
+
+// SetThrottle задает расписание лимитов скорости загрузки по времени суток;
+// вместе с SetRateLimit действует меньший из лимитов
+func (s *LocalStorage) SetThrottle(throttle *utils.Throttle) {
+	s.throttle = throttle
+}
+
+// Upload копирует архив в basePath/key; у файлов нет метаданных, metadata не сохраняется
+func (s *LocalStorage) Upload(localPath, key string, metadata map[string]string) error {
+	destination := filepath.Join(s.basePath, key)
+	if err := os.MkdirAll(filepath.Dir(destination), 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	srcFile, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer srcFile.Close()
+
+	// Пишем во временный файл и переименовываем, чтобы читатели destination
+	// никогда не видели недописанный архив
+	err = utils.WriteAtomic(destination, 0644, func(w io.Writer) error {
+		_, err := io.Copy(w, s.throttle.Reader(srcFile, s.rateLimit))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy archive: %w", err)
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) Delete(key string) error {
+	if err := os.Remove(filepath.Join(s.basePath, key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) List(prefix string) ([]Object, error) {
+	dir := filepath.Join(s.basePath, prefix)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var objects []Object
+	for _, entry := range entries {
+		// Пропускаем директории и файлы, которые еще записываются
+		if entry.IsDir() || utils.IsTempFile(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			// Файл мог быть удален между ReadDir и Info
+			continue
+		}
+
+		objects = append(objects, Object{
+			Key:     filepath.Join(prefix, entry.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"goback/backup"
+	"goback/spool"
+	"goback/utils"
+)
+
+// uploadCommand: goback upload - загружает отложенные в spool архивы
+func uploadCommand(args []string) int {
+	fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	force := fs.Bool("force", false, "Upload regardless of destination upload windows")
+	list := fs.Bool("list", false, "List spooled uploads without uploading")
+	offline := fs.Bool("offline", false, "Only upload to local destinations")
+
+	if _, err := parseFlags(fs, args); err != nil {
+		return 2
+	}
+
+	cfg := loadConfigOrExit(*configPath)
+
+	// У каждого клиента своя очередь в его state_dir
+	scopes := cfg.Scopes()
+
+	if *list {
+		var jobs []spool.Job
+		for _, scope := range scopes {
+			scopeJobs, err := spool.Open(scope.Global.SpoolDir()).Jobs()
+			if err != nil {
+				utils.PrintError("%v", err)
+				return 1
+			}
+			jobs = append(jobs, scopeJobs...)
+		}
+		for _, job := range jobs {
+			fmt.Printf("%s  %-12s %-12s %s (%s)", job.CreatedAt.Format("2006-01-02 15:04"), job.Backup, job.Destination, job.Key, utils.FormatSize(job.Size))
+			if job.Attempts > 0 {
+				fmt.Printf("  attempts: %d, last error: %s", job.Attempts, job.LastError)
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d job(s) in spool\n", len(jobs))
+		return 0
+	}
+
+	utils.PrintHeader("Processing upload spool...")
+	uploaded, pending := 0, 0
+	for _, scope := range scopes {
+		executor := backup.NewExecutor(&scope.Global)
+		executor.SetOffline(*offline)
+
+		scopeUploaded, scopePending, err := executor.ProcessSpool(scope.Backups, *force)
+		uploaded += scopeUploaded
+		pending += scopePending
+		if err != nil {
+			utils.PrintError("Failed to process spool: %v", err)
+			return 1
+		}
+	}
+
+	utils.PrintSuccess("Uploaded: %d, pending: %d", uploaded, pending)
+	return 0
+}
@@ -14,33 +14,48 @@ const (
 	ColorYellow = "\033[33m"
 )
 
+// colors - выводить ли цвета ANSI (отключается --no-color и NO_COLOR)
+var colors = os.Getenv("NO_COLOR") == ""
+
+// SetColors включает или отключает цветной вывод
+func SetColors(enabled bool) {
+	colors = enabled
+}
+
+// colorize оборачивает текст в код цвета, если цвета включены
+func colorize(color, text string) string {
+	if !colors {
+		return text
+	}
+	return color + text + ColorReset
+}
+
 // PrintSuccess выводит успешное сообщение зеленым цветом
 func PrintSuccess(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s\n", ColorGreen, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Println(colorize(ColorGreen, fmt.Sprintf(format, args...)))
 }
 
 // PrintError выводит сообщение об ошибке красным цветом
 func PrintError(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "%s%s%s\n", ColorRed, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprintln(os.Stderr, colorize(ColorRed, fmt.Sprintf(format, args...)))
 }
 
 // PrintHeader выводит заголовок оранжевым цветом
 func PrintHeader(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s\n", ColorOrange, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Println(colorize(ColorOrange, fmt.Sprintf(format, args...)))
 }
 
 // PrintSuccessf выводит успешное сообщение зеленым цветом (аналог Printf)
 func PrintSuccessf(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s", ColorGreen, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Print(colorize(ColorGreen, fmt.Sprintf(format, args...)))
 }
 
 // PrintErrorf выводит сообщение об ошибке красным цветом (аналог Printf)
 func PrintErrorf(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "%s%s%s", ColorRed, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Fprint(os.Stderr, colorize(ColorRed, fmt.Sprintf(format, args...)))
 }
 
 // PrintHeaderf выводит заголовок оранжевым цветом (аналог Printf)
 func PrintHeaderf(format string, args ...interface{}) {
-	fmt.Printf("%s%s%s", ColorOrange, fmt.Sprintf(format, args...), ColorReset)
+	fmt.Print(colorize(ColorOrange, fmt.Sprintf(format, args...)))
 }
-
@@ -3,15 +3,18 @@ package utils
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // GenerateFilename создает имя файла по маске
-// Маска: %name%-%Y%m%d%H%M%S
+// Маска: %name%-%Y%m%d%H%M%S; %iso% - 20060102T150405Z (UTC), %epoch% - секунды Unix
 func GenerateFilename(mask, name string, t time.Time) string {
 	result := mask
 	result = strings.ReplaceAll(result, "%name%", name)
+	result = strings.ReplaceAll(result, "%iso%", t.UTC().Format(isoLayout))
+	result = strings.ReplaceAll(result, "%epoch%", strconv.FormatInt(t.Unix(), 10))
 	result = strings.ReplaceAll(result, "%Y", t.Format("2006"))
 	result = strings.ReplaceAll(result, "%m", t.Format("01"))
 	result = strings.ReplaceAll(result, "%d", t.Format("02"))
@@ -22,17 +25,12 @@ func GenerateFilename(mask, name string, t time.Time) string {
 }
 
 // ParseDateFromFilename извлекает дату из имени файла
-// Формат: {name}-{YYYYMMDDHHmmss}.{ext}
+// Формат: {name}-{YYYYMMDDHHmmss}[.{ext}...]
 func ParseDateFromFilename(filename string) (time.Time, error) {
-	// Убираем расширение
-	base := filename
-	if idx := strings.LastIndex(filename, "."); idx != -1 {
-		base = filename[:idx]
-	}
-
-	// Ищем паттерн YYYYMMDDHHmmss в конце имени
-	re := regexp.MustCompile(`(\d{14})$`)
-	matches := re.FindStringSubmatch(base)
+	// Ищем паттерн YYYYMMDDHHmmss в конце имени, за которым могут идти
+	// одно или несколько расширений (.gz, .tar.gz)
+	re := regexp.MustCompile(`(\d{14})(\.[A-Za-z0-9]+)*$`)
+	matches := re.FindStringSubmatch(filename)
 	if len(matches) < 2 {
 		return time.Time{}, fmt.Errorf("cannot parse date from filename: %s", filename)
 	}
@@ -57,8 +55,68 @@ func GetExtension(compression string) string {
 		return ".tar"
 	case "tar.gz":
 		return ".tar.gz"
+	case "zstd":
+		return ".zst"
+	case "tar.zst":
+		return ".tar.zst"
+	case "xz":
+		return ".xz"
+	case "tar.xz":
+		return ".tar.xz"
 	default:
 		return ""
 	}
 }
 
+// EncryptedExtensions - расширения зашифрованных архивов (age, gpg)
+var EncryptedExtensions = []string{".age", ".gpg"}
+
+// EncryptionExtension возвращает расширение шифрования архива или пустую строку
+func EncryptionExtension(filename string) string {
+	lower := strings.ToLower(filename)
+	for _, ext := range EncryptedExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// IsEncrypted проверяет, что архив зашифрован (по расширению)
+func IsEncrypted(filename string) bool {
+	return EncryptionExtension(filename) != ""
+}
+
+// DetectCompression определяет тип сжатия по расширению файла архива
+func DetectCompression(filename string) string {
+	// Шифрование - внешний слой, тип сжатия определяется по расширению под ним
+	lower := strings.TrimSuffix(strings.ToLower(filename), EncryptionExtension(filename))
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"):
+		return "tar.gz"
+	case strings.HasSuffix(lower, ".tar.zst"):
+		return "tar.zst"
+	case strings.HasSuffix(lower, ".zst"):
+		return "zstd"
+	case strings.HasSuffix(lower, ".tar.xz"):
+		return "tar.xz"
+	case strings.HasSuffix(lower, ".xz"):
+		return "xz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".gz"):
+		return "gzip"
+	default:
+		return "none"
+	}
+}
+
+// IsTempFile проверяет, что файл является промежуточным результатом незавершенной
+// операции (архив в процессе записи, временный tar перед gzip и т.п.).
+// Такие файлы игнорируются всеми командами, читающими состояние без блокировки
+func IsTempFile(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".tmp") || strings.Contains(lower, ".tmp.")
+}
@@ -0,0 +1,242 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Стратегии разбора даты из имени архива (global.naming)
+const (
+	// NamingHeuristic ищет YYYYMMDDHHmmss перед расширениями (по умолчанию)
+	NamingHeuristic = "heuristic"
+	// NamingMask разбирает имя строго по filename_mask
+	NamingMask = "mask"
+	// NamingISO8601 ищет метку %iso% (20060102T150405Z, UTC)
+	NamingISO8601 = "iso8601"
+	// NamingEpoch ищет метку %epoch% (секунды Unix)
+	NamingEpoch = "epoch"
+)
+
+// NamingStrategies - допустимые значения global.naming
+var NamingStrategies = []string{NamingHeuristic, NamingMask, NamingISO8601, NamingEpoch}
+
+const isoLayout = "20060102T150405Z"
+
+// extensionsPattern - одно или несколько расширений после даты (.gz, .tar.gz.age)
+const extensionsPattern = `(\.[A-Za-z0-9]+)*$`
+
+var (
+	isoPattern   = regexp.MustCompile(`(\d{8}T\d{6}Z)` + extensionsPattern)
+	epochPattern = regexp.MustCompile(`-(\d{9,12})` + extensionsPattern)
+)
+
+// Naming строит имя архива из filename_mask и извлекает из имени дату создания
+type Naming interface {
+	// Generate возвращает имя архива (без расширения) бэкапа name, созданного в t
+	Generate(name string, t time.Time) string
+	// ParseDate возвращает дату создания архива filename бэкапа name
+	ParseDate(filename, name string) (time.Time, error)
+}
+
+// NewNaming создает стратегию strategy для маски mask
+func NewNaming(strategy, mask string) (Naming, error) {
+	switch strategy {
+	case "", NamingHeuristic:
+		return &heuristicNaming{mask: mask}, nil
+	case NamingMask:
+		if !strings.Contains(mask, "%name%") {
+			return nil, fmt.Errorf("naming: mask requires %%name%% in filename_mask")
+		}
+		if !strings.Contains(mask, "%iso%") && !strings.Contains(mask, "%epoch%") &&
+			!(strings.Contains(mask, "%Y") && strings.Contains(mask, "%m") && strings.Contains(mask, "%d")) {
+			return nil, fmt.Errorf("naming: mask requires %%Y, %%m and %%d, %%iso%% or %%epoch%% in filename_mask")
+		}
+		return &maskNaming{mask: mask}, nil
+	case NamingISO8601:
+		if !strings.Contains(mask, "%iso%") {
+			return nil, fmt.Errorf("naming: iso8601 requires %%iso%% in filename_mask")
+		}
+		return &patternNaming{mask: mask, pattern: isoPattern, parse: func(s string) (time.Time, error) {
+			return time.Parse(isoLayout, s)
+		}}, nil
+	case NamingEpoch:
+		if !strings.Contains(mask, "%epoch%") {
+			return nil, fmt.Errorf("naming: epoch requires %%epoch%% in filename_mask")
+		}
+		return &patternNaming{mask: mask, pattern: epochPattern, parse: func(s string) (time.Time, error) {
+			seconds, err := strconv.ParseInt(s, 10, 64)
+			return time.Unix(seconds, 0).UTC(), err
+		}}, nil
+	default:
+		return nil, fmt.Errorf("naming must be one of: %s", strings.Join(NamingStrategies, ", "))
+	}
+}
+
+// hasNamePrefix проверяет, что имя начинается с {name}- (до первого расширения)
+func hasNamePrefix(filename, name string) bool {
+	baseName := filename
+	if idx := strings.LastIndex(filename, "."); idx != -1 {
+		baseName = filename[:idx]
+	}
+	return strings.HasPrefix(baseName, name+"-")
+}
+
+// heuristicNaming - исторический разбор: 14 цифр даты перед расширениями
+type heuristicNaming struct {
+	mask string
+}
+
+func (n *heuristicNaming) Generate(name string, t time.Time) string {
+	return GenerateFilename(n.mask, name, t)
+}
+
+func (n *heuristicNaming) ParseDate(filename, name string) (time.Time, error) {
+	if !hasNamePrefix(filename, name) {
+		return time.Time{}, fmt.Errorf("%s is not an archive of %s", filename, name)
+	}
+	return ParseDateFromFilename(filename)
+}
+
+// patternNaming ищет метку времени одного формата перед расширениями
+type patternNaming struct {
+	mask    string
+	pattern *regexp.Regexp
+	parse   func(string) (time.Time, error)
+}
+
+func (n *patternNaming) Generate(name string, t time.Time) string {
+	return GenerateFilename(n.mask, name, t)
+}
+
+func (n *patternNaming) ParseDate(filename, name string) (time.Time, error) {
+	if !hasNamePrefix(filename, name) {
+		return time.Time{}, fmt.Errorf("%s is not an archive of %s", filename, name)
+	}
+	matches := n.pattern.FindStringSubmatch(filename)
+	if len(matches) < 2 {
+		return time.Time{}, fmt.Errorf("cannot parse date from filename: %s", filename)
+	}
+	t, err := n.parse(matches[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse date: %w", err)
+	}
+	return t, nil
+}
+
+// maskNaming разбирает имя строго по маске: литералы маски и имя бэкапа должны
+// совпасть, а каждый плейсхолдер - иметь свою ширину
+type maskNaming struct {
+	mask string
+	// patterns - скомпилированные выражения по имени бэкапа
+	patterns sync.Map
+}
+
+// maskFields - плейсхолдеры маски и выражения для них
+var maskFields = []struct {
+	placeholder string
+	pattern     string
+}{
+	{"%iso%", `(\d{8}T\d{6}Z)`},
+	{"%epoch%", `(\d+)`},
+	{"%Y", `(\d{4})`},
+	{"%m", `(\d{2})`},
+	{"%d", `(\d{2})`},
+	{"%H", `(\d{2})`},
+	{"%M", `(\d{2})`},
+	{"%S", `(\d{2})`},
+}
+
+type compiledMask struct {
+	re     *regexp.Regexp
+	fields []string
+}
+
+func (n *maskNaming) Generate(name string, t time.Time) string {
+	return GenerateFilename(n.mask, name, t)
+}
+
+func (n *maskNaming) compile(name string) compiledMask {
+	if cached, ok := n.patterns.Load(name); ok {
+		return cached.(compiledMask)
+	}
+
+	var expr strings.Builder
+	var fields []string
+	expr.WriteString("^")
+	for rest := n.mask; rest != ""; {
+		if strings.HasPrefix(rest, "%name%") {
+			expr.WriteString(regexp.QuoteMeta(name))
+			rest = rest[len("%name%"):]
+			continue
+		}
+		matched := false
+		for _, field := range maskFields {
+			if strings.HasPrefix(rest, field.placeholder) {
+				expr.WriteString(field.pattern)
+				fields = append(fields, field.placeholder)
+				rest = rest[len(field.placeholder):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			expr.WriteString(regexp.QuoteMeta(rest[:1]))
+			rest = rest[1:]
+		}
+	}
+	expr.WriteString(extensionsPattern)
+
+	compiled := compiledMask{re: regexp.MustCompile(expr.String()), fields: fields}
+	n.patterns.Store(name, compiled)
+	return compiled
+}
+
+func (n *maskNaming) ParseDate(filename, name string) (time.Time, error) {
+	compiled := n.compile(name)
+	matches := compiled.re.FindStringSubmatch(filename)
+	if matches == nil {
+		return time.Time{}, fmt.Errorf("%s does not match filename_mask %s", filename, n.mask)
+	}
+
+	// Компоненты, которых нет в маске, берутся из начала суток/месяца
+	year, month, day, hour, minute, second := 0, 1, 1, 0, 0, 0
+	for i, field := range compiled.fields {
+		value := matches[i+1]
+		switch field {
+		case "%iso%":
+			return time.Parse(isoLayout, value)
+		case "%epoch%":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("failed to parse date: %w", err)
+			}
+			return time.Unix(seconds, 0).UTC(), nil
+		}
+		number, _ := strconv.Atoi(value)
+		switch field {
+		case "%Y":
+			year = number
+		case "%m":
+			month = number
+		case "%d":
+			day = number
+		case "%H":
+			hour = number
+		case "%M":
+			minute = number
+		case "%S":
+			second = number
+		}
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	// time.Date нормализует 2024-02-31 в март - такое имя не является датой
+	if t.Month() != time.Month(month) || t.Day() != day || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, fmt.Errorf("failed to parse date: invalid date in %s", filename)
+	}
+	return t, nil
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"io"
+	"time"
+)
+
+// RateLimitedReader ограничивает скорость чтения до лимита, который
+// перечитывается при каждом чтении (лимит может зависеть от времени суток)
+type RateLimitedReader struct {
+	reader io.Reader
+	limit  func() int64
+	// current - лимит, для которого считаются start и read
+	current int64
+	start   time.Time
+	read    int64
+}
+
+// NewRateLimitedReader оборачивает reader; при bytesPerSecond <= 0 ограничения нет
+func NewRateLimitedReader(reader io.Reader, bytesPerSecond int64) io.Reader {
+	if bytesPerSecond <= 0 {
+		return reader
+	}
+	return NewThrottledReader(reader, func() int64 { return bytesPerSecond })
+}
+
+// NewThrottledReader оборачивает reader лимитом limit() байт в секунду (0 - без ограничения)
+func NewThrottledReader(reader io.Reader, limit func() int64) io.Reader {
+	return &RateLimitedReader{
+		reader: reader,
+		limit:  limit,
+	}
+}
+
+func (r *RateLimitedReader) Read(p []byte) (int, error) {
+	// При смене лимита (начало или конец окна) скорость считается заново
+	if limit := r.limit(); limit != r.current || r.start.IsZero() {
+		r.current = limit
+		r.start = time.Now()
+		r.read = 0
+	}
+	if r.current <= 0 {
+		return r.reader.Read(p)
+	}
+
+	// Не читаем больше, чем разрешено за секунду, чтобы паузы были равномерными
+	if int64(len(p)) > r.current {
+		p = p[:r.current]
+	}
+
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+
+	// Ждем, пока фактическая скорость не опустится до заданной
+	expected := time.Duration(float64(r.read) / float64(r.current) * float64(time.Second))
+	if elapsed := time.Since(r.start); expected > elapsed {
+		time.Sleep(expected - elapsed)
+	}
+
+	return n, err
+}
+
+// ThrottleRule - лимит скорости в окне Window; правило без окна действует в остальное время
+type ThrottleRule struct {
+	Window *TimeWindow
+	// BytesPerSecond - лимит в байтах в секунду (0 - без ограничения)
+	BytesPerSecond int64
+}
+
+// Throttle - расписание лимитов скорости по времени суток: действует первое
+// правило, в окно которого попадает текущее время. nil - без ограничения
+type Throttle struct {
+	Rules []ThrottleRule
+}
+
+// Limit возвращает лимит скорости в момент now (0 - без ограничения)
+func (t *Throttle) Limit(now time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	for _, rule := range t.Rules {
+		if rule.Window == nil || rule.Window.Contains(now) {
+			return rule.BytesPerSecond
+		}
+	}
+	return 0
+}
+
+// Reader ограничивает чтение из reader расписанием t и постоянным лимитом
+// bytesPerSecond (0 - без него); действует меньший из лимитов
+func (t *Throttle) Reader(reader io.Reader, bytesPerSecond int64) io.Reader {
+	if t == nil {
+		return NewRateLimitedReader(reader, bytesPerSecond)
+	}
+	return NewThrottledReader(reader, func() int64 {
+		return t.Effective(time.Now(), bytesPerSecond)
+	})
+}
+
+// Effective возвращает меньший из лимита расписания в момент now и постоянного
+// лимита bytesPerSecond (0 - без ограничения)
+func (t *Throttle) Effective(now time.Time, bytesPerSecond int64) int64 {
+	limit := t.Limit(now)
+	if bytesPerSecond > 0 && (limit <= 0 || bytesPerSecond < limit) {
+		return bytesPerSecond
+	}
+	return limit
+}
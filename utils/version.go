@@ -0,0 +1,5 @@
+package utils
+
+// Version - версия goback, задается при сборке:
+// go build -ldflags "-X goback/utils.Version=1.4.0"
+var Version = "dev"
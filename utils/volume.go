@@ -0,0 +1,426 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Архив, разбитый по split_size, хранится томами <archive>.part001, .part002, ...
+// без файла <archive>; путь <archive> остается именем архива для retention,
+// каталога, контрольных сумм и восстановления
+
+var (
+	volumePattern = regexp.MustCompile(`^(.+)\.part(\d{3,})$`)
+	globMeta      = regexp.MustCompile(`[*?\[\\]`)
+)
+
+// VolumeName возвращает имя тома n (с 1) архива path
+func VolumeName(path string, n int) string {
+	return fmt.Sprintf("%s.part%03d", path, n)
+}
+
+// ParseVolume разбирает имя тома: возвращает имя архива и номер тома
+func ParseVolume(name string) (string, int, bool) {
+	matches := volumePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(matches[2])
+	if err != nil || n < 1 {
+		return "", 0, false
+	}
+	return matches[1], n, true
+}
+
+// VolumeParts возвращает тома архива path по порядку; nil - архив не разбит
+func VolumeParts(path string) []string {
+	candidates, _ := filepath.Glob(escapeGlob(path) + ".part*")
+
+	numbers := make(map[string]int)
+	var parts []string
+	for _, candidate := range candidates {
+		archive, n, ok := ParseVolume(candidate)
+		if !ok || archive != path {
+			continue
+		}
+		numbers[candidate] = n
+		parts = append(parts, candidate)
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return numbers[parts[i]] < numbers[parts[j]]
+	})
+	return parts
+}
+
+// escapeGlob экранирует метасимволы Glob в пути
+func escapeGlob(path string) string {
+	return globMeta.ReplaceAllString(path, `\$0`)
+}
+
+// StatArchive возвращает сведения об архиве path; для разбитого архива - размер
+// всех томов и время изменения последнего
+func StatArchive(path string) (os.FileInfo, error) {
+	info, err := os.Stat(path)
+	if err == nil || !os.IsNotExist(err) {
+		return info, err
+	}
+
+	parts := VolumeParts(path)
+	if len(parts) == 0 {
+		return nil, err
+	}
+
+	volumes := volumesInfo{name: filepath.Base(path)}
+	for _, part := range parts {
+		partInfo, err := os.Stat(part)
+		if err != nil {
+			return nil, err
+		}
+		volumes.size += partInfo.Size()
+		volumes.mode = partInfo.Mode()
+		if partInfo.ModTime().After(volumes.modTime) {
+			volumes.modTime = partInfo.ModTime()
+		}
+	}
+	return volumes, nil
+}
+
+type volumesInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (v volumesInfo) Name() string       { return v.name }
+func (v volumesInfo) Size() int64        { return v.size }
+func (v volumesInfo) Mode() os.FileMode  { return v.mode }
+func (v volumesInfo) ModTime() time.Time { return v.modTime }
+func (v volumesInfo) IsDir() bool        { return false }
+func (v volumesInfo) Sys() interface{}   { return nil }
+
+// RemoveArchive удаляет архив path вместе с его томами (директорию format:
+// directory - с содержимым); отсутствующий архив не считается ошибкой
+func RemoveArchive(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	for _, part := range VolumeParts(path) {
+		if err := os.Remove(part); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommitArchive завершает архив, записанный под временным именем tmpPath: данные
+// сбрасываются на диск, архив (файл, тома или директория format: directory)
+// переименовывается в path, после чего сбрасывается и запись в директории. До
+// переименования недописанный архив игнорируется retention и сканированием
+func CommitArchive(tmpPath, path string) error {
+	parts := VolumeParts(tmpPath)
+	if len(parts) == 0 {
+		parts = []string{tmpPath}
+	}
+
+	for _, part := range parts {
+		if err := syncTree(part); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", filepath.Base(part), err)
+		}
+	}
+
+	if len(parts) == 1 && parts[0] == tmpPath {
+		if err := os.Rename(tmpPath, path); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", filepath.Base(tmpPath), err)
+		}
+	} else {
+		for i, part := range parts {
+			if err := os.Rename(part, VolumeName(path, i+1)); err != nil {
+				return fmt.Errorf("failed to rename %s: %w", filepath.Base(part), err)
+			}
+		}
+	}
+
+	if err := SyncDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", filepath.Dir(path), err)
+	}
+	return nil
+}
+
+// SyncDir сбрасывает на диск записи директории (созданные и переименованные файлы)
+func SyncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// syncTree сбрасывает на диск файл или директорию со всем содержимым
+func syncTree(path string) error {
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return file.Sync()
+	})
+}
+
+// Volumes читает архив - один файл или тома по порядку - как непрерывный поток
+type Volumes struct {
+	files   []*os.File
+	offsets []int64
+	size    int64
+	current int
+}
+
+// OpenArchive открывает архив path: файл или, если его нет, тома path.partNNN
+func OpenArchive(path string) (*Volumes, error) {
+	paths := []string{path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if parts := VolumeParts(path); len(parts) > 0 {
+			paths = parts
+		}
+	}
+
+	v := &Volumes{}
+	for _, p := range paths {
+		file, err := os.Open(p)
+		if err != nil {
+			v.Close()
+			return nil, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			v.Close()
+			return nil, err
+		}
+		v.files = append(v.files, file)
+		v.offsets = append(v.offsets, v.size)
+		v.size += info.Size()
+	}
+	return v, nil
+}
+
+// Size возвращает суммарный размер архива
+func (v *Volumes) Size() int64 {
+	return v.size
+}
+
+func (v *Volumes) Read(p []byte) (int, error) {
+	for v.current < len(v.files) {
+		n, err := v.files[v.current].Read(p)
+		if err == io.EOF {
+			v.current++
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+// ReadAt читает с произвольного смещения (zip читает оглавление с конца архива)
+func (v *Volumes) ReadAt(p []byte, off int64) (int, error) {
+	read := 0
+	for read < len(p) {
+		pos := off + int64(read)
+		if pos >= v.size {
+			return read, io.EOF
+		}
+		i := sort.Search(len(v.offsets), func(i int) bool { return v.offsets[i] > pos }) - 1
+		n, err := v.files[i].ReadAt(p[read:], pos-v.offsets[i])
+		read += n
+		if err != nil && err != io.EOF {
+			return read, err
+		}
+		if n == 0 && err == io.EOF {
+			return read, io.ErrUnexpectedEOF
+		}
+	}
+	return read, nil
+}
+
+func (v *Volumes) Close() error {
+	var firstErr error
+	for _, file := range v.files {
+		if err := file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	v.files = nil
+	return firstErr
+}
+
+// VolumeWriter пишет архив path томами не больше size байт. Тома пишутся как
+// .partNNN.tmp и получают окончательные имена в Close; архив, уместившийся в
+// один том, сохраняется обычным файлом path
+type VolumeWriter struct {
+	path    string
+	size    int64
+	parts   []string
+	file    *os.File
+	written int64
+	closed  bool
+}
+
+// CreateVolumes создает VolumeWriter для архива path с томами по size байт
+func CreateVolumes(path string, size int64) *VolumeWriter {
+	return &VolumeWriter{path: path, size: size}
+}
+
+func (w *VolumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if w.file == nil || w.written >= w.size {
+			if err := w.next(); err != nil {
+				return total, err
+			}
+		}
+		chunk := p
+		if room := w.size - w.written; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		n, err := w.file.Write(chunk)
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+// next закрывает текущий том и начинает следующий
+func (w *VolumeWriter) next() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+	name := VolumeName(w.path, len(w.parts)+1) + ".tmp"
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w.parts = append(w.parts, name)
+	w.file = file
+	w.written = 0
+	return nil
+}
+
+// Close дописывает последний том и переименовывает тома; повторный вызов ничего не делает
+func (w *VolumeWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.file == nil {
+		// Пустой архив - один пустой файл
+		return os.WriteFile(w.path, nil, 0644)
+	}
+	if err := w.file.Close(); err != nil {
+		w.Abort()
+		return err
+	}
+
+	if len(w.parts) == 1 {
+		return os.Rename(w.parts[0], w.path)
+	}
+	for i, part := range w.parts {
+		if err := os.Rename(part, VolumeName(w.path, i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Volumes возвращает число записанных томов
+func (w *VolumeWriter) Volumes() int {
+	return len(w.parts)
+}
+
+// Abort удаляет недописанные тома
+func (w *VolumeWriter) Abort() {
+	if w.file != nil {
+		w.file.Close()
+	}
+	for _, part := range w.parts {
+		os.Remove(part)
+	}
+	w.closed = true
+}
+
+// SplitArchive разбивает готовый архив path на тома по size байт и удаляет
+// исходный файл; архив не больше size остается как есть. Возвращает число томов
+func SplitArchive(path string, size int64) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.Size() <= size {
+		return 1, nil
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	writer := CreateVolumes(path, size)
+	if _, err := io.Copy(writer, source); err != nil {
+		writer.Abort()
+		return 0, fmt.Errorf("failed to split %s: %w", filepath.Base(path), err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to split %s: %w", filepath.Base(path), err)
+	}
+	source.Close()
+
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	return len(writer.parts), nil
+}
+
+// JoinArchive собирает тома архива source в один файл destination
+func JoinArchive(source, destination string) error {
+	reader, err := OpenArchive(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		os.Remove(destination)
+		return fmt.Errorf("failed to join volumes of %s: %w", filepath.Base(source), err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"goback/backup"
+	"goback/config"
+	"goback/utils"
+)
+
+// validateCommand: goback validate [config] [--lint] - проверяет конфигурацию без
+// запуска бэкапов: ошибки загрузки, неизвестные ключи и недоступные source_dir;
+// --lint дополнительно ищет рискованные, но допустимые настройки
+func validateCommand(args []string) int {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	lint := fs.Bool("lint", false, "Also report risky settings (exit code 1 if any are found)")
+	noSourceCheck := fs.Bool("no-source-check", false, "Do not check that source_dir paths exist (validating on another host)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) > 1 {
+		utils.PrintError("Usage: goback validate [-c config.yaml | config.yaml] [--lint] [--no-source-check]")
+		return 2
+	}
+	if len(positional) == 1 {
+		*configPath = positional[0]
+	}
+
+	// Неизвестные ключи ищутся и в конфигурации, которая не загружается:
+	// опечатка в имени параметра часто и есть причина ошибки
+	problems := config.UnknownKeys(*configPath)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		problems = append(problems, err.Error())
+	} else if !*noSourceCheck {
+		problems = append(problems, checkSources(cfg)...)
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Printf("Error: %s\n", problem)
+		}
+		utils.PrintError("Configuration is invalid: %d problem(s)", len(problems))
+		return 1
+	}
+	utils.PrintSuccess("Configuration is valid: %d backup(s)", len(cfg.Backups))
+
+	if !*lint {
+		return 0
+	}
+
+	warnings := backup.Lint(cfg)
+	for _, warning := range warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	if len(warnings) > 0 {
+		utils.PrintError("%d lint warning(s)", len(warnings))
+		return 1
+	}
+	fmt.Println("No lint warnings")
+	return 0
+}
+
+// checkSources проверяет, что source_dir включенных бэкапов существуют и читаются
+func checkSources(cfg *config.Config) []string {
+	var problems []string
+	for _, backupCfg := range cfg.Backups {
+		if backupCfg.SourceDir == "" || (backupCfg.Enabled != nil && !*backupCfg.Enabled) {
+			continue
+		}
+		file, err := os.Open(backupCfg.SourceDir)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("backup %s: source_dir is not accessible: %v", backupCfg.Name, err))
+			continue
+		}
+		file.Close()
+	}
+	return problems
+}
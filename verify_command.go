@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"goback/backup"
+	"goback/checksum"
+	"goback/config"
+	"goback/metadata"
+	"goback/restore"
+	"goback/retention"
+	"goback/utils"
+)
+
+// verifyCommand: goback verify - пересчитывает контрольные суммы архивов в backup_dir
+// и сравнивает их с sidecar/MANIFEST (или с каталогом), чтобы обнаружить порчу данных.
+// С --deep архив дополнительно читается целиком через распаковщик
+func verifyCommand(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	configPath := fs.String("config", "config.yaml", "Path to configuration file")
+	fs.StringVar(configPath, "c", "config.yaml", "Path to configuration file (short)")
+	var backupNames flagArray
+	fs.Var(&backupNames, "backup", "Name of backup to verify (can be specified multiple times)")
+	fs.Var(&backupNames, "b", "Name of backup to verify (short)")
+	deep := fs.Bool("deep", false, "Also read every archive through the decompressor and compare file counts with its .meta.json")
+	latest := fs.Bool("latest", false, "Verify only the latest archive of each backup")
+	identity := fs.String("identity", os.Getenv("GOBACK_IDENTITY"), "age identity file(s) for --deep on encrypted archives, comma-separated (default: $GOBACK_IDENTITY)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	backupNames = append(backupNames, positional...)
+
+	cfg := loadConfigOrExit(*configPath)
+
+	// Каталог - запасной источник сумм для архивов без sidecar и MANIFEST;
+	// у каждого клиента свой каталог
+	catalogs := make(map[string]map[string]string)
+	knownChecksums := func(global *config.GlobalConfig) map[string]string {
+		path := global.CatalogPath()
+		if known, ok := catalogs[path]; ok {
+			return known
+		}
+		known, err := backup.CatalogChecksums(global)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+		catalogs[path] = known
+		return known
+	}
+
+	backups := cfg.Backups
+	if self := cfg.Global.SelfBackup; self != nil && self.Enabled {
+		backups = append(backups, config.BackupConfig{Name: self.Name, Subdirectory: self.Subdirectory})
+	}
+
+	verified, failed, unchecked := 0, 0, 0
+	for i := range backups {
+		backupCfg := &backups[i]
+		if len(backupNames) > 0 && !containsString(backupNames, backupCfg.Name) {
+			continue
+		}
+
+		global := cfg.GlobalFor(backupCfg)
+		known := knownChecksums(global)
+		dir := filepath.Join(global.BackupDir, backupCfg.Subdirectory)
+		files, err := retention.FindBackupFiles(global.BackupDir, backupCfg.Subdirectory, backupCfg.Name, global.FileNaming())
+		if err != nil {
+			utils.PrintError("Failed to list archives of %s: %v", backupCfg.Name, err)
+			return 1
+		}
+		manifest, err := checksum.ReadManifest(dir)
+		if err != nil {
+			utils.PrintError("%v", err)
+			return 1
+		}
+
+		seen := make(map[string]bool)
+		for _, file := range files {
+			seen[filepath.Base(file.Path)] = true
+		}
+		if *latest && len(files) > 0 {
+			files = files[len(files)-1:]
+		}
+		opts := restoreOptions(cfg, backupCfg, *identity)
+
+		utils.PrintHeader("Verifying %s (%d archive(s))", backupCfg.Name, len(files))
+
+		for _, file := range files {
+			name := filepath.Base(file.Path)
+
+			// У бэкапа с format: directory нет контрольной суммы архива
+			if info, err := os.Stat(file.Path); err == nil && info.IsDir() {
+				if !*deep {
+					fmt.Printf("  NO CHECKSUM  %s (directory)\n", name)
+					unchecked++
+					continue
+				}
+				result, err := deepCheck(opts, file.Path)
+				if err != nil {
+					utils.PrintError("  FAILED       %s: %v", name, err)
+					failed++
+					continue
+				}
+				fmt.Printf("  OK           %s (directory, %s)\n", name, result)
+				verified++
+				continue
+			}
+
+			expected, err := backup.ExpectedChecksum(file.Path, backupCfg.Subdirectory, manifest, known)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+			}
+			if expected == "" && !*deep {
+				fmt.Printf("  NO CHECKSUM  %s\n", name)
+				unchecked++
+				continue
+			}
+
+			if expected != "" {
+				if err := checksum.Verify(file.Path, expected); err != nil {
+					utils.PrintError("  FAILED       %s: %v", name, err)
+					failed++
+					continue
+				}
+			}
+
+			if !*deep {
+				fmt.Printf("  OK           %s\n", name)
+				verified++
+				continue
+			}
+			result, err := deepCheck(opts, file.Path)
+			if err != nil {
+				utils.PrintError("  FAILED       %s: %v", name, err)
+				failed++
+				continue
+			}
+			if expected == "" {
+				fmt.Printf("  OK           %s (no checksum, %s)\n", name, result)
+			} else {
+				fmt.Printf("  OK           %s (%s)\n", name, result)
+			}
+			verified++
+		}
+
+		// Архив из MANIFEST, которого нет на диске, тоже потеря данных
+		for name := range manifest {
+			if _, ok := retention.MatchBackupFile(name, backupCfg.Name, global.FileNaming()); !ok || seen[name] {
+				continue
+			}
+			if _, err := utils.StatArchive(filepath.Join(dir, name)); os.IsNotExist(err) {
+				utils.PrintError("  MISSING      %s (listed in %s)", name, checksum.ManifestName)
+				failed++
+			}
+		}
+	}
+
+	utils.PrintHeader("\n=== Verify summary ===")
+	fmt.Printf("Verified: %d, failed: %d, without checksum: %d\n", verified, failed, unchecked)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// deepResult - итог пробного чтения архива для вывода
+type deepResult restore.CheckResult
+
+func (r deepResult) String() string {
+	return fmt.Sprintf("deep: %d file(s), %s", r.Files, utils.FormatSize(r.Bytes))
+}
+
+// deepCheck читает архив целиком и сравнивает число файлов и их размер с
+// .meta.json. Для бэкапов через output_file описание хранит только размер
+// исходного файла, поэтому они проверяются лишь чтением
+func deepCheck(opts restore.Options, archivePath string) (deepResult, error) {
+	result, err := opts.Check(archivePath)
+	if err != nil {
+		return deepResult(result), err
+	}
+
+	meta, ok, err := metadata.ReadArchive(archivePath)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if !ok || meta.Command != "" {
+		return deepResult(result), nil
+	}
+	if meta.Files != result.Files {
+		return deepResult(result), fmt.Errorf("archive has %d file(s), %s lists %d", result.Files, metadata.ArchiveExtension, meta.Files)
+	}
+	if meta.UncompressedSize != result.Bytes {
+		return deepResult(result), fmt.Errorf("archive has %s of file data, %s lists %s", utils.FormatSize(result.Bytes), metadata.ArchiveExtension, utils.FormatSize(meta.UncompressedSize))
+	}
+	return deepResult(result), nil
+}